@@ -22,6 +22,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"knative.dev/test-infra/kntest/pkg/cluster"
+	"knative.dev/test-infra/kntest/pkg/dkcm"
 	"knative.dev/test-infra/kntest/pkg/junit"
 	"knative.dev/test-infra/kntest/pkg/kubetest2"
 	"knative.dev/test-infra/kntest/pkg/metadata"
@@ -39,6 +40,7 @@ func main() {
 	}
 
 	cluster.AddCommands(cmds)
+	dkcm.AddCommands(cmds)
 	junit.AddCommands(cmds)
 	metadata.AddCommands(cmds)
 	kubetest2.AddCommand(cmds)
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gke
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	clm "knative.dev/test-infra/pkg/clustermanager/e2e-tests"
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
+)
+
+func addBoskos(clusterCmd *cobra.Command, rw *clm.RequestWrapper) {
+	var boskosCmd = &cobra.Command{
+		Use:   "boskos",
+		Short: "Manage the lease of a Boskos GKE project from shell scripts.",
+	}
+	addBoskosAcquire(boskosCmd, rw)
+	addBoskosHeartbeat(boskosCmd, rw)
+	addBoskosRelease(boskosCmd, rw)
+	clusterCmd.AddCommand(boskosCmd)
+}
+
+func newBoskosClient() *boskos.Client {
+	client, err := boskos.NewClient("", /* boskos owner */
+		"", /* boskos user */
+		"" /* boskos password file */)
+	if err != nil {
+		log.Fatalf("Failed to create boskos client: '%v'", err)
+	}
+	return client
+}
+
+func addBoskosAcquire(boskosCmd *cobra.Command, rw *clm.RequestWrapper) {
+	var acquireCmd = &cobra.Command{
+		Use:   "acquire",
+		Short: "Acquire a Boskos GKE project and print its name.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resType := rw.Request.ResourceType
+			if resType == "" {
+				resType = boskos.GKEProjectResource
+			}
+			p, err := newBoskosClient().AcquireGKEProject(resType)
+			if err != nil {
+				log.Fatalf("Error acquiring the Boskos project: %v", err)
+			}
+			fmt.Println(p.Name)
+		},
+	}
+	boskosCmd.AddCommand(acquireCmd)
+}
+
+func addBoskosHeartbeat(boskosCmd *cobra.Command, rw *clm.RequestWrapper) {
+	var (
+		interval time.Duration
+		release  bool
+	)
+	var heartbeatCmd = &cobra.Command{
+		Use:   "heartbeat",
+		Short: "Keep a Boskos GKE project busy until receiving SIGINT or SIGTERM.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if rw.Request.Project == "" {
+				log.Fatal("--project must be set")
+			}
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			lease := boskos.NewLease(newBoskosClient(), rw.Request.Project, interval)
+			log.Printf("Sending heartbeats for Boskos project %q every %v", rw.Request.Project, interval)
+			log.Printf("Received %v", <-sigs)
+			if !release {
+				lease.Stop()
+				return
+			}
+			if err := lease.Release(); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	heartbeatCmd.Flags().DurationVar(&interval, "interval", boskos.DefaultHeartbeatInterval, "interval between heartbeats")
+	heartbeatCmd.Flags().BoolVar(&release, "release", false, "release the project when receiving SIGINT or SIGTERM")
+	boskosCmd.AddCommand(heartbeatCmd)
+}
+
+func addBoskosRelease(boskosCmd *cobra.Command, rw *clm.RequestWrapper) {
+	var releaseCmd = &cobra.Command{
+		Use:   "release",
+		Short: "Release a Boskos GKE project.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if rw.Request.Project == "" {
+				log.Fatal("--project must be set")
+			}
+			if err := newBoskosClient().ReleaseGKEProject(rw.Request.Project); err != nil {
+				log.Fatalf("Error releasing the Boskos project: %v", err)
+			}
+		},
+	}
+	boskosCmd.AddCommand(releaseCmd)
+}
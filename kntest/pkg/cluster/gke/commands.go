@@ -39,6 +39,7 @@ func AddCommands(clusterCmd *cobra.Command) {
 	addCreate(gkeCmd, rw)
 	addDelete(gkeCmd, rw)
 	addGet(gkeCmd, rw)
+	addBoskos(gkeCmd, rw)
 	clusterCmd.AddCommand(gkeCmd)
 }
 
@@ -55,9 +56,13 @@ func addCreate(cc *cobra.Command, rw *clm.RequestWrapper) {
 			if len(regions) > 1 {
 				rw.Request.BackupRegions = regions[1:]
 			}
-			if _, err := clm.Create(rw); err != nil {
+			gc, err := clm.Create(rw)
+			if err != nil {
 				log.Fatalf("Error creating the cluster: %v", err)
 			}
+			// The Boskos project outlives this command, keeping it busy is
+			// left to `kntest cluster gke boskos heartbeat`.
+			gc.StopHeartbeat()
 		},
 	}
 	addCreateOptions(createCmd, rw)
@@ -17,9 +17,12 @@ limitations under the License.
 package gke
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	clm "knative.dev/test-infra/pkg/clustermanager/e2e-tests"
+	"knative.dev/test-infra/pkg/gke"
 )
 
 func addCommonOptions(clusterCmd *cobra.Command, rw *clm.RequestWrapper) {
@@ -48,4 +51,39 @@ func addCreateOptions(clusterCmd *cobra.Command, rw *clm.RequestWrapper) {
 	pf.StringVar(&req.ReleaseChannel, "release-channel", "", "GKE release channel")
 	pf.StringVar(&req.GKEVersion, "version", "", "GKE version")
 	pf.StringSliceVar(&req.Addons, "addons", []string{}, "addons to be added, separated by comma")
+	pf.BoolVar(&req.Preemptible, "preemptible", false, "use preemptible VMs for the default node pool")
+	pf.BoolVar(&req.Spot, "spot", false, "use Spot VMs for the default node pool")
+	pf.Var(&nodePoolsValue{&req.NodePools}, "node-pool",
+		"extra node pool, e.g. name=system,min-nodes=1,max-nodes=3,node-type=e2-standard-2,taint=dedicated=system:NoSchedule,label=role=system,spot, can be repeated")
+	pf.StringToStringVar(&req.ResourceLabels, "labels", nil, "GCP resource labels applied to the cluster, e.g. team=serving,owner=me")
+	pf.BoolVar(&req.Autopilot, "autopilot", false, "create an Autopilot cluster, node settings cannot be used with it")
+}
+
+// nodePoolsValue is a repeatable flag parsing node pools.
+type nodePoolsValue struct {
+	nodePools *[]gke.NodePool
+}
+
+func (v *nodePoolsValue) String() string {
+	if v.nodePools == nil {
+		return ""
+	}
+	names := make([]string, len(*v.nodePools))
+	for i, np := range *v.nodePools {
+		names[i] = np.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func (v *nodePoolsValue) Set(spec string) error {
+	np, err := gke.ParseNodePool(spec)
+	if err != nil {
+		return err
+	}
+	*v.nodePools = append(*v.nodePools, np)
+	return nil
+}
+
+func (v *nodePoolsValue) Type() string {
+	return "nodePool"
 }
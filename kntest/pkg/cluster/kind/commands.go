@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/kind"
+)
+
+// AddCommands adds kind subcommands.
+func AddCommands(clusterCmd *cobra.Command) {
+	var kindCmd = &cobra.Command{
+		Use:   "kind",
+		Short: "kind related commands.",
+	}
+
+	req := &kind.KindRequest{}
+	addCommonOptions(kindCmd, req)
+	addCreate(kindCmd, req)
+	addDelete(kindCmd, req)
+	addGet(kindCmd, req)
+	clusterCmd.AddCommand(kindCmd)
+}
+
+func addCreate(kindCmd *cobra.Command, req *kind.KindRequest) {
+	var createCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create a kind cluster, or use the existing one with the same name.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := (&kind.KindClient{}).Setup(*req).Acquire(); err != nil {
+				log.Fatalf("Error creating the cluster: %v", err)
+			}
+		},
+	}
+	addCreateOptions(createCmd, req)
+	kindCmd.AddCommand(createCmd)
+}
+
+func addDelete(kindCmd *cobra.Command, req *kind.KindRequest) {
+	var deleteCmd = &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the kind cluster.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := (&kind.KindClient{}).Setup(*req).Delete(); err != nil {
+				log.Fatalf("Error deleting the cluster: %v", err)
+			}
+		},
+	}
+	kindCmd.AddCommand(deleteCmd)
+}
+
+func addGet(kindCmd *cobra.Command, req *kind.KindRequest) {
+	var getCmd = &cobra.Command{
+		Use:   "get",
+		Short: "Get the existing kind cluster and write its kubeconfig.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			req.SkipCreation = true
+			if err := (&kind.KindClient{}).Setup(*req).Acquire(); err != nil {
+				log.Fatalf("Error getting the cluster: %v", err)
+			}
+		},
+	}
+	kindCmd.AddCommand(getCmd)
+}
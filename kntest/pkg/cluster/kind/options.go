@@ -0,0 +1,38 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"github.com/spf13/cobra"
+
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/kind"
+)
+
+func addCommonOptions(kindCmd *cobra.Command, req *kind.KindRequest) {
+	pf := kindCmd.PersistentFlags()
+	// The default values set here are not used in the final operations,
+	// they will further be defaulted in KindClient.Setup
+	pf.StringVar(&req.ClusterName, "name", "", "cluster name, default e2e-cls")
+	pf.StringVar(&req.Kubeconfig, "kubeconfig", "", "the kubeconfig file to write, default $KUBECONFIG or $HOME/.kube/config")
+	pf.BoolVar(&req.SaveMetaData, "save-meta-data", false, "save meta data for the cluster into a file")
+}
+
+func addCreateOptions(createCmd *cobra.Command, req *kind.KindRequest) {
+	pf := createCmd.Flags()
+	pf.IntVar(&req.NodeCount, "nodes", 0, "number of worker nodes, default 1")
+	pf.StringVar(&req.K8sVersion, "version", "", "Kubernetes version, e.g. 1.19.1, default to the one of the installed kind release")
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dkcm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"knative.dev/test-infra/tools/dkcm/client"
+)
+
+// AddCommands adds dkcm subcommands.
+func AddCommands(topLevel *cobra.Command) {
+	var dkcmCmd = &cobra.Command{
+		Use:   "dkcm",
+		Short: "Request, wait for and release clusters managed by DKCM.",
+	}
+
+	var host string
+	dkcmCmd.PersistentFlags().StringVar(&host, "host", "dkcm", "DKCM server host or base URL")
+	addRequest(dkcmCmd, &host)
+	addWait(dkcmCmd, &host)
+	addHeartbeat(dkcmCmd, &host)
+	addRelease(dkcmCmd, &host)
+	topLevel.AddCommand(dkcmCmd)
+}
+
+func addRequest(dkcmCmd *cobra.Command, host *string) {
+	var (
+		prowJobID string
+		params    client.Params
+	)
+	var requestCmd = &cobra.Command{
+		Use:   "request",
+		Short: "Request a cluster and print the token to wait for and release it.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			token, err := client.NewClient(*host).RequestCluster(prowJobID, params)
+			if err != nil {
+				log.Fatalf("Error requesting the cluster: %v", err)
+			}
+			fmt.Println(token)
+		},
+	}
+	f := requestCmd.Flags()
+	f.StringVar(&prowJobID, "prow-job-id", os.Getenv("PROW_JOB_ID"), "ID of the Prow job requesting the cluster, default $PROW_JOB_ID")
+	f.StringVar(&params.Zone, "zone", "", "GCP region or zone of the cluster, default to the one of the server")
+	f.Int64Var(&params.Nodes, "nodes", 0, "number of nodes of the cluster, default to the one of the server")
+	f.StringVar(&params.NodeType, "node-type", "", "machine type of the nodes, default to the one of the server")
+	dkcmCmd.AddCommand(requestCmd)
+}
+
+func addWait(dkcmCmd *cobra.Command, host *string) {
+	var (
+		token       string
+		timeout     time.Duration
+		kubeconfig  string
+		metaDataDir string
+	)
+	var waitCmd = &cobra.Command{
+		Use:   "wait",
+		Short: "Wait for the requested cluster, save its info into metadata.json and write its kubeconfig.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if token == "" {
+				log.Fatal("--token must be set")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			info, err := client.NewClient(*host).Acquire(ctx, token, metaDataDir, kubeconfig)
+			if err != nil {
+				log.Fatalf("Error acquiring the cluster: %v", err)
+			}
+			log.Printf("Cluster %q is ready in project %q", info.ClusterName, info.ProjectID)
+		},
+	}
+	f := waitCmd.Flags()
+	f.StringVar(&token, "token", "", "token printed by the request command")
+	f.DurationVar(&timeout, "timeout", time.Hour, "how long to wait for the cluster")
+	f.StringVar(&kubeconfig, "kubeconfig", "", "the kubeconfig file to write, default $KUBECONFIG or $HOME/.kube/config")
+	f.StringVar(&metaDataDir, "meta-data-dir", "", "directory of the metadata.json to write the cluster info into, default to the artifacts directory")
+	dkcmCmd.AddCommand(waitCmd)
+}
+
+func addHeartbeat(dkcmCmd *cobra.Command, host *string) {
+	var (
+		token    string
+		interval time.Duration
+		release  bool
+	)
+	var heartbeatCmd = &cobra.Command{
+		Use:   "heartbeat",
+		Short: "Renew the lease of the requested cluster until receiving SIGINT or SIGTERM.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if token == "" {
+				log.Fatal("--token must be set")
+			}
+			c := client.NewClient(*host)
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				log.Printf("Received %v", <-sigs)
+				cancel()
+			}()
+			log.Printf("Renewing the lease of the cluster every %v", interval)
+			c.KeepLease(ctx, token, interval)
+			if !release {
+				return
+			}
+			if err := c.ReleaseCluster(token); err != nil {
+				log.Fatalf("Error releasing the cluster: %v", err)
+			}
+		},
+	}
+	f := heartbeatCmd.Flags()
+	f.StringVar(&token, "token", "", "token printed by the request command")
+	f.DurationVar(&interval, "interval", client.DefaultRenewInterval, "interval between lease renewals")
+	f.BoolVar(&release, "release", false, "release the cluster when receiving SIGINT or SIGTERM")
+	dkcmCmd.AddCommand(heartbeatCmd)
+}
+
+func addRelease(dkcmCmd *cobra.Command, host *string) {
+	var token string
+	var releaseCmd = &cobra.Command{
+		Use:   "release",
+		Short: "Release the requested cluster once done with it.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if token == "" {
+				log.Fatal("--token must be set")
+			}
+			if err := client.NewClient(*host).ReleaseCluster(token); err != nil {
+				log.Fatalf("Error releasing the cluster: %v", err)
+			}
+		},
+	}
+	releaseCmd.Flags().StringVar(&token, "token", "", "token printed by the request command")
+	dkcmCmd.AddCommand(releaseCmd)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package autopr generates changes in a git repository, and proposes them
+// with a PR, which is updated as long as it's open. It's the common flow of
+// the bots regenerating configs or floating dependencies.
+package autopr
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+
+	"knative.dev/test-infra/pkg/ghutil"
+	"knative.dev/test-infra/pkg/helpers"
+)
+
+// maxListedChanges caps the number of changed files listed in the PR body.
+const maxListedChanges = 30
+
+// Generator updates the files of the repository, e.g. regenerates configs.
+type Generator func() error
+
+// Options configures where the changes are pushed to, and the PR proposing them.
+type Options struct {
+	Org  string // Org of the repository the PR targets
+	Repo string // Repo the PR targets
+	Base string // Base branch the PR targets, e.g. "main"
+	Head string // Head branch of the fork the changes are pushed to
+
+	// UserID is the Github user owning the fork, i.e. the Github ID of the bot.
+	UserID string
+	// RemoteURL is the fork the changes are pushed to, default to the
+	// https://github.com/<UserID>/<Repo>.git
+	RemoteURL string
+
+	// Title is the title of the PR and the message of the commit.
+	Title string
+	// MatchTitle finds the open PR to update, default to Title.
+	MatchTitle string
+	// Description starts the body of the PR, followed by the changed files.
+	Description string
+	Labels      []string
+	Assignees   []string
+
+	// DryRun only logs the commit, push and PR.
+	DryRun bool
+}
+
+// AutoPR commits the generated changes of the repository, pushes them to the
+// head branch and creates or updates the PR.
+type AutoPR struct {
+	Options
+	// Git is the local repository the changes are generated in.
+	Git    Repo
+	GitHub ghutil.GithubOperations
+}
+
+// validate checks the required options are set.
+func (o *Options) validate() error {
+	var missing []string
+	for name, value := range map[string]string{
+		"org": o.Org, "repo": o.Repo, "base": o.Base, "head": o.Head, "user ID": o.UserID, "title": o.Title,
+	} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing options: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// remoteURL returns the URL of the fork the changes are pushed to.
+func (o *Options) remoteURL() string {
+	if o.RemoteURL != "" {
+		return o.RemoteURL
+	}
+	return fmt.Sprintf("https://github.com/%s/%s.git", o.UserID, o.Repo)
+}
+
+// headRef returns the head of the PR, in the form of "user:branch".
+func (o *Options) headRef() string {
+	return fmt.Sprintf("%s:%s", o.UserID, o.Head)
+}
+
+// Run runs the generator, and proposes the changes it made with a PR. It
+// returns the created or updated PR, nil if there are no changes or in dry
+// run mode.
+func (a *AutoPR) Run(generate Generator) (*github.PullRequest, error) {
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	if a.Git == nil || a.GitHub == nil {
+		return nil, errors.New("both the repository and the Github client must be set")
+	}
+	if generate != nil {
+		if err := generate(); err != nil {
+			return nil, fmt.Errorf("failed generating the changes: %w", err)
+		}
+	}
+
+	changes, err := a.Git.Changes()
+	if err != nil {
+		return nil, fmt.Errorf("failed listing the changes: %w", err)
+	}
+	if len(changes) == 0 {
+		log.Print("There is nothing changed, skip PR")
+		return nil, nil
+	}
+	log.Printf("Changed files: %v", changes)
+
+	if err := helpers.Run(
+		fmt.Sprintf("Committing %d changed files and pushing them to branch %q of %q", len(changes), a.Head, a.remoteURL()),
+		func() error {
+			if err := a.Git.Commit(a.Title); err != nil {
+				return fmt.Errorf("failed git commit: %w", err)
+			}
+			return a.Git.Push(a.remoteURL(), a.Head)
+		},
+		a.DryRun,
+	); err != nil {
+		return nil, err
+	}
+	return a.createOrUpdatePR(changes)
+}
+
+// getExistingPR returns the open PR of the head branch matching the title,
+// nil if there is none.
+func (a *AutoPR) getExistingPR() (*github.PullRequest, error) {
+	matchTitle := a.MatchTitle
+	if matchTitle == "" {
+		matchTitle = a.Title
+	}
+	PRs, err := a.GitHub.ListPullRequests(a.Org, a.Repo, a.headRef(), a.Base)
+	if err != nil {
+		return nil, err
+	}
+	for _, PR := range PRs {
+		if string(ghutil.PullRequestOpenState) == PR.GetState() && strings.Contains(PR.GetTitle(), matchTitle) {
+			return PR, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *AutoPR) createOrUpdatePR(changes []string) (*github.PullRequest, error) {
+	body := GenerateBody(a.Description, changes)
+	existPR, err := a.getExistingPR()
+	if err != nil {
+		return nil, fmt.Errorf("failed querying existing pullrequests: %w", err)
+	}
+
+	var PR *github.PullRequest
+	if existPR != nil {
+		log.Printf("Found open PR %d", existPR.GetNumber())
+		err = helpers.Run(
+			fmt.Sprintf("Updating PR %d, title: %q, body: %q", existPR.GetNumber(), a.Title, body),
+			func() error {
+				if PR, err = a.GitHub.EditPullRequest(a.Org, a.Repo, existPR.GetNumber(), a.Title, body); err != nil {
+					return fmt.Errorf("failed updating pullrequest: %w", err)
+				}
+				return nil
+			},
+			a.DryRun,
+		)
+	} else {
+		err = helpers.Run(
+			fmt.Sprintf("Creating PR, title: %q, body: %q", a.Title, body),
+			func() error {
+				if PR, err = a.GitHub.CreatePullRequest(a.Org, a.Repo, a.headRef(), a.Base, a.Title, body); err != nil {
+					return fmt.Errorf("failed creating pullrequest: %w", err)
+				}
+				return nil
+			},
+			a.DryRun,
+		)
+	}
+	if err != nil || PR == nil {
+		return nil, err
+	}
+
+	if len(a.Labels) != 0 {
+		if err := a.GitHub.AddLabelsToIssue(a.Org, a.Repo, PR.GetNumber(), a.Labels); err != nil {
+			return PR, fmt.Errorf("failed labeling PR %d: %w", PR.GetNumber(), err)
+		}
+	}
+	if len(a.Assignees) != 0 {
+		if err := a.GitHub.AddAssignees(a.Org, a.Repo, PR.GetNumber(), a.Assignees); err != nil {
+			return PR, fmt.Errorf("failed assigning PR %d: %w", PR.GetNumber(), err)
+		}
+	}
+	return PR, nil
+}
+
+// GenerateBody returns the body of the PR, made of the description and the
+// list of changed files.
+func GenerateBody(description string, changes []string) string {
+	var b strings.Builder
+	if description != "" {
+		b.WriteString(strings.TrimSpace(description))
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(&b, "Changed files (%d):\n", len(changes))
+	for i, c := range changes {
+		if i == maxListedChanges {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(changes)-maxListedChanges)
+			break
+		}
+		fmt.Fprintf(&b, "- `%s`\n", c)
+	}
+	b.WriteString("\nThis PR is updated automatically, the changes pushed to it by hand will be overwritten.\n")
+	return b.String()
+}
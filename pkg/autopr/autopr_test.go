@@ -0,0 +1,200 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autopr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
+
+	"knative.dev/test-infra/pkg/autopr/fakeautopr"
+	"knative.dev/test-infra/pkg/ghutil"
+	"knative.dev/test-infra/pkg/ghutil/fakeghutil"
+)
+
+func testOptions() Options {
+	return Options{
+		Org:         "knative",
+		Repo:        "test-infra",
+		Base:        "main",
+		Head:        "autoupdate",
+		UserID:      "knative-automation",
+		Title:       "[Auto] Update configs",
+		Description: "Produced by the config generator.",
+		Labels:      []string{"skip-review"},
+		Assignees:   []string{"oncaller"},
+	}
+}
+
+func newFakeGithub() *fakeghutil.FakeGithubClient {
+	fgc := fakeghutil.NewFakeGithubClient()
+	fgc.PullRequests["test-infra"] = make(map[int]*github.PullRequest)
+	return fgc
+}
+
+func TestRun(t *testing.T) {
+	const wantURL = "https://github.com/knative-automation/test-infra.git"
+	tests := []struct {
+		name        string
+		changed     []string
+		generated   []string
+		existingPRs []string // titles of the open PRs of the head branch
+		dryRun      bool
+
+		wantCommits []string
+		wantPushes  []fakeautopr.Push
+		wantPR      bool
+		wantPRs     int
+	}{{
+		name:        "create PR",
+		generated:   []string{"config/jobs.yaml", "config/testgrid.yaml"},
+		wantCommits: []string{"[Auto] Update configs"},
+		wantPushes: []fakeautopr.Push{{
+			RemoteURL: wantURL, Branch: "autoupdate", Commits: []string{"[Auto] Update configs"},
+		}},
+		wantPR:  true,
+		wantPRs: 1,
+	}, {
+		name:        "update PR",
+		changed:     []string{"config/jobs.yaml"},
+		existingPRs: []string{"Something else", "[Auto] Update configs"},
+		wantCommits: []string{"[Auto] Update configs"},
+		wantPushes: []fakeautopr.Push{{
+			RemoteURL: wantURL, Branch: "autoupdate", Commits: []string{"[Auto] Update configs"},
+		}},
+		wantPR:  true,
+		wantPRs: 2,
+	}, {
+		name:    "no changes",
+		wantPRs: 0,
+	}, {
+		name:      "dry run",
+		generated: []string{"config/jobs.yaml"},
+		dryRun:    true,
+		wantPRs:   0,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := fakeautopr.NewFakeRepo(test.changed...)
+			fgc := newFakeGithub()
+			for _, title := range test.existingPRs {
+				if _, err := fgc.CreatePullRequest("knative", "test-infra", "knative-automation:autoupdate", "main", title, ""); err != nil {
+					t.Fatalf("Failed creating the existing PR: '%v'", err)
+				}
+			}
+			opts := testOptions()
+			opts.DryRun = test.dryRun
+			a := &AutoPR{Options: opts, Git: repo, GitHub: fgc}
+
+			PR, err := a.Run(func() error {
+				repo.Change(test.generated...)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed running: '%v'", err)
+			}
+			if diff := cmp.Diff(test.wantCommits, repo.Commits); diff != "" {
+				t.Errorf("commits got(+) is different from wanted(-)\n%v", diff)
+			}
+			if diff := cmp.Diff(test.wantPushes, repo.Pushes); diff != "" {
+				t.Errorf("pushes got(+) is different from wanted(-)\n%v", diff)
+			}
+			if got := len(fgc.PullRequests["test-infra"]); got != test.wantPRs {
+				t.Errorf("got %d PRs, want %d", got, test.wantPRs)
+			}
+			if (PR != nil) != test.wantPR {
+				t.Fatalf("got PR %v, want a PR: %v", PR, test.wantPR)
+			}
+			if PR == nil {
+				return
+			}
+			if PR.GetTitle() != opts.Title || !strings.HasPrefix(PR.GetBody(), opts.Description) {
+				t.Errorf("got PR title %q and body %q", PR.GetTitle(), PR.GetBody())
+			}
+			if len(PR.Labels) != 1 || PR.Labels[0].GetName() != "skip-review" {
+				t.Errorf("got PR labels %v, want [skip-review]", PR.Labels)
+			}
+			if len(PR.Assignees) != 1 || PR.Assignees[0].GetLogin() != "oncaller" {
+				t.Errorf("got PR assignees %v, want [oncaller]", PR.Assignees)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*AutoPR, *fakeautopr.FakeRepo)
+		generate  Generator
+		wantErr   string
+	}{{
+		name:      "missing options",
+		configure: func(a *AutoPR, _ *fakeautopr.FakeRepo) { a.Head, a.UserID = "", "" },
+		wantErr:   "missing options: head, user ID",
+	}, {
+		name:     "generator fails",
+		generate: func() error { return errors.New("boom") },
+		wantErr:  "failed generating the changes: boom",
+	}, {
+		name:      "push fails",
+		configure: func(_ *AutoPR, r *fakeautopr.FakeRepo) { r.PushErr = errors.New("denied") },
+		wantErr:   "denied",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := fakeautopr.NewFakeRepo("config/jobs.yaml")
+			fgc := newFakeGithub()
+			a := &AutoPR{Options: testOptions(), Git: repo, GitHub: fgc}
+			if test.configure != nil {
+				test.configure(a, repo)
+			}
+			_, err := a.Run(test.generate)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Expected an error containing %q, got '%v'", test.wantErr, err)
+			}
+			if len(fgc.PullRequests["test-infra"]) != 0 {
+				t.Error("Expected no PR to be created")
+			}
+		})
+	}
+}
+
+func TestGenerateBody(t *testing.T) {
+	var many []string
+	for i := 0; i < maxListedChanges+2; i++ {
+		many = append(many, "file")
+	}
+	body := GenerateBody("Description.\n", many)
+	if !strings.HasPrefix(body, "Description.\n\nChanged files (32):\n") {
+		t.Errorf("unexpected body start: %q", body)
+	}
+	if got := strings.Count(body, "- `file`"); got != maxListedChanges {
+		t.Errorf("got %d files listed, want %d", got, maxListedChanges)
+	}
+	if !strings.Contains(body, "- ... and 2 more\n") {
+		t.Errorf("body doesn't mention the files not listed: %q", body)
+	}
+}
+
+// assert the fake implements the interface
+var (
+	_ Repo                    = &fakeautopr.FakeRepo{}
+	_ ghutil.GithubOperations = &fakeghutil.FakeGithubClient{}
+)
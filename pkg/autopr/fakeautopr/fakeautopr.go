@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// fakeautopr.go fakes the git repository of autopr for testing purpose,
+// fakeghutil fakes Github
+
+package fakeautopr
+
+import (
+	"sort"
+)
+
+// Push records a push of FakeRepo.
+type Push struct {
+	RemoteURL string
+	Branch    string
+	// Commits are the messages of the commits pushed, oldest first
+	Commits []string
+}
+
+// FakeRepo is a faked git repository, implements autopr.Repo
+type FakeRepo struct {
+	// Changed are the files changed in the worktree
+	Changed map[string]bool
+	// Commits are the messages of the commits, oldest first
+	Commits []string
+	Pushes  []Push
+
+	// Errors returned by the methods, if set
+	ChangesErr error
+	CommitErr  error
+	PushErr    error
+}
+
+// NewFakeRepo creates a FakeRepo with the files changed
+func NewFakeRepo(changed ...string) *FakeRepo {
+	r := &FakeRepo{Changed: make(map[string]bool)}
+	r.Change(changed...)
+	return r
+}
+
+// Change marks the files as changed, generators faking changes call it
+func (r *FakeRepo) Change(files ...string) {
+	for _, f := range files {
+		r.Changed[f] = true
+	}
+}
+
+// Changes returns the files changed in the worktree, sorted
+func (r *FakeRepo) Changes() ([]string, error) {
+	if r.ChangesErr != nil {
+		return nil, r.ChangesErr
+	}
+	var changes []string
+	for f := range r.Changed {
+		changes = append(changes, f)
+	}
+	sort.Strings(changes)
+	return changes, nil
+}
+
+// Commit commits all the changes
+func (r *FakeRepo) Commit(message string) error {
+	if r.CommitErr != nil {
+		return r.CommitErr
+	}
+	r.Commits = append(r.Commits, message)
+	r.Changed = make(map[string]bool)
+	return nil
+}
+
+// Push records the push of all the commits
+func (r *FakeRepo) Push(remoteURL, branch string) error {
+	if r.PushErr != nil {
+		return r.PushErr
+	}
+	r.Pushes = append(r.Pushes, Push{
+		RemoteURL: remoteURL,
+		Branch:    branch,
+		Commits:   append([]string{}, r.Commits...),
+	})
+	return nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// git.go commits and pushes the changes of a local git repository
+
+package autopr
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/openpgp"
+)
+
+// pushRefPrefix prefixes the local ref pushed to the remote branch, so that
+// pushing doesn't touch the local branches
+const pushRefPrefix = "refs/autopr/"
+
+// Repo is a git repository the generated changes are committed into.
+type Repo interface {
+	// Changes returns the files changed in the worktree, sorted.
+	Changes() ([]string, error)
+	// Commit stages all the changes, including the new and deleted files,
+	// and commits them.
+	Commit(message string) error
+	// Push force-pushes the HEAD commit to the branch of the remote.
+	Push(remoteURL, branch string) error
+}
+
+// GitRepo is a Repo on the local filesystem, operated with go-git so that
+// neither the git binary nor its global config are needed.
+type GitRepo struct {
+	repo *git.Repository
+
+	// AuthorName and AuthorEmail are the author of the commits, if not set
+	// they are read from the config of the repository.
+	AuthorName  string
+	AuthorEmail string
+	// SignKey signs the commits if set, it must be decrypted.
+	SignKey *openpgp.Entity
+	// Auth authenticates to the remote when pushing.
+	Auth transport.AuthMethod
+}
+
+// OpenRepo opens the git repository at dir, or any parent directory of it.
+func OpenRepo(dir string) (*GitRepo, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed opening the git repository at %q: %w", dir, err)
+	}
+	return &GitRepo{repo: repo}, nil
+}
+
+// ReadSignKey reads the armored private key signing the commits, and decrypts
+// it with the passphrase if it's encrypted.
+func ReadSignKey(path, passphrase string) (*openpgp.Entity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entities, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading the key ring %q: %w", path, err)
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("the key ring %q must have exactly one key, got %d", path, len(entities))
+	}
+	key := entities[0]
+	if key.PrivateKey == nil {
+		return nil, fmt.Errorf("the key ring %q has no private key", path)
+	}
+	if key.PrivateKey.Encrypted {
+		if err := key.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("failed decrypting the private key: %w", err)
+		}
+	}
+	return key, nil
+}
+
+// Changes implements Repo.
+func (r *GitRepo) Changes() ([]string, error) {
+	status, err := r.status()
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for path := range status {
+		changes = append(changes, path)
+	}
+	sort.Strings(changes)
+	return changes, nil
+}
+
+// Commit implements Repo.
+func (r *GitRepo) Commit(message string) error {
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := r.status()
+	if err != nil {
+		return err
+	}
+	for path, s := range status {
+		if s.Worktree == git.Deleted {
+			_, err = w.Remove(path)
+		} else {
+			_, err = w.Add(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed staging %q: %w", path, err)
+		}
+	}
+	opts := &git.CommitOptions{SignKey: r.SignKey}
+	if r.AuthorName != "" && r.AuthorEmail != "" {
+		opts.Author = &object.Signature{Name: r.AuthorName, Email: r.AuthorEmail, When: time.Now()}
+	}
+	if _, err := w.Commit(message, opts); err != nil {
+		return fmt.Errorf("failed committing: %w", err)
+	}
+	return nil
+}
+
+// Push implements Repo.
+func (r *GitRepo) Push(remoteURL, branch string) error {
+	head, err := r.repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed getting HEAD: %w", err)
+	}
+	local := plumbing.ReferenceName(pushRefPrefix + branch)
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(local, head.Hash())); err != nil {
+		return err
+	}
+	defer r.repo.Storer.RemoveReference(local)
+
+	remote := git.NewRemote(r.repo.Storer, &config.RemoteConfig{
+		Name: "autopr",
+		URLs: []string{remoteURL},
+	})
+	err = remote.Push(&git.PushOptions{
+		RemoteName: "autopr",
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", local, plumbing.NewBranchReferenceName(branch)))},
+		Auth:       r.Auth,
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed pushing to branch %q of %q: %w", branch, remoteURL, err)
+	}
+	return nil
+}
+
+// status returns the status of the files changed in the worktree.
+func (r *GitRepo) status() (git.Status, error) {
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting the git status: %w", err)
+	}
+	for path, s := range status {
+		if s.Worktree == git.Unmodified && s.Staging == git.Unmodified {
+			delete(status, path)
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autopr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/openpgp"
+)
+
+var _ Repo = &GitRepo{}
+
+// initRepo creates a git repository with the files committed, and a bare
+// repository to push to.
+func initRepo(t *testing.T, files map[string]string) (string, string) {
+	dir, err := ioutil.TempDir("", "autopr")
+	if err != nil {
+		t.Fatalf("Failed creating the temp dir: '%v'", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	local, remote := filepath.Join(dir, "local"), filepath.Join(dir, "remote")
+	if _, err := git.PlainInit(remote, true); err != nil {
+		t.Fatalf("Failed creating the remote repository: '%v'", err)
+	}
+	repo, err := git.PlainInit(local, false)
+	if err != nil {
+		t.Fatalf("Failed creating the local repository: '%v'", err)
+	}
+	writeFiles(t, local, files)
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed getting the worktree: '%v'", err)
+	}
+	for f := range files {
+		if _, err := w.Add(f); err != nil {
+			t.Fatalf("Failed adding %q: '%v'", f, err)
+		}
+	}
+	if _, err := w.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Someone", Email: "someone@knative.dev"},
+	}); err != nil {
+		t.Fatalf("Failed committing: '%v'", err)
+	}
+	return local, remote
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for f, content := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed creating the parent of %q: '%v'", f, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed writing %q: '%v'", f, err)
+		}
+	}
+}
+
+func TestGitRepo(t *testing.T) {
+	local, remote := initRepo(t, map[string]string{
+		"config/jobs.yaml":     "jobs",
+		"config/testgrid.yaml": "testgrid",
+		"README.md":            "readme",
+	})
+	r, err := OpenRepo(filepath.Join(local, "config"))
+	if err != nil {
+		t.Fatalf("Failed opening the repository: '%v'", err)
+	}
+	r.AuthorName, r.AuthorEmail = "Knative Automation", "automation@knative.team"
+	r.SignKey, err = openpgp.NewEntity("Knative Automation", "", "automation@knative.team", nil)
+	if err != nil {
+		t.Fatalf("Failed creating the sign key: '%v'", err)
+	}
+
+	changes, err := r.Changes()
+	if err != nil {
+		t.Fatalf("Failed listing the changes: '%v'", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %v", changes)
+	}
+
+	writeFiles(t, local, map[string]string{"config/jobs.yaml": "new jobs", "config/new.yaml": "new"})
+	if err := os.Remove(filepath.Join(local, "config/testgrid.yaml")); err != nil {
+		t.Fatalf("Failed removing a file: '%v'", err)
+	}
+	changes, err = r.Changes()
+	if err != nil {
+		t.Fatalf("Failed listing the changes: '%v'", err)
+	}
+	want := []string{"config/jobs.yaml", "config/new.yaml", "config/testgrid.yaml"}
+	if diff := cmp.Diff(want, changes); diff != "" {
+		t.Errorf("changes got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	if err := r.Commit("[Auto] Update configs"); err != nil {
+		t.Fatalf("Failed committing: '%v'", err)
+	}
+	if changes, err := r.Changes(); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes after committing, got %v, '%v'", changes, err)
+	}
+	for i := 0; i < 2; i++ {
+		// pushing again is a no-op
+		if err := r.Push(remote, "autoupdate"); err != nil {
+			t.Fatalf("Failed pushing: '%v'", err)
+		}
+	}
+
+	remoteRepo, err := git.PlainOpen(remote)
+	if err != nil {
+		t.Fatalf("Failed opening the remote repository: '%v'", err)
+	}
+	ref, err := remoteRepo.Reference(plumbing.NewBranchReferenceName("autoupdate"), false)
+	if err != nil {
+		t.Fatalf("Failed getting the pushed branch: '%v'", err)
+	}
+	commit, err := remoteRepo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatalf("Failed getting the pushed commit: '%v'", err)
+	}
+	if commit.Message != "[Auto] Update configs" || commit.Author.Name != "Knative Automation" || commit.Author.Email != "automation@knative.team" {
+		t.Errorf("got commit %q by %s", commit.Message, commit.Author)
+	}
+	if commit.PGPSignature == "" {
+		t.Error("Expected the commit to be signed")
+	}
+	files, err := commit.Files()
+	if err != nil {
+		t.Fatalf("Failed listing the files of the commit: '%v'", err)
+	}
+	var got []string
+	files.ForEach(func(f *object.File) error {
+		got = append(got, f.Name)
+		return nil
+	})
+	if diff := cmp.Diff([]string{"README.md", "config/jobs.yaml", "config/new.yaml"}, got); diff != "" {
+		t.Errorf("committed files got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	if _, err := r.repo.Reference(plumbing.ReferenceName(pushRefPrefix+"autoupdate"), false); err == nil {
+		t.Error("Expected the ref pushed to be removed")
+	}
+}
@@ -34,16 +34,15 @@ const (
 
 var (
 	boskosURI = "http://boskos.test-pods.svc.cluster.local."
-	// Keep Boskos for 2 hours, which will be used to release Boskos resource
-	// only when a job forgot or failed to release Boskos, which shouldn't
-	// happen that often. Using this can avoid keeping "heartbeat" which is
-	// almost not doable for Go
+	// Wait for 2 hours at most for a free Boskos resource. Once acquired,
+	// resources are kept busy by a Lease heartbeat.
 	defaultWaitDuration = 2 * time.Hour
 )
 
 // Operation defines actions for handling GKE resources
 type Operation interface {
 	AcquireGKEProject(string) (*boskoscommon.Resource, error)
+	HeartbeatGKEProject(string) error
 	ReleaseGKEProject(string) error
 }
 
@@ -87,6 +86,15 @@ func (c *Client) AcquireGKEProject(resType string) (*boskoscommon.Resource, erro
 	return p, nil
 }
 
+// HeartbeatGKEProject keeps the project busy, so that Boskos doesn't reclaim
+// it, the host must match with the host name that acquired the project.
+func (c *Client) HeartbeatGKEProject(name string) error {
+	if err := c.Update(name, boskoscommon.Busy, nil); err != nil {
+		return fmt.Errorf("boskos failed to send heartbeat for GKE project %q: %w", name, err)
+	}
+	return nil
+}
+
 // ReleaseGKEProject releases project, the host must match with the host name that acquired
 // the project, which by default is env var `JOB_NAME`. The state is set to
 // "dirty" for Janitor picking up.
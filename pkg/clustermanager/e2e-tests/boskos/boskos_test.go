@@ -157,3 +157,28 @@ func TestReleaseGKEProject(t *testing.T) {
 		})
 	}
 }
+
+func TestHeartbeatGKEProject(t *testing.T) {
+	oldBoskosURI := boskosURI
+	defer func() {
+		boskosURI = oldBoskosURI
+	}()
+	expReq := "/update?name=a&owner=fakehost&state=busy"
+	ts := fakeServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.RequestURI != expReq {
+			t.Errorf("Request URI doesn't match: want: '%s', got: '%s'", expReq, r.RequestURI)
+			http.Error(w, "", http.StatusBadRequest)
+		}
+	})
+	defer ts.Close()
+	boskosURI = ts.URL
+	client, err := NewClient(fakeHost, /* boskos owner */
+		"", /* boskos user */
+		"" /* boskos password file */)
+	if err != nil {
+		t.Fatalf("Failed to create test client %v", err)
+	}
+	if err := client.HeartbeatGKEProject("a"); err != nil {
+		t.Fatalf("Unexpected error when sending heartbeat for GKE project, '%v'", err)
+	}
+}
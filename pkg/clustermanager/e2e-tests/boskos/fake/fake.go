@@ -18,6 +18,8 @@ package fake
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	boskoscommon "sigs.k8s.io/boskos/common"
 
@@ -31,6 +33,18 @@ const (
 // FakeBoskosClient implements boskos.Operation
 type FakeBoskosClient struct {
 	resources []*boskoscommon.Resource
+
+	// LeaseDuration simulates Boskos reaper: busy resources without
+	// heartbeat for longer than this are reset to dirty and lose their owner.
+	// Leases never expire if it's 0.
+	LeaseDuration time.Duration
+	// Now returns the current time, time.Now if not set.
+	Now func() time.Time
+	// map of resource name: last time it was acquired or updated
+	lastUpdates map[string]time.Time
+	// map of resource name: number of heartbeats received
+	heartbeats map[string]int
+	mutex      sync.Mutex
 }
 
 func (c *FakeBoskosClient) getOwner(host *string) string {
@@ -40,25 +54,92 @@ func (c *FakeBoskosClient) getOwner(host *string) string {
 	return *host
 }
 
+func (c *FakeBoskosClient) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// touch records that the resource was acquired or updated now.
+func (c *FakeBoskosClient) touch(name string) {
+	if c.lastUpdates == nil {
+		c.lastUpdates = make(map[string]time.Time)
+	}
+	c.lastUpdates[name] = c.now()
+}
+
+// expireLeases resets the busy resources whose lease expired, like Boskos reaper.
+func (c *FakeBoskosClient) expireLeases() {
+	if c.LeaseDuration == 0 {
+		return
+	}
+	for _, res := range c.resources {
+		if res.State == boskoscommon.Busy && c.now().Sub(c.lastUpdates[res.Name]) > c.LeaseDuration {
+			res.State = boskoscommon.Dirty
+			res.Owner = ""
+		}
+	}
+}
+
 func (c *FakeBoskosClient) GetResources() []*boskoscommon.Resource {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expireLeases()
 	return c.resources
 }
 
+// Heartbeats returns the number of heartbeats received for the resource.
+func (c *FakeBoskosClient) Heartbeats(name string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.heartbeats[name]
+}
+
 // AcquireGKEProject fakes to be no op
 func (c *FakeBoskosClient) AcquireGKEProject(resType string) (*boskoscommon.Resource, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expireLeases()
 	for _, res := range c.resources {
 		if res.State == boskoscommon.Free {
 			res.State = boskoscommon.Busy
 			res.Owner = c.getOwner(nil)
 			res.Type = resType
+			c.touch(res.Name)
 			return res, nil
 		}
 	}
 	return nil, fmt.Errorf("no GKE project available")
 }
 
+// HeartbeatGKEProject renews the lease of the resource, which fails if it expired
+func (c *FakeBoskosClient) HeartbeatGKEProject(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expireLeases()
+	owner := c.getOwner(nil)
+	for _, res := range c.resources {
+		if res.Name == name {
+			if res.Owner != owner || res.State != boskoscommon.Busy {
+				return fmt.Errorf("resource %q is %s and owned by '%s', expect busy and owned by '%s'", name, res.State, res.Owner, owner)
+			}
+			c.touch(name)
+			if c.heartbeats == nil {
+				c.heartbeats = make(map[string]int)
+			}
+			c.heartbeats[name]++
+			return nil
+		}
+	}
+	return fmt.Errorf("resource doesn't exist yet: '%s'", name)
+}
+
 // ReleaseGKEProject fakes to be no op
 func (c *FakeBoskosClient) ReleaseGKEProject(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expireLeases()
 	owner := c.getOwner(nil)
 	for _, res := range c.resources {
 		if res.Name == name {
@@ -76,6 +157,8 @@ func (c *FakeBoskosClient) ReleaseGKEProject(name string) error {
 
 // NewGKEProject adds Boskos resources for testing purpose
 func (c *FakeBoskosClient) NewGKEProject(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.resources = append(c.resources, &boskoscommon.Resource{
 		Type:  boskos.GKEProjectResource,
 		Name:  name,
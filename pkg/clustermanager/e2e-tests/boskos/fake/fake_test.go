@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	boskoscommon "sigs.k8s.io/boskos/common"
+
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	c := &FakeBoskosClient{LeaseDuration: 30 * time.Minute, Now: clock.Now}
+	c.NewGKEProject("a")
+	c.NewGKEProject("b")
+
+	// Without heartbeat, the lease expires.
+	res, err := c.AcquireGKEProject(boskos.GKEProjectResource)
+	if err != nil {
+		t.Fatalf("Unexpected error acquiring project: '%v'", err)
+	}
+	clock.advance(31 * time.Minute)
+	if err := c.HeartbeatGKEProject(res.Name); err == nil {
+		t.Errorf("Expected an error sending heartbeat for expired project %q", res.Name)
+	}
+	if res.State != boskoscommon.Dirty || res.Owner != "" {
+		t.Errorf("Expired project is %s and owned by %q, want dirty without owner", res.State, res.Owner)
+	}
+
+	// With heartbeats, the lease is kept.
+	res, err = c.AcquireGKEProject(boskos.GKEProjectResource)
+	if err != nil {
+		t.Fatalf("Unexpected error acquiring project: '%v'", err)
+	}
+	for i := 0; i < 4; i++ {
+		clock.advance(20 * time.Minute)
+		if err := c.HeartbeatGKEProject(res.Name); err != nil {
+			t.Fatalf("Unexpected error sending heartbeat: '%v'", err)
+		}
+	}
+	if res.State != boskoscommon.Busy {
+		t.Errorf("Project %q is %s, want busy", res.Name, res.State)
+	}
+	if n := c.Heartbeats(res.Name); n != 4 {
+		t.Errorf("Got %d heartbeats, want 4", n)
+	}
+	if err := c.ReleaseGKEProject(res.Name); err != nil {
+		t.Errorf("Unexpected error releasing project: '%v'", err)
+	}
+}
+
+func TestLeaseHeartbeat(t *testing.T) {
+	c := &FakeBoskosClient{LeaseDuration: 50 * time.Millisecond}
+	c.NewGKEProject("a")
+	l, err := boskos.AcquireLease(c, boskos.GKEProjectResource, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error acquiring lease: '%v'", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := l.Err(); err != nil {
+		t.Errorf("Unexpected heartbeat error: '%v'", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Unexpected error releasing lease: '%v'", err)
+	}
+	if n := c.Heartbeats("a"); n == 0 {
+		t.Error("Expected heartbeats, got none")
+	}
+	if res := c.GetResources()[0]; res.State != boskoscommon.Free {
+		t.Errorf("Released project is %s, want free", res.State)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package boskos
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultHeartbeatInterval is how often a lease is renewed. Boskos reaper
+// resets the busy resources that haven't been updated for a while, so this
+// needs to be well below its expiry.
+const DefaultHeartbeatInterval = 5 * time.Minute
+
+// Lease is a Boskos GKE project held by this process. It's kept busy by a
+// heartbeat in the background, until it's stopped or released.
+type Lease struct {
+	// Project is the name of the leased GKE project
+	Project string
+
+	ops      Operation
+	interval time.Duration
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mutex    sync.Mutex
+	err      error
+	released bool
+}
+
+// AcquireLease acquires a GKE project of the given Boskos resource type, and
+// starts sending heartbeats for it at the given interval.
+func AcquireLease(ops Operation, resType string, interval time.Duration) (*Lease, error) {
+	p, err := ops.AcquireGKEProject(resType)
+	if err != nil {
+		return nil, err
+	}
+	return NewLease(ops, p.Name, interval), nil
+}
+
+// NewLease starts sending heartbeats at the given interval for a GKE project
+// already acquired by the same owner, e.g. by another process.
+func NewLease(ops Operation, project string, interval time.Duration) *Lease {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Lease{
+		Project:  project,
+		ops:      ops,
+		interval: interval,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go l.heartbeat(ctx)
+	return l
+}
+
+// heartbeat renews the lease until the context is cancelled.
+func (l *Lease) heartbeat(ctx context.Context) {
+	defer close(l.done)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.ops.HeartbeatGKEProject(l.Project)
+			if err != nil {
+				log.Printf("Failed sending heartbeat for Boskos project %q: '%v'", l.Project, err)
+			}
+			l.mutex.Lock()
+			l.err = err
+			l.mutex.Unlock()
+		}
+	}
+}
+
+// Err returns the error of the last heartbeat, if it failed.
+func (l *Lease) Err() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.err
+}
+
+// Stop stops sending heartbeats without releasing the project, e.g. to hand
+// it over to another process. It's safe to call it more than once.
+func (l *Lease) Stop() {
+	l.cancel()
+	<-l.done
+}
+
+// Release stops sending heartbeats and releases the project. It only releases
+// the project once, so it can be both deferred and called on signal.
+func (l *Lease) Release() error {
+	l.cancel()
+	<-l.done
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		return nil
+	}
+	l.released = true
+	log.Printf("Releasing Boskos project %q", l.Project)
+	if err := l.ops.ReleaseGKEProject(l.Project); err != nil {
+		return fmt.Errorf("failed releasing Boskos project %q: %w", l.Project, err)
+	}
+	return nil
+}
+
+// ReleaseOnSignal releases the project if the process receives SIGINT or
+// SIGTERM, e.g. when Prow aborts the job. The returned context is cancelled
+// once the project is released, so that the caller can stop what it's doing
+// with the project. The returned function stops watching for signals.
+func (l *Lease) ReleaseOnSignal() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	var once sync.Once
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Printf("Received %v", sig)
+			if err := l.Release(); err != nil {
+				log.Print(err)
+			}
+			cancel()
+		case <-stop:
+		}
+	}()
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(stop)
+		})
+	}
+}
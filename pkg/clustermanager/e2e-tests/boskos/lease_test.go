@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package boskos
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	boskoscommon "sigs.k8s.io/boskos/common"
+)
+
+// recordingOps records the calls made by a lease.
+type recordingOps struct {
+	heartbeatErr error
+	calls        []string
+	mutex        sync.Mutex
+}
+
+func (o *recordingOps) record(call string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.calls = append(o.calls, call)
+}
+
+func (o *recordingOps) count(call string) int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	n := 0
+	for _, c := range o.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+func (o *recordingOps) AcquireGKEProject(resType string) (*boskoscommon.Resource, error) {
+	o.record("acquire")
+	return &boskoscommon.Resource{Name: "p", Type: resType, State: boskoscommon.Busy}, nil
+}
+
+func (o *recordingOps) HeartbeatGKEProject(name string) error {
+	o.record("heartbeat")
+	return o.heartbeatErr
+}
+
+func (o *recordingOps) ReleaseGKEProject(name string) error {
+	o.record("release")
+	return nil
+}
+
+func TestLease(t *testing.T) {
+	ops := &recordingOps{}
+	l, err := AcquireLease(ops, GKEProjectResource, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error acquiring lease: '%v'", err)
+	}
+	if l.Project != "p" {
+		t.Errorf("Lease project = %q, want %q", l.Project, "p")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ops.count("heartbeat") < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Unexpected error releasing lease: '%v'", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Unexpected error releasing lease twice: '%v'", err)
+	}
+	heartbeats := ops.count("heartbeat")
+	if heartbeats < 3 {
+		t.Errorf("Got %d heartbeats, want at least 3", heartbeats)
+	}
+	if n := ops.count("release"); n != 1 {
+		t.Errorf("Got %d releases, want 1", n)
+	}
+	// No more heartbeats after release.
+	time.Sleep(10 * time.Millisecond)
+	if n := ops.count("heartbeat"); n != heartbeats {
+		t.Errorf("Got %d heartbeats after release, want %d", n, heartbeats)
+	}
+}
+
+func TestLeaseHeartbeatError(t *testing.T) {
+	ops := &recordingOps{heartbeatErr: errors.New("lease expired")}
+	l := NewLease(ops, "p", time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	l.Stop()
+	if l.Err() == nil {
+		t.Error("Expected the heartbeat error, got nil")
+	}
+	if n := ops.count("release"); n != 0 {
+		t.Errorf("Got %d releases after stopping, want 0", n)
+	}
+}
+
+func TestLeaseReleaseOnSignal(t *testing.T) {
+	ops := &recordingOps{}
+	l := NewLease(ops, "p", time.Hour)
+	released, stop := l.ReleaseOnSignal()
+	defer stop()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed sending signal: '%v'", err)
+	}
+	select {
+	case <-released.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the release on signal")
+	}
+	if n := ops.count("release"); n != 1 {
+		t.Errorf("Got %d releases, want 1", n)
+	}
+}
@@ -16,11 +16,7 @@ limitations under the License.
 
 package gke
 
-import (
-	"regexp"
-
-	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
-)
+import "knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
 
 const (
 	defaultGKEMinNodes  = 1
@@ -39,15 +35,4 @@ var (
 	protectedProjects       = []string{"knative-tests"}
 	protectedClusters       = []string{"knative-prow"}
 	defaultGKEBackupRegions = []string{"us-west1", "us-east1"}
-
-	// If one of the error patterns below is matched, it would be recommended to
-	// retry creating the cluster in a different region/zone.
-	// - stockout (https://github.com/knative/test-infra/issues/592)
-	// - latest GKE not available in this region/zone yet (https://github.com/knative/test-infra/issues/694)
-	retryableCreationErrors = []*regexp.Regexp{
-		regexp.MustCompile(`.*Master version "[0-9a-z\-.]+" is unsupported.*`),
-		regexp.MustCompile(`.*No valid versions with the prefix "[0-9.]+" found.*`),
-		regexp.MustCompile(`.*does not have enough resources available to fulfill.*`),
-		regexp.MustCompile(`.*only \d+ nodes out of \d+ have registered; this is likely due to Nodes failing to start correctly.*`),
-	}
 )
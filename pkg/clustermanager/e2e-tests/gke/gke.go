@@ -17,6 +17,7 @@ limitations under the License.
 package gke
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -26,6 +27,7 @@ import (
 	container "google.golang.org/api/container/v1beta1"
 	"google.golang.org/api/option"
 
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
 	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/common"
 	"knative.dev/test-infra/pkg/gke"
 )
@@ -54,12 +56,25 @@ func (gc *GKECluster) Acquire() error {
 	}
 
 	// If running on Prow and project name is not provided, get project name from boskos.
+	released := context.Background()
 	if gc.Request.Project == "" && gc.isBoskos {
-		project, err := gc.boskosOps.AcquireGKEProject(gc.Request.ResourceType)
+		lease, err := boskos.AcquireLease(gc.boskosOps, gc.Request.ResourceType, boskos.DefaultHeartbeatInterval)
 		if err != nil {
 			return fmt.Errorf("failed acquiring boskos project: '%w'", err)
 		}
-		gc.Project = project.Name
+		gc.Project = lease.Project
+		// Keep the project busy until the cluster is deleted, or the
+		// heartbeat is stopped by the caller, and release it if the job is
+		// aborted or the creation fails.
+		gc.lease = lease
+		released, gc.stopWatchingSignals = lease.ReleaseOnSignal()
+		defer func() {
+			if gc.Cluster == nil {
+				if err := gc.releaseLease(); err != nil {
+					log.Print(err)
+				}
+			}
+		}()
 	}
 	if gc.Project == "" {
 		return errors.New("GCP project must be set")
@@ -98,6 +113,9 @@ func (gc *GKECluster) Acquire() error {
 		return fmt.Errorf("failed building the CreateClusterRequest: '%w'", err)
 	}
 	for i, region := range regions {
+		if released.Err() != nil {
+			return fmt.Errorf("aborted creating the cluster, boskos project %q was released", gc.Project)
+		}
 		// Restore innocence
 		err = nil
 
@@ -120,9 +138,16 @@ func (gc *GKECluster) Acquire() error {
 				errMsg = fmt.Sprintf("%sDeleting cluster %q in region %q zone %q in background...\n", errMsg, clusterName, region, request.Zone)
 				client.DeleteClusterAsync(gc.Project, region, request.Zone, clusterName)
 			}
-			// Retry another region if cluster creation failed.
-			if i != len(regions)-1 && needsRetryCreation(err.Error()) {
-				errMsg = fmt.Sprintf("%sRetry another region %q for cluster creation", errMsg, regions[i+1])
+			// Retry another region if cluster creation failed, unless the
+			// GKE API rejected the request itself. As before errors were
+			// classified, unknown errors are retried in the other regions too.
+			errType := gke.ClassifyCreationError(err)
+			if errType == gke.CreationErrorPermanent {
+				log.Printf("%sNot retrying after %s error", errMsg, errType)
+				break
+			}
+			if i != len(regions)-1 {
+				errMsg = fmt.Sprintf("%sRetry another region %q for cluster creation after %s error", errMsg, regions[i+1], errType)
 			}
 			log.Print(errMsg)
 		} else {
@@ -131,18 +156,31 @@ func (gc *GKECluster) Acquire() error {
 			break
 		}
 	}
+	if released.Err() != nil {
+		gc.Cluster = nil
+		return fmt.Errorf("aborted creating the cluster, boskos project %q was released", gc.Project)
+	}
 
 	return err
 }
 
-// needsRetryCreation determines if cluster creation needs to be retried based on the error message.
-func needsRetryCreation(errMsg string) bool {
-	for _, regx := range retryableCreationErrors {
-		if regx.MatchString(errMsg) {
-			return true
-		}
+// StopHeartbeat stops keeping the Boskos project of the cluster busy without
+// releasing it, e.g. to hand it over to `kntest cluster gke boskos heartbeat`
+// when this process exits. Otherwise the project is kept busy until Delete
+// releases it. It does nothing if the project isn't leased by this client.
+func (gc *GKECluster) StopHeartbeat() {
+	if gc.lease == nil {
+		return
 	}
-	return false
+	gc.stopWatchingSignals()
+	gc.lease.Stop()
+}
+
+// releaseLease stops the heartbeat of the Boskos project leased by this
+// client and releases the project.
+func (gc *GKECluster) releaseLease() error {
+	gc.stopWatchingSignals()
+	return gc.lease.Release()
 }
 
 // Delete takes care of GKE cluster resource cleanup.
@@ -176,7 +214,11 @@ func (gc *GKECluster) Delete() error {
 	}
 
 	// Release Boskos if running in Prow
-	if gc.isBoskos {
+	if gc.lease != nil {
+		if err = gc.releaseLease(); err != nil {
+			return fmt.Errorf("failed releasing boskos resource: '%w'", err)
+		}
+	} else if gc.isBoskos {
 		log.Printf("Releasing Boskos resource: '%v'", gc.Project)
 		if err = gc.boskosOps.ReleaseGKEProject(gc.Project); err != nil {
 			return fmt.Errorf("failed releasing boskos resource: '%w'", err)
@@ -654,6 +654,45 @@ func TestAcquire(t *testing.T) {
 	}
 }
 
+func TestAcquireKeepsBoskosLease(t *testing.T) {
+	fakeBoskosProj := "fake-boskos-proj-0"
+	fgc := setupFakeGKECluster()
+	fboskos := fgc.boskosOps.(*boskosFake.FakeBoskosClient)
+	fboskos.NewGKEProject(fakeBoskosProj)
+	fgc.isBoskos = true
+	fgc.Request = &GKERequest{
+		Request: gke.Request{
+			ClusterName: "predefined-cluster-name",
+			MinNodes:    defaultGKEMinNodes,
+			MaxNodes:    defaultGKEMaxNodes,
+			NodeType:    defaultGKENodeType,
+			Region:      defaultGKERegion,
+		},
+		ResourceType: defaultResourceType,
+	}
+	if err := fgc.Acquire(); err != nil {
+		t.Fatalf("Unexpected error acquiring the cluster: '%v'", err)
+	}
+	// The project is kept busy after the cluster is created.
+	if state := fboskos.GetResources()[0].State; state != boskoscommon.Busy {
+		t.Errorf("Boskos project is %q after creating the cluster, want %q", state, boskoscommon.Busy)
+	}
+	if fgc.lease == nil {
+		t.Fatal("Expected the lease to be kept after creating the cluster")
+	}
+	// Stopping the heartbeat hands the project over without releasing it.
+	fgc.StopHeartbeat()
+	if state := fboskos.GetResources()[0].State; state != boskoscommon.Busy {
+		t.Errorf("Boskos project is %q after stopping the heartbeat, want %q", state, boskoscommon.Busy)
+	}
+	if err := fgc.releaseLease(); err != nil {
+		t.Fatalf("Unexpected error releasing the project: '%v'", err)
+	}
+	if state := fboskos.GetResources()[0].State; state != boskoscommon.Free {
+		t.Errorf("Boskos project is %q after releasing it, want %q", state, boskoscommon.Free)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	type testdata struct {
 		isProw      bool
@@ -64,6 +64,10 @@ type GKECluster struct {
 	asyncCleanup bool
 	operations   gke.SDKOperations
 	boskosOps    boskos.Operation
+	// lease keeps the Boskos project acquired by Acquire busy, until the
+	// cluster is deleted or the heartbeat is stopped.
+	lease               *boskos.Lease
+	stopWatchingSignals func()
 }
 
 // Setup sets up a GKECluster client, takes GEKRequest as parameter and applies
@@ -78,18 +82,21 @@ func (gs *GKEClient) Setup(r GKERequest) ClusterOperations {
 		gc.asyncCleanup = true
 	}
 
-	if r.MinNodes == 0 {
-		r.MinNodes = defaultGKEMinNodes
-	}
-	if r.MaxNodes == 0 {
-		r.MaxNodes = defaultGKEMaxNodes
-		// We don't want MaxNodes < MinNodes
-		if r.MinNodes > r.MaxNodes {
-			r.MaxNodes = r.MinNodes
+	// Autopilot clusters manage their own nodes
+	if !r.Autopilot {
+		if r.MinNodes == 0 {
+			r.MinNodes = defaultGKEMinNodes
+		}
+		if r.MaxNodes == 0 {
+			r.MaxNodes = defaultGKEMaxNodes
+			// We don't want MaxNodes < MinNodes
+			if r.MinNodes > r.MaxNodes {
+				r.MaxNodes = r.MinNodes
+			}
+		}
+		if r.NodeType == "" {
+			r.NodeType = defaultGKENodeType
 		}
-	}
-	if r.NodeType == "" {
-		r.NodeType = defaultGKENodeType
 	}
 	// Only use default backup regions if region is not provided
 	if len(r.BackupRegions) == 0 && r.Region == "" {
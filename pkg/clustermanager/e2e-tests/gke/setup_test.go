@@ -349,6 +349,26 @@ func TestSetup(t *testing.T) {
 					ResourceType:  defaultResourceType,
 				},
 			},
+		}, {
+			name: "Autopilot, no node defaults",
+			arg: GKERequest{
+				Request: gke.Request{
+					Autopilot: true,
+				},
+			},
+			env: env{false, "", ""},
+			want: &GKECluster{
+				Request: &GKERequest{
+					Request: gke.Request{
+						ClusterName: "",
+						Region:      "us-central1",
+						Zone:        "",
+						Autopilot:   true,
+					},
+					BackupRegions: []string{"us-west1", "us-east1"},
+					ResourceType:  defaultResourceType,
+				},
+			},
 		},
 	}
 
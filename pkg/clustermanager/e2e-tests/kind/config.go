@@ -0,0 +1,38 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import "time"
+
+const (
+	defaultClusterName = "e2e-cls"
+	// defaultNodeCount is the number of worker nodes, besides the control
+	// plane node
+	defaultNodeCount = 1
+	// nodeImageRepo is the repository of the node images published for each
+	// Kubernetes version by kind
+	nodeImageRepo = "kindest/node"
+	// waitTimeout is how long to wait for the control plane to be ready
+	waitTimeout = 5 * time.Minute
+
+	// Keys to be written into metadata.json
+	providerKey       = "E2E:Provider"
+	clusterNameKey    = "E2E:Machine"
+	clusterVersionKey = "E2E:Version"
+	minNodesKey       = "E2E:MinNodes"
+	maxNodesKey       = "E2E:MaxNodes"
+)
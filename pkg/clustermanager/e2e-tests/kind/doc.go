@@ -14,11 +14,9 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package clerk
-
-// Response is the struct to return(end product) to Prow once the cluster is available
-type Response struct {
-	ClusterName string `json:"clusterName"`
-	ProjectID   string `json:"projectID"`
-	Zone        string `json:"zone"`
-}
+/*
+Package kind provides support for managing local kind clusters for e2e tests,
+implementing the same cluster operations as the GKE provider, so that e2e
+flows can be run without GCP access.
+*/
+package kind
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	shell "github.com/kballard/go-shellquote"
+
+	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/pkg/metautil"
+)
+
+// Provider returns kind
+func (kc *KindCluster) Provider() string {
+	return "kind"
+}
+
+// Acquire gets the existing kind cluster or creates a new one, and writes
+// its kubeconfig.
+func (kc *KindCluster) Acquire() error {
+	exists, err := kc.exists()
+	if err != nil {
+		return fmt.Errorf("failed checking existing kind clusters: '%w'", err)
+	}
+	name := kc.Request.ClusterName
+	switch {
+	case exists:
+		log.Printf("Using existing kind cluster %q", name)
+		if out, err := cmd.RunCommand(kc.command("export", "kubeconfig")); err != nil {
+			return fmt.Errorf("failed exporting kubeconfig of kind cluster %q: %q, '%w'", name, out, err)
+		}
+	case kc.Request.SkipCreation:
+		return fmt.Errorf("kind cluster %q doesn't exist", name)
+	default:
+		if err := kc.create(); err != nil {
+			return err
+		}
+		log.Print("Cluster creation completed")
+	}
+	kc.Exists = true
+
+	if kc.Request.SaveMetaData {
+		kc.saveMetaData()
+	}
+	return nil
+}
+
+// Delete deletes the kind cluster and removes it from the kubeconfig.
+func (kc *KindCluster) Delete() error {
+	exists, err := kc.exists()
+	if err != nil {
+		return fmt.Errorf("failed checking existing kind clusters: '%w'", err)
+	}
+	if !exists {
+		return errors.New("cluster doesn't exist")
+	}
+	log.Printf("Deleting kind cluster %q", kc.Request.ClusterName)
+	if out, err := cmd.RunCommand(kc.command("delete", "cluster")); err != nil {
+		return fmt.Errorf("failed deleting cluster: %q, '%w'", out, err)
+	}
+	kc.Exists = false
+	return nil
+}
+
+// exists checks whether the requested kind cluster exists.
+func (kc *KindCluster) exists() (bool, error) {
+	out, err := cmd.RunCommand("kind get clusters")
+	if err != nil {
+		return false, err
+	}
+	for _, name := range strings.Fields(out) {
+		if name == kc.Request.ClusterName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// create creates the kind cluster with a config file setting up its nodes.
+func (kc *KindCluster) create() error {
+	f, err := ioutil.TempFile("", "kind-config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed creating the kind config file: '%w'", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(clusterConfig(kc.Request.NodeCount)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed writing the kind config file: '%w'", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed writing the kind config file: '%w'", err)
+	}
+
+	args := []string{"create", "cluster", "--config", f.Name(), "--wait", waitTimeout.String()}
+	if image := nodeImage(kc.Request.K8sVersion); image != "" {
+		args = append(args, "--image", image)
+	}
+	log.Printf("Creating kind cluster %q with %d worker node(s)", kc.Request.ClusterName, kc.Request.NodeCount)
+	if out, err := cmd.RunCommand(kc.command(args...)); err != nil {
+		return fmt.Errorf("failed creating kind cluster %q: %q, '%w'", kc.Request.ClusterName, out, err)
+	}
+	return nil
+}
+
+// command returns the kind command line with the given args, for the
+// requested cluster and kubeconfig.
+func (kc *KindCluster) command(args ...string) string {
+	args = append([]string{"kind"}, args...)
+	args = append(args, "--name", kc.Request.ClusterName)
+	if kc.Request.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", kc.Request.Kubeconfig)
+	}
+	return shell.Join(args...)
+}
+
+// clusterConfig returns the kind config for a cluster with one control plane
+// node and nodeCount worker nodes.
+func clusterConfig(nodeCount int) string {
+	var sb strings.Builder
+	sb.WriteString("kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: control-plane\n")
+	for i := 0; i < nodeCount; i++ {
+		sb.WriteString("- role: worker\n")
+	}
+	return sb.String()
+}
+
+// nodeImage returns the node image for the Kubernetes version, or an empty
+// string to use the default image of kind.
+func nodeImage(k8sVersion string) string {
+	if k8sVersion == "" {
+		return ""
+	}
+	return nodeImageRepo + ":v" + strings.TrimPrefix(k8sVersion, "v")
+}
+
+// serverVersion returns the Kubernetes version of the cluster, e.g. v1.19.1.
+func (kc *KindCluster) serverVersion() (string, error) {
+	kubectl := "kubectl version -o json"
+	if kc.Request.Kubeconfig != "" {
+		kubectl += " --kubeconfig " + shell.Join(kc.Request.Kubeconfig)
+	}
+	out, err := cmd.RunCommand(kubectl)
+	if err != nil {
+		return "", err
+	}
+	var version struct {
+		ServerVersion *struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal([]byte(out), &version); err != nil {
+		return "", fmt.Errorf("failed parsing the output of %q: %w", kubectl, err)
+	}
+	if version.ServerVersion == nil {
+		return "", fmt.Errorf("no server version in the output of %q", kubectl)
+	}
+	return version.ServerVersion.GitVersion, nil
+}
+
+// saveMetaData will save the metadata with best effort.
+func (kc *KindCluster) saveMetaData() {
+	c, err := metautil.NewClient("")
+	if err != nil {
+		log.Printf("error creating the metautil client: %v", err)
+		return
+	}
+	log.Printf("Writing metadata to: %q", c.Path)
+	version := kc.Request.K8sVersion
+	if version == "" {
+		if version, err = kc.serverVersion(); err != nil {
+			log.Printf("error getting the cluster version: %v", err)
+		}
+	}
+	nodeCount := strconv.Itoa(kc.Request.NodeCount)
+	for key, val := range map[string]string{
+		providerKey:       kc.Provider(),
+		clusterNameKey:    kc.Request.ClusterName,
+		clusterVersionKey: strings.TrimSpace(version),
+		minNodesKey:       nodeCount,
+		maxNodesKey:       nodeCount,
+	} {
+		if err = c.Set(key, val); err != nil {
+			log.Printf("Failed saving metadata %q:%q: '%v'", key, val, err)
+		}
+	}
+	log.Println("Done writing metadata")
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	shell "github.com/kballard/go-shellquote"
+
+	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/pkg/metautil"
+)
+
+// fakeKind mocks cmd.RunCommand, and records the commands run and the kind
+// config used for creating the cluster.
+type fakeKind struct {
+	clusters  string
+	createErr error
+
+	commands []string
+	config   string
+}
+
+func (f *fakeKind) runCommand(cmdLine string, options ...cmd.Option) (string, error) {
+	args, _ := shell.Split(cmdLine)
+	for i, arg := range args {
+		// The config file is a temporary file, record its content instead
+		// of its random path.
+		if arg == "--config" && i+1 < len(args) {
+			content, _ := ioutil.ReadFile(args[i+1])
+			f.config = string(content)
+			args[i+1] = "CONFIG"
+		}
+	}
+	f.commands = append(f.commands, shell.Join(args...))
+	switch {
+	case cmdLine == "kind get clusters":
+		return f.clusters, nil
+	case len(args) > 2 && args[1] == "create":
+		return "", f.createErr
+	case len(args) > 1 && args[0] == "kubectl":
+		return kubectlVersionOutput, nil
+	}
+	return "", nil
+}
+
+// kubectlVersionOutput is the output of `kubectl version -o json`.
+const kubectlVersionOutput = `{
+  "clientVersion": {
+    "major": "1",
+    "minor": "28",
+    "gitVersion": "v1.28.2",
+    "gitCommit": "89a4ea3e1e4ddd7f7572286090359983e0387b2f",
+    "gitTreeState": "clean",
+    "buildDate": "2023-09-13T09:35:06Z",
+    "goVersion": "go1.20.8",
+    "compiler": "gc",
+    "platform": "linux/amd64"
+  },
+  "kustomizeVersion": "v5.0.4-0.20230601165947-6ce0bf390ce3",
+  "serverVersion": {
+    "major": "1",
+    "minor": "19",
+    "gitVersion": "v1.19.1",
+    "gitCommit": "206bcadf021e76c27513500ca24182692aabd17e",
+    "gitTreeState": "clean",
+    "buildDate": "2020-09-14T07:30:52Z",
+    "goVersion": "go1.15",
+    "compiler": "gc",
+    "platform": "linux/amd64"
+  }
+}
+`
+
+func mockRunCommand(f *fakeKind) func() {
+	oldFunc := cmd.RunCommand
+	cmd.RunCommand = f.runCommand
+	return func() {
+		cmd.RunCommand = oldFunc
+	}
+}
+
+func TestSetup(t *testing.T) {
+	datas := []struct {
+		name string
+		req  KindRequest
+		exp  KindRequest
+	}{
+		{"defaults", KindRequest{}, KindRequest{ClusterName: defaultClusterName, NodeCount: defaultNodeCount}},
+		{"custom", KindRequest{ClusterName: "a", NodeCount: 3, K8sVersion: "1.19.1"}, KindRequest{ClusterName: "a", NodeCount: 3, K8sVersion: "1.19.1"}},
+	}
+	for _, data := range datas {
+		t.Run(data.name, func(t *testing.T) {
+			kc := (&KindClient{}).Setup(data.req).(*KindCluster)
+			if diff := cmp.Diff(data.exp, *kc.Request); diff != "" {
+				t.Errorf("Setup request got(+) is different from wanted(-)\n%v", diff)
+			}
+			if kc.Provider() != "kind" {
+				t.Errorf("Provider = %q, want kind", kc.Provider())
+			}
+		})
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	datas := []struct {
+		name      string
+		req       KindRequest
+		clusters  string
+		createErr error
+		expCmds   []string
+		expConfig string
+		expErr    bool
+	}{{
+		name:     "create with defaults",
+		req:      KindRequest{},
+		clusters: "other\n",
+		expCmds: []string{
+			"kind get clusters",
+			"kind create cluster --config CONFIG --wait 5m0s --name e2e-cls",
+		},
+		expConfig: "kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: control-plane\n- role: worker\n",
+	}, {
+		name: "create with version and kubeconfig",
+		req:  KindRequest{ClusterName: "a", NodeCount: 3, K8sVersion: "v1.19.1", Kubeconfig: "/tmp/my config"},
+		expCmds: []string{
+			"kind get clusters",
+			"kind create cluster --config CONFIG --wait 5m0s --image kindest/node:v1.19.1 --name a --kubeconfig '/tmp/my config'",
+		},
+		expConfig: "kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: control-plane\n- role: worker\n- role: worker\n- role: worker\n",
+	}, {
+		name:     "use existing cluster",
+		req:      KindRequest{ClusterName: "a", Kubeconfig: "kubeconfig"},
+		clusters: "kind\na\n",
+		expCmds: []string{
+			"kind get clusters",
+			"kind export kubeconfig --name a --kubeconfig kubeconfig",
+		},
+	}, {
+		name:    "skip creation without existing cluster",
+		req:     KindRequest{SkipCreation: true},
+		expCmds: []string{"kind get clusters"},
+		expErr:  true,
+	}, {
+		name:      "creation failure",
+		req:       KindRequest{},
+		createErr: errors.New("no docker"),
+		expCmds: []string{
+			"kind get clusters",
+			"kind create cluster --config CONFIG --wait 5m0s --name e2e-cls",
+		},
+		expConfig: "kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n- role: control-plane\n- role: worker\n",
+		expErr:    true,
+	}}
+	for _, data := range datas {
+		t.Run(data.name, func(t *testing.T) {
+			f := &fakeKind{clusters: data.clusters, createErr: data.createErr}
+			defer mockRunCommand(f)()
+			kc := (&KindClient{}).Setup(data.req).(*KindCluster)
+			err := kc.Acquire()
+			if (err != nil) != data.expErr {
+				t.Errorf("Acquire err = %v, want error: %v", err, data.expErr)
+			}
+			if kc.Exists == data.expErr {
+				t.Errorf("Exists = %v after Acquire, want %v", kc.Exists, !data.expErr)
+			}
+			if diff := cmp.Diff(data.expCmds, f.commands); diff != "" {
+				t.Errorf("Commands got(+) is different from wanted(-)\n%v", diff)
+			}
+			if diff := cmp.Diff(data.expConfig, f.config); diff != "" {
+				t.Errorf("Kind config got(+) is different from wanted(-)\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	datas := []struct {
+		name     string
+		clusters string
+		expCmds  []string
+		expErr   bool
+	}{
+		{"existing cluster", "e2e-cls\n", []string{"kind get clusters", "kind delete cluster --name e2e-cls"}, false},
+		{"missing cluster", "", []string{"kind get clusters"}, true},
+	}
+	for _, data := range datas {
+		t.Run(data.name, func(t *testing.T) {
+			f := &fakeKind{clusters: data.clusters}
+			defer mockRunCommand(f)()
+			kc := (&KindClient{}).Setup(KindRequest{}).(*KindCluster)
+			if err := kc.Delete(); (err != nil) != data.expErr {
+				t.Errorf("Delete err = %v, want error: %v", err, data.expErr)
+			}
+			if diff := cmp.Diff(data.expCmds, f.commands); diff != "" {
+				t.Errorf("Commands got(+) is different from wanted(-)\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestSaveMetaData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oriArtifactDir := os.Getenv("ARTIFACTS")
+	defer os.Setenv("ARTIFACTS", oriArtifactDir)
+	os.Setenv("ARTIFACTS", dir)
+
+	f := &fakeKind{}
+	defer mockRunCommand(f)()
+	kc := (&KindClient{}).Setup(KindRequest{ClusterName: "a", NodeCount: 2, SaveMetaData: true}).(*KindCluster)
+	if err := kc.Acquire(); err != nil {
+		t.Fatalf("Unexpected error acquiring the cluster: '%v'", err)
+	}
+
+	if got, want := f.commands[len(f.commands)-1], "kubectl version -o json"; got != want {
+		t.Errorf("Command getting the cluster version = %q, want %q", got, want)
+	}
+
+	c, err := metautil.NewClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, exp := range map[string]string{
+		providerKey:       "kind",
+		clusterNameKey:    "a",
+		clusterVersionKey: "v1.19.1",
+		minNodesKey:       "2",
+		maxNodesKey:       "2",
+	} {
+		if got, err := c.Get(key); err != nil || got != exp {
+			t.Errorf("Metadata %q = %q, %v, want %q", key, got, err, exp)
+		}
+	}
+}
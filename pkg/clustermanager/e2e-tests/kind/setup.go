@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/gke"
+)
+
+// KindClient implements Client
+type KindClient struct {
+}
+
+// KindRequest contains all requests collected for cluster creation
+type KindRequest struct {
+	// ClusterName: name of the kind cluster
+	ClusterName string
+
+	// NodeCount: number of worker nodes, besides the control plane node
+	NodeCount int
+
+	// K8sVersion: Kubernetes version of the nodes, e.g. 1.19.1, defaults to
+	// the version the installed kind release is built for
+	K8sVersion string
+
+	// Kubeconfig: the kubeconfig file to write, defaults to $KUBECONFIG or
+	// $HOME/.kube/config
+	Kubeconfig string
+
+	// SkipCreation: skips cluster creation
+	SkipCreation bool
+
+	// SaveMetaData: save the meta data for the created cluster into a file
+	SaveMetaData bool
+}
+
+// KindCluster implements ClusterOperations
+type KindCluster struct {
+	Request *KindRequest
+	// Exists is true once the cluster is created, or found if it already exists
+	Exists bool
+}
+
+// Setup sets up a KindCluster client, takes KindRequest as parameter and
+// applies all defaults if not defined.
+func (kc *KindClient) Setup(r KindRequest) gke.ClusterOperations {
+	if r.ClusterName == "" {
+		r.ClusterName = defaultClusterName
+	}
+	if r.NodeCount == 0 {
+		r.NodeCount = defaultNodeCount
+	}
+	return &KindCluster{Request: &r}
+}
@@ -21,11 +21,11 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/pkg/gke"
 	"knative.dev/test-infra/pkg/helpers"
 	"knative.dev/test-infra/pkg/metautil"
 	"knative.dev/test-infra/pkg/prow"
@@ -38,17 +38,7 @@ const (
 	boskosAcquireDefaultTimeoutSeconds = 1200
 )
 
-var (
-	baseKubetest2Flags = []string{"gke", "--ignore-gcp-ssh-key=true", "--up", "-v=1"}
-
-	// If one of the error patterns below is matched, it would be recommended to
-	// retry creating the cluster in a different region.
-	// - stockout (https://github.com/knative/test-infra/issues/592)
-	retryableCreationErrors = []*regexp.Regexp{
-		regexp.MustCompile(`.*does not have enough resources available to fulfill.*`),
-		regexp.MustCompile(`.*only \d+ nodes out of \d+ have registered; this is likely due to Nodes failing to start correctly.*`),
-	}
-)
+var baseKubetest2Flags = []string{"gke", "--ignore-gcp-ssh-key=true", "--up", "-v=1"}
 
 // GKEClusterConfig are the supported configurations for creating a GKE cluster.
 type GKEClusterConfig struct {
@@ -149,8 +139,8 @@ func createGKEClusterWithRetries(kubetest2Flags []string, opts *Options, cc *GKE
 		var out string
 		out, err = runWithOutput(command)
 		if err != nil {
-			if isRetryableCreationError(out) {
-				log.Print("Cluster creation fails due to unpredictable reasons, will retry creating with different args")
+			if errType := gke.ClassifyCreationError(errors.New(out)); errType.RetryInAnotherLocation() {
+				log.Printf("Cluster creation fails in region %q due to %s, will retry creating with different args", region, errType)
 				continue
 			} else {
 				return err
@@ -167,16 +157,6 @@ func createGKEClusterWithRetries(kubetest2Flags []string, opts *Options, cc *GKE
 	return err
 }
 
-// isRetryableCreationError determines if cluster creation should be retried based on the error message.
-func isRetryableCreationError(errMsg string) bool {
-	for _, regx := range retryableCreationErrors {
-		if regx.MatchString(errMsg) {
-			return true
-		}
-	}
-	return false
-}
-
 // saveMetaData will save the metadata with best effort.
 func saveMetaData(cc *GKEClusterConfig, region string) {
 	cli, err := metautil.NewClient("")
@@ -18,7 +18,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"sort"
 
 	testPkg "knative.dev/test-infra/pkg/clustermanager/perf-tests/pkg"
 )
@@ -28,6 +30,9 @@ var (
 	isRecreate          bool
 	isReconcile         bool
 	isDelete            bool
+	isPlan              bool
+	isApply             bool
+	parallelism         int
 	gcpProjectName      string
 	repoName            string
 	benchmarkRootFolder string
@@ -42,10 +47,19 @@ func main() {
 	flag.BoolVar(&isRecreate, "recreate", false, "is recreate operation or not")
 	flag.BoolVar(&isReconcile, "reconcile", false, "is reconcile operation or not")
 	flag.BoolVar(&isDelete, "delete", false, "is delete operation or not")
+	flag.BoolVar(&isPlan, "plan", false, "print the changes needed to make clusters consistent with the benchmarks, without applying them")
+	flag.BoolVar(&isApply, "apply", false, "print and apply the changes needed to make clusters consistent with the benchmarks")
+	flag.IntVar(&parallelism, "parallelism", 4, "maximum number of clusters changed at the same time by --apply")
 	flag.Parse()
 
-	if (isRecreate && isReconcile) || (isRecreate && isDelete) || (isReconcile && isDelete) {
-		log.Fatal("--recreate, --reconcile and --delete are mutually exclusive")
+	operations := 0
+	for _, isOperation := range []bool{isRecreate, isReconcile, isDelete, isPlan, isApply} {
+		if isOperation {
+			operations++
+		}
+	}
+	if operations > 1 {
+		log.Fatal("--recreate, --reconcile, --delete, --plan and --apply are mutually exclusive")
 	}
 
 	client, err := testPkg.NewClient(gkeEnvironment)
@@ -58,17 +72,47 @@ func main() {
 			log.Fatalf("Failed recreating clusters for repo %q: %v", repoName, err)
 		}
 		log.Printf("Done with recreating clusters for repo %q", repoName)
+		logLocations(client)
 	case isReconcile:
 		if err := client.ReconcileClusters(gcpProjectName, repoName, benchmarkRootFolder); err != nil {
 			log.Fatalf("Failed reconciling clusters for repo %q: %v", repoName, err)
 		}
 		log.Printf("Done with reconciling clusters for repo %q", repoName)
+		logLocations(client)
 	case isDelete:
 		if err := client.DeleteClusters(gcpProjectName, repoName, benchmarkRootFolder); err != nil {
 			log.Fatalf("Failed deleting clusters for repo %q: %v", repoName, err)
 		}
 		log.Printf("Done with deleting clusters for repo %q", repoName)
+	case isPlan, isApply:
+		plan, err := client.Plan(gcpProjectName, repoName, benchmarkRootFolder)
+		if err != nil {
+			log.Fatalf("Failed planning clusters for repo %q: %v", repoName, err)
+		}
+		fmt.Println(plan)
+		if isPlan {
+			return
+		}
+		if err := client.Apply(plan, parallelism); err != nil {
+			log.Fatalf("Failed applying the plan for repo %q: %v", repoName, err)
+		}
+		log.Printf("Done with applying the plan for repo %q", repoName)
+		logLocations(client)
 	default:
-		log.Fatal("One operation must be specified, either recreate, reconcile or delete")
+		log.Fatal("One operation must be specified, either recreate, reconcile, delete, plan or apply")
+	}
+}
+
+// logLocations logs where each benchmark cluster is running, as clusters can
+// land in backup locations.
+func logLocations(client *testPkg.Client) {
+	locations := client.Locations()
+	names := make([]string, 0, len(locations))
+	for name := range locations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("Cluster %q is running in %q", name, locations[name])
 	}
 }
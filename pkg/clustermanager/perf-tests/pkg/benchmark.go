@@ -17,14 +17,16 @@ limitations under the License.
 package pkg
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	yaml "gopkg.in/yaml.v2"
+
+	"knative.dev/test-infra/pkg/helpers"
 )
 
 const (
@@ -40,7 +42,6 @@ const (
 )
 
 // backupLocations are used in retrying cluster creation, if stockout happens in one location.
-// TODO(chizhg): it's currently not used, use it in the cluster creation retry logic.
 var backupLocations = []string{"us-west1", "us-west2", "us-east1"}
 
 // GKECluster saves the config information for the GKE cluster
@@ -85,21 +86,27 @@ func benchmarkClusters(repo, benchmarkRoot string) (map[string]ClusterConfig, er
 		return clusters, err
 	}
 
+	errs := make([]error, 0)
 	for _, benchmarkName := range benchmarkNames {
-		clusterConfig := clusterConfigForBenchmark(benchmarkName, benchmarkRoot)
+		clusterConfig, err := clusterConfigForBenchmark(benchmarkName, benchmarkRoot)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
 		clusterName := clusterNameForBenchmark(benchmarkName, repo)
 		clusters[clusterName] = clusterConfig
 	}
 
-	return clusters, nil
+	return clusters, helpers.CombineErrors(errs)
 }
 
 // clusterConfigForBenchmark returns the cluster config for the given benchmark.
 //
 // Under each benchmark folder, we can put a cluster.yaml file that follows the scheme we define
 // in ClusterConfig struct, in which we specify configuration of the cluster that we use to run the benchmark.
-// If there is no such config file, or the config file is malformed, default config will be used.
-func clusterConfigForBenchmark(benchmarkName, benchmarkRoot string) ClusterConfig {
+// If there is no such config file, default config will be used. If the config file is malformed, or has
+// unknown fields or invalid values, an error will be returned.
+func clusterConfigForBenchmark(benchmarkName, benchmarkRoot string) (ClusterConfig, error) {
 	gkeCluster := GKECluster{
 		Config: ClusterConfig{
 			Location:  defaultLocation,
@@ -110,18 +117,34 @@ func clusterConfigForBenchmark(benchmarkName, benchmarkRoot string) ClusterConfi
 	}
 
 	configFile := filepath.Join(benchmarkRoot, benchmarkName, clusterConfigFile)
-	if fileExists(configFile) {
-		contents, err := ioutil.ReadFile(configFile)
-		if err == nil {
-			if err := yaml.Unmarshal(contents, &gkeCluster); err != nil {
-				log.Printf("WARNING: cannot parse the config file %q, default config will be used", configFile)
-			}
-		} else {
-			log.Printf("WARNING: cannot read the config file %q, default config will be used", configFile)
-		}
+	if !fileExists(configFile) {
+		return gkeCluster.Config, nil
+	}
+	contents, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return ClusterConfig{}, fmt.Errorf("cannot read the config file %q: %w", configFile, err)
+	}
+	if err := yaml.UnmarshalStrict(contents, &gkeCluster); err != nil {
+		return ClusterConfig{}, fmt.Errorf("cannot parse the config file %q: %w", configFile, err)
 	}
+	if err := gkeCluster.Config.validate(); err != nil {
+		return ClusterConfig{}, fmt.Errorf("invalid config file %q: %w", configFile, err)
+	}
+	return gkeCluster.Config, nil
+}
 
-	return gkeCluster.Config
+// validate checks the values of the cluster config after defaults are applied.
+func (c ClusterConfig) validate() error {
+	if c.Location == "" {
+		return errors.New("location must not be empty")
+	}
+	if c.NodeCount <= 0 {
+		return fmt.Errorf("nodeCount must be positive, got %d", c.NodeCount)
+	}
+	if c.NodeType == "" {
+		return errors.New("nodeType must not be empty")
+	}
+	return nil
 }
 
 // clusterNameForBenchmark prepends repo name to the benchmark name, and use it as the cluster name.
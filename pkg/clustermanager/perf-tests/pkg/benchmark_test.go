@@ -17,6 +17,7 @@ limitations under the License.
 package pkg
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -58,6 +59,7 @@ func TestClusterConfigForBenchmark(t *testing.T) {
 		benchmarkRoot         string
 		benchmarkName         string
 		expectedClusterConfig ClusterConfig
+		expectedError         bool
 	}{{
 		benchmarkRoot: "testdir",
 		benchmarkName: "test-benchmark1",
@@ -78,10 +80,26 @@ func TestClusterConfigForBenchmark(t *testing.T) {
 		benchmarkName: "test-benchmark4",
 		expectedClusterConfig: ClusterConfig{
 			Location: defaultLocation, NodeCount: defaultNodeCount, NodeType: defaultNodeType, Addons: defaultAddons},
+	}, {
+		benchmarkRoot: "testdir-invalid",
+		benchmarkName: "malformed",
+		expectedError: true,
+	}, {
+		benchmarkRoot: "testdir-invalid",
+		benchmarkName: "unknown-field",
+		expectedError: true,
+	}, {
+		benchmarkRoot: "testdir-invalid",
+		benchmarkName: "negative-node-count",
+		expectedError: true,
 	}}
 
 	for _, tc := range testCases {
-		clusterConfig := clusterConfigForBenchmark(tc.benchmarkName, tc.benchmarkRoot)
+		clusterConfig, err := clusterConfigForBenchmark(tc.benchmarkName, tc.benchmarkRoot)
+		if tc.expectedError != (err != nil) {
+			t.Fatalf("clusterConfigForBenchmark(%q, %q) returns error %v, want error: %v",
+				tc.benchmarkName, tc.benchmarkRoot, err, tc.expectedError)
+		}
 		if diff := cmp.Diff(tc.expectedClusterConfig, clusterConfig); diff != "" {
 			t.Fatalf("clusterConfigForBenchmark(%q, %q) returns wrong result (-want +got):\n%s",
 				tc.benchmarkName, tc.benchmarkRoot, diff)
@@ -89,6 +107,27 @@ func TestClusterConfigForBenchmark(t *testing.T) {
 	}
 }
 
+func TestBenchmarkClusters(t *testing.T) {
+	clusters, err := benchmarkClusters("serving", "testdir")
+	if err != nil {
+		t.Fatalf("Unexpected error getting cluster configs: '%v'", err)
+	}
+	if len(clusters) != 4 {
+		t.Errorf("Got %d cluster configs, want 4", len(clusters))
+	}
+
+	// All invalid cluster configs are reported.
+	_, err = benchmarkClusters("serving", "testdir-invalid")
+	if err == nil {
+		t.Fatal("Expected an error getting invalid cluster configs, got nil")
+	}
+	for _, name := range []string{"malformed", "unknown-field", "negative-node-count"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Error %q doesn't report the invalid config of %q", err, name)
+		}
+	}
+}
+
 func TestClusterNameForBenchmark(t *testing.T) {
 	repo, benchmarkName, expectedName := "serving", "load-test", "serving--load-test"
 	if gotName := clusterNameForBenchmark(benchmarkName, repo); gotName != expectedName {
@@ -40,17 +40,31 @@ const (
 	statusProvisioning = "PROVISIONING"
 	statusRunning      = "RUNNING"
 	statusStopping     = "STOPPING"
+
+	// requestedLocationLabel is the resource label recording the location
+	// requested in the cluster config, as the cluster can land in a backup
+	// location if the requested one is out of resources.
+	requestedLocationLabel = "requested-location"
 )
 
 // Extra configurations we want to support for cluster creation request.
 var (
 	enableWorkloadIdentity = flag.Bool("enable-workload-identity", false, "whether to enable Workload Identity")
 	serviceAccount         = flag.String("service-account", "", "service account that will be used on this cluster")
+
+	// Unknown errors are only retried in the requested location by default,
+	// as they aren't known to be fixed by changing the location.
+	failoverOnTransientErrors = flag.Bool("failover-on-transient-errors", false,
+		"whether to retry creating the cluster in the backup locations if transient or unknown errors persist in the requested location")
 )
 
 // Client is defined for perf tests operations.
 type Client struct {
 	ops gke.SDKOperations
+
+	// map of cluster name: location where it's running
+	locations map[string]string
+	mutex     sync.Mutex
 }
 
 // NewClient will create a new Client.
@@ -71,6 +85,29 @@ func NewClient(environment string) (*Client, error) {
 	return client, nil
 }
 
+// Locations returns where each benchmark cluster handled by the client is
+// running, which can be a backup location if the requested one was out of
+// resources.
+func (gc *Client) Locations() map[string]string {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+	locations := make(map[string]string, len(gc.locations))
+	for name, location := range gc.locations {
+		locations[name] = location
+	}
+	return locations
+}
+
+// recordLocation records where a benchmark cluster is running.
+func (gc *Client) recordLocation(name, location string) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+	if gc.locations == nil {
+		gc.locations = make(map[string]string)
+	}
+	gc.locations[name] = location
+}
+
 // RecreateClusters will delete and recreate the existing clusters, it will also create the clusters if they do
 // not exist for the corresponding benchmarks.
 func (gc *Client) RecreateClusters(gcpProject, repo, benchmarkRoot string) error {
@@ -186,17 +223,12 @@ func (gc *Client) handleExistingClusterHelper(
 		return nil
 	}
 
-	curtNodeCount := cluster.CurrentNodeCount
-	// if it's a regional cluster, the nodes will be in 3 zones. The CurrentNodeCount we get here is
-	// the total node count, so we'll need to divide with 3 to get the actual regional node count
-	if _, zone := gke.RegionZoneFromLoc(cluster.Location); zone == "" {
-		curtNodeCount /= 3
-	}
 	// if retainIfUnchanged is set to true, and the cluster config does not change, do nothing
 	// TODO(chizhg): also check the addons config
 	if configExists && retainIfUnchanged &&
-		curtNodeCount == config.NodeCount && cluster.Location == config.Location {
+		clusterNodeCount(cluster) == config.NodeCount && requestedLocation(cluster) == config.Location {
 		log.Printf("Cluster config is unchanged for %q, skip it", cluster.Name)
+		gc.recordLocation(cluster.Name, cluster.Location)
 		return nil
 	}
 
@@ -209,6 +241,14 @@ func (gc *Client) handleExistingClusterHelper(
 	return nil
 }
 
+// requestedLocation returns the location requested when creating the cluster.
+func requestedLocation(cluster container.Cluster) string {
+	if location, ok := cluster.ResourceLabels[requestedLocationLabel]; ok {
+		return location
+	}
+	return cluster.Location
+}
+
 // listClustersForRepo will list all the clusters under the gcpProject that belong to the given repo.
 func (gc *Client) listClustersForRepo(gcpProject, repo string) ([]container.Cluster, error) {
 	allClusters, err := gc.ops.ListClustersInProject(gcpProject)
@@ -246,8 +286,11 @@ func (gc *Client) deleteClusterWithRetries(gcpProject string, cluster container.
 	return nil
 }
 
-// createClusterWithRetries will create a new cluster with the given config,
-// and retry for a maximum of retryTimes if there is an error.
+// createClusterWithRetries will create a new cluster with the given config.
+// It will retry for a maximum of retryTimes in the same location if there
+// is a transient or unknown error, and fail over to the backup locations if
+// the location is out of resources or quota, or with
+// --failover-on-transient-errors if the retries are exhausted.
 // TODO(chizhg): maybe move it to clustermanager library.
 func (gc *Client) createClusterWithRetries(gcpProject, name string, config ClusterConfig) error {
 	log.Printf("Creating cluster %q under project %q with config %v", name, gcpProject, config)
@@ -264,31 +307,49 @@ func (gc *Client) createClusterWithRetries(gcpProject, name string, config Clust
 		Addons:                 addons,
 		EnableWorkloadIdentity: *enableWorkloadIdentity,
 		ServiceAccount:         *serviceAccount,
+		ResourceLabels:         map[string]string{requestedLocationLabel: config.Location},
 	}
 	creq, err := gke.NewCreateClusterRequest(req)
 	if err != nil {
 		return fmt.Errorf("cannot create cluster with request %v: %w", req, err)
 	}
 
-	region, zone := gke.RegionZoneFromLoc(config.Location)
-	for i := 0; i < retryTimes; i++ {
-		// TODO(chizhg): retry with different requests, based on the error type
-		if err = gc.ops.CreateCluster(gcpProject, region, zone, creq); err != nil {
+	locations := []string{config.Location}
+	for _, location := range backupLocations {
+		if location != config.Location {
+			locations = append(locations, location)
+		}
+	}
+	for _, location := range locations {
+		region, zone := gke.RegionZoneFromLoc(location)
+		for i := 0; i < retryTimes; i++ {
+			if err = gc.ops.CreateCluster(gcpProject, region, zone, creq); err == nil {
+				if location != config.Location {
+					log.Printf("Cluster %q landed in backup location %q instead of %q", name, location, config.Location)
+				}
+				gc.recordLocation(name, location)
+				return nil
+			}
 			// If the cluster is actually created in the end, recreating it with the same name will fail again for sure,
 			// so we need to delete the broken cluster before retry.
 			// It is a best-effort delete, and won't throw any errors if the deletion fails.
 			if cluster, _ := gc.ops.GetCluster(gcpProject, region, zone, name); cluster != nil {
 				gc.deleteClusterWithRetries(gcpProject, *cluster)
 			}
-		} else {
-			break
+			errType := gke.ClassifyCreationError(err)
+			log.Printf("Failed creating cluster %q in %q due to %s error: %v", name, location, errType, err)
+			if errType == gke.CreationErrorPermanent {
+				return fmt.Errorf("failed creating cluster %q in %q: %w", name, location, err)
+			}
+			if errType.RetryInAnotherLocation() {
+				break
+			}
+			if i == retryTimes-1 && !*failoverOnTransientErrors {
+				return fmt.Errorf("failed creating cluster %q in %q after %d retries: %w", name, location, retryTimes, err)
+			}
 		}
 	}
-	if err != nil {
-		return fmt.Errorf(
-			"failed creating cluster %q in %q after retrying %d times: %w",
-			name, config.Location, retryTimes, err)
-	}
-
-	return nil
+	return fmt.Errorf(
+		"failed creating cluster %q in any of %v: %w",
+		name, locations, err)
 }
@@ -17,12 +17,15 @@ limitations under the License.
 package pkg
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	container "google.golang.org/api/container/v1beta1"
+	"google.golang.org/api/googleapi"
 
 	"knative.dev/test-infra/pkg/gke"
 	gkeFake "knative.dev/test-infra/pkg/gke/fake"
@@ -41,11 +44,9 @@ func setupFakeGKEClient() Client {
 }
 
 func TestRecreateClusters(t *testing.T) {
-	allExpectedClusters := map[string]ClusterConfig{
-		clusterNameForBenchmark("test-benchmark1", fakeRepository): clusterConfigForBenchmark("test-benchmark1", testBenchmarkRoot),
-		clusterNameForBenchmark("test-benchmark2", fakeRepository): clusterConfigForBenchmark("test-benchmark2", testBenchmarkRoot),
-		clusterNameForBenchmark("test-benchmark3", fakeRepository): clusterConfigForBenchmark("test-benchmark3", testBenchmarkRoot),
-		clusterNameForBenchmark("test-benchmark4", fakeRepository): clusterConfigForBenchmark("test-benchmark4", testBenchmarkRoot),
+	allExpectedClusters, err := benchmarkClusters(fakeRepository, testBenchmarkRoot)
+	if err != nil {
+		t.Fatalf("Unexpected error getting cluster configs: '%v'", err)
 	}
 	testCases := []struct {
 		testName           string
@@ -165,11 +166,9 @@ func TestRecreateClusters(t *testing.T) {
 }
 
 func TestReconcileClusters(t *testing.T) {
-	reconciledClusters := map[string]ClusterConfig{
-		clusterNameForBenchmark("test-benchmark1", fakeRepository): clusterConfigForBenchmark("test-benchmark1", testBenchmarkRoot),
-		clusterNameForBenchmark("test-benchmark2", fakeRepository): clusterConfigForBenchmark("test-benchmark2", testBenchmarkRoot),
-		clusterNameForBenchmark("test-benchmark3", fakeRepository): clusterConfigForBenchmark("test-benchmark3", testBenchmarkRoot),
-		clusterNameForBenchmark("test-benchmark4", fakeRepository): clusterConfigForBenchmark("test-benchmark4", testBenchmarkRoot),
+	reconciledClusters, err := benchmarkClusters(fakeRepository, testBenchmarkRoot)
+	if err != nil {
+		t.Fatalf("Unexpected error getting cluster configs: '%v'", err)
 	}
 
 	testCases := []struct {
@@ -391,6 +390,128 @@ func TestDeleteClusters(t *testing.T) {
 	}
 }
 
+func TestReconcileClustersFailover(t *testing.T) {
+	stockout := errors.New(`Error 400: The zone "us-central1-a" does not have enough resources available to fulfill the request.`)
+	invalid := &googleapi.Error{Code: http.StatusBadRequest, Message: "Cluster.initial_node_count must be greater than zero."}
+
+	client := setupFakeGKEClient()
+	fakeOps := client.ops.(*gkeFake.GKESDKClient)
+	fakeOps.CreationErrors["us-central1"] = stockout
+	if err := client.ReconcileClusters(fakeProject, fakeRepository, testBenchmarkRoot); err != nil {
+		t.Fatalf("Unexpected error reconciling clusters: '%v'", err)
+	}
+	expLocations := map[string]string{
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): "us-west1",
+		clusterNameForBenchmark("test-benchmark2", fakeRepository): "us-west1",
+		clusterNameForBenchmark("test-benchmark3", fakeRepository): "us-west1",
+		clusterNameForBenchmark("test-benchmark4", fakeRepository): "us-west1",
+	}
+	if diff := cmp.Diff(expLocations, client.Locations()); diff != "" {
+		t.Errorf("Cluster locations are wrong (-want +got):\n%s", diff)
+	}
+	cluster, err := fakeOps.GetCluster(fakeProject, "us-west1", "", clusterNameForBenchmark("test-benchmark2", fakeRepository))
+	if err != nil {
+		t.Fatalf("Cannot get the cluster created in the backup location: '%v'", err)
+	}
+	if got := cluster.ResourceLabels[requestedLocationLabel]; got != "us-central1" {
+		t.Errorf("Requested location label = %q, want %q", got, "us-central1")
+	}
+
+	// Clusters that landed in a backup location are kept when reconciling
+	// again, creating clusters would fail anywhere now.
+	fakeOps.CreationErrors["us-west1"] = invalid
+	client = Client{ops: fakeOps}
+	if err := client.ReconcileClusters(fakeProject, fakeRepository, testBenchmarkRoot); err != nil {
+		t.Fatalf("Unexpected error reconciling clusters again: '%v'", err)
+	}
+	if diff := cmp.Diff(expLocations, client.Locations()); diff != "" {
+		t.Errorf("Cluster locations are wrong after reconciling again (-want +got):\n%s", diff)
+	}
+
+	// Permanent errors are not retried in backup locations.
+	client = setupFakeGKEClient()
+	fakeOps = client.ops.(*gkeFake.GKESDKClient)
+	fakeOps.CreationErrors["us-central1"] = invalid
+	if err := client.ReconcileClusters(fakeProject, fakeRepository, testBenchmarkRoot); err == nil {
+		t.Fatal("Expected an error reconciling clusters, got nil")
+	}
+	expLocations = map[string]string{
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): "us-west1",
+	}
+	if diff := cmp.Diff(expLocations, client.Locations()); diff != "" {
+		t.Errorf("Cluster locations are wrong after permanent errors (-want +got):\n%s", diff)
+	}
+}
+
+func TestReconcileClustersTransientErrors(t *testing.T) {
+	unavailable := &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "The service is currently unavailable."}
+	defer func(failover bool) { *failoverOnTransientErrors = failover }(*failoverOnTransientErrors)
+
+	// Transient errors are only retried in the requested location by default.
+	*failoverOnTransientErrors = false
+	client := setupFakeGKEClient()
+	fakeOps := client.ops.(*gkeFake.GKESDKClient)
+	fakeOps.CreationErrors["us-central1"] = unavailable
+	if err := client.ReconcileClusters(fakeProject, fakeRepository, testBenchmarkRoot); err == nil {
+		t.Fatal("Expected an error reconciling clusters, got nil")
+	}
+	expLocations := map[string]string{
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): "us-west1",
+	}
+	if diff := cmp.Diff(expLocations, client.Locations()); diff != "" {
+		t.Errorf("Cluster locations are wrong (-want +got):\n%s", diff)
+	}
+
+	*failoverOnTransientErrors = true
+	client = setupFakeGKEClient()
+	fakeOps = client.ops.(*gkeFake.GKESDKClient)
+	fakeOps.CreationErrors["us-central1"] = unavailable
+	if err := client.ReconcileClusters(fakeProject, fakeRepository, testBenchmarkRoot); err != nil {
+		t.Fatalf("Unexpected error reconciling clusters: '%v'", err)
+	}
+	expLocations = map[string]string{
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): "us-west1",
+		clusterNameForBenchmark("test-benchmark2", fakeRepository): "us-west1",
+		clusterNameForBenchmark("test-benchmark3", fakeRepository): "us-west1",
+		clusterNameForBenchmark("test-benchmark4", fakeRepository): "us-west1",
+	}
+	if diff := cmp.Diff(expLocations, client.Locations()); diff != "" {
+		t.Errorf("Cluster locations are wrong with --failover-on-transient-errors (-want +got):\n%s", diff)
+	}
+}
+
+func TestReconcileClustersNodeCount(t *testing.T) {
+	client := setupFakeGKEClient()
+	createFakeClusters(t, &client, map[string]ClusterConfig{
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): {Location: "us-west1", NodeCount: 4, NodeType: "e2-standard-8"},
+		clusterNameForBenchmark("test-benchmark3", fakeRepository): {Location: "us-central1", NodeCount: 2, NodeType: defaultNodeType},
+	})
+	kept, _ := client.ops.GetCluster(fakeProject, "us-west1", "", clusterNameForBenchmark("test-benchmark1", fakeRepository))
+	// The node count of the config is compared with the maximum size of the
+	// node pool, not with the nodes currently running, which the autoscaler
+	// or a node failure can make lower.
+	kept.CurrentNodeCount = 3
+	changed, _ := client.ops.GetCluster(fakeProject, "us-central1", "", clusterNameForBenchmark("test-benchmark3", fakeRepository))
+	changed.CurrentNodeCount = 3
+
+	if err := client.ReconcileClusters(fakeProject, fakeRepository, testBenchmarkRoot); err != nil {
+		t.Fatalf("Unexpected error reconciling clusters: '%v'", err)
+	}
+	if cluster, _ := client.ops.GetCluster(fakeProject, "us-west1", "", kept.Name); cluster != kept {
+		t.Errorf("Cluster %q was recreated, although its maximum node count is unchanged", kept.Name)
+	}
+	cluster, err := client.ops.GetCluster(fakeProject, "us-central1", "", changed.Name)
+	if err != nil {
+		t.Fatalf("Cannot get cluster %q: '%v'", changed.Name, err)
+	}
+	if cluster == changed {
+		t.Errorf("Cluster %q was kept, although its maximum node count changed", changed.Name)
+	}
+	if got := cluster.NodePools[0].Autoscaling.MaxNodeCount; got != 1 {
+		t.Errorf("Cluster %q has a maximum node count of %d, want 1", changed.Name, got)
+	}
+}
+
 // Return addons as a string slice for the given cluster.
 // In this test we only use istio so only checking istio is enough here.
 func getAddonsForCluster(cluster *container.Cluster) string {
@@ -0,0 +1,251 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	container "google.golang.org/api/container/v1beta1"
+
+	"knative.dev/test-infra/pkg/gke"
+	"knative.dev/test-infra/pkg/helpers"
+)
+
+// ActionType is the type of change an action makes to a benchmark cluster.
+type ActionType string
+
+const (
+	// ActionCreate creates the cluster for a benchmark that doesn't have one.
+	ActionCreate ActionType = "create"
+	// ActionResize resizes in place the cluster of a benchmark whose node
+	// count is the only change to its cluster config.
+	ActionResize ActionType = "resize"
+	// ActionUpdate recreates the cluster of a benchmark whose location or
+	// node type changed, as they cannot be changed in place.
+	ActionUpdate ActionType = "update"
+	// ActionDelete deletes the cluster of a benchmark that no longer exists.
+	ActionDelete ActionType = "delete"
+)
+
+// Action is a change to make to a benchmark cluster.
+type Action struct {
+	Type        ActionType
+	ClusterName string
+	// Cluster is the existing cluster, it's nil for create actions.
+	Cluster *container.Cluster
+	// Config is the desired cluster config, it's empty for delete actions.
+	Config ClusterConfig
+	// Changes describes the differences between the existing cluster and
+	// the desired config for resize and update actions.
+	Changes []string
+}
+
+// Plan is the list of actions needed to make the clusters of a repo
+// consistent with the cluster configs of its benchmarks.
+type Plan struct {
+	Project string
+	Repo    string
+	Actions []Action
+}
+
+// Plan diffs the cluster configs of the benchmarks under benchmarkRoot
+// against the clusters of the repo in gcpProject, and returns the actions
+// needed to make them consistent, without changing anything.
+//
+// Clusters that are being created or deleted are skipped, as the job doing
+// it will handle them properly.
+func (gc *Client) Plan(gcpProject, repo, benchmarkRoot string) (*Plan, error) {
+	clusterConfigs, err := benchmarkClusters(repo, benchmarkRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting cluster configs for benchmarks in repo %q: %w", repo, err)
+	}
+	curtClusters, err := gc.listClustersForRepo(gcpProject, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting clusters for the repo %q: %w", repo, err)
+	}
+
+	plan := &Plan{Project: gcpProject, Repo: repo}
+	for i := range curtClusters {
+		cluster := curtClusters[i]
+		config, configExists := clusterConfigs[cluster.Name]
+		// remove the cluster from clusterConfigs as it's already been handled
+		delete(clusterConfigs, cluster.Name)
+		if cluster.Status == statusProvisioning || cluster.Status == statusStopping {
+			log.Printf("Cluster %q is being handled by another job, skip it", cluster.Name)
+			continue
+		}
+		if !configExists {
+			plan.Actions = append(plan.Actions, Action{Type: ActionDelete, ClusterName: cluster.Name, Cluster: &cluster})
+			continue
+		}
+		if changes := clusterChanges(cluster, config); len(changes) != 0 {
+			actionType := ActionUpdate
+			if resizable(cluster, config) {
+				actionType = ActionResize
+			}
+			plan.Actions = append(plan.Actions, Action{
+				Type: actionType, ClusterName: cluster.Name, Cluster: &cluster, Config: config, Changes: changes})
+		}
+	}
+	for name, config := range clusterConfigs {
+		plan.Actions = append(plan.Actions, Action{Type: ActionCreate, ClusterName: name, Config: config})
+	}
+	sort.Slice(plan.Actions, func(i, j int) bool {
+		return plan.Actions[i].ClusterName < plan.Actions[j].ClusterName
+	})
+	return plan, nil
+}
+
+// clusterChanges returns the differences between the cluster and its config.
+// TODO(chizhg): also check the addons config
+func clusterChanges(cluster container.Cluster, config ClusterConfig) []string {
+	var changes []string
+	if location := requestedLocation(cluster); location != config.Location {
+		changes = append(changes, fmt.Sprintf("location %q -> %q", location, config.Location))
+	}
+	if nodeCount := clusterNodeCount(cluster); nodeCount != config.NodeCount {
+		changes = append(changes, fmt.Sprintf("node count %d -> %d", nodeCount, config.NodeCount))
+	}
+	if nodeType := clusterNodeType(cluster); nodeType != config.NodeType {
+		changes = append(changes, fmt.Sprintf("node type %q -> %q", nodeType, config.NodeType))
+	}
+	return changes
+}
+
+// resizable tells whether the cluster can be changed to its config by
+// resizing its default node pool, i.e. only its node count changed.
+func resizable(cluster container.Cluster, config ClusterConfig) bool {
+	return len(cluster.NodePools) != 0 &&
+		requestedLocation(cluster) == config.Location &&
+		clusterNodeType(cluster) == config.NodeType
+}
+
+// clusterNodeCount returns the number of nodes per zone of the cluster.
+func clusterNodeCount(cluster container.Cluster) int64 {
+	// the benchmark clusters are created with a fixed size default node pool
+	if len(cluster.NodePools) != 0 && cluster.NodePools[0].Autoscaling != nil {
+		return cluster.NodePools[0].Autoscaling.MaxNodeCount
+	}
+	curtNodeCount := cluster.CurrentNodeCount
+	// if it's a regional cluster, the nodes will be in 3 zones. The CurrentNodeCount we get here is
+	// the total node count, so we'll need to divide with 3 to get the actual regional node count
+	if _, zone := gke.RegionZoneFromLoc(cluster.Location); zone == "" {
+		curtNodeCount /= 3
+	}
+	return curtNodeCount
+}
+
+// clusterNodeType returns the machine type of the default node pool of the cluster.
+func clusterNodeType(cluster container.Cluster) string {
+	if len(cluster.NodePools) != 0 && cluster.NodePools[0].Config != nil {
+		return cluster.NodePools[0].Config.MachineType
+	}
+	return ""
+}
+
+// String returns the human readable actions of the plan.
+func (p *Plan) String() string {
+	if len(p.Actions) == 0 {
+		return fmt.Sprintf("No changes for clusters of repo %q in project %q", p.Repo, p.Project)
+	}
+	counts := make(map[ActionType]int)
+	for _, action := range p.Actions {
+		counts[action.Type]++
+	}
+	lines := []string{fmt.Sprintf("Plan for clusters of repo %q in project %q: %d to create, %d to resize, %d to update, %d to delete",
+		p.Repo, p.Project, counts[ActionCreate], counts[ActionResize], counts[ActionUpdate], counts[ActionDelete])}
+	for _, action := range p.Actions {
+		lines = append(lines, "  "+action.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String returns the human readable action.
+func (a Action) String() string {
+	switch a.Type {
+	case ActionCreate:
+		return fmt.Sprintf("+ create %s in %s with %d %s node(s)",
+			a.ClusterName, a.Config.Location, a.Config.NodeCount, a.Config.NodeType)
+	case ActionResize:
+		return fmt.Sprintf("~ resize %s in %s: %s", a.ClusterName, a.Cluster.Location, strings.Join(a.Changes, ", "))
+	case ActionUpdate:
+		return fmt.Sprintf("~ update %s in %s: %s", a.ClusterName, a.Cluster.Location, strings.Join(a.Changes, ", "))
+	case ActionDelete:
+		return fmt.Sprintf("- delete %s in %s", a.ClusterName, a.Cluster.Location)
+	}
+	return fmt.Sprintf("? %s %s", a.Type, a.ClusterName)
+}
+
+// Apply executes the actions of the plan, running at most parallelism
+// actions at the same time. It keeps going if an action fails, and returns
+// the combined errors of all failed actions.
+func (gc *Client) Apply(plan *Plan, parallelism int) error {
+	if parallelism <= 0 {
+		return fmt.Errorf("parallelism must be positive, got %d", parallelism)
+	}
+
+	sem := make(chan struct{}, parallelism)
+	errCh := make(chan error, len(plan.Actions))
+	wg := sync.WaitGroup{}
+	for i := range plan.Actions {
+		action := plan.Actions[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := gc.applyAction(plan.Project, action); err != nil {
+				errCh <- fmt.Errorf("failed to %s cluster %q: %w", action.Type, action.ClusterName, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	errs := make([]error, 0, len(errCh))
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// applyAction executes a single action of a plan.
+func (gc *Client) applyAction(gcpProject string, action Action) error {
+	switch action.Type {
+	case ActionCreate:
+		return gc.createClusterWithRetries(gcpProject, action.ClusterName, action.Config)
+	case ActionResize:
+		region, zone := gke.RegionZoneFromLoc(action.Cluster.Location)
+		return gc.ops.ResizeNodePool(gcpProject, region, zone, action.ClusterName, action.Cluster.NodePools[0].Name, action.Config.NodeCount)
+	case ActionUpdate:
+		if err := gc.deleteClusterWithRetries(gcpProject, *action.Cluster); err != nil {
+			return err
+		}
+		return gc.createClusterWithRetries(gcpProject, action.ClusterName, action.Config)
+	case ActionDelete:
+		return gc.deleteClusterWithRetries(gcpProject, *action.Cluster)
+	}
+	return errors.New("unknown action type " + string(action.Type))
+}
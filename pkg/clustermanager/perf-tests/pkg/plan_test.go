@@ -0,0 +1,188 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	container "google.golang.org/api/container/v1beta1"
+
+	"knative.dev/test-infra/pkg/gke"
+	gkeFake "knative.dev/test-infra/pkg/gke/fake"
+)
+
+// concurrencyOps records the maximum number of clusters created at the same time.
+type concurrencyOps struct {
+	gke.SDKOperations
+	running, maxRunning int
+	mutex               sync.Mutex
+}
+
+func (o *concurrencyOps) CreateCluster(project, region, zone string, req *container.CreateClusterRequest) error {
+	o.mutex.Lock()
+	o.running++
+	if o.running > o.maxRunning {
+		o.maxRunning = o.running
+	}
+	o.mutex.Unlock()
+	defer func() {
+		o.mutex.Lock()
+		o.running--
+		o.mutex.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	return o.SDKOperations.CreateCluster(project, region, zone, req)
+}
+
+func createFakeClusters(t *testing.T, client *Client, clusters map[string]ClusterConfig) {
+	for name, config := range clusters {
+		region, zone := gke.RegionZoneFromLoc(config.Location)
+		creq, err := gke.NewCreateClusterRequest(&gke.Request{
+			ClusterName: name,
+			MinNodes:    config.NodeCount,
+			MaxNodes:    config.NodeCount,
+			NodeType:    config.NodeType,
+		})
+		if err != nil {
+			t.Fatalf("Failed building the request for cluster %q: '%v'", name, err)
+		}
+		if err := client.ops.CreateCluster(fakeProject, region, zone, creq); err != nil {
+			t.Fatalf("Failed creating cluster %q: '%v'", name, err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	client := setupFakeGKEClient()
+	createFakeClusters(t, &client, map[string]ClusterConfig{
+		"unrelated-cluster": {Location: "us-central1", NodeCount: 3, NodeType: "n1-standard-4"},
+		// only node count changed
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): {Location: "us-west1", NodeCount: 2, NodeType: "e2-standard-8"},
+		// node count and type changed
+		clusterNameForBenchmark("test-benchmark2", fakeRepository): {Location: "us-central1", NodeCount: 2, NodeType: "n1-standard-4"},
+		// being created by another job
+		clusterNameForBenchmark("test-benchmark3", fakeRepository): {Location: "us-west1", NodeCount: 1, NodeType: "n1-standard-4"},
+		// benchmark deleted
+		clusterNameForBenchmark("random-cluster", fakeRepository): {Location: "us-east1", NodeCount: 1, NodeType: defaultNodeType},
+	})
+	cluster, _ := client.ops.GetCluster(fakeProject, "us-west1", "", clusterNameForBenchmark("test-benchmark3", fakeRepository))
+	cluster.Status = statusProvisioning
+
+	plan, err := client.Plan(fakeProject, fakeRepository, testBenchmarkRoot)
+	if err != nil {
+		t.Fatalf("Unexpected error planning: '%v'", err)
+	}
+	got := make([]string, 0, len(plan.Actions))
+	for _, action := range plan.Actions {
+		got = append(got, action.String())
+	}
+	want := []string{
+		"- delete r--random-cluster in us-east1",
+		"~ resize r--test-benchmark1 in us-west1: node count 2 -> 4",
+		`~ update r--test-benchmark2 in us-central1: node count 2 -> 1, node type "n1-standard-4" -> "e2-standard-4"`,
+		"+ create r--test-benchmark4 in us-central1 with 1 e2-standard-4 node(s)",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Plan actions are wrong (-want +got):\n%s", diff)
+	}
+	if !strings.HasPrefix(plan.String(), `Plan for clusters of repo "r" in project "p": 1 to create, 1 to resize, 1 to update, 1 to delete`) {
+		t.Errorf("Plan summary is wrong:\n%s", plan)
+	}
+
+	// Planning doesn't change anything.
+	clusters, _ := client.ops.ListClustersInProject(fakeProject)
+	if len(clusters) != 5 {
+		t.Errorf("Got %d clusters after planning, want 5", len(clusters))
+	}
+}
+
+func TestPlanInvalidConfig(t *testing.T) {
+	client := setupFakeGKEClient()
+	createFakeClusters(t, &client, map[string]ClusterConfig{
+		clusterNameForBenchmark("malformed", fakeRepository): {Location: "us-central1", NodeCount: 1, NodeType: defaultNodeType},
+	})
+	if _, err := client.Plan(fakeProject, fakeRepository, "testdir-invalid"); err == nil {
+		t.Fatal("Expected an error planning with invalid cluster configs, got nil")
+	}
+}
+
+func TestApply(t *testing.T) {
+	client := setupFakeGKEClient()
+	createFakeClusters(t, &client, map[string]ClusterConfig{
+		clusterNameForBenchmark("test-benchmark1", fakeRepository): {Location: "us-central1", NodeCount: 2, NodeType: "n1-standard-4"},
+		clusterNameForBenchmark("test-benchmark4", fakeRepository): {Location: "us-central1", NodeCount: 3, NodeType: defaultNodeType},
+		clusterNameForBenchmark("random-cluster", fakeRepository):  {Location: "us-east1", NodeCount: 1, NodeType: defaultNodeType},
+	})
+	resized, _ := client.ops.GetCluster(fakeProject, "us-central1", "", clusterNameForBenchmark("test-benchmark4", fakeRepository))
+	plan, err := client.Plan(fakeProject, fakeRepository, testBenchmarkRoot)
+	if err != nil {
+		t.Fatalf("Unexpected error planning: '%v'", err)
+	}
+	if err := client.Apply(plan, 2); err != nil {
+		t.Fatalf("Unexpected error applying the plan: '%v'", err)
+	}
+	// The cluster whose node count changed is resized in place, not recreated.
+	if cluster, _ := client.ops.GetCluster(fakeProject, "us-central1", "", clusterNameForBenchmark("test-benchmark4", fakeRepository)); cluster != resized {
+		t.Error("The cluster whose node count changed was recreated instead of resized")
+	}
+
+	expected, _ := benchmarkClusters(fakeRepository, testBenchmarkRoot)
+	clusters, _ := client.ops.ListClustersInProject(fakeProject)
+	actual := make(map[string]ClusterConfig)
+	for _, cluster := range clusters {
+		actual[cluster.Name] = ClusterConfig{
+			Location:  cluster.Location,
+			NodeCount: cluster.NodePools[0].Autoscaling.MaxNodeCount,
+			NodeType:  cluster.NodePools[0].Config.MachineType,
+			Addons:    getAddonsForCluster(cluster),
+		}
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Clusters are wrong after applying the plan (-want +got):\n%s", diff)
+	}
+
+	// Nothing to do once the plan is applied.
+	plan, err = client.Plan(fakeProject, fakeRepository, testBenchmarkRoot)
+	if err != nil {
+		t.Fatalf("Unexpected error planning again: '%v'", err)
+	}
+	if len(plan.Actions) != 0 {
+		t.Errorf("Got actions after applying the plan:\n%s", plan)
+	}
+}
+
+func TestApplyParallelism(t *testing.T) {
+	ops := &concurrencyOps{SDKOperations: gkeFake.NewGKESDKClient()}
+	client := Client{ops: ops}
+	plan, err := client.Plan(fakeProject, fakeRepository, testBenchmarkRoot)
+	if err != nil {
+		t.Fatalf("Unexpected error planning: '%v'", err)
+	}
+	if err := client.Apply(plan, 2); err != nil {
+		t.Fatalf("Unexpected error applying the plan: '%v'", err)
+	}
+	if ops.maxRunning > 2 {
+		t.Errorf("Created %d clusters at the same time, want at most 2", ops.maxRunning)
+	}
+	if err := client.Apply(plan, 0); err == nil {
+		t.Error("Expected an error applying with no parallelism, got nil")
+	}
+}
@@ -56,6 +56,7 @@ type GithubOperations interface {
 	DeleteComment(org, repo string, commentID int64) error
 	AddLabelsToIssue(org, repo string, issueNumber int, labels []string) error
 	RemoveLabelForIssue(org, repo string, issueNumber int, label string) error
+	AddAssignees(org, repo string, issueNumber int, assignees []string) error
 	GetPullRequest(org, repo string, ID int) (*github.PullRequest, error)
 	GetPullRequestByCommitID(org, repo, commitID string) (*github.PullRequest, error)
 	EditPullRequest(org, repo string, ID int, title, body string) (*github.PullRequest, error)
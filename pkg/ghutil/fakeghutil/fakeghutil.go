@@ -174,18 +174,42 @@ func (fgc *FakeGithubClient) DeleteComment(org, repo string, commentID int64) er
 	return fmt.Errorf("cannot find comment")
 }
 
-// AddLabelsToIssue adds label on issue
+// AddLabelsToIssue adds label on issue, or PullRequest as Github does
 func (fgc *FakeGithubClient) AddLabelsToIssue(org, repo string, issueNumber int, labels []string) error {
-	targetIssue := fgc.Issues[repo][issueNumber]
-	if nil == targetIssue {
-		return fmt.Errorf("cannot find issue")
+	var newLabels []*github.Label
+	for i := range labels {
+		newLabels = append(newLabels, &github.Label{
+			Name: &labels[i],
+		})
+	}
+	if targetIssue := fgc.Issues[repo][issueNumber]; nil != targetIssue {
+		targetIssue.Labels = append(targetIssue.Labels, newLabels...)
+		return nil
+	}
+	if targetPR := fgc.PullRequests[repo][issueNumber]; nil != targetPR {
+		targetPR.Labels = append(targetPR.Labels, newLabels...)
+		return nil
 	}
-	for _, label := range labels {
-		targetIssue.Labels = append(targetIssue.Labels, &github.Label{
-			Name: &label,
+	return fmt.Errorf("cannot find issue")
+}
+
+// AddAssignees assigns the users to issue, or PullRequest as Github does
+func (fgc *FakeGithubClient) AddAssignees(org, repo string, issueNumber int, assignees []string) error {
+	var users []*github.User
+	for i := range assignees {
+		users = append(users, &github.User{
+			Login: &assignees[i],
 		})
 	}
-	return nil
+	if targetIssue := fgc.Issues[repo][issueNumber]; nil != targetIssue {
+		targetIssue.Assignees = append(targetIssue.Assignees, users...)
+		return nil
+	}
+	if targetPR := fgc.PullRequests[repo][issueNumber]; nil != targetPR {
+		targetPR.Assignees = append(targetPR.Assignees, users...)
+		return nil
+	}
+	return fmt.Errorf("cannot find issue")
 }
 
 // RemoveLabelForIssue removes given label for issue
@@ -212,6 +212,19 @@ func (gc *GithubClient) RemoveLabelForIssue(org, repo string, issueNumber int, l
 	return err
 }
 
+// AddAssignees assigns the users to issue, or PullRequest
+func (gc *GithubClient) AddAssignees(org, repo string, issueNumber int, assignees []string) error {
+	_, err := gc.retry(
+		fmt.Sprintf("add assignees '%v' to '%s %s %d'", assignees, org, repo, issueNumber),
+		maxRetryCount,
+		func() (*github.Response, error) {
+			_, resp, err := gc.Client.Issues.AddAssignees(ctx, org, repo, issueNumber, assignees)
+			return resp, err
+		},
+	)
+	return err
+}
+
 func (gc *GithubClient) updateIssueState(org, repo string, state IssueStateEnum, issueNumber int) error {
 	stateString := string(state)
 	issueRequest := &github.IssueRequest{
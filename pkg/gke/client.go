@@ -34,6 +34,7 @@ type SDKOperations interface {
 	GetCluster(project, region, zone, clusterName string) (*container.Cluster, error)
 	GetOperation(project, region, zone, opName string) (*container.Operation, error)
 	ListClustersInProject(project string) ([]*container.Cluster, error)
+	ResizeNodePool(project, region, zone, clusterName, nodePoolName string, nodeCount int64) error
 }
 
 // sdkClient Implement SDKOperations
@@ -115,6 +116,65 @@ func (gsc *sdkClient) ListClustersInProject(project string) ([]*container.Cluste
 	return resp.Clusters, nil
 }
 
+// ResizeNodePool resizes the node pool to nodeCount nodes per zone, and wait
+// until it finishes or timeout or there is an error. If autoscaling is enabled
+// for the node pool, it's pinned to nodeCount nodes too, otherwise the
+// autoscaler would bring the node pool back to its previous size.
+func (gsc *sdkClient) ResizeNodePool(project, region, zone, clusterName, nodePoolName string, nodeCount int64) error {
+	cluster, err := gsc.GetCluster(project, region, zone, clusterName)
+	if err != nil {
+		return err
+	}
+	var nodePool *container.NodePool
+	for _, np := range cluster.NodePools {
+		if np.Name == nodePoolName {
+			nodePool = np
+		}
+	}
+	if nodePool == nil {
+		return fmt.Errorf("node pool %q not found in cluster %q", nodePoolName, clusterName)
+	}
+
+	location := GetClusterLocation(region, zone)
+	nodePoolFullPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s/nodePools/%s", project, location, clusterName, nodePoolName)
+	if nodePool.Autoscaling != nil && nodePool.Autoscaling.Enabled {
+		req := &container.SetNodePoolAutoscalingRequest{
+			Autoscaling: &container.NodePoolAutoscaling{
+				Enabled:      true,
+				MinNodeCount: nodeCount,
+				MaxNodeCount: nodeCount,
+			},
+		}
+		var op *container.Operation
+		if zone != "" {
+			op, err = gsc.Projects.Zones.Clusters.NodePools.Autoscaling(project, location, clusterName, nodePoolName, req).Context(context.Background()).Do()
+		} else {
+			op, err = gsc.Projects.Locations.Clusters.NodePools.SetAutoscaling(nodePoolFullPath, req).Context(context.Background()).Do()
+		}
+		if err == nil {
+			err = Wait(gsc, project, region, zone, op.Name, resizeTimeout)
+		}
+		if err != nil {
+			return fmt.Errorf("failed setting the autoscaling of node pool %q: %w", nodePoolName, err)
+		}
+	}
+
+	req := &container.SetNodePoolSizeRequest{NodeCount: nodeCount}
+	var op *container.Operation
+	if zone != "" {
+		op, err = gsc.Projects.Zones.Clusters.NodePools.SetSize(project, location, clusterName, nodePoolName, req).Context(context.Background()).Do()
+	} else {
+		op, err = gsc.Projects.Locations.Clusters.NodePools.SetSize(nodePoolFullPath, req).Context(context.Background()).Do()
+	}
+	if err == nil {
+		err = Wait(gsc, project, region, zone, op.Name, resizeTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("failed setting the size of node pool %q: %w", nodePoolName, err)
+	}
+	return nil
+}
+
 // GetOperation gets the operation ref with the given operation name.
 func (gsc *sdkClient) GetOperation(project, region, zone, opName string) (*container.Operation, error) {
 	location := GetClusterLocation(region, zone)
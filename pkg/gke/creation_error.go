@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gke
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"google.golang.org/api/googleapi"
+)
+
+// CreationErrorType is the kind of a cluster creation error, which tells
+// whether and where the creation can be retried.
+type CreationErrorType string
+
+const (
+	// CreationErrorStockout means the location cannot fulfill the request,
+	// e.g. it doesn't have enough resources or the GKE version isn't available
+	// there yet. The creation can be retried in another location.
+	CreationErrorStockout CreationErrorType = "stockout"
+	// CreationErrorQuota means the project ran out of quota in the location.
+	// The creation can be retried in another location.
+	CreationErrorQuota CreationErrorType = "quota"
+	// CreationErrorTransient means the GKE API failed, or the error isn't
+	// recognized. The creation can be retried in the same location.
+	CreationErrorTransient CreationErrorType = "transient"
+	// CreationErrorPermanent means the GKE API rejected the request itself,
+	// e.g. it's invalid or not allowed. Retrying won't help.
+	CreationErrorPermanent CreationErrorType = "permanent"
+)
+
+// creationErrorPatterns are matched in order against the error message, the
+// first match wins. They are checked before the API error code, so that e.g.
+// a stockout returned with a 400 isn't seen as an invalid request.
+var creationErrorPatterns = []struct {
+	errType CreationErrorType
+	regexps []*regexp.Regexp
+}{{
+	// - stockout (https://github.com/knative/test-infra/issues/592)
+	// - latest GKE not available in this region/zone yet (https://github.com/knative/test-infra/issues/694)
+	CreationErrorStockout,
+	[]*regexp.Regexp{
+		regexp.MustCompile(`does not have enough resources available to fulfill`),
+		regexp.MustCompile(`only \d+ nodes out of \d+ have registered; this is likely due to Nodes failing to start correctly`),
+		regexp.MustCompile(`ZONE_RESOURCE_POOL_EXHAUSTED`),
+		regexp.MustCompile(`Master version "[0-9a-z\-.]+" is unsupported`),
+		regexp.MustCompile(`No valid versions with the prefix "[0-9.]+" found`),
+	},
+}, {
+	CreationErrorQuota,
+	[]*regexp.Regexp{
+		regexp.MustCompile(`(?i)insufficient (regional )?quota`),
+		regexp.MustCompile(`QUOTA_EXCEEDED`),
+		regexp.MustCompile(`(?i)quota .*exceeded`),
+	},
+}}
+
+// permanentErrorCodes are the codes of the GKE API errors returned when the
+// request itself is rejected.
+var permanentErrorCodes = map[int]bool{
+	http.StatusBadRequest:   true,
+	http.StatusUnauthorized: true,
+	http.StatusForbidden:    true,
+	http.StatusNotFound:     true,
+	http.StatusConflict:     true,
+}
+
+// ClassifyCreationError returns the kind of a cluster creation error. Errors
+// that don't match a known stockout or quota message are permanent only if
+// the GKE API rejected the request, as told by the code of the
+// *googleapi.Error. Any other error, including the output of a command that
+// failed creating the cluster, is seen as transient.
+func ClassifyCreationError(err error) CreationErrorType {
+	for _, p := range creationErrorPatterns {
+		for _, re := range p.regexps {
+			if re.MatchString(err.Error()) {
+				return p.errType
+			}
+		}
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		for _, item := range apiErr.Errors {
+			if item.Reason == "quotaExceeded" {
+				return CreationErrorQuota
+			}
+		}
+		if permanentErrorCodes[apiErr.Code] {
+			return CreationErrorPermanent
+		}
+	}
+	return CreationErrorTransient
+}
+
+// RetryInAnotherLocation tells whether the cluster creation can succeed in
+// another location.
+func (t CreationErrorType) RetryInAnotherLocation() bool {
+	return t == CreationErrorStockout || t == CreationErrorQuota
+}
+
+// RetryInSameLocation tells whether the cluster creation can succeed by
+// retrying in the same location.
+func (t CreationErrorType) RetryInSameLocation() bool {
+	return t == CreationErrorTransient
+}
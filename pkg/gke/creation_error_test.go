@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gke
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestClassifyCreationError(t *testing.T) {
+	datas := []struct {
+		err  error
+		want CreationErrorType
+	}{
+		{errors.New(`Error 400: The zone "projects/p/zones/us-central1-a" does not have enough resources available to fulfill the request.`), CreationErrorStockout},
+		{errors.New("only 2 nodes out of 3 have registered; this is likely due to Nodes failing to start correctly"), CreationErrorStockout},
+		{errors.New("ZONE_RESOURCE_POOL_EXHAUSTED: The zone does not have enough resources"), CreationErrorStockout},
+		{&googleapi.Error{Code: http.StatusBadRequest, Message: `Master version "1.18.6-gke.999" is unsupported.`}, CreationErrorStockout},
+		{errors.New(`No valid versions with the prefix "1.19" found.`), CreationErrorStockout},
+		{errors.New(`Error 403: Insufficient regional quota to satisfy request: resource "CPUS": request requires '12.0' and is short '4.0'.`), CreationErrorQuota},
+		{errors.New("Quota 'IN_USE_ADDRESSES' exceeded. Limit: 8.0 in region us-west1."), CreationErrorQuota},
+		{&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}, CreationErrorQuota},
+		{&googleapi.Error{Code: http.StatusBadRequest, Message: "Cluster.initial_node_count must be greater than zero."}, CreationErrorPermanent},
+		{fmt.Errorf("creating cluster: %w", &googleapi.Error{Code: http.StatusConflict, Message: "Already exists"}), CreationErrorPermanent},
+		{&googleapi.Error{Code: http.StatusForbidden, Message: "Permission denied"}, CreationErrorPermanent},
+		{&googleapi.Error{Code: http.StatusServiceUnavailable, Message: "The service is currently unavailable."}, CreationErrorTransient},
+		{&googleapi.Error{Code: http.StatusTooManyRequests, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, CreationErrorTransient},
+		// Free text isn't enough to tell the request is wrong.
+		{errors.New("Error 400: the node pool is invalid, badRequest"), CreationErrorTransient},
+		{errors.New(`unexpected operation status: "BAD"`), CreationErrorTransient},
+		{errors.New("timed out waiting"), CreationErrorTransient},
+	}
+	for _, data := range datas {
+		if got := ClassifyCreationError(data.err); got != data.want {
+			t.Errorf("Classify creation error %q = %q, want: %q", data.err, got, data.want)
+		}
+	}
+}
+
+func TestCreationErrorTypeRetries(t *testing.T) {
+	datas := []struct {
+		errType                       CreationErrorType
+		wantAnotherLocation, wantSame bool
+	}{
+		{CreationErrorStockout, true, false},
+		{CreationErrorQuota, true, false},
+		{CreationErrorTransient, false, true},
+		{CreationErrorPermanent, false, false},
+	}
+	for _, data := range datas {
+		if got := data.errType.RetryInAnotherLocation(); got != data.wantAnotherLocation {
+			t.Errorf("Retry %s in another location = %v, want: %v", data.errType, got, data.wantAnotherLocation)
+		}
+		if got := data.errType.RetryInSameLocation(); got != data.wantSame {
+			t.Errorf("Retry %s in the same location = %v, want: %v", data.errType, got, data.wantSame)
+		}
+	}
+}
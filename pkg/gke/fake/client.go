@@ -46,6 +46,9 @@ type GKESDKClient struct {
 	opNumber int
 	// A lookup table for determining ops statuses
 	OpStatus map[string]string
+	// A lookup table of location: error returned when creating clusters in
+	// this location, e.g. to simulate stockouts
+	CreationErrors map[string]error
 
 	mutex sync.Mutex
 }
@@ -53,9 +56,10 @@ type GKESDKClient struct {
 // NewGKESDKClient returns a new fake gkeSDKClient that can be used in unit tests.
 func NewGKESDKClient() *GKESDKClient {
 	return &GKESDKClient{
-		clusters: make(map[string][]*container.Cluster),
-		ops:      make(map[string]*container.Operation),
-		OpStatus: make(map[string]string),
+		clusters:       make(map[string][]*container.Cluster),
+		ops:            make(map[string]*container.Operation),
+		OpStatus:       make(map[string]string),
+		CreationErrors: make(map[string]error),
 	}
 }
 
@@ -96,6 +100,9 @@ func (fgsc *GKESDKClient) CreateClusterAsync(
 	fgsc.mutex.Lock()
 	defer fgsc.mutex.Unlock()
 	location := gke.GetClusterLocation(region, zone)
+	if err := fgsc.CreationErrors[location]; err != nil {
+		return nil, err
+	}
 	parent := fmt.Sprintf("projects/%s/locations/%s", project, location)
 	name := rb.Cluster.Name
 	if cls, ok := fgsc.clusters[parent]; ok {
@@ -108,14 +115,13 @@ func (fgsc *GKESDKClient) CreateClusterAsync(
 		fgsc.clusters[parent] = make([]*container.Cluster, 0)
 	}
 	cluster := &container.Cluster{
-		Name:         name,
-		Location:     location,
-		Status:       "RUNNING",
-		AddonsConfig: rb.Cluster.AddonsConfig,
-		NodePools:    rb.Cluster.NodePools,
-	}
-	if rb.Cluster.NodePools != nil {
-		cluster.NodePools = rb.Cluster.NodePools
+		Name:           name,
+		Location:       location,
+		Status:         "RUNNING",
+		AddonsConfig:   rb.Cluster.AddonsConfig,
+		NodePools:      rb.Cluster.NodePools,
+		ResourceLabels: rb.Cluster.ResourceLabels,
+		Autopilot:      rb.Cluster.Autopilot,
 	}
 	if rb.Cluster.MasterAuth != nil {
 		cluster.MasterAuth = &container.MasterAuth{
@@ -193,6 +199,29 @@ func (fgsc *GKESDKClient) ListClustersInProject(project string) ([]*container.Cl
 	return allClusters, nil
 }
 
+// ResizeNodePool resizes the node pool, pinning its autoscaling to the new
+// size if it's enabled.
+func (fgsc *GKESDKClient) ResizeNodePool(project, region, zone, clusterName, nodePoolName string, nodeCount int64) error {
+	cluster, err := fgsc.GetCluster(project, region, zone, clusterName)
+	if err != nil {
+		return err
+	}
+	fgsc.mutex.Lock()
+	defer fgsc.mutex.Unlock()
+	for _, np := range cluster.NodePools {
+		if np.Name != nodePoolName {
+			continue
+		}
+		if np.Autoscaling != nil && np.Autoscaling.Enabled {
+			np.Autoscaling.MinNodeCount = nodeCount
+			np.Autoscaling.MaxNodeCount = nodeCount
+		}
+		np.InitialNodeCount = nodeCount
+		return nil
+	}
+	return fmt.Errorf("node pool %q not found in cluster %q", nodePoolName, clusterName)
+}
+
 // GetOperation gets the operation with the given settings.
 func (fgsc *GKESDKClient) GetOperation(project, region, zone, opName string) (*container.Operation, error) {
 	fgsc.mutex.Lock()
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gke
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// taintEffects maps the taint effects as written with kubectl to the GKE API.
+var taintEffects = map[string]string{
+	"NoSchedule":       TaintEffectNoSchedule,
+	"PreferNoSchedule": TaintEffectPreferNoSchedule,
+	"NoExecute":        TaintEffectNoExecute,
+}
+
+// ParseNodePool parses a node pool from a comma separated list of settings,
+// e.g. "name=system,min-nodes=1,max-nodes=3,node-type=e2-standard-2,taint=dedicated=system:NoSchedule,label=role=system,spot".
+// The taint and label settings can be repeated.
+func ParseNodePool(spec string) (NodePool, error) {
+	np := NodePool{}
+	for _, setting := range strings.Split(spec, ",") {
+		key, value := setting, ""
+		hasValue := false
+		if i := strings.Index(setting, "="); i >= 0 {
+			key, value, hasValue = setting[:i], setting[i+1:], true
+		}
+		var err error
+		switch key {
+		case "name":
+			np.Name = value
+		case "min-nodes":
+			np.MinNodes, err = strconv.ParseInt(value, 10, 64)
+		case "max-nodes":
+			np.MaxNodes, err = strconv.ParseInt(value, 10, 64)
+		case "node-type":
+			np.NodeType = value
+		case "taint":
+			var taint Taint
+			if taint, err = ParseTaint(value); err == nil {
+				np.Taints = append(np.Taints, taint)
+			}
+		case "label":
+			kv := strings.SplitN(value, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				err = fmt.Errorf("label must be formatted as key=value")
+				break
+			}
+			if np.Labels == nil {
+				np.Labels = make(map[string]string)
+			}
+			np.Labels[kv[0]] = kv[1]
+		case "preemptible":
+			np.Preemptible, err = parseBoolSetting(value, hasValue)
+		case "spot":
+			np.Spot, err = parseBoolSetting(value, hasValue)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return NodePool{}, fmt.Errorf("invalid node pool setting %q: %w", setting, err)
+		}
+	}
+	if np.Name == "" {
+		return NodePool{}, fmt.Errorf("node pool %q has no name", spec)
+	}
+	if np.MaxNodes == 0 {
+		np.MaxNodes = np.MinNodes
+	}
+	return np, nil
+}
+
+// ParseTaint parses a taint formatted as for kubectl, i.e. key[=value]:effect.
+// The effect can be written as for kubectl, e.g. NoSchedule, or for the GKE
+// API, e.g. NO_SCHEDULE.
+func ParseTaint(spec string) (Taint, error) {
+	i := strings.LastIndex(spec, ":")
+	if i < 0 {
+		return Taint{}, fmt.Errorf("taint %q must be formatted as key[=value]:effect", spec)
+	}
+	taint := Taint{Key: spec[:i], Effect: spec[i+1:]}
+	if j := strings.Index(taint.Key, "="); j >= 0 {
+		taint.Key, taint.Value = taint.Key[:j], taint.Key[j+1:]
+	}
+	if effect, ok := taintEffects[taint.Effect]; ok {
+		taint.Effect = effect
+	}
+	switch taint.Effect {
+	case TaintEffectNoSchedule, TaintEffectPreferNoSchedule, TaintEffectNoExecute:
+	default:
+		return Taint{}, fmt.Errorf("invalid effect %q for taint %q", taint.Effect, spec)
+	}
+	if taint.Key == "" {
+		return Taint{}, fmt.Errorf("taint %q has no key", spec)
+	}
+	return taint, nil
+}
+
+// parseBoolSetting parses a boolean setting, which is true if it has no value.
+func parseBoolSetting(value string, hasValue bool) (bool, error) {
+	if !hasValue {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gke
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNodePool(t *testing.T) {
+	datas := []struct {
+		spec          string
+		want          NodePool
+		errorExpected bool
+	}{
+		{
+			spec: "name=system,min-nodes=1,max-nodes=3,node-type=e2-standard-2,taint=dedicated=system:NoSchedule,taint=critical:NO_EXECUTE,label=role=system,spot",
+			want: NodePool{
+				Name:     "system",
+				MinNodes: 1,
+				MaxNodes: 3,
+				NodeType: "e2-standard-2",
+				Taints: []Taint{
+					{Key: "dedicated", Value: "system", Effect: TaintEffectNoSchedule},
+					{Key: "critical", Effect: TaintEffectNoExecute},
+				},
+				Labels: map[string]string{"role": "system"},
+				Spot:   true,
+			},
+		}, {
+			// max nodes defaults to min nodes
+			spec: "name=cheap,min-nodes=2,node-type=e2-standard-4,preemptible=true",
+			want: NodePool{Name: "cheap", MinNodes: 2, MaxNodes: 2, NodeType: "e2-standard-4", Preemptible: true},
+		}, {
+			spec:          "min-nodes=1,node-type=e2-standard-4",
+			errorExpected: true,
+		}, {
+			spec:          "name=a,min-nodes=one",
+			errorExpected: true,
+		}, {
+			spec:          "name=a,taint=dedicated=system",
+			errorExpected: true,
+		}, {
+			spec:          "name=a,taint=dedicated=system:NoRun",
+			errorExpected: true,
+		}, {
+			spec:          "name=a,label=role",
+			errorExpected: true,
+		}, {
+			spec:          "name=a,spot=maybe",
+			errorExpected: true,
+		}, {
+			spec:          "name=a,disk-size=100",
+			errorExpected: true,
+		},
+	}
+	for _, data := range datas {
+		got, err := ParseNodePool(data.spec)
+		if data.errorExpected {
+			if err == nil {
+				t.Errorf("Expected error from spec %q, but got nil", data.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error from spec %q, but got '%v'", data.spec, err)
+		}
+		if dif := cmp.Diff(data.want, got); dif != "" {
+			t.Errorf("Parsing spec %q: got(+) is different from wanted(-)\n%v", data.spec, dif)
+		}
+	}
+}
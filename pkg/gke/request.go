@@ -18,11 +18,31 @@ package gke
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
 
 	container "google.golang.org/api/container/v1beta1"
 )
 
-const defaultGKEVersion = "latest"
+const (
+	defaultGKEVersion = "latest"
+	defaultNodePool   = "default-pool"
+)
+
+// Node taint effects, as defined in the GKE API.
+const (
+	TaintEffectNoSchedule       = "NO_SCHEDULE"
+	TaintEffectPreferNoSchedule = "PREFER_NO_SCHEDULE"
+	TaintEffectNoExecute        = "NO_EXECUTE"
+)
+
+// labelKeyRegexp and labelValueRegexp match the keys and values of GCP resource labels, see
+// https://cloud.google.com/resource-manager/docs/creating-managing-labels#requirements
+var (
+	labelKeyRegexp   = regexp.MustCompile(`^[\p{Ll}][\p{Ll}0-9_-]{0,62}$`)
+	labelValueRegexp = regexp.MustCompile(`^[\p{Ll}0-9_-]{0,63}$`)
+)
 
 // Request contains all settings collected for cluster creation
 type Request struct {
@@ -66,6 +86,61 @@ type Request struct {
 
 	// ServiceAccount: service account that will be used on this cluster
 	ServiceAccount string
+
+	// Preemptible: whether the nodes of the default node pool are preemptible VMs
+	Preemptible bool
+
+	// Spot: whether the nodes of the default node pool are Spot VMs, only one
+	// of Preemptible or Spot can be specified at a time
+	Spot bool
+
+	// NodePools: node pools to create in addition to the default node pool
+	NodePools []NodePool
+
+	// ResourceLabels: GCP labels applied to the cluster and its resources,
+	// e.g. for cost attribution and cleanup
+	ResourceLabels map[string]string
+
+	// Autopilot: whether to create an Autopilot cluster, whose nodes are
+	// managed by GKE, so none of the node settings, including the service
+	// account, can be specified
+	// https://cloud.google.com/kubernetes-engine/docs/concepts/autopilot-overview
+	Autopilot bool
+}
+
+// NodePool contains the settings of a node pool created with the cluster
+type NodePool struct {
+	// Name: name of the node pool
+	Name string
+
+	// MinNodes: the minimum number of nodes of the node pool
+	MinNodes int64
+
+	// MaxNodes: the maximum number of nodes of the node pool
+	MaxNodes int64
+
+	// NodeType: node type of the node pool, e.g. e2-standard-4, e2-standard-8
+	NodeType string
+
+	// Taints: Kubernetes taints applied to the nodes of the node pool
+	Taints []Taint
+
+	// Labels: Kubernetes labels applied to the nodes of the node pool
+	Labels map[string]string
+
+	// Preemptible: whether the nodes are preemptible VMs
+	Preemptible bool
+
+	// Spot: whether the nodes are Spot VMs
+	Spot bool
+}
+
+// Taint is a Kubernetes taint applied to the nodes of a node pool
+type Taint struct {
+	Key   string
+	Value string
+	// Effect: one of NO_SCHEDULE, PREFER_NO_SCHEDULE or NO_EXECUTE
+	Effect string
 }
 
 // DeepCopy will make a deepcopy of the request struct.
@@ -83,67 +158,76 @@ func (r *Request) DeepCopy() *Request {
 		Addons:                 r.Addons,
 		EnableWorkloadIdentity: r.EnableWorkloadIdentity,
 		ServiceAccount:         r.ServiceAccount,
+		Preemptible:            r.Preemptible,
+		Spot:                   r.Spot,
+		NodePools:              copyNodePools(r.NodePools),
+		ResourceLabels:         copyLabels(r.ResourceLabels),
+		Autopilot:              r.Autopilot,
 	}
 }
 
-// NewCreateClusterRequest returns a new CreateClusterRequest that can be used in gcloud SDK.
-func NewCreateClusterRequest(request *Request) (*container.CreateClusterRequest, error) {
-	if request.ClusterName == "" {
-		return nil, errors.New("cluster name cannot be empty")
-	}
-	if request.MinNodes <= 0 {
-		return nil, errors.New("min nodes must be larger than 1")
+func copyNodePools(nodePools []NodePool) []NodePool {
+	if nodePools == nil {
+		return nil
 	}
-	if request.MinNodes > request.MaxNodes {
-		return nil, errors.New("min nodes cannot be larger than max nodes")
+	res := make([]NodePool, len(nodePools))
+	for i, np := range nodePools {
+		res[i] = np
+		res[i].Taints = append([]Taint(nil), np.Taints...)
+		res[i].Labels = copyLabels(np.Labels)
 	}
-	if request.NodeType == "" {
-		return nil, errors.New("node type cannot be empty")
+	return res
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
 	}
-	if request.EnableWorkloadIdentity && request.Project == "" {
-		return nil, errors.New("project cannot be empty if you want Workload Identity")
+	res := make(map[string]string, len(labels))
+	for k, v := range labels {
+		res[k] = v
 	}
-	if request.GKEVersion != "" && request.ReleaseChannel != "" {
-		return nil, errors.New("can only specify one of GKE version or release channel (not both)")
+	return res
+}
+
+// NewCreateClusterRequest returns a new CreateClusterRequest that can be used in gcloud SDK.
+func NewCreateClusterRequest(request *Request) (*container.CreateClusterRequest, error) {
+	if err := validate(request); err != nil {
+		return nil, err
 	}
 
 	ccr := &container.CreateClusterRequest{
 		Cluster: &container.Cluster{
-			NodePools: []*container.NodePool{
-				{
-					Name:             "default-pool",
-					InitialNodeCount: request.MinNodes,
-					Autoscaling: &container.NodePoolAutoscaling{
-						Enabled:      true,
-						MinNodeCount: request.MinNodes,
-						MaxNodeCount: request.MaxNodes,
-					},
-					Config: &container.NodeConfig{
-						MachineType: request.NodeType,
-						// The set of Google API scopes to be made available on all
-						// of the node VMs under the "default" service account.
-						// If unspecified, no scopes are added, unless Cloud Logging or
-						// Cloud Monitoring are enabled, in which case their required
-						// scopes will be added.
-						// `https://www.googleapis.com/auth/devstorage.read_only` is required
-						// for communicating with **gcr.io**, and it's included in cloud-platform scope.
-						// TODO(chizhg): give more fine granular scope based on the actual needs.
-						OauthScopes: []string{container.CloudPlatformScope},
-					},
-				},
-			},
-			Name: request.ClusterName,
-			// Installing addons after cluster creation takes at least 5
-			// minutes, so install addons as part of cluster creation, which
-			// doesn't seem to add much time on top of cluster creation
-			AddonsConfig: GetAddonsConfig(request.Addons),
-			// Equivalent to --enable-basic-auth, so that user:pass can be
-			// later on retrieved for setting up cluster roles. Use the
-			// default username from gcloud command, the password will be
-			// automatically generated by GKE SDK
-			MasterAuth: &container.MasterAuth{Username: "admin"},
+			Name:           request.ClusterName,
+			ResourceLabels: copyLabels(request.ResourceLabels),
 		},
 	}
+	if request.Autopilot {
+		// Autopilot clusters manage their own nodes and addons, and don't
+		// support basic auth.
+		ccr.Cluster.Autopilot = &container.Autopilot{Enabled: true}
+	} else {
+		ccr.Cluster.NodePools = []*container.NodePool{newNodePool(request, NodePool{
+			Name:        defaultNodePool,
+			MinNodes:    request.MinNodes,
+			MaxNodes:    request.MaxNodes,
+			NodeType:    request.NodeType,
+			Preemptible: request.Preemptible,
+			Spot:        request.Spot,
+		})}
+		for _, np := range request.NodePools {
+			ccr.Cluster.NodePools = append(ccr.Cluster.NodePools, newNodePool(request, np))
+		}
+		// Installing addons after cluster creation takes at least 5
+		// minutes, so install addons as part of cluster creation, which
+		// doesn't seem to add much time on top of cluster creation
+		ccr.Cluster.AddonsConfig = GetAddonsConfig(request.Addons)
+		// Equivalent to --enable-basic-auth, so that user:pass can be
+		// later on retrieved for setting up cluster roles. Use the
+		// default username from gcloud command, the password will be
+		// automatically generated by GKE SDK
+		ccr.Cluster.MasterAuth = &container.MasterAuth{Username: "admin"}
+	}
 	if request.EnableWorkloadIdentity {
 		// Equivalent to --workload-pool=[PROJECT_ID].svc.id.goog, then
 		// we can configure a Kubernetes service account to act as a Google
@@ -152,12 +236,6 @@ func NewCreateClusterRequest(request *Request) (*container.CreateClusterRequest,
 			WorkloadPool: request.Project + ".svc.id.goog",
 		}
 	}
-	if request.ServiceAccount != "" {
-		// The Google Cloud Platform Service Account to be used by the node VMs.
-		// If a service account is specified, the cloud-platform and userinfo.email scopes are used.
-		// If no Service Account is specified, the project default service account is used.
-		ccr.Cluster.NodePools[0].Config.ServiceAccount = request.ServiceAccount
-	}
 
 	// Manage the GKE cluster version. Only one of initial cluster version or release channel can be specified.
 	if request.ReleaseChannel != "" {
@@ -171,3 +249,144 @@ func NewCreateClusterRequest(request *Request) (*container.CreateClusterRequest,
 	}
 	return ccr, nil
 }
+
+// validate checks that the request can be turned into a CreateClusterRequest.
+func validate(request *Request) error {
+	if request.ClusterName == "" {
+		return errors.New("cluster name cannot be empty")
+	}
+	if request.EnableWorkloadIdentity && request.Project == "" {
+		return errors.New("project cannot be empty if you want Workload Identity")
+	}
+	if request.GKEVersion != "" && request.ReleaseChannel != "" {
+		return errors.New("can only specify one of GKE version or release channel (not both)")
+	}
+	if err := validateLabels(request.ResourceLabels); err != nil {
+		return fmt.Errorf("invalid resource labels: %w", err)
+	}
+	if request.Autopilot {
+		if request.MinNodes != 0 || request.MaxNodes != 0 || request.NodeType != "" ||
+			request.Preemptible || request.Spot || len(request.NodePools) != 0 {
+			return errors.New("cannot specify node settings for an Autopilot cluster")
+		}
+		if len(request.Addons) != 0 {
+			return errors.New("cannot specify addons for an Autopilot cluster")
+		}
+		if request.Zone != "" {
+			return errors.New("cannot specify zone for an Autopilot cluster, it must be regional")
+		}
+		if request.ServiceAccount != "" {
+			// The service account is a setting of the node pools, which
+			// Autopilot clusters don't have.
+			return errors.New("cannot specify service account for an Autopilot cluster")
+		}
+		return nil
+	}
+
+	if err := validateNodePool(NodePool{
+		Name:        defaultNodePool,
+		MinNodes:    request.MinNodes,
+		MaxNodes:    request.MaxNodes,
+		NodeType:    request.NodeType,
+		Preemptible: request.Preemptible,
+		Spot:        request.Spot,
+	}); err != nil {
+		return err
+	}
+	names := map[string]bool{defaultNodePool: true}
+	for _, np := range request.NodePools {
+		if err := validateNodePool(np); err != nil {
+			return fmt.Errorf("invalid node pool %q: %w", np.Name, err)
+		}
+		if names[np.Name] {
+			return fmt.Errorf("duplicate node pool name %q", np.Name)
+		}
+		names[np.Name] = true
+	}
+	return nil
+}
+
+// validateNodePool checks the settings of a single node pool.
+func validateNodePool(np NodePool) error {
+	if np.Name == "" {
+		return errors.New("node pool name cannot be empty")
+	}
+	if np.MinNodes <= 0 {
+		return errors.New("min nodes must be larger than 1")
+	}
+	if np.MinNodes > np.MaxNodes {
+		return errors.New("min nodes cannot be larger than max nodes")
+	}
+	if np.NodeType == "" {
+		return errors.New("node type cannot be empty")
+	}
+	if np.Preemptible && np.Spot {
+		return errors.New("can only specify one of preemptible or spot (not both)")
+	}
+	for _, t := range np.Taints {
+		if t.Key == "" {
+			return errors.New("taint key cannot be empty")
+		}
+		switch t.Effect {
+		case TaintEffectNoSchedule, TaintEffectPreferNoSchedule, TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("invalid effect %q for taint %q", t.Effect, t.Key)
+		}
+	}
+	return nil
+}
+
+// validateLabels checks the GCP resource labels, sorting the keys to return
+// the same error every time.
+func validateLabels(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !labelKeyRegexp.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if !labelValueRegexp.MatchString(labels[k]) {
+			return fmt.Errorf("invalid value %q for label %q", labels[k], k)
+		}
+	}
+	return nil
+}
+
+// newNodePool translates a validated node pool into the GKE API.
+func newNodePool(request *Request, np NodePool) *container.NodePool {
+	config := &container.NodeConfig{
+		MachineType: np.NodeType,
+		// The set of Google API scopes to be made available on all
+		// of the node VMs under the "default" service account.
+		// If unspecified, no scopes are added, unless Cloud Logging or
+		// Cloud Monitoring are enabled, in which case their required
+		// scopes will be added.
+		// `https://www.googleapis.com/auth/devstorage.read_only` is required
+		// for communicating with **gcr.io**, and it's included in cloud-platform scope.
+		// TODO(chizhg): give more fine granular scope based on the actual needs.
+		OauthScopes: []string{container.CloudPlatformScope},
+		// The Google Cloud Platform Service Account to be used by the node VMs.
+		// If a service account is specified, the cloud-platform and userinfo.email scopes are used.
+		// If no Service Account is specified, the project default service account is used.
+		ServiceAccount: request.ServiceAccount,
+		Labels:         copyLabels(np.Labels),
+		Preemptible:    np.Preemptible,
+		Spot:           np.Spot,
+	}
+	for _, t := range np.Taints {
+		config.Taints = append(config.Taints, &container.NodeTaint{Key: t.Key, Value: t.Value, Effect: t.Effect})
+	}
+	return &container.NodePool{
+		Name:             np.Name,
+		InitialNodeCount: np.MinNodes,
+		Autoscaling: &container.NodePoolAutoscaling{
+			Enabled:      true,
+			MinNodeCount: np.MinNodes,
+			MaxNodeCount: np.MaxNodes,
+		},
+		Config: config,
+	}
+}
@@ -16,7 +16,12 @@ limitations under the License.
 
 package gke
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	container "google.golang.org/api/container/v1beta1"
+)
 
 func TestNewCreateClusterRequest(t *testing.T) {
 	datas := []struct {
@@ -168,6 +173,113 @@ func TestNewCreateClusterRequest(t *testing.T) {
 				ServiceAccount: "sa-i",
 			},
 			errorExpected: false,
+		}, {
+			req: &Request{
+				ClusterName: "name-j",
+				MinNodes:    1,
+				MaxNodes:    1,
+				NodeType:    "n1-standard-4",
+				Preemptible: true,
+				Spot:        true,
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName: "name-k",
+				MinNodes:    1,
+				MaxNodes:    1,
+				NodeType:    "n1-standard-4",
+				NodePools: []NodePool{
+					{Name: "system", MinNodes: 1, MaxNodes: 1, NodeType: "n1-standard-4"},
+					{Name: "system", MinNodes: 1, MaxNodes: 1, NodeType: "n1-standard-4"},
+				},
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName: "name-l",
+				MinNodes:    1,
+				MaxNodes:    1,
+				NodeType:    "n1-standard-4",
+				NodePools: []NodePool{
+					{Name: "default-pool", MinNodes: 1, MaxNodes: 1, NodeType: "n1-standard-4"},
+				},
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName: "name-m",
+				MinNodes:    1,
+				MaxNodes:    1,
+				NodeType:    "n1-standard-4",
+				NodePools: []NodePool{{
+					Name:     "system",
+					MinNodes: 1,
+					MaxNodes: 1,
+					NodeType: "n1-standard-4",
+					Taints:   []Taint{{Key: "dedicated", Value: "system", Effect: "NoSchedule"}},
+				}},
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName:    "name-n",
+				MinNodes:       1,
+				MaxNodes:       1,
+				NodeType:       "n1-standard-4",
+				ResourceLabels: map[string]string{"Team": "serving"},
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName:    "name-o",
+				MinNodes:       1,
+				MaxNodes:       1,
+				NodeType:       "n1-standard-4",
+				ResourceLabels: map[string]string{"team": "Serving"},
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName: "name-p",
+				Region:      "us-central1",
+				Autopilot:   true,
+			},
+			errorExpected: false,
+		}, {
+			req: &Request{
+				ClusterName: "name-q",
+				Region:      "us-central1",
+				MinNodes:    1,
+				MaxNodes:    1,
+				NodeType:    "n1-standard-4",
+				Autopilot:   true,
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName: "name-r",
+				Region:      "us-central1",
+				Zone:        "a",
+				Autopilot:   true,
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName: "name-s",
+				Region:      "us-central1",
+				Addons:      []string{"Istio"},
+				Autopilot:   true,
+			},
+			errorExpected: true,
+		}, {
+			req: &Request{
+				ClusterName:    "name-t",
+				Region:         "us-central1",
+				ServiceAccount: "sa@project.iam.gserviceaccount.com",
+				Autopilot:      true,
+			},
+			errorExpected: true,
 		}}
 	for _, data := range datas {
 		createReq, err := NewCreateClusterRequest(data.req)
@@ -185,3 +297,90 @@ func TestNewCreateClusterRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestNewCreateClusterRequestNodeSettings(t *testing.T) {
+	req := &Request{
+		Project:        "project-a",
+		ClusterName:    "name-a",
+		MinNodes:       1,
+		MaxNodes:       3,
+		NodeType:       "e2-standard-4",
+		ServiceAccount: "sa-a",
+		Spot:           true,
+		ResourceLabels: map[string]string{"team": "serving", "expires-at": "1600000000"},
+		NodePools: []NodePool{{
+			Name:        "system",
+			MinNodes:    1,
+			MaxNodes:    2,
+			NodeType:    "e2-standard-2",
+			Taints:      []Taint{{Key: "dedicated", Value: "system", Effect: TaintEffectNoSchedule}},
+			Labels:      map[string]string{"role": "system"},
+			Preemptible: true,
+		}},
+	}
+	ccr, err := NewCreateClusterRequest(req)
+	if err != nil {
+		t.Fatalf("Expected no error from request '%v', but got '%v'", req, err)
+	}
+	want := []*container.NodePool{{
+		Name:             "default-pool",
+		InitialNodeCount: 1,
+		Autoscaling:      &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: 1, MaxNodeCount: 3},
+		Config: &container.NodeConfig{
+			MachineType:    "e2-standard-4",
+			OauthScopes:    []string{container.CloudPlatformScope},
+			ServiceAccount: "sa-a",
+			Spot:           true,
+		},
+	}, {
+		Name:             "system",
+		InitialNodeCount: 1,
+		Autoscaling:      &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: 1, MaxNodeCount: 2},
+		Config: &container.NodeConfig{
+			MachineType:    "e2-standard-2",
+			OauthScopes:    []string{container.CloudPlatformScope},
+			ServiceAccount: "sa-a",
+			Labels:         map[string]string{"role": "system"},
+			Preemptible:    true,
+			Taints:         []*container.NodeTaint{{Key: "dedicated", Value: "system", Effect: "NO_SCHEDULE"}},
+		},
+	}}
+	if dif := cmp.Diff(want, ccr.Cluster.NodePools); dif != "" {
+		t.Errorf("Node pools got(+) is different from wanted(-)\n%v", dif)
+	}
+	if dif := cmp.Diff(req.ResourceLabels, ccr.Cluster.ResourceLabels); dif != "" {
+		t.Errorf("Resource labels got(+) is different from wanted(-)\n%v", dif)
+	}
+
+	// Autopilot clusters have no node pools.
+	ccr, err = NewCreateClusterRequest(&Request{ClusterName: "name-b", Region: "us-central1", Autopilot: true})
+	if err != nil {
+		t.Fatalf("Expected no error for an Autopilot cluster, but got '%v'", err)
+	}
+	if ccr.Cluster.Autopilot == nil || !ccr.Cluster.Autopilot.Enabled || ccr.Cluster.NodePools != nil || ccr.Cluster.MasterAuth != nil {
+		t.Errorf("Expected an Autopilot cluster without node pools nor basic auth, got '%+v'", ccr.Cluster)
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	req := &Request{
+		ClusterName:    "name-a",
+		ResourceLabels: map[string]string{"team": "serving"},
+		NodePools: []NodePool{{
+			Name:   "system",
+			Taints: []Taint{{Key: "dedicated", Effect: TaintEffectNoSchedule}},
+			Labels: map[string]string{"role": "system"},
+		}},
+		Autopilot: true,
+	}
+	cp := req.DeepCopy()
+	if dif := cmp.Diff(req, cp); dif != "" {
+		t.Errorf("Copy got(+) is different from wanted(-)\n%v", dif)
+	}
+	cp.ResourceLabels["team"] = "eventing"
+	cp.NodePools[0].Labels["role"] = "test"
+	cp.NodePools[0].Taints[0].Key = "other"
+	if req.ResourceLabels["team"] != "serving" || req.NodePools[0].Labels["role"] != "system" || req.NodePools[0].Taints[0].Key != "dedicated" {
+		t.Errorf("Modifying the copy modified the original request: '%+v'", req)
+	}
+}
@@ -28,6 +28,7 @@ import (
 var (
 	creationTimeout = 20 * time.Minute
 	deletionTimeout = 10 * time.Minute
+	resizeTimeout   = 20 * time.Minute
 )
 
 const (
@@ -18,34 +18,54 @@ limitations under the License.
 
 package testgrid
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	// BaseURL is Knative testgrid base URL
 	BaseURL = "https://testgrid.knative.dev"
+	// K8sBaseURL is the base URL of the k8s testgrid, where the tabs of the
+	// Prow jobs annotations are
+	K8sBaseURL = "https://testgrid.k8s.io"
+
+	// DashboardsAnnotation is the Prow job annotation listing the dashboards
+	// of the job, separated by commas
+	DashboardsAnnotation = "testgrid-dashboards"
+	// TabNameAnnotation is the Prow job annotation naming the tab of the job,
+	// which defaults to the job name
+	TabNameAnnotation = "testgrid-tab-name"
 )
 
-// jobNameTestgridURLMap contains harded coded mapping of job name: Testgrid tab URL relative to base URL
-var jobNameTestgridURLMap = map[string]string{
-	"ci-knative-serving-continuous":           "serving#continuous",
-	"ci-knative-serving-istio-latest-mesh":    "serving#istio-latest-mesh",
-	"ci-knative-serving-istio-latest-no-mesh": "serving#istio-latest-no-mesh",
-	"ci-knative-serving-istio-stable-mesh":    "serving#istio-stable-mesh",
-	"ci-knative-serving-istio-stable-no-mesh": "serving#istio-stable-no-mesh",
-	"ci-knative-serving-gloo-0.17.1":          "serving#gloo-0.17.1",
-	"ci-knative-serving-kourier-stable":       "serving#kourier-stable",
-	"ci-knative-serving-contour-latest":       "serving#contour-latest",
-	"ci-knative-serving-ambassador-latest":    "serving#ambassador-latest",
+// GetTabURL gets Testgrid URL for the first tab of the test group, i.e. the
+// Prow job name, and filters for Testgrid
+func (ac *Config) GetTabURL(tgName string, filters []string) (string, error) {
+	relURL, err := ac.GetTabRelURL(tgName)
+	if err != nil {
+		return "", err
+	}
+	return tabURL(BaseURL, relURL, filters), nil
 }
 
-// GetTestgridTabURL gets Testgrid URL for giving job and filters for Testgrid
-func GetTestgridTabURL(jobName string, filters []string) (string, error) {
-	url, ok := jobNameTestgridURLMap[jobName]
-	if !ok {
-		return "", fmt.Errorf("cannot find Testgrid tab for job '%s'", jobName)
+// GetTabURLFromAnnotations gets the k8s Testgrid URL for the tab of the job in
+// its first dashboard, as set in its Prow job annotations, and filters for
+// Testgrid
+func GetTabURLFromAnnotations(jobName string, annotations map[string]string, filters []string) (string, error) {
+	dashboard := strings.TrimSpace(strings.Split(annotations[DashboardsAnnotation], ",")[0])
+	if dashboard == "" {
+		return "", fmt.Errorf("job '%s' has no '%s' annotation", jobName, DashboardsAnnotation)
 	}
+	tab := annotations[TabNameAnnotation]
+	if tab == "" {
+		tab = jobName
+	}
+	return tabURL(K8sBaseURL, fmt.Sprintf("%s#%s", dashboard, tab), filters), nil
+}
+
+func tabURL(baseURL, relURL string, filters []string) string {
 	for _, filter := range filters {
-		url += "&" + filter
+		relURL += "&" + filter
 	}
-	return fmt.Sprintf("%s/%s", BaseURL, url), nil
+	return fmt.Sprintf("%s/%s", baseURL, relURL)
 }
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testgrid
+
+import "testing"
+
+func TestGetTabURL(t *testing.T) {
+	ac, err := NewConfigFromFile(testConfigPath)
+	if err != nil {
+		t.Fatalf("Failed loading the config: '%v'", err)
+	}
+	got, err := ac.GetTabURL("ci-knative-serving-continuous", []string{"exclude-non-failed-tests=20", "sort-by-name="})
+	if err != nil {
+		t.Fatalf("Failed getting the tab URL: '%v'", err)
+	}
+	if want := "https://testgrid.knative.dev/serving#continuous&exclude-non-failed-tests=20&sort-by-name="; got != want {
+		t.Errorf("Tab URL want: '%s', got: '%s'", want, got)
+	}
+	if _, err := ac.GetTabURL("ci-knative-serving-nope", nil); err == nil {
+		t.Error("Tab URL for a missing testgroup, want: err, got: no err")
+	}
+}
+
+func TestGetTabURLFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{{
+		name:        "tab name",
+		annotations: map[string]string{DashboardsAnnotation: "serving", TabNameAnnotation: "serving-continuous"},
+		want:        "https://testgrid.k8s.io/serving#serving-continuous&exclude-non-failed-tests=20",
+	}, {
+		name:        "tab name defaulting to the job name",
+		annotations: map[string]string{DashboardsAnnotation: "serving"},
+		want:        "https://testgrid.k8s.io/serving#ci-knative-serving-continuous&exclude-non-failed-tests=20",
+	}, {
+		name:        "first of several dashboards",
+		annotations: map[string]string{DashboardsAnnotation: "serving, knative", TabNameAnnotation: "continuous"},
+		want:        "https://testgrid.k8s.io/serving#continuous&exclude-non-failed-tests=20",
+	}, {
+		name:        "no dashboard",
+		annotations: map[string]string{TabNameAnnotation: "continuous"},
+		wantErr:     true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := GetTabURLFromAnnotations("ci-knative-serving-continuous", test.annotations, []string{"exclude-non-failed-tests=20"})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Getting the tab URL, want err: %v, got: '%v'", test.wantErr, err)
+			}
+			if got != test.want {
+				t.Errorf("Tab URL want: '%s', got: '%s'", test.want, got)
+			}
+		})
+	}
+}
@@ -28,21 +28,88 @@ import (
 
 const configPath = "config/prod/prow/testgrid/testgrid.yaml"
 
-// Config is entire testgrid config
+// Config is entire testgrid config, as generated by config-generator
 type Config struct {
-	Dashboards []Dashboard `yaml:"dashboards"`
+	// DefaultTestGroup and DefaultDashboardTab are the defaults of the test
+	// groups and the dashboard tabs
+	DefaultTestGroup    *TestGroup       `yaml:"default_test_group,omitempty"`
+	DefaultDashboardTab *Tab             `yaml:"default_dashboard_tab,omitempty"`
+	TestGroups          []TestGroup      `yaml:"test_groups,omitempty"`
+	Dashboards          []Dashboard      `yaml:"dashboards"`
+	DashboardGroups     []DashboardGroup `yaml:"dashboard_groups,omitempty"`
+}
+
+// TestGroup is the results of a job read from GCS, shown in dashboard tabs
+type TestGroup struct {
+	Name                    string         `yaml:"name,omitempty"`
+	GCSPrefix               string         `yaml:"gcs_prefix,omitempty"`
+	DaysOfResults           int            `yaml:"days_of_results,omitempty"`
+	TestsNamePolicy         int            `yaml:"tests_name_policy,omitempty"`
+	IgnorePending           bool           `yaml:"ignore_pending,omitempty"`
+	ColumnHeader            []ColumnHeader `yaml:"column_header,omitempty"`
+	NumColumnsRecent        int            `yaml:"num_columns_recent,omitempty"`
+	UseKubernetesClient     bool           `yaml:"use_kubernetes_client,omitempty"`
+	IsExternal              bool           `yaml:"is_external,omitempty"`
+	AlertStaleResultsHours  int            `yaml:"alert_stale_results_hours,omitempty"`
+	NumFailuresToAlert      int            `yaml:"num_failures_to_alert,omitempty"`
+	NumPassesToDisableAlert int            `yaml:"num_passes_to_disable_alert,omitempty"`
+	AlertOptions            *AlertOptions  `yaml:"alert_options,omitempty"`
+	ShortTextMetric         string         `yaml:"short_text_metric,omitempty"`
+}
+
+// ColumnHeader is a custom column header of a test group
+type ColumnHeader struct {
+	ConfigurationValue string `yaml:"configuration_value"`
+}
+
+// AlertOptions are the options of the alerts of a test group or a tab
+type AlertOptions struct {
+	AlertMailToAddresses string `yaml:"alert_mail_to_addresses,omitempty"`
 }
 
 // Dashboard is single dashboard on testgrid
 type Dashboard struct {
 	Name string `yaml:"name"`
-	Tabs []Tab  `yaml:"dashboard_tab"`
+	Tabs []Tab  `yaml:"dashboard_tab,omitempty"`
 }
 
 // Tab is a single tab on testgrid
 type Tab struct {
-	Name          string `yaml:"name"`
-	TestGroupName string `yaml:"test_group_name"`
+	Name          string `yaml:"name,omitempty"`
+	TestGroupName string `yaml:"test_group_name,omitempty"`
+	// BaseOptions are the URL options of the tab, e.g. "sort-by-name="
+	BaseOptions             string        `yaml:"base_options,omitempty"`
+	AlertOptions            *AlertOptions `yaml:"alert_options,omitempty"`
+	NumFailuresToAlert      int           `yaml:"num_failures_to_alert,omitempty"`
+	NumPassesToDisableAlert int           `yaml:"num_passes_to_disable_alert,omitempty"`
+	NumColumnsRecent        int           `yaml:"num_columns_recent,omitempty"`
+
+	OpenTestTemplate      *URLTemplate `yaml:"open_test_template,omitempty"`
+	FileBugTemplate       *URLTemplate `yaml:"file_bug_template,omitempty"`
+	AttachBugTemplate     *URLTemplate `yaml:"attach_bug_template,omitempty"`
+	ResultsText           string       `yaml:"results_text,omitempty"`
+	ResultsURLTemplate    *URLTemplate `yaml:"results_url_template,omitempty"`
+	CodeSearchPath        string       `yaml:"code_search_path,omitempty"`
+	CodeSearchURLTemplate *URLTemplate `yaml:"code_search_url_template,omitempty"`
+}
+
+// URLTemplate is a URL with placeholders, e.g. <gcs_prefix>, and its query
+// options
+type URLTemplate struct {
+	URL     string      `yaml:"url,omitempty"`
+	Options []URLOption `yaml:"options,omitempty"`
+}
+
+// URLOption is a query option of a URL template
+type URLOption struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// DashboardGroup groups dashboards under a common name on testgrid
+type DashboardGroup struct {
+	Name           string   `yaml:"name"`
+	DashboardNames []string `yaml:"dashboard_names"`
 }
 
 // NewConfig loads from default config
@@ -79,3 +146,65 @@ func (ac *Config) GetTabRelURL(tgName string) (string, error) {
 	}
 	return "", fmt.Errorf("testgroup name '%s' not exist", tgName)
 }
+
+// Validate checks that the names of the test groups, dashboards and tabs are
+// set and unique, and that the tabs and dashboard groups reference existing
+// test groups and dashboards. Test groups are only checked if the config has
+// some, as they can be created from Prow job annotations instead.
+func (ac *Config) Validate() error {
+	var errs []error
+	testGroups := make(map[string]bool)
+	for i, tg := range ac.TestGroups {
+		switch {
+		case tg.Name == "":
+			errs = append(errs, fmt.Errorf("test group #%d has no name", i))
+		case testGroups[tg.Name]:
+			errs = append(errs, fmt.Errorf("test group '%s' is duplicated", tg.Name))
+		case tg.GCSPrefix == "":
+			errs = append(errs, fmt.Errorf("test group '%s' has no gcs_prefix", tg.Name))
+		}
+		testGroups[tg.Name] = true
+	}
+
+	dashboards := make(map[string]bool)
+	for i, dashboard := range ac.Dashboards {
+		switch {
+		case dashboard.Name == "":
+			errs = append(errs, fmt.Errorf("dashboard #%d has no name", i))
+		case dashboards[dashboard.Name]:
+			errs = append(errs, fmt.Errorf("dashboard '%s' is duplicated", dashboard.Name))
+		}
+		dashboards[dashboard.Name] = true
+
+		tabs := make(map[string]bool)
+		for j, tab := range dashboard.Tabs {
+			switch {
+			case tab.Name == "":
+				errs = append(errs, fmt.Errorf("tab #%d of dashboard '%s' has no name", j, dashboard.Name))
+			case tabs[tab.Name]:
+				errs = append(errs, fmt.Errorf("tab '%s' of dashboard '%s' is duplicated", tab.Name, dashboard.Name))
+			}
+			tabs[tab.Name] = true
+			if tab.TestGroupName == "" {
+				errs = append(errs, fmt.Errorf("tab '%s' of dashboard '%s' has no test_group_name", tab.Name, dashboard.Name))
+			} else if len(ac.TestGroups) != 0 && !testGroups[tab.TestGroupName] {
+				errs = append(errs, fmt.Errorf("tab '%s' of dashboard '%s' references test group '%s' which doesn't exist",
+					tab.Name, dashboard.Name, tab.TestGroupName))
+			}
+		}
+	}
+
+	grouped := make(map[string]string)
+	for _, group := range ac.DashboardGroups {
+		for _, name := range group.DashboardNames {
+			if !dashboards[name] {
+				errs = append(errs, fmt.Errorf("dashboard group '%s' references dashboard '%s' which doesn't exist", group.Name, name))
+			}
+			if other, ok := grouped[name]; ok {
+				errs = append(errs, fmt.Errorf("dashboard '%s' is in both dashboard groups '%s' and '%s'", name, other, group.Name))
+			}
+			grouped[name] = group.Name
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
@@ -17,20 +17,160 @@ limitations under the License.
 package testgrid
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
+const testConfigPath = "testdata/testgrid.yaml"
+
 func TestConfigPath(t *testing.T) {
-	if _, err := NewConfig(); err != nil {
+	ac, err := NewConfig()
+	if err != nil {
 		t.Fatalf("Testing default config file, want: no err, got: %v", err)
 	}
+	if err := ac.Validate(); err != nil {
+		t.Fatalf("Validating default config file, want: no err, got: %v", err)
+	}
+}
+
+func TestNewConfigFromFile(t *testing.T) {
+	ac, err := NewConfigFromFile(testConfigPath)
+	if err != nil {
+		t.Fatalf("Failed loading the config: '%v'", err)
+	}
+	want := &Config{
+		DefaultTestGroup: &TestGroup{
+			DaysOfResults:          14,
+			ColumnHeader:           []ColumnHeader{{ConfigurationValue: "Commit"}},
+			AlertStaleResultsHours: 26,
+		},
+		DefaultDashboardTab: &Tab{
+			OpenTestTemplate: &URLTemplate{URL: "https://prow.knative.dev/view/gcs/<gcs_prefix>/<changelist>"},
+			FileBugTemplate: &URLTemplate{
+				URL:     "https://github.com/knative/serving/issues/new",
+				Options: []URLOption{{Key: "title", Value: `Test "<test-name>" failed`}},
+			},
+			ResultsText: "See these results on Prow",
+		},
+		TestGroups: []TestGroup{{
+			Name:                   "ci-knative-serving-continuous",
+			GCSPrefix:              "knative-prow/logs/ci-knative-serving-continuous",
+			AlertStaleResultsHours: 3,
+		}, {
+			Name:            "ci-knative-serving-test-coverage",
+			GCSPrefix:       "knative-prow/logs/ci-knative-serving-go-coverage",
+			ShortTextMetric: "coverage",
+		}},
+		Dashboards: []Dashboard{{
+			Name: "serving",
+			Tabs: []Tab{{
+				Name:               "continuous",
+				TestGroupName:      "ci-knative-serving-continuous",
+				BaseOptions:        "sort-by-name=",
+				AlertOptions:       &AlertOptions{AlertMailToAddresses: "someone@example.com"},
+				NumFailuresToAlert: 3,
+			}, {
+				Name:          "conformance",
+				TestGroupName: "ci-knative-serving-continuous",
+				BaseOptions:   "include-filter-by-regex=test/conformance/&sort-by-name=",
+			}, {
+				Name:          "coverage",
+				TestGroupName: "ci-knative-serving-test-coverage",
+			}},
+		}},
+		DashboardGroups: []DashboardGroup{{Name: "knative", DashboardNames: []string{"serving"}}},
+	}
+	if diff := cmp.Diff(want, ac); diff != "" {
+		t.Errorf("Config got(+) is different from wanted(-)\n%v", diff)
+	}
+	if err := ac.Validate(); err != nil {
+		t.Errorf("Validating the config, want: no err, got: %v", err)
+	}
 }
 
 func TestTabName(t *testing.T) {
-	ac, _ := NewConfig()
-	for tgName, URL := range jobNameTestgridURLMap {
+	ac, err := NewConfigFromFile(testConfigPath)
+	if err != nil {
+		t.Fatalf("Failed loading the config: '%v'", err)
+	}
+	for tgName, URL := range map[string]string{
+		// the first tab of the test group wins
+		"ci-knative-serving-continuous":    "serving#continuous",
+		"ci-knative-serving-test-coverage": "serving#coverage",
+	} {
 		if got, _ := ac.GetTabRelURL(tgName); got != URL {
 			t.Fatalf("Testing testgroup/tab mapping for '%s', want: '%s', got: '%s'", tgName, URL, got)
 		}
 	}
+	if _, err := ac.GetTabRelURL("ci-knative-serving-nope"); err == nil {
+		t.Fatal("Testing testgroup/tab mapping for a missing testgroup, want: err, got: no err")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testGroups := []TestGroup{{Name: "tg", GCSPrefix: "logs/tg"}}
+	tests := []struct {
+		name string
+		ac   Config
+		errs []string
+	}{{
+		name: "valid",
+		ac: Config{
+			TestGroups:      testGroups,
+			Dashboards:      []Dashboard{{Name: "d", Tabs: []Tab{{Name: "t", TestGroupName: "tg"}}}},
+			DashboardGroups: []DashboardGroup{{Name: "g", DashboardNames: []string{"d"}}},
+		},
+	}, {
+		name: "no test groups, as in the k8s testgrid config",
+		ac: Config{
+			Dashboards: []Dashboard{{Name: "d", Tabs: []Tab{{Name: "t", TestGroupName: "tg"}}}},
+		},
+	}, {
+		name: "dangling test group",
+		ac: Config{
+			TestGroups: testGroups,
+			Dashboards: []Dashboard{{Name: "d", Tabs: []Tab{{Name: "t", TestGroupName: "other"}}}},
+		},
+		errs: []string{"tab 't' of dashboard 'd' references test group 'other' which doesn't exist"},
+	}, {
+		name: "dangling dashboard",
+		ac: Config{
+			Dashboards:      []Dashboard{{Name: "d"}},
+			DashboardGroups: []DashboardGroup{{Name: "g", DashboardNames: []string{"d", "other"}}},
+		},
+		errs: []string{"dashboard group 'g' references dashboard 'other' which doesn't exist"},
+	}, {
+		name: "duplicates and missing fields",
+		ac: Config{
+			TestGroups: []TestGroup{{Name: "tg", GCSPrefix: "logs/tg"}, {Name: "tg", GCSPrefix: "logs/tg"}, {Name: "nogcs"}},
+			Dashboards: []Dashboard{
+				{Name: "d", Tabs: []Tab{{Name: "t", TestGroupName: "tg"}, {Name: "t", TestGroupName: "tg"}, {TestGroupName: "tg"}}},
+				{Name: "d"},
+				{Name: "e", Tabs: []Tab{{Name: "t"}}},
+			},
+			DashboardGroups: []DashboardGroup{{Name: "g", DashboardNames: []string{"d"}}, {Name: "h", DashboardNames: []string{"d"}}},
+		},
+		errs: []string{
+			"test group 'tg' is duplicated",
+			"test group 'nogcs' has no gcs_prefix",
+			"tab 't' of dashboard 'd' is duplicated",
+			"tab #2 of dashboard 'd' has no name",
+			"dashboard 'd' is duplicated",
+			"tab 't' of dashboard 'e' has no test_group_name",
+			"dashboard 'd' is in both dashboard groups 'g' and 'h'",
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var errs []string
+			if err := test.ac.Validate(); err != nil {
+				errs = strings.Split(err.Error(), "\n")
+			}
+			if diff := cmp.Diff(test.errs, errs); diff != "" {
+				t.Errorf("Validation errors got(+) is different from wanted(-)\n%v", diff)
+			}
+		})
+	}
 }
@@ -5,4 +5,5 @@ go 1.15
 require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/spf13/pflag v1.0.5
+	gopkg.in/yaml.v2 v2.3.0
 )
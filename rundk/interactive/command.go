@@ -75,6 +75,28 @@ func (c Command) String() string {
 	return "Command to run: " + s
 }
 
+// ShellString returns the command quoted to be pasted into a shell
+func (c Command) ShellString() string {
+	words := []string{shellQuote(c.Name)}
+	for _, arg := range c.Args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote single-quotes s if it has characters special to the shell
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,@%+", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
 // AddArgs appends arguments to the current list of args
 func (c *Command) AddArgs(args ...string) {
 	c.Args = append(c.Args, args...)
@@ -65,3 +65,11 @@ func TestAddArgs(t *testing.T) {
 func TestCommandStringer(t *testing.T) {
 	t.Log(NewCommand("true"))
 }
+
+func TestShellString(t *testing.T) {
+	cmd := NewCommand("docker", "run", "-e", "A=b c", "--mount", "type=bind,source=/a,target=/b", "", "it's")
+	want := `docker run -e 'A=b c' --mount type=bind,source=/a,target=/b '' 'it'"'"'s'`
+	if got := cmd.ShellString(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
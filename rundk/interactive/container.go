@@ -23,21 +23,21 @@ import (
 	"log"
 	"os"
 	"path"
-	"strings"
+	"sort"
 )
 
-var defaultDockerCommands []string
-
-func init() {
-	defaultDockerCommands = []string{"docker", "run", "-it", "--rm", "--entrypoint", "bash"}
-}
+var (
+	defaultRunArgs        = []string{"run", "-it", "--rm"}
+	defaultEntrypointArgs = []string{"--entrypoint", "bash"}
+)
 
 // Env represents a collection of environment variables and their values
 type Env map[string]string
 
-// Docker is mostly an Command preloaded with arguments which setup Docker for running an image interactively.
-type Docker struct {
+// Container is mostly an Command preloaded with arguments which setup a container runtime for running an image interactively.
+type Container struct {
 	Command
+	Runtime Runtime
 }
 
 // PromoteFromEnv pulls the named environment variables from the environment and puts them in the Env.
@@ -55,31 +55,47 @@ func (e Env) PromoteFromEnv(envVars ...string) error {
 	return err
 }
 
-// NewDocker creates a Docker with default Docker command arguments for running interactively
-func NewDocker() Docker {
-	return Docker{NewCommand(defaultDockerCommands...)}
+// NewContainer creates a Container with default command arguments of the runtime for running interactively
+func NewContainer(r Runtime) Container {
+	cmdAndArgs := append([]string{r.Name()}, defaultRunArgs...)
+	cmdAndArgs = append(cmdAndArgs, r.RunArgs()...)
+	cmdAndArgs = append(cmdAndArgs, defaultEntrypointArgs...)
+	return Container{Command: NewCommand(cmdAndArgs...), Runtime: r}
+}
+
+// NewDocker creates a Container run by a rootful Docker
+func NewDocker() Container {
+	return NewContainer(&DockerRuntime{SocketPath: defaultDockerSocketPath})
 }
 
 // AddEnv adds arguments so all the environment variables present in e become part of the docker run's environment
-func (d *Docker) AddEnv(e Env) {
-	for k, v := range e {
-		d.AddArgs("-e", fmt.Sprintf("%s=%s", k, v))
+func (d *Container) AddEnv(e Env) {
+	// sorted so that the command is the same for the same env vars
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.AddArgs("-e", fmt.Sprintf("%s=%s", k, e[k]))
 	}
 }
 
-// AddMount add arguments for the --mount command
-func (d *Docker) AddMount(typeStr, source, target string, optAdditionalArgs ...string) {
-	addl := ""
-	if len(optAdditionalArgs) != 0 {
-		addl = "," + strings.Join(optAdditionalArgs, ",")
-	}
-	d.AddArgs("--mount", fmt.Sprintf("type=%s,source=%s,target=%s%s", typeStr, source, target, addl))
+// AddMount add arguments for mounting the source to the target, as the runtime expects them
+func (d *Container) AddMount(typeStr, source, target string, optAdditionalArgs ...string) {
+	d.AddArgs(d.Runtime.MountArgs(typeStr, source, target, optAdditionalArgs...)...)
+}
+
+// AddSocket add arguments for forwarding the socket of the runtime to the container,
+// so that docker commands run in the container use the runtime
+func (d *Container) AddSocket() {
+	d.AddArgs(d.Runtime.SocketArgs()...)
 }
 
 // CopyAndAddMount copies the source files into a temp directory, and then
 // mounts them as the target. It also returns a function to remove the temp
 // directory for cleaning up.
-func (d *Docker) CopyAndAddMount(typeStr, parentDir, source, target string, optAdditionalArgs ...string) func() {
+func (d *Container) CopyAndAddMount(typeStr, parentDir, source, target string, optAdditionalArgs ...string) func() {
 	fi, err := os.Stat(source)
 	if err != nil {
 		log.Fatalf("Error getting the FileInfo for %q: %v", source, err)
@@ -128,7 +144,7 @@ func (d *Docker) CopyAndAddMount(typeStr, parentDir, source, target string, optA
 // externalDirectory probably needs to be an absolute path
 // Returns a function to clean up the mount (but does not delete the directory).
 // Uses sudo and probably only works on Linux
-func (d *Docker) AddRWOverlay(externalDirectory, internalDirectory string) func() {
+func (d *Container) AddRWOverlay(externalDirectory, internalDirectory string) func() {
 	tmpDir, err := ioutil.TempDir("", "overlay")
 	if err != nil {
 		log.Fatal(err)
@@ -51,7 +51,7 @@ func TestEnv(t *testing.T) {
 func TestAddEnv(t *testing.T) {
 	d := NewDocker()
 	e := make(Env)
-	l := len(defaultDockerCommands)
+	l := len(d.Args) + 1
 	e["env"] = "var"
 	d.AddEnv(e)
 	d.run = func(c *exec.Cmd) error {
@@ -67,7 +67,7 @@ func TestAddEnv(t *testing.T) {
 
 func TestAddMount(t *testing.T) {
 	d := NewDocker()
-	l := len(defaultDockerCommands)
+	l := len(d.Args) + 1
 	d.AddMount("bind", "mysource", "mytarget", "other1=banana1", "other2=banana2")
 	d.run = func(c *exec.Cmd) error {
 		if c.Args[l] != "--mount" || c.Args[l+1] != "type=bind,source=mysource,target=mytarget,other1=banana1,other2=banana2" {
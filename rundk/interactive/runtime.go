@@ -0,0 +1,219 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interactive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	// DockerRuntimeName is the name of the Docker runtime and its executable.
+	DockerRuntimeName = "docker"
+	// PodmanRuntimeName is the name of the Podman runtime and its executable.
+	PodmanRuntimeName = "podman"
+
+	// containerSocketPath is where the socket of the runtime is forwarded to
+	// in the container, so that the docker CLI uses it by default.
+	containerSocketPath = "/var/run/docker.sock"
+
+	defaultDockerSocketPath         = "/var/run/docker.sock"
+	defaultPodmanSocketPath         = "/run/podman/podman.sock"
+	defaultRootlessPodmanSocketPath = "podman/podman.sock"
+	defaultRootlessDockerSocketPath = "docker.sock"
+)
+
+// could be replaced in tests to fake the runtimes installed
+var (
+	lookPath      = exec.LookPath
+	commandOutput = func(name string, args ...string) (string, error) {
+		out, err := exec.Command(name, args...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+)
+
+// Runtime is a container runtime, it knows the arguments to run a container
+// with it.
+type Runtime interface {
+	// Name is the executable of the runtime.
+	Name() string
+	// RunArgs returns the arguments of the run command specific to the runtime.
+	RunArgs() []string
+	// MountArgs returns the arguments mounting the source to the target.
+	MountArgs(typeStr, source, target string, optAdditionalArgs ...string) []string
+	// SocketArgs returns the arguments forwarding the socket of the runtime
+	// to the container as the docker socket, for running docker commands
+	// from the container.
+	SocketArgs() []string
+}
+
+// DockerRuntime runs containers with Docker.
+type DockerRuntime struct {
+	// Rootless is true if the Docker daemon runs as a non-root user.
+	Rootless bool
+	// SocketPath is the socket of the Docker daemon.
+	SocketPath string
+}
+
+// Name implements Runtime.
+func (r *DockerRuntime) Name() string {
+	return DockerRuntimeName
+}
+
+// RunArgs implements Runtime. The user namespace of a rootless Docker is set
+// up by the daemon, so there is nothing specific.
+func (r *DockerRuntime) RunArgs() []string {
+	return nil
+}
+
+// MountArgs implements Runtime.
+func (r *DockerRuntime) MountArgs(typeStr, source, target string, optAdditionalArgs ...string) []string {
+	addl := ""
+	if len(optAdditionalArgs) != 0 {
+		addl = "," + strings.Join(optAdditionalArgs, ",")
+	}
+	return []string{"--mount", fmt.Sprintf("type=%s,source=%s,target=%s%s", typeStr, source, target, addl)}
+}
+
+// SocketArgs implements Runtime.
+func (r *DockerRuntime) SocketArgs() []string {
+	return r.MountArgs("bind", r.SocketPath, containerSocketPath)
+}
+
+// PodmanRuntime runs containers with Podman.
+type PodmanRuntime struct {
+	// Rootless is true if Podman runs as a non-root user.
+	Rootless bool
+	// SocketPath is the socket of the Podman API service, which is compatible
+	// with the Docker API.
+	SocketPath string
+}
+
+// Name implements Runtime.
+func (r *PodmanRuntime) Name() string {
+	return PodmanRuntimeName
+}
+
+// RunArgs implements Runtime. Rootless Podman maps the user to itself in the
+// container, so that the files it writes into the mounts are owned by the
+// user instead of a subordinate ID.
+func (r *PodmanRuntime) RunArgs() []string {
+	if r.Rootless {
+		return []string{"--userns=keep-id"}
+	}
+	return nil
+}
+
+// MountArgs implements Runtime. Bind mounts are relabeled for SELinux, which
+// is enabled by default on the hosts Podman is the most used on, and a no-op
+// otherwise.
+func (r *PodmanRuntime) MountArgs(typeStr, source, target string, optAdditionalArgs ...string) []string {
+	if typeStr != "bind" {
+		return (&DockerRuntime{}).MountArgs(typeStr, source, target, optAdditionalArgs...)
+	}
+	opts := []string{"Z"}
+	for _, o := range optAdditionalArgs {
+		if o == "readonly" || o == "ro" {
+			opts = append(opts, "ro")
+		}
+	}
+	return []string{"-v", fmt.Sprintf("%s:%s:%s", source, target, strings.Join(opts, ","))}
+}
+
+// SocketArgs implements Runtime. The socket isn't relabeled, as its label
+// must stay the one of the Podman service, so the SELinux separation is
+// disabled for the container instead.
+func (r *PodmanRuntime) SocketArgs() []string {
+	return []string{"--security-opt", "label=disable", "-v", fmt.Sprintf("%s:%s", r.SocketPath, containerSocketPath)}
+}
+
+// DetectRuntime returns the runtime with the given name, or the one installed
+// if name is empty, preferring Docker. Whether it runs rootless is detected
+// unless rootless is true.
+func DetectRuntime(name string, rootless bool) (Runtime, error) {
+	if name == "" {
+		var err error
+		if name, err = detectRuntimeName(); err != nil {
+			return nil, err
+		}
+	}
+	switch name {
+	case DockerRuntimeName:
+		r := &DockerRuntime{Rootless: rootless || isRootlessDocker()}
+		r.SocketPath = dockerSocketPath(r.Rootless)
+		return r, nil
+	case PodmanRuntimeName:
+		r := &PodmanRuntime{Rootless: rootless || isRootlessPodman()}
+		r.SocketPath = podmanSocketPath(r.Rootless)
+		return r, nil
+	}
+	return nil, fmt.Errorf("unsupported container runtime %q, it must be %q or %q", name, DockerRuntimeName, PodmanRuntimeName)
+}
+
+// detectRuntimeName returns the name of the runtime installed. The docker
+// executable can be the one of podman-docker, which runs Podman.
+func detectRuntimeName() (string, error) {
+	if _, err := lookPath(DockerRuntimeName); err == nil {
+		out, err := commandOutput(DockerRuntimeName, "--version")
+		if err == nil && strings.Contains(strings.ToLower(out), PodmanRuntimeName) {
+			return PodmanRuntimeName, nil
+		}
+		return DockerRuntimeName, nil
+	}
+	if _, err := lookPath(PodmanRuntimeName); err == nil {
+		return PodmanRuntimeName, nil
+	}
+	return "", errors.New("neither docker nor podman is installed")
+}
+
+func isRootlessDocker() bool {
+	out, err := commandOutput(DockerRuntimeName, "info", "--format", "{{.SecurityOptions}}")
+	return err == nil && strings.Contains(out, "rootless")
+}
+
+func isRootlessPodman() bool {
+	out, err := commandOutput(PodmanRuntimeName, "info", "--format", "{{.Host.Security.Rootless}}")
+	return err == nil && out == "true"
+}
+
+// dockerSocketPath returns the socket of the Docker daemon, from DOCKER_HOST
+// if set.
+func dockerSocketPath(rootless bool) string {
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); rootless && runtimeDir != "" {
+		return path.Join(runtimeDir, defaultRootlessDockerSocketPath)
+	}
+	return defaultDockerSocketPath
+}
+
+// podmanSocketPath returns the socket of the Podman API service, as reported
+// by Podman if available.
+func podmanSocketPath(rootless bool) string {
+	if out, err := commandOutput(PodmanRuntimeName, "info", "--format", "{{.Host.RemoteSocket.Path}}"); err == nil && out != "" {
+		return strings.TrimPrefix(out, "unix://")
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); rootless && runtimeDir != "" {
+		return path.Join(runtimeDir, defaultRootlessPodmanSocketPath)
+	}
+	return defaultPodmanSocketPath
+}
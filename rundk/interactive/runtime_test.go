@@ -0,0 +1,198 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interactive
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		runtime Runtime
+		want    []string
+	}{{
+		name:    "docker",
+		runtime: &DockerRuntime{SocketPath: "/var/run/docker.sock"},
+		want: []string{"docker", "run", "-it", "--rm", "--entrypoint", "bash",
+			"--mount", "type=bind,source=/src,target=/dst",
+			"--mount", "type=bind,source=/key.json,target=/etc/key.json,readonly",
+			"--mount", "type=bind,source=/var/run/docker.sock,target=/var/run/docker.sock",
+			"image"},
+	}, {
+		name:    "rootless docker",
+		runtime: &DockerRuntime{Rootless: true, SocketPath: "/run/user/1000/docker.sock"},
+		want: []string{"docker", "run", "-it", "--rm", "--entrypoint", "bash",
+			"--mount", "type=bind,source=/src,target=/dst",
+			"--mount", "type=bind,source=/key.json,target=/etc/key.json,readonly",
+			"--mount", "type=bind,source=/run/user/1000/docker.sock,target=/var/run/docker.sock",
+			"image"},
+	}, {
+		name:    "podman",
+		runtime: &PodmanRuntime{SocketPath: "/run/podman/podman.sock"},
+		want: []string{"podman", "run", "-it", "--rm", "--entrypoint", "bash",
+			"-v", "/src:/dst:Z",
+			"-v", "/key.json:/etc/key.json:Z,ro",
+			"--security-opt", "label=disable", "-v", "/run/podman/podman.sock:/var/run/docker.sock",
+			"image"},
+	}, {
+		name:    "rootless podman",
+		runtime: &PodmanRuntime{Rootless: true, SocketPath: "/run/user/1000/podman/podman.sock"},
+		want: []string{"podman", "run", "-it", "--rm", "--userns=keep-id", "--entrypoint", "bash",
+			"-v", "/src:/dst:Z",
+			"-v", "/key.json:/etc/key.json:Z,ro",
+			"--security-opt", "label=disable", "-v", "/run/user/1000/podman/podman.sock:/var/run/docker.sock",
+			"image"},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewContainer(test.runtime)
+			c.AddMount("bind", "/src", "/dst")
+			c.AddMount("bind", "/key.json", "/etc/key.json", "readonly")
+			c.AddSocket()
+			c.AddArgs("image")
+			c.run = func(cmd *exec.Cmd) error {
+				if !reflect.DeepEqual(cmd.Args, test.want) {
+					t.Errorf("got args:\n%q\nwant:\n%q", cmd.Args, test.want)
+				}
+				return nil
+			}
+			c.Run()
+		})
+	}
+}
+
+// setenv sets the env var for the duration of the test
+func setenv(t *testing.T, key, value string) {
+	orig, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, orig)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestDetectRuntime(t *testing.T) {
+	origLookPath, origCommandOutput := lookPath, commandOutput
+	defer func() {
+		lookPath, commandOutput = origLookPath, origCommandOutput
+	}()
+
+	tests := []struct {
+		name      string
+		runtime   string
+		rootless  bool
+		installed []string
+		outputs   map[string]string
+		env       map[string]string
+		want      Runtime
+		wantErr   bool
+	}{{
+		name:      "docker installed",
+		installed: []string{"docker", "podman"},
+		outputs:   map[string]string{"docker --version": "Docker version 19.03.13, build 4484c46d9d"},
+		want:      &DockerRuntime{SocketPath: "/var/run/docker.sock"},
+	}, {
+		name:      "rootless docker",
+		installed: []string{"docker"},
+		outputs: map[string]string{
+			"docker --version":                          "Docker version 20.10.0, build 7287ab3",
+			"docker info --format {{.SecurityOptions}}": "[name=seccomp,profile=default name=rootless]",
+		},
+		env:  map[string]string{"DOCKER_HOST": "", "XDG_RUNTIME_DIR": "/run/user/1000"},
+		want: &DockerRuntime{Rootless: true, SocketPath: "/run/user/1000/docker.sock"},
+	}, {
+		name:      "docker host",
+		installed: []string{"docker"},
+		env:       map[string]string{"DOCKER_HOST": "unix:///home/me/docker.sock"},
+		want:      &DockerRuntime{SocketPath: "/home/me/docker.sock"},
+	}, {
+		name:      "podman-docker",
+		installed: []string{"docker", "podman"},
+		outputs: map[string]string{
+			"docker --version": "podman version 2.1.1",
+			"podman info --format {{.Host.Security.Rootless}}": "true",
+			"podman info --format {{.Host.RemoteSocket.Path}}": "unix:///run/user/1000/podman/podman.sock",
+		},
+		want: &PodmanRuntime{Rootless: true, SocketPath: "/run/user/1000/podman/podman.sock"},
+	}, {
+		name:      "podman installed",
+		installed: []string{"podman"},
+		outputs:   map[string]string{"podman info --format {{.Host.Security.Rootless}}": "false"},
+		want:      &PodmanRuntime{SocketPath: "/run/podman/podman.sock"},
+	}, {
+		name:      "forced rootless podman",
+		runtime:   "podman",
+		rootless:  true,
+		installed: []string{"docker", "podman"},
+		env:       map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"},
+		want:      &PodmanRuntime{Rootless: true, SocketPath: "/run/user/1000/podman/podman.sock"},
+	}, {
+		name:    "nothing installed",
+		wantErr: true,
+	}, {
+		name:      "unsupported runtime",
+		runtime:   "containerd",
+		installed: []string{"containerd"},
+		wantErr:   true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, "DOCKER_HOST", "")
+			setenv(t, "XDG_RUNTIME_DIR", "")
+			for k, v := range test.env {
+				setenv(t, k, v)
+			}
+			lookPath = func(file string) (string, error) {
+				for _, i := range test.installed {
+					if i == file {
+						return "/usr/bin/" + file, nil
+					}
+				}
+				return "", errors.New("not found")
+			}
+			commandOutput = func(name string, args ...string) (string, error) {
+				if out, ok := test.outputs[strings.Join(append([]string{name}, args...), " ")]; ok {
+					return out, nil
+				}
+				return "", errors.New("failed")
+			}
+
+			got, err := DetectRuntime(test.runtime, test.rootless)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got runtime %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed detecting the runtime: '%v'", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got runtime %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
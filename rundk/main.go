@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"go/build"
 	"io/ioutil"
 	"log"
 	"os"
@@ -13,6 +15,7 @@ import (
 	"github.com/spf13/pflag"
 
 	"knative.dev/test-infra/rundk/interactive"
+	"knative.dev/test-infra/rundk/prowjob"
 )
 
 const (
@@ -24,6 +27,9 @@ const (
 	gcloudDefaultConfigMountPath = "/root/.config/gcloud"
 	// The path to mount the kubectl default config files to the container.
 	kubectlDefaultConfigMountPath = "/root/.kube"
+
+	// The path of the Prow job configs under the test-infra repo.
+	jobConfigPath = "config/prod/prow/jobs"
 )
 
 var (
@@ -35,6 +41,15 @@ var (
 	mounts                    []string
 	mandatoryEnvVars          []string
 	optionalEnvVars           []string
+	jobName                   string
+	jobConfigDir              string
+	secrets                   map[string]string
+	printOnly                 bool
+	runtimeName               string
+	rootless                  bool
+
+	// jobSpec is set when reproducing a Prow job.
+	jobSpec *prowjob.RunSpec
 )
 
 func main() {
@@ -50,28 +65,117 @@ func main() {
 		"It must be in the format of `source1:target1,source2:target2,source3:target3`.")
 	pflag.StringSliceVar(&mandatoryEnvVars, "mandatory-env-vars", []string{}, "A list of env vars separated by comma that must be set on local.")
 	pflag.StringSliceVar(&optionalEnvVars, "optional-env-vars", []string{}, "A list of env vars separated by comma that optionally need to be set on local.")
+	pflag.StringVar(&jobName, "job", "", "The name of the Prow job to reproduce. The image, entrypoint, args, env vars and mounts are read from the job, "+
+		"the flags explicitly set and the test command override them.")
+	pflag.StringVar(&jobConfigDir, "config-dir", "", "The directory of the Prow job configs to read the job from, default to "+jobConfigPath+
+		" under the current repo or the knative.dev/test-infra repo in GOPATH.")
+	pflag.StringToStringVar(&secrets, "secret", map[string]string{}, "A list of secret names and the local files or directories mounted in their place, "+
+		"in the format of `name1=path1,name2=path2`. The service account secrets not set use the key file of GOOGLE_APPLICATION_CREDENTIALS.")
+	pflag.BoolVar(&printOnly, "print", false, "Print the docker command instead of running it.")
+	pflag.StringVar(&runtimeName, "runtime", "", "The container runtime to run the test flow with, docker or podman. "+
+		"Default to the one installed, preferring docker.")
+	pflag.BoolVar(&rootless, "rootless", false, "Run with the container runtime in rootless mode, which is detected if not set.")
 	pflag.Parse()
 
+	commandAndArgs := pflag.Args()
+	if jobName != "" {
+		commandAndArgs = loadJob(commandAndArgs)
+	}
+
 	cmd, cancel := setup()
 	defer cancel()
 
-	run(cmd, pflag.Args()...)
+	run(cmd, commandAndArgs...)
 }
 
-func setup() (interactive.Docker, func()) {
-	var err error
+// loadJob reads the Prow job to reproduce, and returns the command and args
+// to run.
+func loadJob(commandAndArgs []string) []string {
+	configDir, err := findJobConfigDir()
+	if err != nil {
+		log.Fatal("Error finding the Prow job configs: ", err)
+	}
+	job, err := prowjob.FindJob(configDir, jobName)
+	if err != nil {
+		log.Fatal("Error finding the Prow job: ", err)
+	}
+	jobSpec, err = job.RunSpec(prowjob.Options{
+		Secrets:           secrets,
+		ServiceAccountKey: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+	})
+	if err != nil {
+		log.Fatalf("Error reading the Prow job from %q: %v", job.File, err)
+	}
+	for _, w := range jobSpec.Warnings {
+		log.Print("Warning: ", w)
+	}
+	if jobSpec.Timeout != 0 {
+		log.Printf("The job times out after %v in CI", jobSpec.Timeout)
+	}
+
+	// Explicitly set flags take precedence over the job
+	if !pflag.CommandLine.Changed("image") {
+		image = jobSpec.Image
+	}
+	if !pflag.CommandLine.Changed("entrypoint") {
+		entrypoint = jobSpec.Entrypoint
+	}
+	if jobSpec.DockerInDocker {
+		dindEnabled = true
+	}
+	if len(commandAndArgs) != 0 {
+		return commandAndArgs
+	}
+	return jobSpec.Args
+}
+
+// findJobConfigDir returns the directory of the Prow job configs.
+func findJobConfigDir() (string, error) {
+	if jobConfigDir != "" {
+		return jobConfigDir, nil
+	}
+	var candidates []string
+	if repoRoot, err := repoRootDir(); err == nil {
+		candidates = append(candidates, path.Join(repoRoot, jobConfigPath))
+	}
+	candidates = append(candidates, path.Join(build.Default.GOPATH, "src", "knative.dev", "test-infra", jobConfigPath))
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && fi.IsDir() {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("none of %v exists, set --config-dir", candidates)
+}
+
+// copyAndAddMount copies the source and mounts the copy, or mounts the source
+// when only printing the command, since the copy would be removed on exit.
+func copyAndAddMount(cmd *interactive.Container, tmpDir, source, target string, optAdditionalArgs ...string) func() {
+	if printOnly {
+		cmd.AddMount("bind", source, target, optAdditionalArgs...)
+		return nil
+	}
+	return cmd.CopyAndAddMount("bind", tmpDir, source, target, optAdditionalArgs...)
+}
+
+func setup() (interactive.Container, func()) {
+	containerRuntime, err := interactive.DetectRuntime(runtimeName, rootless)
+	if err != nil {
+		log.Fatal("Error detecting the container runtime: ", err)
+	}
 
 	// Until everyone is using 20.xx version of docker (see https://github.com/docker/cli/pull/1498) adding the --pull flag,
 	//  need to separately pull the image first to be sure we have the latest
-	pull := interactive.NewCommand("docker", "pull", image)
-	if err = pull.Run(); err != nil {
-		log.Fatal("Error pulling the test image: ", err)
+	if !printOnly {
+		pull := interactive.NewCommand(containerRuntime.Name(), "pull", image)
+		if err = pull.Run(); err != nil {
+			log.Fatal("Error pulling the test image: ", err)
+		}
 	}
 
 	builtUpDefers := make([]func(), 0)
 
 	// Setup command
-	cmd := interactive.NewDocker()
+	cmd := interactive.NewContainer(containerRuntime)
 	envs := interactive.Env{}
 
 	// Setup temporary directory to save the artifacts
@@ -80,9 +184,28 @@ func setup() (interactive.Docker, func()) {
 		log.Fatal("Error setting up the temporary directory: ", err)
 	}
 
+	// Setup the env vars and secrets of the Prow job, the local flags below
+	// take precedence over them
+	if jobSpec != nil {
+		for k, v := range jobSpec.Env {
+			envs[k] = v
+		}
+		for _, m := range jobSpec.Mounts {
+			var addl []string
+			if m.ReadOnly {
+				addl = append(addl, "readonly")
+			}
+			cleanup := copyAndAddMount(&cmd, tmpDir, m.Source, m.Target, addl...)
+			builtUpDefers = append(builtUpDefers, cleanup)
+		}
+		if jobSpec.Privileged {
+			cmd.AddArgs("--privileged")
+		}
+	}
+
 	// Setup docker-in-docker
 	if dindEnabled {
-		cmd.AddMount("bind", "/var/run/docker.sock", "/var/run/docker.sock")
+		cmd.AddSocket()
 		// On a MAC machine, set to host networking to make DinD work. Not
 		// required on Linux.
 		if runtime.GOOS == "darwin" {
@@ -98,7 +221,7 @@ func setup() (interactive.Docker, func()) {
 		if gcloudKey != "" {
 			envs["GOOGLE_APPLICATION_CREDENTIALS"] = gcloudKey
 			if _, err := os.Stat(gcloudKey); !os.IsNotExist(err) {
-				cleanup := cmd.CopyAndAddMount("bind", tmpDir, gcloudKey, gcloudKey)
+				cleanup := copyAndAddMount(&cmd, tmpDir, gcloudKey, gcloudKey)
 				builtUpDefers = append(builtUpDefers, cleanup)
 			}
 		} else {
@@ -106,7 +229,7 @@ func setup() (interactive.Docker, func()) {
 			// We only want to use the default gcloud credentials if GOOGLE_APPLICATION_CREDENTIALS env var is not explicitly set.
 			defaultGcloudConfigPath := path.Join(os.Getenv("HOME"), ".config/gcloud")
 			if _, err := os.Stat(defaultGcloudConfigPath); !os.IsNotExist(err) {
-				cleanup := cmd.CopyAndAddMount("bind", tmpDir, defaultGcloudConfigPath, gcloudDefaultConfigMountPath)
+				cleanup := copyAndAddMount(&cmd, tmpDir, defaultGcloudConfigPath, gcloudDefaultConfigMountPath)
 				// Overwrite the gcloud config path, as per https://stackoverflow.com/a/48343135
 				envs["CLOUDSDK_CONFIG"] = gcloudDefaultConfigMountPath
 				builtUpDefers = append(builtUpDefers, cleanup)
@@ -123,7 +246,7 @@ func setup() (interactive.Docker, func()) {
 			envs["KUBECONFIG"] = kubeconfigEnvVarVal
 			for _, f := range strings.Split(kubeconfigEnvVarVal, string(os.PathListSeparator)) {
 				if _, err := os.Stat(f); !os.IsNotExist(err) {
-					cleanup := cmd.CopyAndAddMount("bind", tmpDir, f, f)
+					cleanup := copyAndAddMount(&cmd, tmpDir, f, f)
 					builtUpDefers = append(builtUpDefers, cleanup)
 				}
 			}
@@ -132,7 +255,7 @@ func setup() (interactive.Docker, func()) {
 			// We only want to use the default kube context if KUBECONFIG env var is not explicitly set.
 			defaultKubeconfigPath := path.Join(os.Getenv("HOME"), ".kube")
 			if _, err := os.Stat(defaultKubeconfigPath); !os.IsNotExist(err) {
-				cleanup := cmd.CopyAndAddMount("bind", tmpDir,
+				cleanup := copyAndAddMount(&cmd, tmpDir,
 					path.Join(os.Getenv("HOME"), ".kube"), kubectlDefaultConfigMountPath)
 				envs["KUBECONFIG"] = kubectlDefaultConfigMountPath
 				builtUpDefers = append(builtUpDefers, cleanup)
@@ -161,7 +284,12 @@ func setup() (interactive.Docker, func()) {
 	if err != nil {
 		log.Fatal("Error getting the repo's root directory: ", err)
 	}
-	cleanup := cmd.CopyAndAddMount("bind", tmpDir, repoRoot, repoRoot)
+	// Prow jobs run from where Prow clones the repo to
+	repoTarget := repoRoot
+	if jobSpec != nil && jobSpec.Workdir != "" {
+		repoTarget = jobSpec.Workdir
+	}
+	cleanup := copyAndAddMount(&cmd, tmpDir, repoRoot, repoTarget)
 	builtUpDefers = append(builtUpDefers, cleanup)
 
 	// Setup logging
@@ -185,6 +313,10 @@ func setup() (interactive.Docker, func()) {
 	if err != nil {
 		log.Fatal("Error getting the working directory: ", err)
 	}
+	if repoTarget != repoRoot {
+		// Keep the same directory relative to the repo root
+		wd = path.Join(repoTarget, strings.TrimPrefix(wd, repoRoot))
+	}
 	// Set starting directory to be the same as the current working directory.
 	cmd.AddArgs("-w=" + wd)
 
@@ -197,13 +329,19 @@ func setup() (interactive.Docker, func()) {
 	}
 }
 
-func run(cmd interactive.Docker, commandAndArgsOpt ...string) error {
+func run(cmd interactive.Container, commandAndArgsOpt ...string) error {
 	// Finally add the image then command to run (if any)
 	cmd.AddArgs(image)
-	log.Println(cmd)
 	if len(commandAndArgsOpt) != 0 {
 		cmd.AddArgs(entrypoint)
 		cmd.AddArgs(commandAndArgsOpt...)
+	}
+	if printOnly {
+		fmt.Println(cmd.ShellString())
+		return nil
+	}
+	log.Println(cmd)
+	if len(commandAndArgsOpt) != 0 {
 		log.Printf("👆 Starting in %d seconds, ^C to abort!", warmupTime)
 	}
 
@@ -0,0 +1,206 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package prowjob reads Prow jobs from the job config, and converts them to
+// what's needed to run them locally.
+package prowjob
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Job types, as set in the JOB_TYPE env var of Prow jobs.
+const (
+	Presubmit  = "presubmit"
+	Postsubmit = "postsubmit"
+	Periodic   = "periodic"
+)
+
+// JobConfig is the part of a Prow job config file read by rundk.
+type JobConfig struct {
+	Presubmits  map[string][]Job `yaml:"presubmits"`
+	Postsubmits map[string][]Job `yaml:"postsubmits"`
+	Periodics   []Job            `yaml:"periodics"`
+}
+
+// Job is the part of a Prow job read by rundk.
+type Job struct {
+	Name             string            `yaml:"name"`
+	Decorate         bool              `yaml:"decorate"`
+	DecorationConfig *DecorationConfig `yaml:"decoration_config"`
+	PathAlias        string            `yaml:"path_alias"`
+	ExtraRefs        []Ref             `yaml:"extra_refs"`
+	Spec             *PodSpec          `yaml:"spec"`
+
+	// Type is the type of the job, e.g. presubmit
+	Type string `yaml:"-"`
+	// Repo is the org/repo the job runs for, it's empty for periodic jobs
+	// without extra refs
+	Repo string `yaml:"-"`
+	// File is the config file the job was read from
+	File string `yaml:"-"`
+}
+
+// DecorationConfig configures the decoration of a Prow job.
+type DecorationConfig struct {
+	Timeout string `yaml:"timeout"`
+}
+
+// Ref is a repository cloned by Prow for a job.
+type Ref struct {
+	Org       string `yaml:"org"`
+	Repo      string `yaml:"repo"`
+	PathAlias string `yaml:"path_alias"`
+	BaseRef   string `yaml:"base_ref"`
+}
+
+// PodSpec is the pod running a Prow job.
+type PodSpec struct {
+	Containers []Container `yaml:"containers"`
+	Volumes    []Volume    `yaml:"volumes"`
+}
+
+// Container is a container of the pod running a Prow job.
+type Container struct {
+	Image           string           `yaml:"image"`
+	Command         []string         `yaml:"command"`
+	Args            []string         `yaml:"args"`
+	Env             []EnvVar         `yaml:"env"`
+	VolumeMounts    []VolumeMount    `yaml:"volumeMounts"`
+	SecurityContext *SecurityContext `yaml:"securityContext"`
+}
+
+// EnvVar is an env var of a container.
+type EnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// VolumeMount is a volume mounted in a container.
+type VolumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly"`
+}
+
+// SecurityContext is the security context of a container.
+type SecurityContext struct {
+	Privileged bool `yaml:"privileged"`
+}
+
+// Volume is a volume of the pod running a Prow job.
+type Volume struct {
+	Name      string           `yaml:"name"`
+	Secret    *SecretSource    `yaml:"secret"`
+	ConfigMap *ConfigMapSource `yaml:"configMap"`
+	HostPath  *HostPathSource  `yaml:"hostPath"`
+	EmptyDir  *struct{}        `yaml:"emptyDir"`
+}
+
+// SecretSource is a volume populated by a secret.
+type SecretSource struct {
+	SecretName string `yaml:"secretName"`
+}
+
+// ConfigMapSource is a volume populated by a config map.
+type ConfigMapSource struct {
+	Name string `yaml:"name"`
+}
+
+// HostPathSource is a volume mapped to a path of the node.
+type HostPathSource struct {
+	Path string `yaml:"path"`
+}
+
+// FindJob looks for the job with the given name in the yaml files under
+// configDir, and returns an error if there is not exactly one.
+func FindJob(configDir, name string) (*Job, error) {
+	var found []*Job
+	err := filepath.Walk(configDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+		jobs, err := readJobs(path)
+		if err != nil {
+			return err
+		}
+		for i := range jobs {
+			if jobs[i].Name == name {
+				found = append(found, &jobs[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no job named %q in %q", name, configDir)
+	case 1:
+		return found[0], nil
+	}
+	var where []string
+	for _, j := range found {
+		where = append(where, fmt.Sprintf("%s %s in %s", j.Repo, j.Type, j.File))
+	}
+	sort.Strings(where)
+	return nil, fmt.Errorf("%d jobs named %q: %s", len(found), name, strings.Join(where, ", "))
+}
+
+// readJobs returns all the jobs of the config file, with their type and repo set.
+func readJobs(path string) ([]Job, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config JobConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed parsing %q: %w", path, err)
+	}
+	var jobs []Job
+	add := func(typ, repo string, j Job) {
+		j.Type, j.Repo, j.File = typ, repo, path
+		if repo == "" && len(j.ExtraRefs) > 0 {
+			j.Repo = j.ExtraRefs[0].Org + "/" + j.ExtraRefs[0].Repo
+		}
+		jobs = append(jobs, j)
+	}
+	for repo, js := range config.Presubmits {
+		for _, j := range js {
+			add(Presubmit, repo, j)
+		}
+	}
+	for repo, js := range config.Postsubmits {
+		for _, j := range js {
+			add(Postsubmit, repo, j)
+		}
+	}
+	for _, j := range config.Periodics {
+		add(Periodic, "", j)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prowjob
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfigDir = "testdata/jobs"
+
+func TestFindJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      string
+		wantType string
+		wantRepo string
+		wantFile string
+		wantErr  string
+	}{{
+		name:     "presubmit",
+		job:      "pull-knative-serving-integration-tests",
+		wantType: Presubmit,
+		wantRepo: "knative/serving",
+		wantFile: filepath.Join(testConfigDir, "config.yaml"),
+	}, {
+		name:     "periodic with extra refs",
+		job:      "ci-knative-client-continuous",
+		wantType: Periodic,
+		wantRepo: "knative/client",
+		wantFile: filepath.Join(testConfigDir, "config.yaml"),
+	}, {
+		name:    "not found",
+		job:     "pull-knative-serving-nothing",
+		wantErr: "no job named",
+	}, {
+		name:    "found in several files",
+		job:     "pull-knative-serving-unit-tests",
+		wantErr: "2 jobs named",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			j, err := FindJob(testConfigDir, test.job)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Expected an error containing %q, got '%v'", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed finding the job: '%v'", err)
+			}
+			if j.Name != test.job || j.Type != test.wantType || j.Repo != test.wantRepo || j.File != test.wantFile {
+				t.Errorf("got job %q, type %q, repo %q, file %q, want %q, %q, %q, %q",
+					j.Name, j.Type, j.Repo, j.File, test.job, test.wantType, test.wantRepo, test.wantFile)
+			}
+		})
+	}
+}
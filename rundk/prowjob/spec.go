@@ -0,0 +1,193 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prowjob
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// GoPath is the GOPATH of decorated Prow jobs.
+	GoPath = "/home/prow/go"
+
+	dindEnvVar         = "DOCKER_IN_DOCKER_ENABLED"
+	credentialsEnvVar  = "GOOGLE_APPLICATION_CREDENTIALS"
+	serviceAccountFile = "service-account.json"
+	// secrets created for the service accounts are all named after this suffix,
+	// e.g. test-account, and hold the key as service-account.json
+	serviceAccountSuffix = "-account"
+)
+
+// Options configures how the Prow job is converted to run locally.
+type Options struct {
+	// Secrets maps the names of the secrets to the local files or directories
+	// mounted in their place.
+	Secrets map[string]string
+	// ServiceAccountKey is the local key file mounted in place of the
+	// service account secrets not in Secrets.
+	ServiceAccountKey string
+}
+
+// Mount is a local file or directory mounted in the container.
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// RunSpec is what's needed to run a Prow job locally.
+type RunSpec struct {
+	Image      string
+	Entrypoint string
+	Args       []string
+	Env        map[string]string
+	Mounts     []Mount
+	// DockerInDocker is true if the job runs docker commands, which are sent
+	// to the local docker daemon instead of the one started in the container.
+	DockerInDocker bool
+	Privileged     bool
+	// Workdir is where the repository is cloned to, and the job starts from.
+	Workdir string
+	// Timeout is the timeout of the job in CI, 0 if not set.
+	Timeout time.Duration
+	// Warnings lists the parts of the job that can't be reproduced locally.
+	Warnings []string
+}
+
+// RunSpec converts the job to what's needed to run it locally.
+func (j *Job) RunSpec(opts Options) (*RunSpec, error) {
+	if j.Spec == nil || len(j.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("job %q has no container", j.Name)
+	}
+	if len(j.Spec.Containers) > 1 {
+		return nil, fmt.Errorf("job %q has %d containers, only one is supported", j.Name, len(j.Spec.Containers))
+	}
+	c := j.Spec.Containers[0]
+	if len(c.Command) == 0 {
+		return nil, errors.New("the container has no command")
+	}
+
+	spec := &RunSpec{
+		Image:      c.Image,
+		Entrypoint: c.Command[0],
+		Args:       append(append([]string{}, c.Command[1:]...), c.Args...),
+		Env:        make(map[string]string),
+	}
+	for _, e := range c.Env {
+		if e.Name == dindEnvVar {
+			spec.DockerInDocker = e.Value == "true"
+			continue
+		}
+		spec.Env[e.Name] = e.Value
+	}
+	spec.Privileged = c.SecurityContext != nil && c.SecurityContext.Privileged && !spec.DockerInDocker
+
+	spec.Env["JOB_NAME"] = j.Name
+	spec.Env["JOB_TYPE"] = j.Type
+	if org, repo, ok := splitRepo(j.Repo); ok {
+		spec.Env["REPO_OWNER"] = org
+		spec.Env["REPO_NAME"] = repo
+	}
+	if j.Decorate {
+		spec.Env["GOPATH"] = GoPath
+		if p := j.clonePath(); p != "" {
+			spec.Workdir = path.Join(GoPath, "src", p)
+		}
+		if j.DecorationConfig != nil && j.DecorationConfig.Timeout != "" {
+			timeout, err := time.ParseDuration(j.DecorationConfig.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout %q: %w", j.DecorationConfig.Timeout, err)
+			}
+			spec.Timeout = timeout
+		}
+	}
+
+	volumes := make(map[string]Volume)
+	for _, v := range j.Spec.Volumes {
+		volumes[v.Name] = v
+	}
+	for _, m := range c.VolumeMounts {
+		v, ok := volumes[m.Name]
+		if !ok {
+			return nil, fmt.Errorf("volume %q mounted at %q is not defined", m.Name, m.MountPath)
+		}
+		switch {
+		case v.Secret != nil:
+			spec.addSecret(v.Secret.SecretName, m, opts)
+		case (v.HostPath != nil || v.EmptyDir != nil) && spec.DockerInDocker:
+			// volumes for the docker daemon started in the container, which
+			// isn't needed when using the local one
+		case v.EmptyDir != nil:
+			// an empty directory is what the container has without a mount
+		default:
+			spec.Warnings = append(spec.Warnings, fmt.Sprintf("volume %q mounted at %q is not supported, it's skipped", m.Name, m.MountPath))
+		}
+	}
+	return spec, nil
+}
+
+// addSecret mounts the local equivalent of the secret, or adds a warning if
+// there is none.
+func (s *RunSpec) addSecret(name string, m VolumeMount, opts Options) {
+	if local, ok := opts.Secrets[name]; ok {
+		s.Mounts = append(s.Mounts, Mount{Source: local, Target: m.MountPath, ReadOnly: m.ReadOnly})
+		return
+	}
+	if strings.HasSuffix(name, serviceAccountSuffix) && opts.ServiceAccountKey != "" {
+		s.Mounts = append(s.Mounts, Mount{
+			Source:   opts.ServiceAccountKey,
+			Target:   path.Join(m.MountPath, serviceAccountFile),
+			ReadOnly: m.ReadOnly,
+		})
+		return
+	}
+	s.Warnings = append(s.Warnings, fmt.Sprintf("secret %q mounted at %q has no local equivalent, set it with --secret=%s=<path>", name, m.MountPath, name))
+}
+
+// clonePath returns the path under $GOPATH/src Prow clones the repository to.
+func (j *Job) clonePath() string {
+	if j.Type == Periodic {
+		if len(j.ExtraRefs) == 0 {
+			return ""
+		}
+		ref := j.ExtraRefs[0]
+		if ref.PathAlias != "" {
+			return ref.PathAlias
+		}
+		return path.Join("github.com", ref.Org, ref.Repo)
+	}
+	if j.PathAlias != "" {
+		return j.PathAlias
+	}
+	if j.Repo == "" {
+		return ""
+	}
+	return path.Join("github.com", j.Repo)
+}
+
+// splitRepo splits org/repo.
+func splitRepo(orgRepo string) (string, string, bool) {
+	parts := strings.Split(orgRepo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prowjob
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestRunSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		job  string
+		opts Options
+		want *RunSpec
+	}{{
+		name: "docker in docker with secrets",
+		job:  "pull-knative-serving-integration-tests",
+		opts: Options{
+			Secrets:           map[string]string{"repoview-token": "/home/me/token"},
+			ServiceAccountKey: "/home/me/key.json",
+		},
+		want: &RunSpec{
+			Image:      "gcr.io/knative-tests/test-infra/prow-tests:stable",
+			Entrypoint: "runner.sh",
+			Args:       []string{"./test/presubmit-tests.sh", "--integration-tests"},
+			Env: map[string]string{
+				"GOOGLE_APPLICATION_CREDENTIALS": "/etc/test-account/service-account.json",
+				"JOB_NAME":                       "pull-knative-serving-integration-tests",
+				"JOB_TYPE":                       Presubmit,
+				"REPO_OWNER":                     "knative",
+				"REPO_NAME":                      "serving",
+				"GOPATH":                         GoPath,
+			},
+			Mounts: []Mount{
+				{Source: "/home/me/token", Target: "/etc/repoview-token", ReadOnly: true},
+				{Source: "/home/me/key.json", Target: "/etc/test-account/service-account.json", ReadOnly: true},
+			},
+			DockerInDocker: true,
+			Workdir:        "/home/prow/go/src/knative.dev/serving",
+		},
+	}, {
+		name: "secrets without local equivalent",
+		job:  "pull-knative-serving-integration-tests",
+		want: &RunSpec{
+			Image:      "gcr.io/knative-tests/test-infra/prow-tests:stable",
+			Entrypoint: "runner.sh",
+			Args:       []string{"./test/presubmit-tests.sh", "--integration-tests"},
+			Env: map[string]string{
+				"GOOGLE_APPLICATION_CREDENTIALS": "/etc/test-account/service-account.json",
+				"JOB_NAME":                       "pull-knative-serving-integration-tests",
+				"JOB_TYPE":                       Presubmit,
+				"REPO_OWNER":                     "knative",
+				"REPO_NAME":                      "serving",
+				"GOPATH":                         GoPath,
+			},
+			DockerInDocker: true,
+			Workdir:        "/home/prow/go/src/knative.dev/serving",
+			Warnings: []string{
+				`secret "repoview-token" mounted at "/etc/repoview-token" has no local equivalent, set it with --secret=repoview-token=<path>`,
+				`secret "test-account" mounted at "/etc/test-account" has no local equivalent, set it with --secret=test-account=<path>`,
+			},
+		},
+	}, {
+		name: "periodic",
+		job:  "ci-knative-client-continuous",
+		want: &RunSpec{
+			Image:      "gcr.io/knative-tests/test-infra/prow-tests:stable",
+			Entrypoint: "runner.sh",
+			Args:       []string{"./test/presubmit-tests.sh", "--all-tests"},
+			Env: map[string]string{
+				"GOOGLE_APPLICATION_CREDENTIALS": "/etc/test-account/service-account.json",
+				"JOB_NAME":                       "ci-knative-client-continuous",
+				"JOB_TYPE":                       Periodic,
+				"REPO_OWNER":                     "knative",
+				"REPO_NAME":                      "client",
+				"GOPATH":                         GoPath,
+			},
+			Workdir:  "/home/prow/go/src/github.com/knative/client",
+			Timeout:  2 * time.Hour,
+			Warnings: []string{`volume "config" mounted at "/etc/config" is not supported, it's skipped`},
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			j, err := FindJob(testConfigDir, test.job)
+			if err != nil {
+				t.Fatalf("Failed finding the job: '%v'", err)
+			}
+			got, err := j.RunSpec(test.opts)
+			if err != nil {
+				t.Fatalf("Failed converting the job: '%v'", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", spew.Sdump(got), spew.Sdump(test.want))
+			}
+		})
+	}
+}
+
+func TestRunSpecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		job  *Job
+	}{{
+		name: "no container",
+		job:  &Job{Name: "no-container", Spec: &PodSpec{}},
+	}, {
+		name: "no command",
+		job:  &Job{Name: "no-command", Spec: &PodSpec{Containers: []Container{{Image: "image"}}}},
+	}, {
+		name: "undefined volume",
+		job: &Job{Name: "undefined-volume", Spec: &PodSpec{Containers: []Container{{
+			Image:        "image",
+			Command:      []string{"runner.sh"},
+			VolumeMounts: []VolumeMount{{Name: "nothing", MountPath: "/etc/nothing"}},
+		}}}},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.job.RunSpec(Options{}); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
@@ -130,6 +130,17 @@ function create_kind_test_cluster() {
   kubetest2 kind "${_custom_flags[@]}" --up --down --test=exec -- "${_test_command[@]}"
 }
 
+# Acquire a GKE project from Boskos, and keep it busy until the script exits,
+# when it's released. The project name is exported as E2E_BOSKOS_PROJECT.
+function acquire_boskos_project() {
+  E2E_BOSKOS_PROJECT="$(run_kntest cluster gke boskos acquire)" || return 1
+  export E2E_BOSKOS_PROJECT
+  echo "Acquired Boskos project ${E2E_BOSKOS_PROJECT}"
+  run_kntest cluster gke boskos heartbeat --project="${E2E_BOSKOS_PROJECT}" --release &
+  local heartbeat_pid=$!
+  add_trap "kill ${heartbeat_pid}; wait ${heartbeat_pid}" EXIT
+}
+
 # Create a GKE test cluster with kubetest2 and run the test command.
 # Parameters: $1 - custom flags defined in kntest
 #             $2 - test command to run after the cluster is created (optional)
@@ -137,5 +148,10 @@ function create_gke_test_cluster() {
   local -n _custom_flags=$1
   local -n _test_command=$2
 
+  # Hold the Boskos project for the whole job, not only while kubetest2 runs.
+  if (( IS_BOSKOS )); then
+    acquire_boskos_project || return 1
+    _custom_flags+=("--gcp-project-id=${E2E_BOSKOS_PROJECT}")
+  fi
   run_kntest kubetest2 gke "${_custom_flags[@]}" --test-command="${_test_command[*]}"
 }
@@ -136,6 +136,15 @@ function reconcile_benchmark_clusters() {
   # can be as long as <update_clusters interval>.
 }
 
+# Print the changes needed to make clusters consistent with the benchmarks in the current repo,
+# without applying them.
+function plan_benchmark_clusters() {
+  header "Planning clusters for ${REPO_NAME}"
+  run_perf_cluster_tool --plan \
+    --gcp-project="${PROJECT_NAME}" --repository="${REPO_NAME}" --benchmark-root="${BENCHMARK_ROOT_PATH}" \
+    || abort "failed planning clusters for ${REPO_NAME}"
+}
+
 # Parse flags and excute the command.
 function main() {
   if (( ! IS_PROW )); then
@@ -150,7 +159,8 @@ function main() {
     --recreate-clusters) recreate_clusters ;;
     --update-clusters) update_clusters ;;
     --reconcile-benchmark-clusters) reconcile_benchmark_clusters ;;
-    *) abort "unknown command $1, must be --recreate-clusters, --update-clusters or --reconcile_benchmark_clusters"
+    --plan-benchmark-clusters) plan_benchmark_clusters ;;
+    *) abort "unknown command $1, must be --recreate-clusters, --update-clusters, --reconcile-benchmark-clusters or --plan-benchmark-clusters"
   esac
   shift
 }
@@ -14,8 +14,8 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// The cleanup tool deletes old images and test clusters from test
-// projects.
+// The cleanup tool deletes old images, test clusters and other leaked test
+// resources from test projects.
 
 package main
 
@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -34,23 +36,8 @@ import (
 
 	"knative.dev/test-infra/tools/cleanup/options"
 
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/google"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/pkg/errors"
-	"google.golang.org/api/option"
-	"knative.dev/test-infra/pkg/cmd"
 	"knative.dev/test-infra/pkg/gke"
-	"knative.dev/test-infra/pkg/helpers"
-)
-
-var (
-	// Authentication method for using Google Cloud Registry APIs.
-	defaultKeychain = authn.DefaultKeychain
-
-	// Alias of remote.Delete for testing purposes.
-	remoteDelete = remote.Delete
 )
 
 // ResourceDeleter deletes a specific kind of resource in a GCP project.
@@ -59,6 +46,7 @@ type ResourceDeleter interface {
 	Delete(hoursToKeepResource int, concurrentOperations int, dryRun bool) (int, []string)
 	DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error)
 	ShowStats(count int, errors []string)
+	SetRetention(policy *RetentionPolicy, audit *auditReport)
 }
 
 // BaseResourceDeleter implements the base operations of a ResourceDeleter.
@@ -66,12 +54,16 @@ type BaseResourceDeleter struct {
 	ResourceDeleter
 	projects           []string
 	deleteResourceFunc func(string, int, bool) (int, error)
+	resourceType       string
+	policy             *RetentionPolicy
+	audit              *auditReport
 }
 
 // ImageDeleter deletes old images in a given registry.
 type ImageDeleter struct {
 	BaseResourceDeleter
-	registry string
+	registry       string
+	registryClient RegistryOperations
 }
 
 // GkeClusterDeleter deletes old GKE cluster in a given project.
@@ -89,33 +81,21 @@ func NewBaseResourceDeleter(projects []string) *BaseResourceDeleter {
 
 // NewImageDeleter returns a brand new ImageDeleter.
 func NewImageDeleter(projects []string, registry string, serviceAccount string) (*ImageDeleter, error) {
-	var err error
-	deleter := ImageDeleter{*NewBaseResourceDeleter(projects), registry}
+	client, err := newRegistryClient(serviceAccount)
+	deleter := ImageDeleter{*NewBaseResourceDeleter(projects), registry, client}
+	deleter.resourceType = imagesResourceType
 	deleter.deleteResourceFunc = deleter.DeleteResources
-	if serviceAccount != "" {
-		// Activate the service account.
-		_, err = cmd.RunCommand("gcloud auth activate-service-account --key-file=" + serviceAccount)
-		if err != nil {
-			if cmdErr, ok := err.(*cmd.CommandLineError); ok {
-				err = fmt.Errorf("cannot activate service account:\n%s", cmdErr.ErrorOutput)
-			}
-		}
-	}
 	return &deleter, err
 }
 
 // NewGkeClusterDeleter returns a brand new GkeClusterDeleter.
 func NewGkeClusterDeleter(projects []string, serviceAccount string) (*GkeClusterDeleter, error) {
-	opts := make([]option.ClientOption, 0)
-	if serviceAccount != "" {
-		// Create GKE client with specific credentials.
-		opts = append(opts, option.WithCredentialsFile(serviceAccount))
-	}
-	gkeClient, err := gke.NewSDKClient(opts...)
+	gkeClient, err := gke.NewSDKClient(clientOptions(serviceAccount)...)
 	if err != nil {
 		err = errors.Wrapf(err, "cannot create GKE SDK client")
 	}
 	deleter := GkeClusterDeleter{*NewBaseResourceDeleter(projects), gkeClient}
+	deleter.resourceType = clustersResourceType
 	deleter.deleteResourceFunc = deleter.DeleteResources
 	return &deleter, err
 }
@@ -171,101 +151,79 @@ func fromResourceFiles(resourceFiles []string, regex string) ([]string, error) {
 	return projects, nil
 }
 
-// deleteImage deletes a single image pointed by the given reference.
-func (d *ImageDeleter) deleteImage(ref string) error {
-	image, err := name.ParseReference(ref)
-	if err != nil {
-		return errors.Wrapf(err, "failed to parse reference %q", ref)
-	}
-	if err := remoteDelete(image, remote.WithAuthFromKeychain(defaultKeychain)); err != nil {
-		return errors.Wrapf(err, "failed to delete %q", image)
-	}
-	return nil
-}
-
 // DeleteResources deletes old clusters from a given project.
 func (d *GkeClusterDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
-	before := time.Now().Add(-time.Hour * time.Duration(hoursToKeepResource))
 	// TODO(adrcunha): Consider exposing https://github.com/knative/pkg/blob/6d806b998379948bd0107d77bcd831e2bdb4f3cb/testutils/clustermanager/e2e-tests/gke.go#L281
 	if project == "knative-tests" {
 		return 0, fmt.Errorf("cleaning up %q is forbidden", project)
 	}
-	// List clusters, delete those created before the given timestamp.
+	// List clusters, delete those the retention policy doesn't keep.
 	clusters, err := d.gkeClient.ListClustersInProject(project)
 	if err != nil {
 		return 0, errors.Wrapf(err, "error listing clusters in %q, maybe try 'gcloud auth application-default login'", project)
 	}
-	count := 0
-	for _, cluster := range clusters {
-		creation, err := time.Parse(time.RFC3339, cluster.CreateTime)
-		if err != nil {
-			return count, errors.Wrapf(err, "error getting creation time for cluster %q", cluster.Name)
-		}
-		age := int(time.Since(creation).Hours())
-		fullClusterName := project + "/" + cluster.Name
-		log.Printf("%s is %d hours old", fullClusterName, age)
-		if creation.Before(before) {
-			if err := helpers.Run(fmt.Sprintf("Deleting %q", fullClusterName), func() error {
-				region, zone := gke.RegionZoneFromLoc(cluster.Location)
-				if err := d.gkeClient.DeleteCluster(project, region, zone, cluster.Name); err != nil {
-					return errors.Wrapf(err, "error deleting cluster %q in project %q", cluster.Name, project)
-				}
-				count++
-				return nil
-			}, dryRun); err != nil {
-				return count, err
-			}
+	resources := make([]cloudResource, len(clusters))
+	for i, cluster := range clusters {
+		resources[i] = cloudResource{
+			Name:              cluster.Name,
+			Location:          cluster.Location,
+			CreationTimestamp: cluster.CreateTime,
+			Labels:            cluster.ResourceLabels,
 		}
 	}
-	return count, nil
+	return d.applyRetention("cluster", project, resources, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		region, zone := gke.RegionZoneFromLoc(r.Location)
+		return d.gkeClient.DeleteCluster(project, region, zone, r.Name)
+	})
 }
 
 // DeleteResources deletes old docker images from a given project.
 func (d *ImageDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
-	before := time.Now().Add(-time.Hour * time.Duration(hoursToKeepResource))
 	repoRoot := d.registry + "/" + project
 	// TODO(adrcunha): This should be a helper function, like https://github.com/knative/pkg/blob/6d806b998379948bd0107d77bcd831e2bdb4f3cb/testutils/clustermanager/e2e-tests/gke.go#L281
 	if repoRoot == "gcr.io/knative-releases" || repoRoot == "gcr.io/knative-nightly" {
 		return 0, fmt.Errorf("cleaning up %q is forbidden", repoRoot)
 	}
-	gcrrepo, err := name.NewRepository(repoRoot)
-	if err != nil {
-		return 0, errors.Wrapf(err, "cannot open registry %q", repoRoot)
-	}
 	count := 0
 	// Walk down the registry, checking all images and deleting the old ones.
-	return count, google.Walk(gcrrepo, func(repo name.Repository, tags *google.Tags, err error) error {
-		// If we got an error, just return it, there's nothing to do here.
-		if tags == nil || err != nil {
-			if err == nil {
-				return fmt.Errorf("unexpected nil tags for %q", repo)
-			}
-			return errors.Wrapf(err, "cannot walk down GCR %q", repo.String())
+	repos := []string{repoRoot}
+	for len(repos) > 0 {
+		repo := repos[0]
+		repos = repos[1:]
+		tags, err := d.registryClient.ListTags(repo)
+		if err != nil {
+			return count, errors.Wrapf(err, "cannot walk down GCR %q", repo)
 		}
-		for k, m := range tags.Manifests {
-			ref := repo.String() + "@" + k
-			age := int(time.Since(m.Uploaded).Hours() / 24)
-			log.Printf("%q is %d days old (uploaded on %s)", ref, age, m.Uploaded)
-			if m.Uploaded.Before(before) {
-				if err := helpers.Run(fmt.Sprintf("Deleting %q", ref), func() error {
-					// Delete all tags first, otherwise the image can't be deleted.
-					for _, tag := range m.Tags {
-						if err := d.deleteImage(repo.String() + ":" + tag); err != nil {
-							return err
-						}
-					}
-					if err := d.deleteImage(ref); err != nil {
-						return err
-					}
-					count++
-					return nil
-				}, dryRun); err != nil {
+		for _, child := range tags.Children {
+			repos = append(repos, repo+"/"+child)
+		}
+		resources := make([]cloudResource, 0, len(tags.Manifests))
+		digests := make(map[string]string, len(tags.Manifests))
+		for _, m := range tags.Manifests {
+			ref := repo + "@" + m.Digest
+			digests[ref] = m.Digest
+			resources = append(resources, cloudResource{
+				Name:              ref,
+				CreationTimestamp: m.Uploaded.Format(time.RFC3339),
+				Tags:              m.Tags,
+				Group:             repo,
+			})
+		}
+		c, err := d.applyRetention("image", project, resources, hoursToKeepResource, dryRun, func(r cloudResource) error {
+			// Delete all tags first, otherwise the image can't be deleted.
+			for _, tag := range r.Tags {
+				if err := d.registryClient.DeleteTag(repo, tag); err != nil {
 					return err
 				}
 			}
+			return d.registryClient.DeleteManifest(repo, digests[r.Name])
+		})
+		count += c
+		if err != nil {
+			return count, err
 		}
-		return nil
-	}, google.WithAuthFromKeychain(defaultKeychain))
+	}
+	return count, nil
 }
 
 // Projects returns the projects that should be cleaned up by a ResourceDeleter.
@@ -333,6 +291,13 @@ func (d *BaseResourceDeleter) Delete(hoursToKeepResource int, concurrentOperatio
 	return int(count.Load()), errStrings
 }
 
+// SetRetention sets the retention policy deciding which resources to keep,
+// and the audit report recording what was done. Both are optional.
+func (d *BaseResourceDeleter) SetRetention(policy *RetentionPolicy, audit *auditReport) {
+	d.policy = policy
+	d.audit = audit
+}
+
 // ShowStats simply shows the number of resources deleted, and any errors.
 func (d *BaseResourceDeleter) ShowStats(count int, errors []string) {
 	log.Printf("%d resources deleted", count)
@@ -351,37 +316,85 @@ func cleanup(o options.Options) error {
 		return fmt.Errorf("currently only GCR is supported")
 	}
 
+	resourceTypes, err := parseResourceTypes(o.ResourceTypes)
+	if err != nil {
+		return err
+	}
+
 	var projects []string
-	var err error
 	if projects, err = selectProjects(o.Project, o.ProjectResourceYaml, o.ReProjectName); err != nil {
 		return err
 	}
 
 	start := time.Now()
 
-	var deleter ResourceDeleter
-	if o.DaysToKeepImages >= 0 {
-		if deleter, err = NewImageDeleter(projects, o.Registry, o.ServiceAccount); err != nil {
+	var policy *RetentionPolicy
+	if o.RetentionPolicy != "" {
+		if policy, err = readRetentionPolicy(o.RetentionPolicy); err != nil {
 			return err
 		}
-		log.Println("Removing images that are:")
-		log.Printf("- older than %d days", o.DaysToKeepImages)
-		deleter.ShowStats(deleter.Delete(o.DaysToKeepImages*24, o.ConcurrentOperations, o.DryRun))
+	}
+	audit := newAuditReport(o.DryRun)
+
+	for _, resourceType := range resourceTypes {
+		var deleter ResourceDeleter
+		var hoursToKeep int
+		switch resourceType {
+		case imagesResourceType:
+			if o.DaysToKeepImages < 0 {
+				continue
+			}
+			hoursToKeep = o.DaysToKeepImages * 24
+			deleter, err = NewImageDeleter(projects, o.Registry, o.ServiceAccount)
+		case clustersResourceType:
+			if o.HoursToKeepClusters < 0 {
+				continue
+			}
+			hoursToKeep = o.HoursToKeepClusters
+			deleter, err = NewGkeClusterDeleter(projects, o.ServiceAccount)
+		default:
+			if o.HoursToKeepResources < 0 {
+				continue
+			}
+			hoursToKeep = o.HoursToKeepResources
+			deleter, err = newResourceDeleter(resourceType, projects, o.ServiceAccount)
+		}
+		if err != nil {
+			return err
+		}
+		deleter.SetRetention(policy, audit)
+		log.Printf("Removing %s that are:", resourceType)
+		if rule := policy.rule(resourceType); rule.HoursToKeep != nil {
+			log.Printf("- older than %d hours, as set by the retention policy", *rule.HoursToKeep)
+		} else {
+			log.Printf("- older than %d hours", hoursToKeep)
+		}
+		deleter.ShowStats(deleter.Delete(hoursToKeep, o.ConcurrentOperations, o.DryRun))
 	}
 
-	if o.HoursToKeepClusters >= 0 {
-		if deleter, err = NewGkeClusterDeleter(projects, o.ServiceAccount); err != nil {
+	if reportPath := auditReportPath(o.AuditReport, os.Getenv("ARTIFACTS")); reportPath == "" {
+		log.Print("Audit report not written, neither --audit-report nor $ARTIFACTS is set")
+	} else {
+		if err := audit.write(reportPath); err != nil {
 			return err
 		}
-		log.Println("Removing clusters that are:")
-		log.Printf("- older than %d hours", o.HoursToKeepClusters)
-		deleter.ShowStats(deleter.Delete(o.HoursToKeepClusters, o.ConcurrentOperations, o.DryRun))
+		log.Printf("Audit report written to %q", reportPath)
 	}
 
 	log.Printf("All operations finished in %s", time.Since(start))
 	return nil
 }
 
+// auditReportPath returns the file to write the audit report to, the given one
+// or the default one in the artifacts directory, empty if neither is set so that
+// local runs don't leave reports around.
+func auditReportPath(path, artifactsDir string) string {
+	if path != "" || artifactsDir == "" {
+		return path
+	}
+	return filepath.Join(artifactsDir, "cleanup-audit-report.json")
+}
+
 // main is the script entry point.
 func main() {
 	var o options.Options
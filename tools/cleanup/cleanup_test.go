@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
@@ -252,17 +253,24 @@ func TestNewImageDeleter(t *testing.T) {
 		sa  string
 		err error
 	}{
-		{ // No service account.
+		{ // No service account, use the application default credentials.
 			"",
 			nil,
 		},
+		{ // Service account file.
+			"testdata/fake-service-account.json",
+			nil,
+		},
 		{ // Bad service account file.
 			"/boot/foo/bar/nonono",
-			errors.New("cannot activate service account"),
+			errors.New("cannot read service account"),
 		},
-		// TODO: Test with a valid service account file.
 	}
 
+	// Don't look up the credentials of the machine running the tests.
+	defer os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "testdata/fake-service-account.json")
+
 	projects := []string{"a", "b", "c"}
 	registry := "fake_registry"
 	for _, data := range datas {
@@ -277,6 +285,9 @@ func TestNewImageDeleter(t *testing.T) {
 		if m := errorMismatch(err, data.err); m != "" {
 			t.Errorf("%s%s", errMsg, m)
 		}
+		if err == nil && d.registryClient == nil {
+			t.Errorf("%sgot no registry client", errMsg)
+		}
 	}
 }
 
@@ -312,11 +323,6 @@ func TestNewGkeClusterDeleter(t *testing.T) {
 /*
   TODO: Increase test coverage.
 
-  ImageDeleter.deleteImage (requires mocking remote)
-  - bad reference
-  - error deleting image
-  - delete image
-
   GkeClusterDeleter.DeleteResources (requires mocking gkeClient)
   - bad project
   - error listing clusters
@@ -326,15 +332,6 @@ func TestNewGkeClusterDeleter(t *testing.T) {
   - error deleting cluster
   - dry run
 
-  ImageDeleter.DeleteResources (requires mocking name, google)
-  - bad project
-  - bad registry
-  - error walking down registry
-  - no images to delete
-  - error deleting tag
-  - error deleting image
-  - dry run
-
   cleanup (requires mocking deleters)
   - bad --gcr flag
   - error selecting projects
@@ -343,3 +340,20 @@ func TestNewGkeClusterDeleter(t *testing.T) {
   - skipping deleting clusters
   - deleting images and clusters
 */
+
+func TestAuditReportPath(t *testing.T) {
+	datas := []struct {
+		path, artifactsDir string
+		exp                string
+	}{
+		{"report.json", "/artifacts", "report.json"},
+		{"report.json", "", "report.json"},
+		{"", "/artifacts", "/artifacts/cleanup-audit-report.json"},
+		{"", "", ""},
+	}
+	for _, data := range datas {
+		if got := auditReportPath(data.path, data.artifactsDir); got != data.exp {
+			t.Errorf("Audit report path for %q and artifacts %q: got %q, wanted %q", data.path, data.artifactsDir, got, data.exp)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// globalLocation is the location of the resources that are not zonal or regional.
+const globalLocation = "global"
+
+// cloudResource is a GCP resource that can be cleaned up.
+type cloudResource struct {
+	Name string
+	// Location is the zone or region of the resource, or "global".
+	Location string
+	// CreationTimestamp is in RFC3339 format. It's empty if the API doesn't
+	// record it, in which case the resource is only deleted once expired
+	// according to its expires-at label, unless DeleteWhenUnused is set.
+	CreationTimestamp string
+	// DeleteWhenUnused deletes the resource as soon as it's not in use if it
+	// has no creation timestamp, e.g. topics, instead of keeping it forever.
+	DeleteWhenUnused bool
+	// InUse is set when the resource is still used by another one, e.g. an
+	// attached disk, so it must not be deleted.
+	InUse bool
+	// Labels of the resource, if the API supports them.
+	Labels map[string]string
+	// Tags of the resource, for images.
+	Tags []string
+	// Group is the group of resources the retention policy keeps the newest
+	// of, e.g. the repository of images. Empty means the whole project.
+	Group string
+}
+
+// ComputeOperations wraps the Compute Engine API functions used for cleaning up.
+type ComputeOperations interface {
+	ListDisks(project string) ([]cloudResource, error)
+	DeleteDisk(project, zone, name string) error
+	ListForwardingRules(project string) ([]cloudResource, error)
+	DeleteForwardingRule(project, region, name string) error
+	ListTargetPools(project string) ([]cloudResource, error)
+	DeleteTargetPool(project, region, name string) error
+	ListAddresses(project string) ([]cloudResource, error)
+	DeleteAddress(project, region, name string) error
+	ListFirewallRules(project string) ([]cloudResource, error)
+	DeleteFirewallRule(project, name string) error
+}
+
+// StorageOperations wraps the GCS API functions used for cleaning up.
+type StorageOperations interface {
+	ListBuckets(project string) ([]cloudResource, error)
+	// DeleteBucket deletes the bucket and all the objects in it.
+	DeleteBucket(name string) error
+}
+
+// PubSubOperations wraps the Pub/Sub API functions used for cleaning up.
+type PubSubOperations interface {
+	// ListTopics lists the topics of the project, which are in use if they
+	// still have subscriptions.
+	ListTopics(project string) ([]cloudResource, error)
+	DeleteTopic(project, name string) error
+}
+
+// computeClient implements ComputeOperations.
+type computeClient struct {
+	*compute.Service
+}
+
+// storageClient implements StorageOperations.
+type storageClient struct {
+	*storage.Client
+}
+
+// pubsubClient implements PubSubOperations.
+type pubsubClient struct {
+	opts []option.ClientOption
+}
+
+// newComputeClient returns a ComputeOperations using the given client options.
+func newComputeClient(opts ...option.ClientOption) (ComputeOperations, error) {
+	service, err := compute.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create compute service")
+	}
+	return &computeClient{service}, nil
+}
+
+// newStorageClient returns a StorageOperations using the given client options.
+func newStorageClient(opts ...option.ClientOption) (StorageOperations, error) {
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create storage client")
+	}
+	return &storageClient{client}, nil
+}
+
+// newPubSubClient returns a PubSubOperations using the given client options.
+// Pub/Sub clients are bound to a project, so they are created when needed.
+func newPubSubClient(opts ...option.ClientOption) (PubSubOperations, error) {
+	return &pubsubClient{opts}, nil
+}
+
+// scopeLocation returns the location of an aggregated list scope, e.g.
+// "us-central1-a" for "zones/us-central1-a".
+func scopeLocation(scope string) string {
+	return path.Base(scope)
+}
+
+// wait waits for a compute operation to finish, and returns its error if any.
+func (c *computeClient) wait(project string, op *compute.Operation) error {
+	for op.Status != "DONE" {
+		var err error
+		switch {
+		case op.Zone != "":
+			op, err = c.ZoneOperations.Wait(project, path.Base(op.Zone), op.Name).Do()
+		case op.Region != "":
+			op, err = c.RegionOperations.Wait(project, path.Base(op.Region), op.Name).Do()
+		default:
+			op, err = c.GlobalOperations.Wait(project, op.Name).Do()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		var msgs []string
+		for _, e := range op.Error.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("operation %q failed: %s", op.Name, strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+// do waits for the operation returned by a compute call.
+func (c *computeClient) do(project string, op *compute.Operation, err error) error {
+	if err != nil {
+		return err
+	}
+	return c.wait(project, op)
+}
+
+// ListDisks lists the disks in all zones of the project.
+func (c *computeClient) ListDisks(project string) ([]cloudResource, error) {
+	var res []cloudResource
+	err := c.Disks.AggregatedList(project).Pages(context.Background(), func(l *compute.DiskAggregatedList) error {
+		for scope, items := range l.Items {
+			for _, d := range items.Disks {
+				res = append(res, cloudResource{
+					Name:              d.Name,
+					Location:          scopeLocation(scope),
+					CreationTimestamp: d.CreationTimestamp,
+					InUse:             len(d.Users) > 0,
+					Labels:            d.Labels,
+				})
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// DeleteDisk deletes the disk.
+func (c *computeClient) DeleteDisk(project, zone, name string) error {
+	op, err := c.Disks.Delete(project, zone, name).Do()
+	return c.do(project, op, err)
+}
+
+// selfLinks is a set of URLs of compute resources.
+type selfLinks map[string]bool
+
+// forwardingRuleTargets returns the target pools and backend services of the
+// project, which forwarding rules send traffic to.
+func (c *computeClient) forwardingRuleTargets(project string) (selfLinks, error) {
+	targets := make(selfLinks)
+	ctx := context.Background()
+	if err := c.TargetPools.AggregatedList(project).Pages(ctx, func(l *compute.TargetPoolAggregatedList) error {
+		for _, items := range l.Items {
+			for _, p := range items.TargetPools {
+				targets[p.SelfLink] = true
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "cannot list target pools")
+	}
+	if err := c.BackendServices.AggregatedList(project).Pages(ctx, func(l *compute.BackendServiceAggregatedList) error {
+		for _, items := range l.Items {
+			for _, s := range items.BackendServices {
+				targets[s.SelfLink] = true
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "cannot list backend services")
+	}
+	return targets, nil
+}
+
+// instances returns the instances of the project, and the network tags they have.
+func (c *computeClient) instances(project string) (selfLinks, map[string]bool, error) {
+	instances := make(selfLinks)
+	tags := make(map[string]bool)
+	err := c.Instances.AggregatedList(project).Pages(context.Background(), func(l *compute.InstanceAggregatedList) error {
+		for _, items := range l.Items {
+			for _, i := range items.Instances {
+				instances[i.SelfLink] = true
+				if i.Tags != nil {
+					for _, tag := range i.Tags.Items {
+						tags[tag] = true
+					}
+				}
+			}
+		}
+		return nil
+	})
+	return instances, tags, errors.Wrap(err, "cannot list instances")
+}
+
+// forwardingRuleInUse returns true if the forwarding rule still sends traffic
+// to an existing target pool or backend service. Rules with other kinds of
+// targets, e.g. target proxies, are always considered in use.
+func forwardingRuleInUse(r *compute.ForwardingRule, targets selfLinks) bool {
+	target := r.Target
+	if target == "" {
+		target = r.BackendService
+	}
+	if !strings.Contains(target, "/targetPools/") && !strings.Contains(target, "/backendServices/") {
+		return true
+	}
+	return targets[target]
+}
+
+// targetPoolInUse returns true if any instance of the target pool still exists.
+func targetPoolInUse(p *compute.TargetPool, instances selfLinks) bool {
+	for _, i := range p.Instances {
+		if instances[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// firewallRuleInUse returns true if the network of the firewall rule still
+// exists and, if the rule targets instances by tag, e.g. the nodes of a GKE
+// cluster, any instance still has one of these tags.
+func firewallRuleInUse(f *compute.Firewall, networks selfLinks, tags map[string]bool) bool {
+	if !networks[f.Network] {
+		return false
+	}
+	if len(f.TargetTags) == 0 {
+		return true
+	}
+	for _, tag := range f.TargetTags {
+		if tags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// ListForwardingRules lists the regional and global forwarding rules of the
+// project, which are in use if their target still exists.
+func (c *computeClient) ListForwardingRules(project string) ([]cloudResource, error) {
+	targets, err := c.forwardingRuleTargets(project)
+	if err != nil {
+		return nil, err
+	}
+	var res []cloudResource
+	err = c.ForwardingRules.AggregatedList(project).Pages(context.Background(), func(l *compute.ForwardingRuleAggregatedList) error {
+		for scope, items := range l.Items {
+			for _, r := range items.ForwardingRules {
+				res = append(res, cloudResource{
+					Name:              r.Name,
+					Location:          scopeLocation(scope),
+					CreationTimestamp: r.CreationTimestamp,
+					InUse:             forwardingRuleInUse(r, targets),
+					Labels:            r.Labels,
+				})
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// DeleteForwardingRule deletes the forwarding rule, in the given region or global.
+func (c *computeClient) DeleteForwardingRule(project, region, name string) error {
+	if region == globalLocation {
+		op, err := c.GlobalForwardingRules.Delete(project, name).Do()
+		return c.do(project, op, err)
+	}
+	op, err := c.ForwardingRules.Delete(project, region, name).Do()
+	return c.do(project, op, err)
+}
+
+// ListTargetPools lists the target pools in all regions of the project, which
+// are in use if any of their instances still exists.
+func (c *computeClient) ListTargetPools(project string) ([]cloudResource, error) {
+	instances, _, err := c.instances(project)
+	if err != nil {
+		return nil, err
+	}
+	var res []cloudResource
+	err = c.TargetPools.AggregatedList(project).Pages(context.Background(), func(l *compute.TargetPoolAggregatedList) error {
+		for scope, items := range l.Items {
+			for _, p := range items.TargetPools {
+				res = append(res, cloudResource{
+					Name:              p.Name,
+					Location:          scopeLocation(scope),
+					CreationTimestamp: p.CreationTimestamp,
+					InUse:             targetPoolInUse(p, instances),
+				})
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// DeleteTargetPool deletes the target pool.
+func (c *computeClient) DeleteTargetPool(project, region, name string) error {
+	op, err := c.TargetPools.Delete(project, region, name).Do()
+	return c.do(project, op, err)
+}
+
+// ListAddresses lists the regional and global static IP addresses of the project.
+func (c *computeClient) ListAddresses(project string) ([]cloudResource, error) {
+	var res []cloudResource
+	err := c.Addresses.AggregatedList(project).Pages(context.Background(), func(l *compute.AddressAggregatedList) error {
+		for scope, items := range l.Items {
+			for _, a := range items.Addresses {
+				res = append(res, cloudResource{
+					Name:              a.Name,
+					Location:          scopeLocation(scope),
+					CreationTimestamp: a.CreationTimestamp,
+					InUse:             a.Status == "IN_USE",
+				})
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// DeleteAddress deletes the static IP address, in the given region or global.
+func (c *computeClient) DeleteAddress(project, region, name string) error {
+	if region == globalLocation {
+		op, err := c.GlobalAddresses.Delete(project, name).Do()
+		return c.do(project, op, err)
+	}
+	op, err := c.Addresses.Delete(project, region, name).Do()
+	return c.do(project, op, err)
+}
+
+// ListFirewallRules lists the firewall rules of the project, which are in use
+// if their network and the instances they target still exist.
+func (c *computeClient) ListFirewallRules(project string) ([]cloudResource, error) {
+	_, tags, err := c.instances(project)
+	if err != nil {
+		return nil, err
+	}
+	networks := make(selfLinks)
+	if err := c.Networks.List(project).Pages(context.Background(), func(l *compute.NetworkList) error {
+		for _, n := range l.Items {
+			networks[n.SelfLink] = true
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "cannot list networks")
+	}
+	var res []cloudResource
+	err = c.Firewalls.List(project).Pages(context.Background(), func(l *compute.FirewallList) error {
+		for _, f := range l.Items {
+			res = append(res, cloudResource{
+				Name:              f.Name,
+				Location:          globalLocation,
+				CreationTimestamp: f.CreationTimestamp,
+				InUse:             firewallRuleInUse(f, networks, tags),
+			})
+		}
+		return nil
+	})
+	return res, err
+}
+
+// DeleteFirewallRule deletes the firewall rule.
+func (c *computeClient) DeleteFirewallRule(project, name string) error {
+	op, err := c.Firewalls.Delete(project, name).Do()
+	return c.do(project, op, err)
+}
+
+// ListBuckets lists the buckets of the project.
+func (c *storageClient) ListBuckets(project string) ([]cloudResource, error) {
+	var res []cloudResource
+	it := c.Buckets(context.Background(), project)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, cloudResource{
+			Name:              attrs.Name,
+			Location:          strings.ToLower(attrs.Location),
+			CreationTimestamp: attrs.Created.Format(time.RFC3339),
+			Labels:            attrs.Labels,
+		})
+	}
+	return res, nil
+}
+
+// DeleteBucket deletes all objects in the bucket, then the bucket itself.
+func (c *storageClient) DeleteBucket(name string) error {
+	ctx := context.Background()
+	bucket := c.Bucket(name)
+	it := bucket.Objects(ctx, nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := bucket.Object(attrs.Name).Delete(ctx); err != nil {
+			return errors.Wrapf(err, "cannot delete object %q", attrs.Name)
+		}
+	}
+	return bucket.Delete(ctx)
+}
+
+// ListTopics lists the topics of the project. Pub/Sub doesn't record when a
+// topic was created, so topics have no creation timestamp.
+func (c *pubsubClient) ListTopics(project string) ([]cloudResource, error) {
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, project, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	var res []cloudResource
+	it := client.Topics(ctx)
+	for {
+		topic, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = topic.Subscriptions(ctx).Next()
+		if err != nil && err != iterator.Done {
+			return nil, errors.Wrapf(err, "cannot list subscriptions of topic %q", topic.ID())
+		}
+		inUse := err == nil
+		config, err := topic.Config(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get the configuration of topic %q", topic.ID())
+		}
+		res = append(res, cloudResource{Name: topic.ID(), Location: globalLocation, InUse: inUse, Labels: config.Labels})
+	}
+	return res, nil
+}
+
+// DeleteTopic deletes the topic.
+func (c *pubsubClient) DeleteTopic(project, name string) error {
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, project, c.opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Topic(name).Delete(ctx)
+}
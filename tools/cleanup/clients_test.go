@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+const computeURL = "https://www.googleapis.com/compute/v1/projects/p/"
+
+func TestForwardingRuleInUse(t *testing.T) {
+	targets := selfLinks{
+		computeURL + "regions/us-central1/targetPools/existing":     true,
+		computeURL + "regions/us-central1/backendServices/existing": true,
+	}
+	datas := []struct {
+		rule *compute.ForwardingRule
+		want bool
+	}{
+		{&compute.ForwardingRule{Target: computeURL + "regions/us-central1/targetPools/existing"}, true},
+		{&compute.ForwardingRule{Target: computeURL + "regions/us-central1/targetPools/deleted"}, false},
+		{&compute.ForwardingRule{BackendService: computeURL + "regions/us-central1/backendServices/existing"}, true},
+		{&compute.ForwardingRule{BackendService: computeURL + "regions/us-central1/backendServices/deleted"}, false},
+		// Targets that are not listed are considered in use.
+		{&compute.ForwardingRule{Target: computeURL + "global/targetHttpProxies/proxy"}, true},
+	}
+	for _, data := range datas {
+		if got := forwardingRuleInUse(data.rule, targets); got != data.want {
+			t.Errorf("forwardingRuleInUse(%q, %q) = %v, want %v", data.rule.Target, data.rule.BackendService, got, data.want)
+		}
+	}
+}
+
+func TestTargetPoolInUse(t *testing.T) {
+	instances := selfLinks{computeURL + "zones/us-central1-a/instances/node": true}
+	if !targetPoolInUse(&compute.TargetPool{Instances: []string{computeURL + "zones/us-central1-a/instances/gone", computeURL + "zones/us-central1-a/instances/node"}}, instances) {
+		t.Error("Expected a target pool with an existing instance to be in use")
+	}
+	if targetPoolInUse(&compute.TargetPool{Instances: []string{computeURL + "zones/us-central1-a/instances/gone"}}, instances) {
+		t.Error("Expected a target pool without existing instances not to be in use")
+	}
+	if targetPoolInUse(&compute.TargetPool{}, instances) {
+		t.Error("Expected an empty target pool not to be in use")
+	}
+}
+
+func TestFirewallRuleInUse(t *testing.T) {
+	networks := selfLinks{computeURL + "global/networks/default": true}
+	tags := map[string]bool{"gke-live-cluster-node": true}
+	datas := []struct {
+		firewall *compute.Firewall
+		want     bool
+	}{
+		{&compute.Firewall{Name: "default-allow-ssh", Network: computeURL + "global/networks/default"}, true},
+		{&compute.Firewall{Name: "deleted-network", Network: computeURL + "global/networks/deleted"}, false},
+		{&compute.Firewall{Name: "live-cluster", Network: computeURL + "global/networks/default", TargetTags: []string{"gke-live-cluster-node"}}, true},
+		{&compute.Firewall{Name: "deleted-cluster", Network: computeURL + "global/networks/default", TargetTags: []string{"gke-deleted-cluster-node"}}, false},
+	}
+	for _, data := range datas {
+		if got := firewallRuleInUse(data.firewall, networks, tags); got != data.want {
+			t.Errorf("firewallRuleInUse(%q) = %v, want %v", data.firewall.Name, got, data.want)
+		}
+	}
+}
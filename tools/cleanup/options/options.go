@@ -11,6 +11,10 @@ type Options struct {
 	ReProjectName        string
 	DaysToKeepImages     int
 	HoursToKeepClusters  int
+	HoursToKeepResources int
+	ResourceTypes        string
+	RetentionPolicy      string
+	AuditReport          string
 	Registry             string
 	ServiceAccount       string
 	ConcurrentOperations int
@@ -34,6 +38,10 @@ func (o *Options) AddOptions() {
 	flag.StringVar(&o.ReProjectName, "re-project-name", "knative-boskos-[a-zA-Z0-9]+", "Regular expression for filtering project names from the resources file.")
 	flag.IntVar(&o.DaysToKeepImages, "days-to-keep-images", 365, "Images older than this amount of days will be deleted (defaults to 1 year, -1 means 'forever').")
 	flag.IntVar(&o.HoursToKeepClusters, "hours-to-keep-clusters", 720, "Clusters older than this amount of hours will be deleted (defaults to 1 month, -1 means 'forever').")
+	flag.IntVar(&o.HoursToKeepResources, "hours-to-keep-resources", 720, "Other resources older than this amount of hours will be deleted (defaults to 1 month, -1 means 'forever').")
+	flag.StringVar(&o.ResourceTypes, "resource-types", "images,clusters", "Comma separated list of the types of resources to clean up: images, clusters, forwarding-rules, target-pools, addresses, disks, firewall-rules, buckets, topics (defaults to images,clusters).")
+	flag.StringVar(&o.RetentionPolicy, "retention-policy", "", "Retention policy file, defining the resources to keep per resource type.")
+	flag.StringVar(&o.AuditReport, "audit-report", "", "File to write the JSON audit report of the resources kept or deleted to (defaults to cleanup-audit-report.json in $ARTIFACTS, not written if $ARTIFACTS isn't set).")
 	flag.StringVar(&o.Registry, "gcr", "gcr.io", "The registry hostname to use (defaults to gcr.io; currently only GCR is supported).")
 	flag.StringVar(&o.ServiceAccount, "service-account", "", "Specify the key file of the service account to use.")
 	flag.IntVar(&o.ConcurrentOperations, "concurrent-operations", 10, "How many deletion operations to run concurrently (defaults to 10).")
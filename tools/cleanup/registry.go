@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// registryManifest is an image in a repository.
+type registryManifest struct {
+	Digest   string
+	Tags     []string
+	Uploaded time.Time
+}
+
+// registryTags is the content of a repository.
+type registryTags struct {
+	// Children are the names of the repositories right under this one.
+	Children  []string
+	Manifests []registryManifest
+}
+
+// RegistryOperations wraps the container registry API functions used for cleaning up.
+type RegistryOperations interface {
+	// ListTags lists the images and the child repositories of a repository.
+	ListTags(repo string) (*registryTags, error)
+	DeleteTag(repo, tag string) error
+	DeleteManifest(repo, digest string) error
+}
+
+// registryClient implements RegistryOperations with the registry API.
+type registryClient struct {
+	// auth authenticates to the registry, keychain is used if it's not set.
+	auth     authn.Authenticator
+	keychain authn.Keychain
+}
+
+// newRegistryClient returns a RegistryOperations authenticated with the given
+// service account key file, or the application default credentials if empty.
+// Without application default credentials, the credentials of the docker
+// config file are used.
+func newRegistryClient(serviceAccount string) (RegistryOperations, error) {
+	if serviceAccount == "" {
+		auth, err := google.NewEnvAuthenticator()
+		if err != nil {
+			log.Printf("Cannot find the application default credentials, using the docker config: %v", err)
+			return &registryClient{keychain: authn.DefaultKeychain}, nil
+		}
+		return &registryClient{auth: auth}, nil
+	}
+	key, err := ioutil.ReadFile(serviceAccount)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read service account")
+	}
+	return &registryClient{auth: google.NewJSONKeyAuthenticator(string(key))}, nil
+}
+
+// listerOption returns the authentication option of the registry listing.
+func (c *registryClient) listerOption() google.ListerOption {
+	if c.auth == nil {
+		return google.WithAuthFromKeychain(c.keychain)
+	}
+	return google.WithAuth(c.auth)
+}
+
+// remoteOption returns the authentication option of the registry calls.
+func (c *registryClient) remoteOption() remote.Option {
+	if c.auth == nil {
+		return remote.WithAuthFromKeychain(c.keychain)
+	}
+	return remote.WithAuth(c.auth)
+}
+
+// ListTags lists the images and the child repositories of a repository.
+func (c *registryClient) ListTags(repo string) (*registryTags, error) {
+	r, err := name.NewRepository(repo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse repository %q", repo)
+	}
+	tags, err := google.List(r, c.listerOption())
+	if err != nil {
+		return nil, err
+	}
+	res := &registryTags{Children: tags.Children}
+	for digest, m := range tags.Manifests {
+		res.Manifests = append(res.Manifests, registryManifest{Digest: digest, Tags: m.Tags, Uploaded: m.Uploaded})
+	}
+	// Keep the order stable, manifests are returned as a map.
+	sort.Slice(res.Manifests, func(i, j int) bool {
+		return res.Manifests[i].Digest < res.Manifests[j].Digest
+	})
+	return res, nil
+}
+
+// DeleteTag deletes a tag from a repository.
+func (c *registryClient) DeleteTag(repo, tag string) error {
+	ref, err := name.NewTag(repo + ":" + tag)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse reference %q", repo+":"+tag)
+	}
+	return errors.Wrapf(remote.Delete(ref, c.remoteOption()), "failed to delete %q", ref)
+}
+
+// DeleteManifest deletes an image from a repository by its digest.
+func (c *registryClient) DeleteManifest(repo, digest string) error {
+	ref, err := name.NewDigest(repo + "@" + digest)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse reference %q", repo+"@"+digest)
+	}
+	return errors.Wrapf(remote.Delete(ref, c.remoteOption()), "failed to delete %q", ref)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/pkg/errors"
+)
+
+// fakeRegistry is an in-memory RegistryOperations.
+type fakeRegistry struct {
+	// map of repository: images
+	repos map[string][]registryManifest
+	// Deleted references, as "repo:tag" or "repo@digest", in order.
+	deleted   []string
+	listErr   error
+	deleteErr error
+	mutex     sync.Mutex
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{repos: make(map[string][]registryManifest)}
+}
+
+// add adds an image to a repository, and creates its parent repositories.
+func (r *fakeRegistry) add(repo, digest string, uploaded time.Time, tags ...string) {
+	r.repos[repo] = append(r.repos[repo], registryManifest{Digest: digest, Tags: tags, Uploaded: uploaded})
+	for i := strings.LastIndex(repo, "/"); i > 0; i = strings.LastIndex(repo[:i], "/") {
+		if _, ok := r.repos[repo[:i]]; !ok {
+			r.repos[repo[:i]] = nil
+		}
+	}
+}
+
+func (r *fakeRegistry) ListTags(repo string) (*registryTags, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.listErr != nil {
+		return nil, r.listErr
+	}
+	manifests, ok := r.repos[repo]
+	if !ok {
+		return nil, fmt.Errorf("repository %q not found", repo)
+	}
+	res := &registryTags{Manifests: append([]registryManifest(nil), manifests...)}
+	for name := range r.repos {
+		if child := strings.TrimPrefix(name, repo+"/"); child != name && !strings.Contains(child, "/") {
+			res.Children = append(res.Children, child)
+		}
+	}
+	sort.Strings(res.Children)
+	return res, nil
+}
+
+func (r *fakeRegistry) DeleteTag(repo, tag string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	for i, m := range r.repos[repo] {
+		for j, t := range m.Tags {
+			if t == tag {
+				r.repos[repo][i].Tags = append(m.Tags[:j:j], m.Tags[j+1:]...)
+				r.deleted = append(r.deleted, repo+":"+tag)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("tag %q not found in %q", tag, repo)
+}
+
+func (r *fakeRegistry) DeleteManifest(repo, digest string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	for i, m := range r.repos[repo] {
+		if m.Digest == digest {
+			if len(m.Tags) > 0 {
+				return fmt.Errorf("image %q is still tagged", repo+"@"+digest)
+			}
+			r.repos[repo] = append(r.repos[repo][:i], r.repos[repo][i+1:]...)
+			r.deleted = append(r.deleted, repo+"@"+digest)
+			return nil
+		}
+	}
+	return fmt.Errorf("image %q not found", repo+"@"+digest)
+}
+
+func newFakeImageDeleter(projects []string, registry *fakeRegistry) *ImageDeleter {
+	deleter := ImageDeleter{*NewBaseResourceDeleter(projects), "gcr.io", registry}
+	deleter.resourceType = imagesResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+func TestImageDeleter(t *testing.T) {
+	old := time.Now().Add(-100 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	r := newFakeRegistry()
+	r.add("gcr.io/p1/serving/activator", "sha256:a1", old, "v1", "latest")
+	r.add("gcr.io/p1/serving/activator", "sha256:a2", recent)
+	r.add("gcr.io/p1/serving/queue", "sha256:q1", old)
+	r.add("gcr.io/p1/eventing", "sha256:e1", old)
+	r.add("gcr.io/p2", "sha256:p1", recent)
+
+	for _, dryRun := range []bool{true, false} {
+		r.deleted = nil
+		c, errs := newFakeImageDeleter([]string{"p1", "p2"}, r).Delete(24, 2, dryRun)
+		errMsg := fmt.Sprintf("Delete images (dry run: %v): ", dryRun)
+		if len(errs) != 0 {
+			t.Errorf("%sgot unexpected errors %v", errMsg, errs)
+		}
+		var expDeleted []string
+		expCount := 0
+		if !dryRun {
+			expCount = 3
+			expDeleted = []string{
+				"gcr.io/p1/eventing@sha256:e1",
+				"gcr.io/p1/serving/activator:latest",
+				"gcr.io/p1/serving/activator:v1",
+				"gcr.io/p1/serving/activator@sha256:a1",
+				"gcr.io/p1/serving/queue@sha256:q1",
+			}
+		}
+		if c != expCount {
+			t.Errorf("%sgot %d items deleted, wanted %d", errMsg, c, expCount)
+		}
+		deleted := append([]string(nil), r.deleted...)
+		sort.Strings(deleted)
+		if dif := cmp.Diff(expDeleted, deleted); dif != "" {
+			t.Errorf("%sgot(+) is different from wanted(-)\n%v", errMsg, dif)
+		}
+	}
+	if dif := cmp.Diff([]registryManifest{{Digest: "sha256:a2", Uploaded: recent}}, r.repos["gcr.io/p1/serving/activator"]); dif != "" {
+		t.Errorf("Unexpected images left, got(+) is different from wanted(-)\n%v", dif)
+	}
+}
+
+func TestImageDeleterErrors(t *testing.T) {
+	old := time.Now().Add(-100 * time.Hour)
+	datas := []struct {
+		project   string
+		listErr   error
+		deleteErr error
+		expErr    error
+	}{
+		{ // Forbidden registry.
+			"knative-releases",
+			nil,
+			nil,
+			errors.New(`cleaning up "gcr.io/knative-releases" is forbidden`),
+		},
+		{ // Missing repository.
+			"p2",
+			nil,
+			nil,
+			errors.New(`cannot walk down GCR "gcr.io/p2"`),
+		},
+		{ // Error listing images.
+			"p1",
+			errors.New("unauthorized"),
+			nil,
+			errors.New(`cannot walk down GCR "gcr.io/p1": unauthorized`),
+		},
+		{ // Error deleting images.
+			"p1",
+			nil,
+			errors.New("unauthorized"),
+			errors.New(`error deleting image "gcr.io/p1/a@sha256:1": unauthorized`),
+		},
+	}
+	for _, data := range datas {
+		r := newFakeRegistry()
+		r.add("gcr.io/p1/a", "sha256:1", old, "latest")
+		r.listErr = data.listErr
+		r.deleteErr = data.deleteErr
+		_, err := newFakeImageDeleter([]string{data.project}, r).DeleteResources(data.project, 24, false)
+		if m := errorMismatch(err, data.expErr); m != "" {
+			t.Errorf("Delete images in %q: %s", data.project, m)
+		}
+	}
+}
+
+func TestNewRegistryClientWithoutCredentials(t *testing.T) {
+	old, ok := os.LookupEnv("GOOGLE_APPLICATION_CREDENTIALS")
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/nonexistent/service-account.json")
+	defer func() {
+		if ok {
+			os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", old)
+		} else {
+			os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+	}()
+
+	client, err := newRegistryClient("")
+	if err != nil {
+		t.Fatalf("Expected the docker config to be used, got error %v", err)
+	}
+	if c := client.(*registryClient); c.auth != nil || c.keychain != authn.DefaultKeychain {
+		t.Errorf("Expected the default keychain, got %+v", c)
+	}
+
+	if _, err := newRegistryClient("/nonexistent/key.json"); err == nil {
+		t.Error("Expected an error for a missing service account")
+	}
+}
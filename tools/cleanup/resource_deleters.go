@@ -0,0 +1,393 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	"knative.dev/test-infra/pkg/helpers"
+)
+
+// Types of resources that can be cleaned up, selected with --resource-types.
+const (
+	imagesResourceType          = "images"
+	clustersResourceType        = "clusters"
+	forwardingRulesResourceType = "forwarding-rules"
+	targetPoolsResourceType     = "target-pools"
+	addressesResourceType       = "addresses"
+	disksResourceType           = "disks"
+	firewallRulesResourceType   = "firewall-rules"
+	bucketsResourceType         = "buckets"
+	topicsResourceType          = "topics"
+)
+
+var (
+	// All resource types, in the order they are cleaned up. Load balancer
+	// resources go first, as they use the target pools and addresses.
+	allResourceTypes = []string{
+		imagesResourceType,
+		clustersResourceType,
+		forwardingRulesResourceType,
+		targetPoolsResourceType,
+		addressesResourceType,
+		disksResourceType,
+		firewallRulesResourceType,
+		bucketsResourceType,
+		topicsResourceType,
+	}
+
+	// Prefixes of the firewall rules that come with the default network, which are never deleted.
+	protectedFirewallRulePrefixes = []string{"default-allow-"}
+)
+
+// DiskDeleter deletes old persistent disks that are not attached to any instance.
+type DiskDeleter struct {
+	BaseResourceDeleter
+	computeClient ComputeOperations
+}
+
+// ForwardingRuleDeleter deletes old forwarding rules, leaked by load balancers.
+type ForwardingRuleDeleter struct {
+	BaseResourceDeleter
+	computeClient ComputeOperations
+}
+
+// TargetPoolDeleter deletes old target pools, leaked by load balancers.
+type TargetPoolDeleter struct {
+	BaseResourceDeleter
+	computeClient ComputeOperations
+}
+
+// AddressDeleter deletes old static IP addresses that are not in use.
+type AddressDeleter struct {
+	BaseResourceDeleter
+	computeClient ComputeOperations
+}
+
+// FirewallRuleDeleter deletes old firewall rules, e.g. leaked by deleted clusters.
+type FirewallRuleDeleter struct {
+	BaseResourceDeleter
+	computeClient ComputeOperations
+}
+
+// BucketDeleter deletes old GCS buckets, along with their content.
+type BucketDeleter struct {
+	BaseResourceDeleter
+	storageClient StorageOperations
+}
+
+// TopicDeleter deletes Pub/Sub topics without subscriptions.
+type TopicDeleter struct {
+	BaseResourceDeleter
+	pubsubClient PubSubOperations
+}
+
+// NewDiskDeleter returns a brand new DiskDeleter.
+func NewDiskDeleter(projects []string, client ComputeOperations) *DiskDeleter {
+	deleter := DiskDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = disksResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// NewForwardingRuleDeleter returns a brand new ForwardingRuleDeleter.
+func NewForwardingRuleDeleter(projects []string, client ComputeOperations) *ForwardingRuleDeleter {
+	deleter := ForwardingRuleDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = forwardingRulesResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// NewTargetPoolDeleter returns a brand new TargetPoolDeleter.
+func NewTargetPoolDeleter(projects []string, client ComputeOperations) *TargetPoolDeleter {
+	deleter := TargetPoolDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = targetPoolsResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// NewAddressDeleter returns a brand new AddressDeleter.
+func NewAddressDeleter(projects []string, client ComputeOperations) *AddressDeleter {
+	deleter := AddressDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = addressesResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// NewFirewallRuleDeleter returns a brand new FirewallRuleDeleter.
+func NewFirewallRuleDeleter(projects []string, client ComputeOperations) *FirewallRuleDeleter {
+	deleter := FirewallRuleDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = firewallRulesResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// NewBucketDeleter returns a brand new BucketDeleter.
+func NewBucketDeleter(projects []string, client StorageOperations) *BucketDeleter {
+	deleter := BucketDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = bucketsResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// NewTopicDeleter returns a brand new TopicDeleter.
+func NewTopicDeleter(projects []string, client PubSubOperations) *TopicDeleter {
+	deleter := TopicDeleter{*NewBaseResourceDeleter(projects), client}
+	deleter.resourceType = topicsResourceType
+	deleter.deleteResourceFunc = deleter.DeleteResources
+	return &deleter
+}
+
+// clientOptions returns the options for creating the API clients.
+func clientOptions(serviceAccount string) []option.ClientOption {
+	opts := make([]option.ClientOption, 0)
+	if serviceAccount != "" {
+		// Create clients with specific credentials.
+		opts = append(opts, option.WithCredentialsFile(serviceAccount))
+	}
+	return opts
+}
+
+// newResourceDeleter returns the ResourceDeleter for one of the resource
+// types, other than images and clusters.
+func newResourceDeleter(resourceType string, projects []string, serviceAccount string) (ResourceDeleter, error) {
+	opts := clientOptions(serviceAccount)
+	switch resourceType {
+	case bucketsResourceType:
+		client, err := newStorageClient(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return NewBucketDeleter(projects, client), nil
+	case topicsResourceType:
+		client, err := newPubSubClient(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return NewTopicDeleter(projects, client), nil
+	}
+	client, err := newComputeClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	switch resourceType {
+	case disksResourceType:
+		return NewDiskDeleter(projects, client), nil
+	case forwardingRulesResourceType:
+		return NewForwardingRuleDeleter(projects, client), nil
+	case targetPoolsResourceType:
+		return NewTargetPoolDeleter(projects, client), nil
+	case addressesResourceType:
+		return NewAddressDeleter(projects, client), nil
+	case firewallRulesResourceType:
+		return NewFirewallRuleDeleter(projects, client), nil
+	}
+	return nil, fmt.Errorf("unknown resource type %q", resourceType)
+}
+
+// parseResourceTypes parses the comma separated list of resource types, and
+// returns them in the order they must be cleaned up.
+func parseResourceTypes(s string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !isResourceType(t) {
+			return nil, fmt.Errorf("unknown resource type %q, must be one of %s", t, strings.Join(allResourceTypes, ", "))
+		}
+		selected[t] = true
+	}
+	var res []string
+	for _, t := range allResourceTypes {
+		if selected[t] {
+			res = append(res, t)
+		}
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no resource type provided")
+	}
+	return res, nil
+}
+
+// isResourceType returns whether the given string is a known resource type.
+func isResourceType(s string) bool {
+	for _, t := range allResourceTypes {
+		if s == t {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteOldResources deletes the resources of the given kind that the
+// retention policy doesn't keep, e.g. created before the given amount of hours.
+func (d *BaseResourceDeleter) deleteOldResources(kind, project string, resources []cloudResource, hoursToKeepResource int, dryRun bool, deleteFunc func(cloudResource) error) (int, error) {
+	// TODO(adrcunha): Consider exposing https://github.com/knative/pkg/blob/6d806b998379948bd0107d77bcd831e2bdb4f3cb/testutils/clustermanager/e2e-tests/gke.go#L281
+	if project == "knative-tests" {
+		return 0, fmt.Errorf("cleaning up %q is forbidden", project)
+	}
+	return d.applyRetention(kind, project, resources, hoursToKeepResource, dryRun, deleteFunc)
+}
+
+// applyRetention deletes the resources of the given kind that the retention
+// policy doesn't keep, and records what was done in the audit report.
+func (d *BaseResourceDeleter) applyRetention(kind, project string, resources []cloudResource, hoursToKeepResource int, dryRun bool, deleteFunc func(cloudResource) error) (int, error) {
+	decisions := d.policy.decide(d.resourceType, resources, hoursToKeepResource, time.Now())
+	count := 0
+	for i, r := range resources {
+		fullName := project + "/" + r.Location + "/" + r.Name
+		if r.Location == "" {
+			fullName = r.Name
+		}
+		entry := auditEntry{
+			ResourceType: d.resourceType,
+			Project:      project,
+			Location:     r.Location,
+			Name:         r.Name,
+			Action:       keepAction,
+			Reason:       decisions[i].Reason,
+		}
+		if !decisions[i].Delete {
+			log.Printf("Keeping %s %s: %s", kind, fullName, decisions[i].Reason)
+			d.audit.record(entry)
+			continue
+		}
+		entry.Action = deleteAction
+		err := helpers.Run(fmt.Sprintf("Deleting %s %q (%s)", kind, fullName, decisions[i].Reason), func() error {
+			if err := deleteFunc(r); err != nil {
+				return errors.Wrapf(err, "error deleting %s %q", kind, fullName)
+			}
+			count++
+			return nil
+		}, dryRun)
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		d.audit.record(entry)
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// DeleteResources deletes old unattached disks from a given project.
+func (d *DiskDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	disks, err := d.computeClient.ListDisks(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing disks in %q", project)
+	}
+	return d.deleteOldResources("disk", project, disks, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.computeClient.DeleteDisk(project, r.Location, r.Name)
+	})
+}
+
+// DeleteResources deletes old forwarding rules from a given project.
+func (d *ForwardingRuleDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	rules, err := d.computeClient.ListForwardingRules(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing forwarding rules in %q", project)
+	}
+	return d.deleteOldResources("forwarding rule", project, rules, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.computeClient.DeleteForwardingRule(project, r.Location, r.Name)
+	})
+}
+
+// DeleteResources deletes old target pools from a given project.
+func (d *TargetPoolDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	pools, err := d.computeClient.ListTargetPools(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing target pools in %q", project)
+	}
+	return d.deleteOldResources("target pool", project, pools, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.computeClient.DeleteTargetPool(project, r.Location, r.Name)
+	})
+}
+
+// DeleteResources deletes old unused static IP addresses from a given project.
+func (d *AddressDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	addresses, err := d.computeClient.ListAddresses(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing addresses in %q", project)
+	}
+	return d.deleteOldResources("address", project, addresses, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.computeClient.DeleteAddress(project, r.Location, r.Name)
+	})
+}
+
+// DeleteResources deletes old firewall rules from a given project, except
+// the ones of the default network.
+func (d *FirewallRuleDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	rules, err := d.computeClient.ListFirewallRules(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing firewall rules in %q", project)
+	}
+	var candidates []cloudResource
+	for _, r := range rules {
+		if !isProtectedFirewallRule(r.Name) {
+			candidates = append(candidates, r)
+		}
+	}
+	return d.deleteOldResources("firewall rule", project, candidates, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.computeClient.DeleteFirewallRule(project, r.Name)
+	})
+}
+
+// DeleteResources deletes old GCS buckets from a given project.
+func (d *BucketDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	buckets, err := d.storageClient.ListBuckets(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing buckets in %q", project)
+	}
+	return d.deleteOldResources("bucket", project, buckets, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.storageClient.DeleteBucket(r.Name)
+	})
+}
+
+// DeleteResources deletes the topics without subscriptions from a given
+// project. Pub/Sub doesn't record when topics were created, so unused topics
+// are deleted regardless of their age, unless the retention policy keeps them
+// or they're not expired according to their expires-at label; subscriptions
+// of abandoned topics expire after 31 days of inactivity by default.
+func (d *TopicDeleter) DeleteResources(project string, hoursToKeepResource int, dryRun bool) (int, error) {
+	topics, err := d.pubsubClient.ListTopics(project)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error listing topics in %q", project)
+	}
+	for i := range topics {
+		topics[i].DeleteWhenUnused = true
+	}
+	return d.deleteOldResources("topic", project, topics, hoursToKeepResource, dryRun, func(r cloudResource) error {
+		return d.pubsubClient.DeleteTopic(project, r.Name)
+	})
+}
+
+// isProtectedFirewallRule returns whether the firewall rule must never be deleted.
+func isProtectedFirewallRule(name string) bool {
+	for _, prefix := range protectedFirewallRulePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
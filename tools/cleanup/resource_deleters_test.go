@@ -0,0 +1,398 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+// fakeCloud is a fake implementing ComputeOperations, StorageOperations and
+// PubSubOperations, keeping the resources in memory.
+type fakeCloud struct {
+	// map of "kind/project": resources
+	resources map[string][]cloudResource
+	// Deleted resources, as "kind/project/location/name".
+	deleted   []string
+	listErr   error
+	deleteErr error
+	mutex     sync.Mutex
+}
+
+func newFakeCloud() *fakeCloud {
+	return &fakeCloud{resources: make(map[string][]cloudResource)}
+}
+
+func (f *fakeCloud) add(kind, project string, resources ...cloudResource) {
+	key := kind + "/" + project
+	f.resources[key] = append(f.resources[key], resources...)
+}
+
+func (f *fakeCloud) list(kind, project string) ([]cloudResource, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return append([]cloudResource(nil), f.resources[kind+"/"+project]...), nil
+}
+
+func (f *fakeCloud) delete(kind, project, location, name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.deleteErr != nil {
+		return f.deleteErr
+	}
+	key := kind + "/" + project
+	for i, r := range f.resources[key] {
+		if r.Name == name && (location == "" || r.Location == location) {
+			f.resources[key] = append(f.resources[key][:i], f.resources[key][i+1:]...)
+			f.deleted = append(f.deleted, fmt.Sprintf("%s/%s/%s/%s", kind, project, r.Location, name))
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q not found in %q", kind, name, project)
+}
+
+func (f *fakeCloud) ListDisks(project string) ([]cloudResource, error) {
+	return f.list(disksResourceType, project)
+}
+
+func (f *fakeCloud) DeleteDisk(project, zone, name string) error {
+	return f.delete(disksResourceType, project, zone, name)
+}
+
+func (f *fakeCloud) ListForwardingRules(project string) ([]cloudResource, error) {
+	return f.list(forwardingRulesResourceType, project)
+}
+
+func (f *fakeCloud) DeleteForwardingRule(project, region, name string) error {
+	return f.delete(forwardingRulesResourceType, project, region, name)
+}
+
+func (f *fakeCloud) ListTargetPools(project string) ([]cloudResource, error) {
+	return f.list(targetPoolsResourceType, project)
+}
+
+func (f *fakeCloud) DeleteTargetPool(project, region, name string) error {
+	return f.delete(targetPoolsResourceType, project, region, name)
+}
+
+func (f *fakeCloud) ListAddresses(project string) ([]cloudResource, error) {
+	return f.list(addressesResourceType, project)
+}
+
+func (f *fakeCloud) DeleteAddress(project, region, name string) error {
+	return f.delete(addressesResourceType, project, region, name)
+}
+
+func (f *fakeCloud) ListFirewallRules(project string) ([]cloudResource, error) {
+	return f.list(firewallRulesResourceType, project)
+}
+
+func (f *fakeCloud) DeleteFirewallRule(project, name string) error {
+	return f.delete(firewallRulesResourceType, project, globalLocation, name)
+}
+
+func (f *fakeCloud) ListBuckets(project string) ([]cloudResource, error) {
+	return f.list(bucketsResourceType, project)
+}
+
+func (f *fakeCloud) DeleteBucket(name string) error {
+	// Bucket names are global, find the project owning it.
+	f.mutex.Lock()
+	var project string
+	for key, resources := range f.resources {
+		for _, r := range resources {
+			if strings.HasPrefix(key, bucketsResourceType+"/") && r.Name == name {
+				project = strings.TrimPrefix(key, bucketsResourceType+"/")
+			}
+		}
+	}
+	f.mutex.Unlock()
+	return f.delete(bucketsResourceType, project, "", name)
+}
+
+func (f *fakeCloud) ListTopics(project string) ([]cloudResource, error) {
+	return f.list(topicsResourceType, project)
+}
+
+func (f *fakeCloud) DeleteTopic(project, name string) error {
+	return f.delete(topicsResourceType, project, globalLocation, name)
+}
+
+// hoursAgo returns the RFC3339 timestamp of the given amount of hours ago.
+func hoursAgo(hours int) string {
+	return time.Now().Add(-time.Hour * time.Duration(hours)).Format(time.RFC3339)
+}
+
+// sortedDeleted returns the deleted resources, sorted for comparison.
+func (f *fakeCloud) sortedDeleted() []string {
+	res := append([]string(nil), f.deleted...)
+	sort.Strings(res)
+	return res
+}
+
+func TestResourceDeleters(t *testing.T) {
+	datas := []struct {
+		kind       string
+		newDeleter func(projects []string, f *fakeCloud) ResourceDeleter
+		resources  []cloudResource
+		expDeleted []string
+	}{
+		{
+			disksResourceType,
+			func(projects []string, f *fakeCloud) ResourceDeleter { return NewDiskDeleter(projects, f) },
+			[]cloudResource{
+				{Name: "old", Location: "us-central1-a", CreationTimestamp: hoursAgo(30)},
+				{Name: "new", Location: "us-central1-a", CreationTimestamp: hoursAgo(1)},
+				{Name: "attached", Location: "us-east1-b", CreationTimestamp: hoursAgo(30), InUse: true},
+			},
+			[]string{"disks/p1/us-central1-a/old", "disks/p2/us-central1-a/old"},
+		},
+		{
+			forwardingRulesResourceType,
+			func(projects []string, f *fakeCloud) ResourceDeleter { return NewForwardingRuleDeleter(projects, f) },
+			[]cloudResource{
+				{Name: "old", Location: "us-central1", CreationTimestamp: hoursAgo(30)},
+				{Name: "old-global", Location: globalLocation, CreationTimestamp: hoursAgo(30)},
+				{Name: "new", Location: "us-central1", CreationTimestamp: hoursAgo(1)},
+			},
+			[]string{
+				"forwarding-rules/p1/global/old-global", "forwarding-rules/p1/us-central1/old",
+				"forwarding-rules/p2/global/old-global", "forwarding-rules/p2/us-central1/old",
+			},
+		},
+		{
+			targetPoolsResourceType,
+			func(projects []string, f *fakeCloud) ResourceDeleter { return NewTargetPoolDeleter(projects, f) },
+			[]cloudResource{
+				{Name: "old", Location: "us-central1", CreationTimestamp: hoursAgo(30)},
+				{Name: "new", Location: "us-central1", CreationTimestamp: hoursAgo(1)},
+			},
+			[]string{"target-pools/p1/us-central1/old", "target-pools/p2/us-central1/old"},
+		},
+		{
+			addressesResourceType,
+			func(projects []string, f *fakeCloud) ResourceDeleter { return NewAddressDeleter(projects, f) },
+			[]cloudResource{
+				{Name: "old", Location: "us-central1", CreationTimestamp: hoursAgo(30)},
+				{Name: "reserved", Location: "us-central1", CreationTimestamp: hoursAgo(30), InUse: true},
+				{Name: "new", Location: globalLocation, CreationTimestamp: hoursAgo(1)},
+			},
+			[]string{"addresses/p1/us-central1/old", "addresses/p2/us-central1/old"},
+		},
+		{
+			firewallRulesResourceType,
+			func(projects []string, f *fakeCloud) ResourceDeleter { return NewFirewallRuleDeleter(projects, f) },
+			[]cloudResource{
+				{Name: "gke-old-cluster-all", Location: globalLocation, CreationTimestamp: hoursAgo(30)},
+				{Name: "default-allow-ssh", Location: globalLocation, CreationTimestamp: hoursAgo(3000)},
+				{Name: "gke-new-cluster-all", Location: globalLocation, CreationTimestamp: hoursAgo(1)},
+			},
+			[]string{"firewall-rules/p1/global/gke-old-cluster-all", "firewall-rules/p2/global/gke-old-cluster-all"},
+		},
+		{
+			topicsResourceType,
+			func(projects []string, f *fakeCloud) ResourceDeleter { return NewTopicDeleter(projects, f) },
+			[]cloudResource{
+				{Name: "orphaned", Location: globalLocation},
+				{Name: "subscribed", Location: globalLocation, InUse: true},
+				{Name: "expired", Location: globalLocation, Labels: map[string]string{expiresAtLabel: "1"}},
+			},
+			[]string{
+				"topics/p1/global/expired", "topics/p1/global/orphaned",
+				"topics/p2/global/expired", "topics/p2/global/orphaned",
+			},
+		},
+	}
+
+	projects := []string{"p1", "p2"}
+	for _, data := range datas {
+		for _, dryRun := range []bool{true, false} {
+			f := newFakeCloud()
+			for _, p := range projects {
+				f.add(data.kind, p, data.resources...)
+			}
+			d := data.newDeleter(projects, f)
+			errMsg := fmt.Sprintf("Delete %s (dry run: %v): ", data.kind, dryRun)
+			c, errs := d.Delete(24, 5, dryRun)
+			d.ShowStats(c, errs)
+			if len(errs) != 0 {
+				t.Errorf("%sgot unexpected errors %v", errMsg, errs)
+			}
+			var expDeleted []string
+			if !dryRun {
+				expDeleted = data.expDeleted
+				if c != len(data.expDeleted) {
+					t.Errorf("%sgot %d items deleted, wanted %d", errMsg, c, len(data.expDeleted))
+				}
+			}
+			if dif := cmp.Diff(expDeleted, f.sortedDeleted()); dif != "" {
+				t.Errorf("%sgot(+) is different from wanted(-)\n%v", errMsg, dif)
+			}
+		}
+	}
+}
+
+func TestBucketDeleter(t *testing.T) {
+	f := newFakeCloud()
+	f.add(bucketsResourceType, "p1",
+		cloudResource{Name: "p1-old", Location: "us", CreationTimestamp: hoursAgo(100)},
+		cloudResource{Name: "p1-new", Location: "us", CreationTimestamp: hoursAgo(10)})
+	f.add(bucketsResourceType, "p2",
+		cloudResource{Name: "p2-old", Location: "us", CreationTimestamp: hoursAgo(100)})
+	d := NewBucketDeleter([]string{"p1", "p2"}, f)
+	c, errs := d.Delete(48, 5, false)
+	if len(errs) != 0 || c != 2 {
+		t.Errorf("got %d items deleted and errors %v, wanted 2 and no errors", c, errs)
+	}
+	if dif := cmp.Diff([]string{"buckets/p1/us/p1-old", "buckets/p2/us/p2-old"}, f.sortedDeleted()); dif != "" {
+		t.Errorf("got(+) is different from wanted(-)\n%v", dif)
+	}
+}
+
+func TestTopicDeleterRetentionPolicy(t *testing.T) {
+	f := newFakeCloud()
+	f.add(topicsResourceType, "p1",
+		cloudResource{Name: "orphaned", Location: globalLocation},
+		cloudResource{Name: "pinned", Location: globalLocation},
+		cloudResource{Name: "labeled", Location: globalLocation, Labels: map[string]string{"keep": "true"}},
+		cloudResource{Name: "not-expired", Location: globalLocation, Labels: map[string]string{expiresAtLabel: "99999999999"}},
+		cloudResource{Name: "subscribed", Location: globalLocation, InUse: true})
+	d := NewTopicDeleter([]string{"p1"}, f)
+	d.SetRetention(&RetentionPolicy{Rules: map[string]*RetentionRule{
+		topicsResourceType: {
+			KeepLabels: map[string]string{"keep": "true"},
+			Protected:  []string{"pinned"},
+		},
+	}}, nil)
+	c, errs := d.Delete(24, 5, false)
+	if len(errs) != 0 || c != 1 {
+		t.Errorf("got %d items deleted and errors %v, wanted 1 and no errors", c, errs)
+	}
+	if dif := cmp.Diff([]string{"topics/p1/global/orphaned"}, f.sortedDeleted()); dif != "" {
+		t.Errorf("got(+) is different from wanted(-)\n%v", dif)
+	}
+}
+
+func TestResourceDeletersErrors(t *testing.T) {
+	datas := []struct {
+		project   string
+		resources []cloudResource
+		listErr   error
+		deleteErr error
+		expCount  int
+		expErr    error
+	}{
+		{ // Forbidden project.
+			"knative-tests",
+			[]cloudResource{{Name: "old", Location: "us-central1-a", CreationTimestamp: hoursAgo(30)}},
+			nil,
+			nil,
+			0,
+			errors.New(`cleaning up "knative-tests" is forbidden`),
+		},
+		{ // Error listing resources.
+			"p1",
+			nil,
+			errors.New("list failed"),
+			nil,
+			0,
+			errors.New(`error listing disks in "p1": list failed`),
+		},
+		{ // Bad timestamp, the resource is kept without failing the others.
+			"p1",
+			[]cloudResource{
+				{Name: "bad", Location: "us-central1-a", CreationTimestamp: "yesterday"},
+				{Name: "old", Location: "us-central1-a", CreationTimestamp: hoursAgo(30)},
+			},
+			nil,
+			nil,
+			1,
+			nil,
+		},
+		{ // Error deleting resources.
+			"p1",
+			[]cloudResource{{Name: "old", Location: "us-central1-a", CreationTimestamp: hoursAgo(30)}},
+			nil,
+			errors.New("delete failed"),
+			0,
+			errors.New(`error deleting disk "p1/us-central1-a/old": delete failed`),
+		},
+	}
+	for _, data := range datas {
+		f := newFakeCloud()
+		f.add(disksResourceType, data.project, data.resources...)
+		f.listErr = data.listErr
+		f.deleteErr = data.deleteErr
+		c, err := NewDiskDeleter([]string{data.project}, f).DeleteResources(data.project, 24, false)
+		errMsg := fmt.Sprintf("Delete disks in %q: ", data.project)
+		if c != data.expCount {
+			t.Errorf("%sgot %d items deleted, wanted %d", errMsg, c, data.expCount)
+		}
+		if m := errorMismatch(err, data.expErr); m != "" {
+			t.Errorf("%s%s", errMsg, m)
+		}
+	}
+}
+
+func TestParseResourceTypes(t *testing.T) {
+	datas := []struct {
+		flag string
+		exp  []string
+		err  error
+	}{
+		{ // Default.
+			"images,clusters",
+			[]string{"images", "clusters"},
+			nil,
+		},
+		{ // Returned in cleanup order.
+			"topics, disks,forwarding-rules,images,",
+			[]string{"images", "forwarding-rules", "disks", "topics"},
+			nil,
+		},
+		{ // Unknown type.
+			"disks,vms",
+			nil,
+			errors.New(`unknown resource type "vms"`),
+		},
+		{ // No type.
+			" , ",
+			nil,
+			errors.New("no resource type provided"),
+		},
+	}
+	for _, data := range datas {
+		r, err := parseResourceTypes(data.flag)
+		errMsg := fmt.Sprintf("Parse resource types %q: ", data.flag)
+		if m := errorMismatch(err, data.err); m != "" {
+			t.Errorf("%s%s", errMsg, m)
+		}
+		if dif := cmp.Diff(data.exp, r); dif != "" {
+			t.Errorf("%sgot(+) is different from wanted(-)\n%v", errMsg, dif)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// expiresAtLabel is the label used by jobs to declare when a resource they
+// created can be deleted, as a Unix timestamp in seconds.
+const expiresAtLabel = "expires-at"
+
+// Actions recorded in the audit report.
+const (
+	keepAction   = "keep"
+	deleteAction = "delete"
+)
+
+// RetentionPolicy defines the resources to keep, per resource type.
+type RetentionPolicy struct {
+	Rules map[string]*RetentionRule `yaml:"rules"`
+}
+
+// RetentionRule defines the resources of a type to keep.
+type RetentionRule struct {
+	// HoursToKeep overrides the age threshold of the command line flags.
+	HoursToKeep *int `yaml:"hours-to-keep"`
+	// KeepNewest is the number of newest resources to keep in each group,
+	// i.e. repository for images and project for the other resources.
+	KeepNewest int `yaml:"keep-newest"`
+	// KeepLabels keeps the resources with any of these labels, "*" matching any value.
+	KeepLabels map[string]string `yaml:"keep-labels"`
+	// KeepNames keeps the resources whose name matches any of these regular expressions.
+	KeepNames []string `yaml:"keep-names"`
+	// KeepTags keeps the images with a tag matching any of these regular expressions.
+	KeepTags []string `yaml:"keep-tags"`
+	// Protected is the allow-list of resources, or image repositories, that are never deleted.
+	Protected []string `yaml:"protected"`
+
+	keepNames []*regexp.Regexp
+	keepTags  []*regexp.Regexp
+}
+
+// retentionDecision is whether to delete a resource, and why.
+type retentionDecision struct {
+	Delete bool
+	Reason string
+}
+
+// auditEntry records what was done to a resource.
+type auditEntry struct {
+	ResourceType string `json:"resourceType"`
+	Project      string `json:"project"`
+	Location     string `json:"location,omitempty"`
+	Name         string `json:"name"`
+	Action       string `json:"action"`
+	Reason       string `json:"reason"`
+	Error        string `json:"error,omitempty"`
+}
+
+// auditReport records all resources kept or deleted in a run.
+type auditReport struct {
+	StartTime time.Time    `json:"startTime"`
+	DryRun    bool         `json:"dryRun"`
+	Entries   []auditEntry `json:"entries"`
+
+	mutex sync.Mutex
+}
+
+// readRetentionPolicy reads and validates a retention policy file.
+func readRetentionPolicy(path string) (*RetentionPolicy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read retention policy %q", path)
+	}
+	policy := &RetentionPolicy{}
+	if err := yaml.UnmarshalStrict(content, policy); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse retention policy %q", path)
+	}
+	for resourceType, rule := range policy.Rules {
+		if !isResourceType(resourceType) {
+			return nil, fmt.Errorf("unknown resource type %q in retention policy %q", resourceType, path)
+		}
+		if rule == nil {
+			policy.Rules[resourceType] = &RetentionRule{}
+			continue
+		}
+		if rule.KeepNewest < 0 {
+			return nil, fmt.Errorf("negative keep-newest for %q in retention policy %q", resourceType, path)
+		}
+		if rule.keepNames, err = compileRegexps(rule.KeepNames); err != nil {
+			return nil, errors.Wrapf(err, "invalid keep-names for %q in retention policy %q", resourceType, path)
+		}
+		if rule.keepTags, err = compileRegexps(rule.KeepTags); err != nil {
+			return nil, errors.Wrapf(err, "invalid keep-tags for %q in retention policy %q", resourceType, path)
+		}
+	}
+	return policy, nil
+}
+
+// compileRegexps compiles the regular expressions, which must match the whole string.
+func compileRegexps(exprs []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, expr := range exprs {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// rule returns the rule for the resource type, which is empty if there's none.
+func (p *RetentionPolicy) rule(resourceType string) *RetentionRule {
+	if p != nil && p.Rules[resourceType] != nil {
+		return p.Rules[resourceType]
+	}
+	return &RetentionRule{}
+}
+
+// decide returns whether to delete each resource of the given type, at the
+// given time. Resources are kept if they are protected, in use, matched by a
+// keep rule, not expired according to their expires-at label, among the
+// newest of their group, or newer than the given amount of hours.
+func (p *RetentionPolicy) decide(resourceType string, resources []cloudResource, hoursToKeep int, now time.Time) []retentionDecision {
+	rule := p.rule(resourceType)
+	if rule.HoursToKeep != nil {
+		hoursToKeep = *rule.HoursToKeep
+	}
+
+	creations := make([]time.Time, len(resources))
+	invalid := make([]error, len(resources))
+	for i, r := range resources {
+		// Resources without creation time are kept unless they expired.
+		if r.CreationTimestamp == "" {
+			continue
+		}
+		creations[i], invalid[i] = time.Parse(time.RFC3339, r.CreationTimestamp)
+	}
+	newest := newestResources(resources, creations, rule.KeepNewest)
+
+	decisions := make([]retentionDecision, len(resources))
+	for i, r := range resources {
+		if invalid[i] != nil {
+			// Don't guess the age of the resource, nor let it fail the others.
+			decisions[i] = retentionDecision{Reason: fmt.Sprintf("invalid creation time %q", r.CreationTimestamp)}
+			continue
+		}
+		decisions[i] = rule.decideOne(r, creations[i], newest[i], hoursToKeep, now)
+	}
+	return decisions
+}
+
+// decideOne returns whether to delete a single resource.
+func (rule *RetentionRule) decideOne(r cloudResource, creation time.Time, newest bool, hoursToKeep int, now time.Time) retentionDecision {
+	keep := func(format string, args ...interface{}) retentionDecision {
+		return retentionDecision{Reason: fmt.Sprintf(format, args...)}
+	}
+	for _, name := range rule.Protected {
+		if name == r.Name || (r.Group != "" && name == r.Group) {
+			return keep("protected")
+		}
+	}
+	if r.InUse {
+		return keep("in use")
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for key := range r.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if want, ok := rule.KeepLabels[key]; ok && (want == "*" || want == r.Labels[key]) {
+			return keep("label %s=%s", key, r.Labels[key])
+		}
+	}
+	for i, re := range rule.keepNames {
+		if re.MatchString(r.Name) {
+			return keep("name matches %q", rule.KeepNames[i])
+		}
+	}
+	for _, tag := range r.Tags {
+		for i, re := range rule.keepTags {
+			if re.MatchString(tag) {
+				return keep("tag %q matches %q", tag, rule.KeepTags[i])
+			}
+		}
+	}
+	if value, ok := r.Labels[expiresAtLabel]; ok {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return keep("invalid %s label %q", expiresAtLabel, value)
+		}
+		expiration := time.Unix(seconds, 0).UTC()
+		if now.Before(expiration) {
+			return keep("expires at %s", expiration.Format(time.RFC3339))
+		}
+		return retentionDecision{Delete: true, Reason: fmt.Sprintf("expired at %s", expiration.Format(time.RFC3339))}
+	}
+	if newest {
+		return keep("one of the %d newest", rule.KeepNewest)
+	}
+	if creation.IsZero() {
+		// The age of the resource is unknown, e.g. for topics.
+		if r.DeleteWhenUnused {
+			return retentionDecision{Delete: true, Reason: "not in use, no creation time"}
+		}
+		return keep("no creation time")
+	}
+	age := int(now.Sub(creation).Hours())
+	if creation.After(now.Add(-time.Hour * time.Duration(hoursToKeep))) {
+		return keep("%d hours old, newer than %d hours", age, hoursToKeep)
+	}
+	return retentionDecision{Delete: true, Reason: fmt.Sprintf("%d hours old, older than %d hours", age, hoursToKeep)}
+}
+
+// newestResources flags the n newest resources of each group.
+func newestResources(resources []cloudResource, creations []time.Time, n int) []bool {
+	newest := make([]bool, len(resources))
+	if n == 0 {
+		return newest
+	}
+	groups := make(map[string][]int)
+	for i, r := range resources {
+		groups[r.Group] = append(groups[r.Group], i)
+	}
+	for _, indexes := range groups {
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return creations[indexes[a]].After(creations[indexes[b]])
+		})
+		for i := 0; i < n && i < len(indexes); i++ {
+			newest[indexes[i]] = true
+		}
+	}
+	return newest
+}
+
+// newAuditReport returns an empty audit report for a run starting now.
+func newAuditReport(dryRun bool) *auditReport {
+	return &auditReport{StartTime: time.Now().UTC(), DryRun: dryRun, Entries: make([]auditEntry, 0)}
+}
+
+// record adds an entry to the report, if any.
+func (r *auditReport) record(e auditEntry) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Entries = append(r.Entries, e)
+}
+
+// write writes the report as JSON to the given file, sorting the entries to
+// make it easier to read.
+func (r *auditReport) write(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	sort.SliceStable(r.Entries, func(i, j int) bool {
+		a, b := r.Entries[i], r.Entries[j]
+		if a.ResourceType != b.ResourceType {
+			return a.ResourceType < b.ResourceType
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Location+"/"+a.Name < b.Location+"/"+b.Name
+	})
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, content, 0644), "cannot write audit report %q", path)
+}
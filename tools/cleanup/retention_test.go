@@ -0,0 +1,238 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestReadRetentionPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	datas := []struct {
+		content string
+		err     error
+	}{
+		{ // Unknown resource type.
+			"rules:\n  vms:\n    keep-newest: 1\n",
+			errors.New(`unknown resource type "vms"`),
+		},
+		{ // Unknown field.
+			"rules:\n  disks:\n    keep-oldest: 1\n",
+			errors.New("field keep-oldest not found"),
+		},
+		{ // Negative number of resources to keep.
+			"rules:\n  disks:\n    keep-newest: -1\n",
+			errors.New("negative keep-newest"),
+		},
+		{ // Bad regex.
+			"rules:\n  images:\n    keep-tags: ['v[0-9']\n",
+			errors.New("invalid keep-tags"),
+		},
+	}
+	for i, data := range datas {
+		path := filepath.Join(dir, strconv.Itoa(i)+".yaml")
+		if err := ioutil.WriteFile(path, []byte(data.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readRetentionPolicy(path)
+		if m := errorMismatch(err, data.err); m != "" {
+			t.Errorf("Read retention policy %q: %s", data.content, m)
+		}
+	}
+
+	policy, err := readRetentionPolicy("testdata/retention_policy.yaml")
+	if err != nil {
+		t.Fatalf("Cannot read retention policy: %v", err)
+	}
+	if rule := policy.rule(clustersResourceType); rule.HoursToKeep == nil || *rule.HoursToKeep != 48 {
+		t.Errorf("Unexpected hours to keep clusters: %v", rule.HoursToKeep)
+	}
+	if rule := policy.rule(disksResourceType); rule == nil || rule.KeepNewest != 0 {
+		t.Errorf("Expected an empty rule for disks, got %+v", rule)
+	}
+}
+
+func TestRetentionDecisions(t *testing.T) {
+	policy, err := readRetentionPolicy("testdata/retention_policy.yaml")
+	if err != nil {
+		t.Fatalf("Cannot read retention policy: %v", err)
+	}
+	now := time.Now()
+	past := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+	future := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+
+	datas := []struct {
+		resourceType string
+		resources    []cloudResource
+		exp          []string
+	}{
+		{ // Clusters, with the age threshold overridden.
+			clustersResourceType,
+			[]cloudResource{
+				{Name: "old", CreationTimestamp: hoursAgo(50)},
+				{Name: "recent", CreationTimestamp: hoursAgo(30)},
+				{Name: "perf-serving", CreationTimestamp: hoursAgo(500)},
+				{Name: "labeled", CreationTimestamp: hoursAgo(500), Labels: map[string]string{"keep": "true"}},
+				{Name: "expired", CreationTimestamp: hoursAgo(1), Labels: map[string]string{expiresAtLabel: past, "keep": "true"}},
+				{Name: "not-expired", CreationTimestamp: hoursAgo(500), Labels: map[string]string{expiresAtLabel: future}},
+				{Name: "bad-expiration", CreationTimestamp: hoursAgo(500), Labels: map[string]string{expiresAtLabel: "tomorrow"}},
+				{Name: "expired-perf", CreationTimestamp: hoursAgo(1), Labels: map[string]string{expiresAtLabel: past}},
+				{Name: "bad-creation", CreationTimestamp: "yesterday"},
+			},
+			[]string{
+				"delete: 50 hours old, older than 48 hours",
+				"keep: 30 hours old, newer than 48 hours",
+				`keep: name matches "perf-.*"`,
+				"keep: label keep=true",
+				"keep: label keep=true",
+				"keep: expires at " + time.Unix(now.Add(time.Hour).Unix(), 0).UTC().Format(time.RFC3339),
+				`keep: invalid expires-at label "tomorrow"`,
+				"delete: expired at " + time.Unix(now.Add(-time.Hour).Unix(), 0).UTC().Format(time.RFC3339),
+				`keep: invalid creation time "yesterday"`,
+			},
+		},
+		{ // Images, keeping the 2 newest per repository.
+			imagesResourceType,
+			[]cloudResource{
+				{Name: "gcr.io/p/a@1", Group: "gcr.io/p/a", CreationTimestamp: hoursAgo(100)},
+				{Name: "gcr.io/p/a@2", Group: "gcr.io/p/a", CreationTimestamp: hoursAgo(300)},
+				{Name: "gcr.io/p/a@3", Group: "gcr.io/p/a", CreationTimestamp: hoursAgo(200)},
+				{Name: "gcr.io/p/a@4", Group: "gcr.io/p/a", CreationTimestamp: hoursAgo(400), Tags: []string{"v0.19.1"}},
+				{Name: "gcr.io/p/b@1", Group: "gcr.io/p/b", CreationTimestamp: hoursAgo(400), Tags: []string{"latest-0"}},
+				{Name: "gcr.io/knative-performance/pinned@1", Group: "gcr.io/knative-performance/pinned", CreationTimestamp: hoursAgo(400)},
+			},
+			[]string{
+				"keep: one of the 2 newest",
+				"delete: 300 hours old, older than 24 hours",
+				"keep: one of the 2 newest",
+				`keep: tag "v0.19.1" matches "v[0-9.]+"`,
+				"keep: one of the 2 newest",
+				"keep: protected",
+			},
+		},
+		{ // Resources without rule nor creation time.
+			topicsResourceType,
+			[]cloudResource{
+				{Name: "orphaned"},
+				{Name: "unused", DeleteWhenUnused: true},
+				{Name: "subscribed", InUse: true, DeleteWhenUnused: true},
+				{Name: "expired", Labels: map[string]string{expiresAtLabel: strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)}},
+			},
+			[]string{
+				"keep: no creation time",
+				"delete: not in use, no creation time",
+				"keep: in use",
+				"delete: expired at " + now.Add(-time.Hour).UTC().Format(time.RFC3339),
+			},
+		},
+	}
+	for _, data := range datas {
+		decisions := policy.decide(data.resourceType, data.resources, 24, now)
+		var got []string
+		for _, d := range decisions {
+			action := keepAction
+			if d.Delete {
+				action = deleteAction
+			}
+			got = append(got, action+": "+d.Reason)
+		}
+		if dif := cmp.Diff(data.exp, got); dif != "" {
+			t.Errorf("Decide for %s: got(+) is different from wanted(-)\n%v", data.resourceType, dif)
+		}
+	}
+
+	// Without a policy, only the age matters.
+	var noPolicy *RetentionPolicy
+	decisions := noPolicy.decide(imagesResourceType, []cloudResource{{Name: "a", CreationTimestamp: hoursAgo(30)}}, 24, now)
+	if len(decisions) != 1 || !decisions[0].Delete {
+		t.Errorf("Decide without policy: got %v", decisions)
+	}
+}
+
+func TestAuditReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := newFakeCloud()
+	for _, p := range []string{"p1", "p2"} {
+		f.add(disksResourceType, p,
+			cloudResource{Name: "old", Location: "us-central1-a", CreationTimestamp: hoursAgo(30)},
+			cloudResource{Name: "pinned", Location: "us-central1-a", CreationTimestamp: hoursAgo(30), Labels: map[string]string{"keep": "yes"}})
+	}
+	audit := newAuditReport(false)
+	d := NewDiskDeleter([]string{"p2", "p1"}, f)
+	d.SetRetention(&RetentionPolicy{Rules: map[string]*RetentionRule{
+		disksResourceType: {KeepLabels: map[string]string{"keep": "*"}},
+	}}, audit)
+	if c, errs := d.Delete(24, 2, false); c != 2 || len(errs) != 0 {
+		t.Fatalf("Got %d items deleted and errors %v, wanted 2 and no errors", c, errs)
+	}
+
+	path := filepath.Join(dir, "report.json")
+	if err := audit.write(path); err != nil {
+		t.Fatalf("Cannot write audit report: %v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		DryRun  bool         `json:"dryRun"`
+		Entries []auditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Cannot parse audit report: %v\n%s", err, content)
+	}
+	var exp []auditEntry
+	for _, p := range []string{"p1", "p2"} {
+		exp = append(exp,
+			auditEntry{ResourceType: "disks", Project: p, Location: "us-central1-a", Name: "old", Action: "delete", Reason: "30 hours old, older than 24 hours"},
+			auditEntry{ResourceType: "disks", Project: p, Location: "us-central1-a", Name: "pinned", Action: "keep", Reason: "label keep=yes"})
+	}
+	if dif := cmp.Diff(exp, report.Entries); dif != "" {
+		t.Errorf("Audit report: got(+) is different from wanted(-)\n%v", dif)
+	}
+
+	// Failed deletions are recorded too.
+	f.add(disksResourceType, "p3", cloudResource{Name: "old", Location: "us-central1-a", CreationTimestamp: hoursAgo(30)})
+	f.deleteErr = errors.New("quota exceeded")
+	audit = newAuditReport(false)
+	d.SetRetention(nil, audit)
+	if _, err := d.DeleteResources("p3", 24, false); err == nil {
+		t.Error("Expected an error deleting disks")
+	}
+	if len(audit.Entries) != 1 || audit.Entries[0].Error == "" {
+		t.Errorf("Expected the error in the audit report, got %+v", audit.Entries)
+	}
+}
@@ -0,0 +1,494 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// configdiff.go computes the semantic diff between the generated Prow and
+// TestGrid configs and the checked-in ones, so that reviewers of meta config
+// changes can see the job level changes instead of the regenerated lines.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"knative.dev/test-infra/pkg/ghutil"
+)
+
+const (
+	// Directories of the checked-in configs, relative to the directory passed to --diff-against.
+	diffProwJobsDir      = "jobs"
+	diffTestgridDir      = "testgrid"
+	diffK8sTestgridDir   = "k8s-testgrid"
+	diffCommentSignature = "<!-- config-generator semantic diff -->"
+)
+
+// prowJob is the subset of a Prow job that is compared in the semantic diff.
+type prowJob struct {
+	Name         string            `yaml:"name"`
+	Cron         string            `yaml:"cron"`
+	Branches     []string          `yaml:"branches"`
+	SkipBranches []string          `yaml:"skip_branches"`
+	Annotations  map[string]string `yaml:"annotations"`
+	Spec         struct {
+		Containers []prowContainer `yaml:"containers"`
+	} `yaml:"spec"`
+}
+
+type prowContainer struct {
+	Image   string   `yaml:"image"`
+	Command []string `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Env     []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// prowJobsConfig is the subset of the Prow config that contains the jobs.
+type prowJobsConfig struct {
+	Presubmits  map[string][]prowJob `yaml:"presubmits"`
+	Postsubmits map[string][]prowJob `yaml:"postsubmits"`
+	Periodics   []prowJob            `yaml:"periodics"`
+}
+
+// fieldChange is the change of a single field of a job, test group or dashboard.
+type fieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// entryDiff is the list of field changes of a job, test group or dashboard.
+type entryDiff struct {
+	Name    string
+	Changes []fieldChange
+}
+
+// sectionDiff is the diff of a set of named entries, e.g. presubmit jobs or
+// TestGrid dashboards.
+type sectionDiff struct {
+	Title   string
+	Added   []string
+	Removed []string
+	Changed []entryDiff
+}
+
+func (sd sectionDiff) empty() bool {
+	return len(sd.Added) == 0 && len(sd.Removed) == 0 && len(sd.Changed) == 0
+}
+
+// configDiff is the semantic diff of all generated configs.
+type configDiff []sectionDiff
+
+func (cd configDiff) empty() bool {
+	for _, sd := range cd {
+		if !sd.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// markdown formats the diff as markdown, to be printed or posted as a comment.
+func (cd configDiff) markdown() string {
+	var sb strings.Builder
+	sb.WriteString("### Generated config changes\n\n")
+	if cd.empty() {
+		sb.WriteString("No changes to the generated Prow and TestGrid configs.\n")
+		return sb.String()
+	}
+	for _, sd := range cd {
+		if sd.empty() {
+			continue
+		}
+		fmt.Fprintf(&sb, "#### %s\n\n", sd.Title)
+		for _, name := range sd.Added {
+			fmt.Fprintf(&sb, "- :heavy_plus_sign: `%s`\n", name)
+		}
+		for _, name := range sd.Removed {
+			fmt.Fprintf(&sb, "- :heavy_minus_sign: `%s`\n", name)
+		}
+		for _, ed := range sd.Changed {
+			fmt.Fprintf(&sb, "- :pencil2: `%s`\n", ed.Name)
+			for _, fc := range ed.Changes {
+				fmt.Fprintf(&sb, "  - `%s`: %s → %s\n", fc.Field, formatDiffValue(fc.Before), formatDiffValue(fc.After))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func formatDiffValue(v string) string {
+	if v == "" {
+		return "_unset_"
+	}
+	return "`" + v + "`"
+}
+
+// diffFields compares two flattened entries field by field, sorted by field name.
+func diffFields(before, after map[string]string) []fieldChange {
+	fields := make(map[string]bool)
+	for f := range before {
+		fields[f] = true
+	}
+	for f := range after {
+		fields[f] = true
+	}
+	var changes []fieldChange
+	for f := range fields {
+		if before[f] != after[f] {
+			changes = append(changes, fieldChange{Field: f, Before: before[f], After: after[f]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// diffEntries compares two sets of flattened entries, keyed by entry name.
+func diffEntries(title string, before, after map[string]map[string]string) sectionDiff {
+	sd := sectionDiff{Title: title}
+	for name, fields := range after {
+		old, ok := before[name]
+		if !ok {
+			sd.Added = append(sd.Added, name)
+			continue
+		}
+		if changes := diffFields(old, fields); len(changes) > 0 {
+			sd.Changed = append(sd.Changed, entryDiff{Name: name, Changes: changes})
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			sd.Removed = append(sd.Removed, name)
+		}
+	}
+	sort.Strings(sd.Added)
+	sort.Strings(sd.Removed)
+	sort.Slice(sd.Changed, func(i, j int) bool {
+		return sd.Changed[i].Name < sd.Changed[j].Name
+	})
+	return sd
+}
+
+// formatList formats a list of strings in a way that keeps the element boundaries visible.
+func formatList(l []string) string {
+	if len(l) == 0 {
+		return ""
+	}
+	return strings.Join(quoteAll(l), " ")
+}
+
+func quoteAll(l []string) []string {
+	res := make([]string, len(l))
+	for i, s := range l {
+		res[i] = fmt.Sprintf("%q", s)
+	}
+	return res
+}
+
+// prowJobFields flattens the compared fields of a Prow job.
+func prowJobFields(job prowJob) map[string]string {
+	fields := map[string]string{
+		"cron":          job.Cron,
+		"branches":      formatList(job.Branches),
+		"skip_branches": formatList(job.SkipBranches),
+	}
+	for k, v := range job.Annotations {
+		fields["annotations."+k] = v
+	}
+	for i, c := range job.Spec.Containers {
+		prefix := ""
+		if len(job.Spec.Containers) > 1 {
+			prefix = fmt.Sprintf("containers[%d].", i)
+		}
+		fields[prefix+"image"] = c.Image
+		fields[prefix+"command"] = formatList(c.Command)
+		fields[prefix+"args"] = formatList(c.Args)
+		for _, e := range c.Env {
+			fields[prefix+"env."+e.Name] = e.Value
+		}
+	}
+	return fields
+}
+
+// addProwJobs adds the flattened jobs to the map, keyed by the job name, and
+// for presubmits and postsubmits also by the repo.
+func addProwJobs(jobs map[string]map[string]string, repo string, list []prowJob) {
+	for _, job := range list {
+		name := job.Name
+		if repo != "" {
+			name = repo + ": " + job.Name
+		}
+		jobs[name] = prowJobFields(job)
+	}
+}
+
+// loadProwJobs parses the Prow jobs in the given config files, grouped by job type.
+func loadProwJobs(files []string) (map[string]map[string]map[string]string, error) {
+	res := map[string]map[string]map[string]string{
+		"presubmits":  {},
+		"postsubmits": {},
+		"periodics":   {},
+	}
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var config prowJobsConfig
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("cannot parse Prow config %q: %v", f, err)
+		}
+		for repo, jobs := range config.Presubmits {
+			addProwJobs(res["presubmits"], repo, jobs)
+		}
+		for repo, jobs := range config.Postsubmits {
+			addProwJobs(res["postsubmits"], repo, jobs)
+		}
+		addProwJobs(res["periodics"], "", config.Periodics)
+	}
+	return res, nil
+}
+
+// flattenYaml flattens a yaml value into dotted field names. Lists of objects
+// with a name are keyed by the name, other lists are formatted as a single value.
+func flattenYaml(prefix string, v interface{}, fields map[string]string) {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		for k, item := range val {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenYaml(key, item, fields)
+		}
+	case []interface{}:
+		if names, ok := itemNames(val); ok {
+			for i, item := range val {
+				key := fmt.Sprintf("%s[%s]", prefix, names[i])
+				flattenYaml(key, item, fields)
+				// The name is already part of the key.
+				delete(fields, key+".name")
+			}
+			return
+		}
+		l := make([]string, len(val))
+		for i, item := range val {
+			l[i] = fmt.Sprint(item)
+		}
+		fields[prefix] = formatList(l)
+	case nil:
+		fields[prefix] = ""
+	default:
+		fields[prefix] = fmt.Sprint(val)
+	}
+}
+
+// itemNames returns the names of the items if all of them are objects with a name.
+func itemNames(l []interface{}) ([]string, bool) {
+	names := make([]string, len(l))
+	for i, item := range l {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil, false
+		}
+		names[i] = name
+	}
+	return names, len(l) > 0
+}
+
+// loadTestgridEntries parses the named entries of the given TestGrid sections
+// (test_groups, dashboards and dashboard_groups), grouped by section.
+func loadTestgridEntries(files []string, sections []string) (map[string]map[string]map[string]string, error) {
+	res := make(map[string]map[string]map[string]string)
+	for _, s := range sections {
+		res[s] = make(map[string]map[string]string)
+	}
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		config := make(map[string]interface{})
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("cannot parse TestGrid config %q: %v", f, err)
+		}
+		for _, s := range sections {
+			entries, _ := config[s].([]interface{})
+			for _, entry := range entries {
+				m, ok := entry.(map[interface{}]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid entry in %s of TestGrid config %q: %v", s, f, entry)
+				}
+				fields := make(map[string]string)
+				flattenYaml("", m, fields)
+				delete(fields, "name")
+				res[s][fmt.Sprint(m["name"])] = fields
+			}
+		}
+	}
+	return res, nil
+}
+
+// yamlFilesIn returns the yaml files directly under dir, sorted by name.
+func yamlFilesIn(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// generatedConfigs are the config files written by the generator, empty if
+// the corresponding config was not generated.
+type generatedConfigs struct {
+	prowJobs    string
+	testgrid    string
+	k8sTestgrid string
+}
+
+var (
+	prowJobSections     = []string{"presubmits", "postsubmits", "periodics"}
+	testgridSections    = []string{"test_groups", "dashboards", "dashboard_groups"}
+	k8sTestgridSections = []string{"dashboards", "dashboard_groups"}
+)
+
+// diffConfigs computes the semantic diff between the generated configs and
+// the checked-in configs under dir.
+func diffConfigs(dir string, generated generatedConfigs) (configDiff, error) {
+	var cd configDiff
+	if generated.prowJobs != "" {
+		existingFiles, err := yamlFilesIn(filepath.Join(dir, diffProwJobsDir))
+		if err != nil {
+			return nil, err
+		}
+		before, err := loadProwJobs(existingFiles)
+		if err != nil {
+			return nil, err
+		}
+		after, err := loadProwJobs([]string{generated.prowJobs})
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range prowJobSections {
+			cd = append(cd, diffEntries("Prow "+s, before[s], after[s]))
+		}
+	}
+	testgridConfigs := []struct {
+		title     string
+		dir       string
+		generated string
+		sections  []string
+	}{
+		{"TestGrid", diffTestgridDir, generated.testgrid, testgridSections},
+		{"K8s TestGrid", diffK8sTestgridDir, generated.k8sTestgrid, k8sTestgridSections},
+	}
+	for _, tc := range testgridConfigs {
+		if tc.generated == "" {
+			continue
+		}
+		existingFiles, err := yamlFilesIn(filepath.Join(dir, tc.dir))
+		if err != nil {
+			return nil, err
+		}
+		before, err := loadTestgridEntries(existingFiles, tc.sections)
+		if err != nil {
+			return nil, err
+		}
+		after, err := loadTestgridEntries([]string{tc.generated}, tc.sections)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range tc.sections {
+			cd = append(cd, diffEntries(tc.title+" "+s, before[s], after[s]))
+		}
+	}
+	return cd, nil
+}
+
+// postDiffComment posts the diff as a comment on the given pull request, or
+// updates the comment previously posted by the same user.
+func postDiffComment(gc ghutil.GithubOperations, org, repo string, pr int, cd configDiff) error {
+	body := diffCommentSignature + "\n" + cd.markdown()
+	user, err := gc.GetGithubUser()
+	if err != nil {
+		return fmt.Errorf("cannot get the github user: %v", err)
+	}
+	comments, err := gc.ListComments(org, repo, pr)
+	if err != nil {
+		return fmt.Errorf("cannot list the comments of %s/%s#%d: %v", org, repo, pr, err)
+	}
+	for _, c := range comments {
+		if c.GetUser().GetLogin() == user.GetLogin() && strings.HasPrefix(c.GetBody(), diffCommentSignature) {
+			return gc.EditComment(org, repo, c.GetID(), body)
+		}
+	}
+	_, err = gc.CreateComment(org, repo, pr, body)
+	return err
+}
+
+// parsePullRequestRef parses a pull request reference in the form of org/repo#number.
+func parsePullRequestRef(ref string) (string, string, int, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	orgRepo := strings.Split(parts[0], "/")
+	if len(parts) != 2 || len(orgRepo) != 2 || orgRepo[0] == "" || orgRepo[1] == "" {
+		return "", "", 0, fmt.Errorf("invalid pull request %q, expecting org/repo#number", ref)
+	}
+	number, err := strconv.Atoi(parts[1])
+	if err != nil || number <= 0 {
+		return "", "", 0, fmt.Errorf("invalid pull request number in %q", ref)
+	}
+	return orgRepo[0], orgRepo[1], number, nil
+}
+
+// reportConfigDiff prints the semantic diff of the generated configs against
+// the checked-in configs under dir, and posts it to the pull request, if any.
+func reportConfigDiff(dir string, generated generatedConfigs, commentPR string) error {
+	cd, err := diffConfigs(dir, generated)
+	if err != nil {
+		return fmt.Errorf("failed computing the diff against %q: %v", dir, err)
+	}
+	fmt.Print(cd.markdown())
+	if commentPR == "" {
+		return nil
+	}
+	org, repo, pr, err := parsePullRequestRef(commentPR)
+	if err != nil {
+		return err
+	}
+	gc, err := ghutil.NewGithubClient(githubTokenPath)
+	if err != nil {
+		return fmt.Errorf("failed creating github client from %q: %v", githubTokenPath, err)
+	}
+	if err := postDiffComment(gc, org, repo, pr, cd); err != nil {
+		return fmt.Errorf("failed posting the diff to %s: %v", commentPR, err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
+
+	"knative.dev/test-infra/pkg/ghutil/fakeghutil"
+)
+
+const existingProwJobs = `presubmits:
+  knative/serving:
+  - name: pull-knative-serving-unit-tests
+    spec:
+      containers:
+      - image: gcr.io/knative-tests/test-infra/prow-tests:stable
+        args:
+        - "./test/presubmit-tests.sh"
+        - "--unit-tests"
+  - name: pull-knative-serving-removed
+    spec:
+      containers:
+      - image: gcr.io/knative-tests/test-infra/prow-tests:stable
+periodics:
+- cron: "0 */4 * * *"
+  name: ci-knative-serving-continuous
+  annotations:
+    testgrid-dashboards: serving
+  spec:
+    containers:
+    - image: gcr.io/knative-tests/test-infra/prow-tests:stable
+      env:
+      - name: E2E_CLUSTER_REGION
+        value: us-central1
+`
+
+const generatedProwJobs = `presubmits:
+  knative/serving:
+  - name: pull-knative-serving-unit-tests
+    branches:
+    - main
+    spec:
+      containers:
+      - image: gcr.io/knative-tests/test-infra/prow-tests:beta
+        args:
+        - "./test/presubmit-tests.sh"
+        - "--unit-tests"
+periodics:
+- cron: "0 3 * * *"
+  name: ci-knative-serving-continuous
+  annotations:
+    testgrid-dashboards: serving
+  spec:
+    containers:
+    - image: gcr.io/knative-tests/test-infra/prow-tests:stable
+      env:
+      - name: E2E_CLUSTER_REGION
+        value: us-east1
+- cron: "0 5 * * *"
+  name: ci-knative-serving-nightly-release
+`
+
+const existingTestgrid = `default_test_group:
+  days_of_results: 14
+test_groups:
+- name: ci-knative-serving-continuous
+  gcs_prefix: knative-prow/logs/ci-knative-serving-continuous
+dashboards:
+- name: serving
+  dashboard_tab:
+  - name: continuous
+    test_group_name: ci-knative-serving-continuous
+    base_options: "sort-by-name="
+`
+
+const generatedTestgrid = `default_test_group:
+  days_of_results: 14
+test_groups:
+- name: ci-knative-serving-continuous
+  gcs_prefix: knative-prow/logs/ci-knative-serving-continuous
+  alert_stale_results_hours: 3
+dashboards:
+- name: serving
+  dashboard_tab:
+  - name: continuous
+    test_group_name: ci-knative-serving-continuous
+    base_options: "sort-by-failures="
+`
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "existing")
+	writeTestFile(t, filepath.Join(existing, diffProwJobsDir, "config.yaml"), existingProwJobs)
+	// Jobs in subdirectories are not generated, and are not part of the diff.
+	writeTestFile(t, filepath.Join(existing, diffProwJobsDir, "custom", "custom.yaml"), "periodics:\n- name: custom-job\n")
+	writeTestFile(t, filepath.Join(existing, diffTestgridDir, "testgrid.yaml"), existingTestgrid)
+	generated := generatedConfigs{
+		prowJobs: filepath.Join(dir, "prow-jobs.yaml"),
+		testgrid: filepath.Join(dir, "testgrid.yaml"),
+	}
+	writeTestFile(t, generated.prowJobs, generatedProwJobs)
+	writeTestFile(t, generated.testgrid, generatedTestgrid)
+
+	cd, err := diffConfigs(existing, generated)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := configDiff{
+		{
+			Title:   "Prow presubmits",
+			Removed: []string{"knative/serving: pull-knative-serving-removed"},
+			Changed: []entryDiff{{
+				Name: "knative/serving: pull-knative-serving-unit-tests",
+				Changes: []fieldChange{
+					{Field: "branches", Before: "", After: `"main"`},
+					{Field: "image", Before: "gcr.io/knative-tests/test-infra/prow-tests:stable", After: "gcr.io/knative-tests/test-infra/prow-tests:beta"},
+				},
+			}},
+		},
+		{Title: "Prow postsubmits"},
+		{
+			Title: "Prow periodics",
+			Added: []string{"ci-knative-serving-nightly-release"},
+			Changed: []entryDiff{{
+				Name: "ci-knative-serving-continuous",
+				Changes: []fieldChange{
+					{Field: "cron", Before: "0 */4 * * *", After: "0 3 * * *"},
+					{Field: "env.E2E_CLUSTER_REGION", Before: "us-central1", After: "us-east1"},
+				},
+			}},
+		},
+		{
+			Title: "TestGrid test_groups",
+			Changed: []entryDiff{{
+				Name:    "ci-knative-serving-continuous",
+				Changes: []fieldChange{{Field: "alert_stale_results_hours", Before: "", After: "3"}},
+			}},
+		},
+		{
+			Title: "TestGrid dashboards",
+			Changed: []entryDiff{{
+				Name:    "serving",
+				Changes: []fieldChange{{Field: "dashboard_tab[continuous].base_options", Before: "sort-by-name=", After: "sort-by-failures="}},
+			}},
+		},
+		{Title: "TestGrid dashboard_groups"},
+	}
+	if diff := cmp.Diff(want, cd); diff != "" {
+		t.Errorf("Unexpected diff (-want +got):\n%s", diff)
+	}
+
+	md := cd.markdown()
+	for _, s := range []string{
+		"#### Prow periodics",
+		"- :heavy_plus_sign: `ci-knative-serving-nightly-release`",
+		"- :heavy_minus_sign: `knative/serving: pull-knative-serving-removed`",
+		"  - `cron`: `0 */4 * * *` → `0 3 * * *`",
+		"  - `branches`: _unset_ → `\"main\"`",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Expected %q in markdown:\n%s", s, md)
+		}
+	}
+	if strings.Contains(md, "Prow postsubmits") {
+		t.Errorf("Unexpected empty section in markdown:\n%s", md)
+	}
+}
+
+func TestDiffConfigsWithoutChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFile(t, filepath.Join(dir, diffProwJobsDir, "config.yaml"), existingProwJobs)
+	cd, err := diffConfigs(dir, generatedConfigs{prowJobs: filepath.Join(dir, diffProwJobsDir, "config.yaml")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cd.empty() {
+		t.Errorf("Expected no changes, got %v", cd)
+	}
+	if md := cd.markdown(); !strings.Contains(md, "No changes") {
+		t.Errorf("Unexpected markdown for empty diff:\n%s", md)
+	}
+}
+
+func TestParsePullRequestRef(t *testing.T) {
+	org, repo, pr, err := parsePullRequestRef("knative/test-infra#123")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if org != "knative" || repo != "test-infra" || pr != 123 {
+		t.Errorf("Unexpected result %q %q %d", org, repo, pr)
+	}
+	for _, ref := range []string{"knative/test-infra", "test-infra#1", "knative/test-infra#abc", "/test-infra#1", "knative/test-infra#0"} {
+		if _, _, _, err := parsePullRequestRef(ref); err == nil {
+			t.Errorf("Expected error for %q", ref)
+		}
+	}
+}
+
+func TestPostDiffComment(t *testing.T) {
+	login := "knative-prow-robot"
+	fgc := fakeghutil.NewFakeGithubClient()
+	fgc.User = &github.User{Login: &login}
+	// Comments from other users are left untouched.
+	other := "someone"
+	otherBody := diffCommentSignature + "\nunrelated"
+	otherID := int64(100)
+	fgc.Comments[1] = map[int64]*github.IssueComment{
+		otherID: {ID: &otherID, Body: &otherBody, User: &github.User{Login: &other}},
+	}
+
+	cd := configDiff{{Title: "Prow periodics", Added: []string{"ci-job"}}}
+	if err := postDiffComment(fgc, "knative", "test-infra", 1, cd); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fgc.Comments[1]) != 2 {
+		t.Fatalf("Expected a new comment, got %d comments", len(fgc.Comments[1]))
+	}
+
+	// The comment is updated instead of posting a new one.
+	cd = configDiff{{Title: "Prow periodics", Removed: []string{"ci-job"}}}
+	if err := postDiffComment(fgc, "knative", "test-infra", 1, cd); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fgc.Comments[1]) != 2 {
+		t.Fatalf("Expected the comment to be updated, got %d comments", len(fgc.Comments[1]))
+	}
+	for id, c := range fgc.Comments[1] {
+		if id == otherID {
+			if c.GetBody() != otherBody {
+				t.Errorf("Comment of another user was changed: %q", c.GetBody())
+			}
+			continue
+		}
+		if want := diffCommentSignature + "\n" + cd.markdown(); c.GetBody() != want {
+			t.Errorf("Unexpected comment body (-want +got):\n%s", cmp.Diff(want, c.GetBody()))
+		}
+	}
+}
@@ -51,4 +51,21 @@ func addCustomJobsTestgrid() {
 			Extra:          extras,
 		})
 	}
+	// Custom jobs of the meta config, in the utilities dashboard by default
+	for _, job := range metaConfig.CustomJobs {
+		tg := NonAlignedTestGroup{
+			DashboardGroup: job.DashboardGroup,
+			DashboardName:  job.Dashboard,
+			HumanTabName:   job.Name,
+			CIJobName:      job.Name,
+			Extra:          extras,
+		}
+		if tg.DashboardGroup == "" {
+			tg.DashboardGroup = "maintenance"
+		}
+		if tg.DashboardName == "" {
+			tg.DashboardName = "utilities"
+		}
+		metaData.AddNonAlignedTest(tg)
+	}
 }
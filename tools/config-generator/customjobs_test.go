@@ -23,6 +23,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"gopkg.in/yaml.v2"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
@@ -81,6 +83,7 @@ func TestEnsureCustomJob(t *testing.T) {
 
 func TestAddCustomJobsTestgrid(t *testing.T) {
 	SetupForTesting()
+	metaData = NewTestGridMetaData()
 	addCustomJobsTestgrid()
 	if len(metaData.nonAligned) != len(customJobnames) {
 		t.Errorf("Mismatch in number of nonaligned jobs: expected %d, Actual %d",
@@ -88,3 +91,21 @@ func TestAddCustomJobsTestgrid(t *testing.T) {
 			len(metaData.nonAligned))
 	}
 }
+
+func TestAddCustomJobsTestgridFromMetaConfig(t *testing.T) {
+	SetupForTesting()
+	metaData = NewTestGridMetaData()
+	metaConfig.CustomJobs = []CustomJobConfig{
+		{Name: "ci-org1-repo1-custom"},
+		{Name: "ci-org1-repo1-other", DashboardGroup: "org1", Dashboard: "repo1"},
+	}
+	addCustomJobsTestgrid()
+	got := metaData.nonAligned[len(customJobnames):]
+	want := []NonAlignedTestGroup{
+		{DashboardGroup: "maintenance", DashboardName: "utilities", HumanTabName: "ci-org1-repo1-custom", CIJobName: "ci-org1-repo1-custom"},
+		{DashboardGroup: "org1", DashboardName: "repo1", HumanTabName: "ci-org1-repo1-other", CIJobName: "ci-org1-repo1-other"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(NonAlignedTestGroup{}, "Extra")); diff != "" {
+		t.Errorf("Custom jobs from the meta config got(+) is different from wanted(-)\n%v", diff)
+	}
+}
@@ -0,0 +1,385 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// explain.go traces where the fields of a generated Prow job come from: the
+// defaults, the meta config, the command-line flags or the generator itself.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// Name of the job to explain, tracing is disabled if empty.
+	explainJob string
+
+	// Line numbers of the meta config entries, for the sources of the fields.
+	metaConfigLines lineIndex
+
+	// Path (as in lineIndex) of the meta config entry being generated, if any.
+	currentJobEntry string
+
+	// Explanations of the generated jobs named explainJob.
+	jobExplanations []jobExplanation
+)
+
+// fieldOrigin is the value of a field and what set it.
+type fieldOrigin struct {
+	Value  string
+	Source string
+}
+
+// jobProvenance records which step of the generation last changed each field
+// of a baseProwJobTemplateData. Fields and values are exported so that the
+// provenance survives Clone().
+type jobProvenance struct {
+	// Values of the fields when the last step was recorded.
+	Last map[string]string
+	// Origins of the fields.
+	Origins map[string]fieldOrigin
+}
+
+// newJobProvenance returns a provenance if a job is being explained, or nil.
+func newJobProvenance() *jobProvenance {
+	if explainJob == "" {
+		return nil
+	}
+	return &jobProvenance{Last: make(map[string]string), Origins: make(map[string]fieldOrigin)}
+}
+
+// trace attributes the fields changed since the last traced step to the given
+// source. It's a no-op unless a job is being explained.
+func (data *baseProwJobTemplateData) trace(source string) {
+	p := data.Provenance
+	if p == nil {
+		return
+	}
+	fields := baseJobFields(*data)
+	for k, v := range fields {
+		if last, ok := p.Last[k]; !ok || last != v {
+			p.Origins[k] = fieldOrigin{Value: v, Source: source}
+		}
+	}
+	for k := range p.Last {
+		if _, ok := fields[k]; !ok {
+			delete(p.Origins, k)
+		}
+	}
+	p.Last = fields
+}
+
+// traceFields is like trace, but also attributes the given fields to the
+// source if they didn't change, e.g. when a flag is set to the default value.
+func (data *baseProwJobTemplateData) traceFields(source string, fields ...string) {
+	data.trace(source)
+	if p := data.Provenance; p != nil {
+		for _, f := range fields {
+			p.Origins[f] = fieldOrigin{Value: p.Last[f], Source: source}
+		}
+	}
+}
+
+// traceField attributes a field that is not part of baseProwJobTemplateData,
+// e.g. the cron schedule of a periodic job.
+func (data *baseProwJobTemplateData) traceField(field, value, source string) {
+	if data.Provenance != nil {
+		data.Provenance.Origins[field] = fieldOrigin{Value: value, Source: source}
+	}
+}
+
+// Sources of the fields.
+
+func defaultSource(function string) string {
+	return "default in " + function
+}
+
+func flagSource(flags ...string) string {
+	return "flag --" + strings.Join(flags, ", --")
+}
+
+func helperSource(function string) string {
+	return "hard-coded in " + function
+}
+
+func templateSource(name string) string {
+	return fmt.Sprintf("hard-coded in the %s template", name)
+}
+
+// metaConfigSource returns the key of the meta config entry being generated, with its line.
+func metaConfigSource(key string) string {
+	if currentJobEntry == "" {
+		return fmt.Sprintf("meta config key %q", key)
+	}
+	if line, ok := metaConfigLines[currentJobEntry+"."+key]; ok {
+		return fmt.Sprintf("meta config %s.%s (line %d)", currentJobEntry, key, line)
+	}
+	return fmt.Sprintf("meta config %s.%s", currentJobEntry, key)
+}
+
+// baseJobFields flattens the fields of the job data. Env vars, labels,
+// annotations, extra refs, volumes and volume mounts are split by name, so
+// that each of them has its own origin.
+func baseJobFields(data baseProwJobTemplateData) map[string]string {
+	fields := make(map[string]string)
+	v := reflect.ValueOf(data)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		switch f := v.Field(i).Interface().(type) {
+		case []string:
+			switch name {
+			case "Env":
+				for j := 0; j+1 < len(f); j += 2 {
+					key := strings.TrimPrefix(f[j], envNameToKey(""))
+					fields[name+"["+key+"]"] = strings.TrimPrefix(f[j+1], envValueToValue(""))
+				}
+			case "Labels", "Annotations", "ExtraRefs":
+				for _, line := range f {
+					kv := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(line), "- "), ": ", 2)
+					if len(kv) == 2 {
+						fields[name+"["+kv[0]+"]"] = kv[1]
+					}
+				}
+			case "Volumes", "VolumeMounts":
+				key := ""
+				for _, line := range f {
+					if strings.HasPrefix(line, "- name: ") {
+						key = name + "[" + strings.TrimPrefix(line, "- name: ") + "]"
+						fields[key] = ""
+						continue
+					}
+					fields[key] = strings.TrimSpace(fields[key] + "\n" + line)
+				}
+			default:
+				if len(f) > 0 {
+					fields[name] = strings.Join(f, "\n")
+				}
+			}
+		case *jobProvenance:
+			// Not a field of the job.
+		default:
+			fields[name] = fmt.Sprint(f)
+		}
+	}
+	return fields
+}
+
+// provenanceKeys returns the keys of the traced fields (see baseJobFields) a
+// field of the generated job comes from, or nil if it's set by the template.
+func provenanceKeys(path string) []string {
+	named := func(field, rest string) []string {
+		if i := strings.Index(rest, "]"); strings.HasPrefix(rest, "[") && i > 0 {
+			return []string{field + rest[:i+1]}
+		}
+		return []string{field}
+	}
+	for _, prefix := range []string{"spec.containers[0].", "spec."} {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(path, prefix)
+		switch {
+		case rest == "image":
+			return []string{"Image"}
+		case rest == "args":
+			return []string{"Command", "Args", "preCommand"}
+		case strings.HasPrefix(rest, "env"):
+			return named("Env", strings.TrimPrefix(rest, "env"))
+		case strings.HasPrefix(rest, "volumeMounts"):
+			return named("VolumeMounts", strings.TrimPrefix(rest, "volumeMounts"))
+		case strings.HasPrefix(rest, "volumes"):
+			return named("Volumes", strings.TrimPrefix(rest, "volumes"))
+		case strings.HasPrefix(rest, "resources"):
+			return []string{"Resources"}
+		case strings.HasPrefix(rest, "securityContext"):
+			return []string{"SecurityContext"}
+		}
+		return nil
+	}
+	top := strings.SplitN(path, ".", 2)
+	switch top[0] {
+	case "name", "context", "rerun_command", "trigger":
+		return []string{"jobName"}
+	case "cron":
+		return []string{"cron"}
+	case "labels":
+		return []string{"Labels[" + strings.TrimPrefix(path, "labels.") + "]"}
+	case "annotations":
+		return []string{"Annotations[" + strings.TrimPrefix(path, "annotations.") + "]"}
+	case "always_run":
+		return []string{"AlwaysRun"}
+	case "optional":
+		return []string{"Optional"}
+	case "branches":
+		return []string{"Branches"}
+	case "skip_branches":
+		return []string{"SkipBranches"}
+	case "cluster":
+		return []string{"Cluster"}
+	case "path_alias":
+		return []string{"PathAlias"}
+	case "decoration_config":
+		return []string{"DecorationConfig", "Timeout"}
+	case "reporter_config":
+		return []string{"ReporterConfig", "JobStatesToReport"}
+	case "run_if_changed":
+		return []string{"runIfChanged"}
+	}
+	if strings.HasPrefix(path, "extra_refs") {
+		key := path[strings.LastIndex(path, ".")+1:]
+		if key == "base_ref" {
+			return []string{"ExtraRefs[base_ref]", "RepoBranch"}
+		}
+		return []string{"ExtraRefs[" + key + "]"}
+	}
+	return nil
+}
+
+// explainedField is a field of the generated job and where it comes from.
+type explainedField struct {
+	Path   string
+	Value  string
+	Source string
+}
+
+// jobExplanation is a generated job with the provenance of its fields.
+type jobExplanation struct {
+	Name    string
+	Section string
+	Fields  []explainedField
+}
+
+// flattenJob flattens the generated job, like flattenYaml but also keeping
+// the items of lists without names apart, e.g. the containers.
+func flattenJob(prefix string, v interface{}, fields map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		for k, item := range val {
+			flattenJob(join(fmt.Sprint(k)), item, fields)
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			if _, ok := val[0].(map[interface{}]interface{}); ok {
+				names, named := itemNames(val)
+				for i, item := range val {
+					key := fmt.Sprintf("%s[%d]", prefix, i)
+					if named {
+						key = fmt.Sprintf("%s[%s]", prefix, names[i])
+						delete(item.(map[interface{}]interface{}), "name")
+					}
+					flattenJob(key, item, fields)
+				}
+				return
+			}
+		}
+		flattenYaml(prefix, val, fields)
+	default:
+		flattenYaml(prefix, val, fields)
+	}
+}
+
+// explainRenderedJob records the explanation of the job rendered from the
+// given template data.
+func explainRenderedJob(jobName, templateName, section, rendered string, data interface{}) {
+	var jobs []interface{}
+	if err := yaml.Unmarshal([]byte(rendered), &jobs); err != nil || len(jobs) != 1 {
+		logFatalf("Cannot parse the generated job %q: %v", jobName, err)
+		return
+	}
+	fields := make(map[string]string)
+	flattenJob("", jobs[0], fields)
+
+	origins := make(map[string]fieldOrigin)
+	if base := templateBase(data); base != nil && base.Provenance != nil {
+		for k, o := range base.Provenance.Origins {
+			origins[k] = o
+		}
+		if preCommand != "" {
+			origins["preCommand"] = fieldOrigin{Value: preCommand, Source: flagSource("pre-command")}
+		}
+		if _, ok := origins["cron"]; !ok && section == "periodics" {
+			origins["cron"] = fieldOrigin{Source: "cron scheduler (see --schedule-report)"}
+		}
+	}
+
+	e := jobExplanation{Name: jobName, Section: section}
+	for path, value := range fields {
+		source := templateSource(templateName)
+		var sources []string
+		for _, k := range provenanceKeys(path) {
+			if o, ok := origins[k]; ok && !strExists(sources, o.Source) {
+				sources = append(sources, o.Source)
+			}
+		}
+		if len(sources) > 0 {
+			source = strings.Join(sources, "; ")
+		}
+		if repositoryOverride != "" && strings.HasPrefix(path, "extra_refs") {
+			source = flagSource("repo-override")
+		}
+		e.Fields = append(e.Fields, explainedField{Path: path, Value: value, Source: source})
+	}
+	sort.Slice(e.Fields, func(i, j int) bool { return e.Fields[i].Path < e.Fields[j].Path })
+	jobExplanations = append(jobExplanations, e)
+}
+
+// templateBase returns the base data of the template data of a job.
+func templateBase(data interface{}) *baseProwJobTemplateData {
+	switch d := data.(type) {
+	case presubmitJobTemplateData:
+		return &d.Base
+	case postsubmitJobTemplateData:
+		return &d.Base
+	case periodicJobTemplateData:
+		return &d.Base
+	}
+	return nil
+}
+
+// writeExplanations prints the explanations of the job fields.
+func writeExplanations(w io.Writer) error {
+	if len(jobExplanations) == 0 {
+		return fmt.Errorf("no job named %q was generated", explainJob)
+	}
+	for i, e := range jobExplanations {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n\n", e.Name, e.Section)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "FIELD\tVALUE\tSOURCE")
+		for _, f := range e.Fields {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Path, strings.ReplaceAll(f.Value, "\n", " "), f.Source)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+const explainMetaConfig = `presubmits:
+  org1/repo1:
+  - build-tests: true
+    env-vars:
+    - FOO=bar
+    timeout: 90
+periodics:
+  org1/repo1:
+  - continuous: true
+    cron: "0 1 * * *"
+`
+
+func TestTrace(t *testing.T) {
+	SetupForTesting()
+	explainJob = "job"
+	data := newbaseProwJobTemplateData("org1/repo1")
+	data.addEnvToJob("FOO", "bar")
+	data.Timeout = 90
+	data.trace("step 1")
+	data.Timeout = 100
+	data.trace("step 2")
+
+	origins := data.Provenance.Origins
+	for field, want := range map[string]fieldOrigin{
+		"Env[FOO]":  {Value: "bar", Source: "step 1"},
+		"Timeout":   {Value: "100", Source: "step 2"},
+		"AlwaysRun": {Value: "true", Source: defaultSource("newbaseProwJobTemplateData")},
+		"Image":     {Value: prowTestsDockerImage, Source: flagSource("image-docker", "prow-tests-docker")},
+	} {
+		if diff := cmp.Diff(want, origins[field]); diff != "" {
+			t.Errorf("Unexpected origin of %s (-want +got):\n%s", field, diff)
+		}
+	}
+
+	// Tracing is disabled unless a job is explained.
+	explainJob = ""
+	data = newbaseProwJobTemplateData("org1/repo1")
+	data.trace("step")
+	if data.Provenance != nil {
+		t.Errorf("Expected no provenance, got %v", data.Provenance)
+	}
+}
+
+func TestProvenanceKeys(t *testing.T) {
+	tests := map[string][]string{
+		"spec.containers[0].image":                      {"Image"},
+		"spec.containers[0].args":                       {"Command", "Args", "preCommand"},
+		"spec.containers[0].env[FOO].value":             {"Env[FOO]"},
+		"spec.containers[0].volumeMounts[foo].readOnly": {"VolumeMounts[foo]"},
+		"spec.volumes[foo].secret.secretName":           {"Volumes[foo]"},
+		"labels.prow.k8s.io/pubsub.topic":               {"Labels[prow.k8s.io/pubsub.topic]"},
+		"extra_refs[0].base_ref":                        {"ExtraRefs[base_ref]", "RepoBranch"},
+		"decoration_config.timeout":                     {"DecorationConfig", "Timeout"},
+		"name":                                          {"jobName"},
+		"spec.containers[0].imagePullPolicy":            nil,
+		"decorate":                                      nil,
+	}
+	for path, want := range tests {
+		if diff := cmp.Diff(want, provenanceKeys(path)); diff != "" {
+			t.Errorf("Unexpected keys for %q (-want +got):\n%s", path, diff)
+		}
+	}
+}
+
+func TestExplainJob(t *testing.T) {
+	SetupForTesting()
+	explainJob = "pull-org1-repo1-build-tests"
+	extraEnvVars = []string{"EXTRA=1"}
+	testAccount = "/etc/test-account/service-account.json"
+	defer func() {
+		extraEnvVars = nil
+		testAccount = ""
+	}()
+	metaConfigLines = newLineIndex([]byte(explainMetaConfig))
+	var config yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(explainMetaConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	parseSection(config, "presubmits", generatePresubmit, nil)
+	if logFatalCalls != 0 {
+		t.Fatalf("LogFatal was called")
+	}
+
+	if len(jobExplanations) != 1 {
+		t.Fatalf("Expected one explained job, got %d", len(jobExplanations))
+	}
+	sources := make(map[string]string)
+	for _, f := range jobExplanations[0].Fields {
+		sources[f.Path] = f.Source
+	}
+	for path, want := range map[string]string{
+		"spec.containers[0].env[FOO].value":            `meta config presubmits["org1/repo1"][0].env-vars (line 4)`,
+		"spec.containers[0].env[EXTRA].value":          flagSource("extra-env"),
+		"spec.containers[0].image":                     flagSource("image-docker", "prow-tests-docker"),
+		"spec.containers[0].args":                      flagSource("presubmit-script") + `; meta config presubmits["org1/repo1"][0].build-tests (line 3)`,
+		"spec.containers[0].imagePullPolicy":           templateSource("presubmit"),
+		"spec.volumes[test-account].secret.secretName": helperSource("configureServiceAccountForJob"),
+		"name": helperSource("generatePresubmit"),
+	} {
+		if sources[path] != want {
+			t.Errorf("Unexpected source of %s: got %q, want %q", path, sources[path], want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeExplanations(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out := buf.String(); !strings.HasPrefix(out, "pull-org1-repo1-build-tests (presubmits)") || !strings.Contains(out, "FIELD") {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+
+	jobExplanations = nil
+	if err := writeExplanations(&buf); err == nil {
+		t.Errorf("Expected error when the job is not generated")
+	}
+}
+
+func TestLineIndexKeys(t *testing.T) {
+	idx := newLineIndex([]byte(explainMetaConfig))
+	for key, want := range map[string]int{
+		`presubmits["org1/repo1"][0].build-tests`: 3,
+		`presubmits["org1/repo1"][0].env-vars`:    4,
+		`presubmits["org1/repo1"][0].timeout`:     6,
+		`periodics["org1/repo1"][0].cron`:         10,
+	} {
+		if idx[key] != want {
+			t.Errorf("Unexpected line of %s: got %d, want %d", key, idx[key], want)
+		}
+	}
+}
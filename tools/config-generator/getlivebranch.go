@@ -25,6 +25,9 @@ import (
 	"knative.dev/test-infra/pkg/ghutil"
 )
 
+// releaseBranchRegex matches the release branches, capturing their [MAJOR].[MINOR] version.
+var releaseBranchRegex = regexp.MustCompile(`^release\-(\d+\.\d+)$`)
+
 func latestReleaseBranch(gc ghutil.GithubOperations, repo string) (string, error) {
 	parts := strings.Split(repo, "/")
 	if len(parts) != 2 {
@@ -41,13 +44,9 @@ func latestReleaseBranch(gc ghutil.GithubOperations, repo string) (string, error
 // [MAJOR].[MINOR], if there is no valid release branch exist in the form of
 // `release-[MAJOR]-[MINOR]`, then it returns ""
 func filterLatest(branches []*github.Branch) string {
-	var (
-		reReleaseBranch = regexp.MustCompile(`^release\-(\d+\.\d+)$`)
-		latest          = ""
-	)
-
+	latest := ""
 	for _, branch := range branches {
-		if matches := reReleaseBranch.FindStringSubmatch(*branch.Name); len(matches) > 1 {
+		if matches := releaseBranchRegex.FindStringSubmatch(*branch.Name); len(matches) > 1 {
 			release := matches[1]
 			if latest == "" || versionComp(release, latest) > 0 {
 				latest = release
@@ -0,0 +1,238 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// github_actions.go generates GitHub Actions workflows for the presubmit and
+// periodic test jobs of the repos that opt in with `github-actions: true` in
+// their repo-settings, from the same job data as the Prow jobs.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// githubActionsWorkflow is the template for GitHub Actions workflows.
+	githubActionsWorkflow = "github_actions_workflow.yaml"
+
+	// githubWorkflowsDir is the directory of the workflows in a repo.
+	githubWorkflowsDir = ".github/workflows"
+)
+
+var (
+	// Repos that opted in for GitHub Actions workflows.
+	githubActionsRepos = sets.NewString()
+
+	// Workflows to generate, in the order the jobs were generated.
+	workflowJobs []workflowJob
+)
+
+// workflowJob is a generated Prow job that is also run as a GitHub Actions workflow.
+type workflowJob struct {
+	repoName string
+	jobName  string
+	periodic bool
+	base     baseProwJobTemplateData
+	command  []string
+}
+
+// workflowSecret is a secret written to a file before running the job, like
+// the secret volumes of Prow jobs.
+type workflowSecret struct {
+	Name   string
+	Secret string
+	Dir    string
+	File   string
+}
+
+// workflowTemplateData contains data about a GitHub Actions workflow.
+type workflowTemplateData struct {
+	Name     string
+	On       []string
+	Timeout  int
+	Image    string
+	Env      []string
+	Checkout []string
+	Secrets  []workflowSecret
+	Run      string
+}
+
+// addWorkflowJob records the job for generating its GitHub Actions workflow,
+// if the repo opted in.
+func addWorkflowJob(repoName, jobName string, periodic bool, base baseProwJobTemplateData, command []string) {
+	if !githubActionsRepos.Has(repoName) {
+		return
+	}
+	workflowJobs = append(workflowJobs, workflowJob{
+		repoName: repoName,
+		jobName:  jobName,
+		periodic: periodic,
+		base:     base,
+		command:  command,
+	})
+}
+
+// branchGlob converts the regex of a branch in a Prow job to the glob pattern
+// used by GitHub Actions. Only the regexes used for branch names are supported,
+// i.e. anchors, escaped dots and ".*".
+func branchGlob(regex string) string {
+	s := strings.TrimSuffix(strings.TrimPrefix(regex, "^"), "$")
+	s = strings.ReplaceAll(s, ".*", "*")
+	return strings.ReplaceAll(s, `\.`, ".")
+}
+
+// yamlFlowList formats the strings as a yaml flow sequence, e.g. ["a", "b"].
+func yamlFlowList(l []string) string {
+	return "[" + strings.Join(quoteAll(l), ", ") + "]"
+}
+
+// workflowEnv converts the env of a Prow job, made of name and value lines, to the env of a workflow.
+func workflowEnv(env []string) []string {
+	var res []string
+	for i := 0; i+1 < len(env); i += 2 {
+		name := strings.TrimPrefix(env[i], envNameToKey(""))
+		value := strings.TrimPrefix(env[i+1], envValueToValue(""))
+		res = append(res, name+": "+value)
+	}
+	return res
+}
+
+// newWorkflowTemplateData maps the Prow job data onto a workflow.
+func newWorkflowTemplateData(job workflowJob) workflowTemplateData {
+	data := workflowTemplateData{
+		Name:    job.jobName,
+		Timeout: job.base.Timeout,
+		Image:   job.base.Image,
+		Env:     workflowEnv(job.base.Env),
+		Run:     shellquote.Join(job.command...),
+	}
+	var branches, branchesIgnore []string
+	for _, b := range job.base.Branches {
+		branches = append(branches, branchGlob(b))
+	}
+	for _, b := range job.base.SkipBranches {
+		branchesIgnore = append(branchesIgnore, branchGlob(b))
+	}
+
+	switch {
+	case job.periodic:
+		data.On = append(data.On, "schedule:", fmt.Sprintf("- cron: %q", periodicScheduler.crons[job.jobName]))
+		if job.base.RepoBranch != "" {
+			data.Checkout = append(data.Checkout, "ref: "+job.base.RepoBranch)
+		}
+	case !job.base.AlwaysRun:
+		// Jobs that are not always run in Prow are triggered manually.
+	case len(branches) == 0 && len(branchesIgnore) == 0:
+		data.On = append(data.On, "pull_request: {}")
+	default:
+		data.On = append(data.On, "pull_request:")
+		if len(branches) > 0 {
+			data.On = append(data.On, "  branches: "+yamlFlowList(branches))
+		}
+		if len(branchesIgnore) > 0 {
+			data.On = append(data.On, "  branches-ignore: "+yamlFlowList(branchesIgnore))
+		}
+	}
+	data.On = append(data.On, "workflow_dispatch: {}")
+
+	for _, v := range job.base.SecretVolumes {
+		files, ok := workflowSecretFiles[v.Name]
+		if !ok && job.base.ServiceAccount == path.Join(v.MountPath, "service-account.json") {
+			files, ok = []string{"service-account.json"}, true
+		}
+		if !ok {
+			logFatalf("Job %q mounts the secret %q, whose files are unknown for GitHub Actions; add them to workflowSecretFiles", job.jobName, v.Name)
+			return data
+		}
+		data.Secrets = append(data.Secrets, newWorkflowSecrets(v, files)...)
+	}
+	return data
+}
+
+// workflowSecretFiles are the files of the secrets mounted in Prow jobs, other
+// than the service accounts, by secret name.
+var workflowSecretFiles = map[string][]string{
+	"covbot-token":     {"token"},
+	"hub-token":        {"token"},
+	"repoview-token":   {"token"},
+	"performance-test": {"service-account.json", "github-token", "slack-read-token", "slack-write-token"},
+}
+
+// newWorkflowSecrets returns the workflow secrets of the files of a secret volume.
+// Each file is stored as a GitHub secret named after the secret volume, e.g.
+// TEST_ACCOUNT for /etc/test-account/service-account.json, and after the file
+// if the volume has several files, e.g. PERFORMANCE_TEST_GITHUB_TOKEN.
+func newWorkflowSecrets(v secretVolume, files []string) []workflowSecret {
+	var res []workflowSecret
+	for _, f := range files {
+		secret := v.Name
+		if len(files) > 1 {
+			secret += "-" + strings.TrimSuffix(f, path.Ext(f))
+		}
+		res = append(res, workflowSecret{
+			Name:   v.Name,
+			Secret: strings.ToUpper(strings.ReplaceAll(secret, "-", "_")),
+			Dir:    v.MountPath,
+			File:   path.Join(v.MountPath, f),
+		})
+	}
+	return res
+}
+
+// generateWorkflows writes the workflows of the opted-in repos to
+// <outputDir>/<org>/<repo>/.github/workflows/<job name>.yaml.
+func generateWorkflows(outputDir string) {
+	for _, job := range workflowJobs {
+		if jobNameFilter != "" && jobNameFilter != job.jobName {
+			continue
+		}
+		dir := filepath.Join(outputDir, job.repoName, githubWorkflowsDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			logFatalf("Cannot create the workflows directory %q: %v", dir, err)
+			return
+		}
+		setOutput(filepath.Join(dir, job.jobName+".yaml"))
+		executeTemplate("general header", readTemplate(commonHeaderConfig), newBaseTestgridTemplateData(""))
+		executeTemplate("github actions workflow", readTemplate(githubActionsWorkflow), newWorkflowTemplateData(job))
+	}
+}
+
+// collectGithubActionsRepos finds the repos that opted in for GitHub Actions
+// workflows in their repo-settings.
+func collectGithubActionsRepos(config yaml.MapSlice) {
+	for _, section := range config {
+		if section.Key != "presubmits" {
+			continue
+		}
+		for _, repo := range getMapSlice(section.Value) {
+			for _, jobConfig := range getInterfaceArray(repo.Value) {
+				for _, item := range getMapSlice(jobConfig) {
+					if item.Key == "github-actions" && getBool(item.Value) {
+						githubActionsRepos.Insert(getString(repo.Key))
+					}
+				}
+			}
+		}
+	}
+}
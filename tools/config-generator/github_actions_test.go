@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBranchGlob(t *testing.T) {
+	tests := map[string]string{
+		"main":                  "main",
+		`^release-0\.19$`:       "release-0.19",
+		"^release-.*$":          "release-*",
+		`^knative-v0\.[0-9]+.*`: "knative-v0.[0-9]+*",
+	}
+	for regex, want := range tests {
+		if got := branchGlob(regex); got != want {
+			t.Errorf("branchGlob(%q) = %q, want %q", regex, got, want)
+		}
+	}
+}
+
+func TestWorkflowEnv(t *testing.T) {
+	env := []string{envNameToKey("GO111MODULE"), envValueToValue("on"), envNameToKey("SYSTEM_NAMESPACE"), envValueToValue("knative-testing")}
+	want := []string{"GO111MODULE: on", "SYSTEM_NAMESPACE: knative-testing"}
+	if diff := cmp.Diff(want, workflowEnv(env)); diff != "" {
+		t.Errorf("Unexpected env (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewWorkflowTemplateData(t *testing.T) {
+	SetupForTesting()
+	base := newbaseProwJobTemplateData("knative/serving")
+	base.Timeout = 60
+	base.AlwaysRun = true
+	base.Branches = []string{`^release-0\.19$`}
+	base.SkipBranches = []string{"^release-0.1$"}
+	base.ServiceAccount = "/etc/test-account/service-account.json"
+	configureServiceAccountForJob(&base)
+	addVolumeToJob(&base, "/etc/hub-token", "hub-token", true, nil)
+
+	data := newWorkflowTemplateData(workflowJob{
+		repoName: "knative/serving",
+		jobName:  "pull-knative-serving-build-tests",
+		base:     base,
+		command:  []string{"./test/presubmit-tests.sh", "--build-tests", "--run-test", "a b"},
+	})
+	wantOn := []string{"pull_request:", `  branches: ["release-0.19"]`, `  branches-ignore: ["release-0.1"]`, "workflow_dispatch: {}"}
+	if diff := cmp.Diff(wantOn, data.On); diff != "" {
+		t.Errorf("Unexpected triggers (-want +got):\n%s", diff)
+	}
+	if want := "./test/presubmit-tests.sh --build-tests --run-test 'a b'"; data.Run != want {
+		t.Errorf("Unexpected run command %q, want %q", data.Run, want)
+	}
+	wantSecrets := []workflowSecret{
+		{Name: "test-account", Secret: "TEST_ACCOUNT", Dir: "/etc/test-account", File: "/etc/test-account/service-account.json"},
+		{Name: "hub-token", Secret: "HUB_TOKEN", Dir: "/etc/hub-token", File: "/etc/hub-token/token"},
+	}
+	if diff := cmp.Diff(wantSecrets, data.Secrets); diff != "" {
+		t.Errorf("Unexpected secrets (-want +got):\n%s", diff)
+	}
+
+	// Secrets with several files are stored as one GitHub secret per file.
+	perf := newbaseProwJobTemplateData("knative/serving")
+	addVolumeToJob(&perf, "/etc/performance-test", "performance-test", true, nil)
+	data = newWorkflowTemplateData(workflowJob{jobName: "perf-tests", base: perf})
+	if len(data.Secrets) != 4 || data.Secrets[1].Secret != "PERFORMANCE_TEST_GITHUB_TOKEN" || data.Secrets[1].File != "/etc/performance-test/github-token" {
+		t.Errorf("Unexpected secrets %v", data.Secrets)
+	}
+
+	// Secrets whose files are unknown cannot be mapped.
+	unknown := newbaseProwJobTemplateData("knative/serving")
+	addVolumeToJob(&unknown, "/etc/some-secret", "some-secret", true, nil)
+	newWorkflowTemplateData(workflowJob{jobName: "unknown-secret", base: unknown})
+	if logFatalCalls != 1 {
+		t.Errorf("Expected a fatal error for an unknown secret, got %d", logFatalCalls)
+	}
+	logFatalCalls = 0
+
+	// Jobs not always run in Prow can only be triggered manually.
+	base.AlwaysRun = false
+	data = newWorkflowTemplateData(workflowJob{jobName: "pull-knative-serving-upgrade-tests", base: base})
+	if diff := cmp.Diff([]string{"workflow_dispatch: {}"}, data.On); diff != "" {
+		t.Errorf("Unexpected triggers (-want +got):\n%s", diff)
+	}
+
+	// Periodic jobs use the cron assigned by the scheduler.
+	periodicScheduler.crons["ci-knative-serving-continuous"] = "17 */3 * * *"
+	base.RepoBranch = "release-0.19"
+	data = newWorkflowTemplateData(workflowJob{jobName: "ci-knative-serving-continuous", periodic: true, base: base})
+	wantOn = []string{"schedule:", `- cron: "17 */3 * * *"`, "workflow_dispatch: {}"}
+	if diff := cmp.Diff(wantOn, data.On); diff != "" {
+		t.Errorf("Unexpected triggers (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"ref: release-0.19"}, data.Checkout); diff != "" {
+		t.Errorf("Unexpected checkout (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectGithubActionsRepos(t *testing.T) {
+	SetupForTesting()
+	var config yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(`presubmits:
+  knative/serving:
+  - repo-settings: true
+    github-actions: true
+  - build-tests: true
+  knative/eventing:
+  - build-tests: true
+periodics:
+  knative/client:
+  - continuous: true
+`), &config); err != nil {
+		t.Fatal(err)
+	}
+	collectGithubActionsRepos(config)
+	if diff := cmp.Diff([]string{"knative/serving"}, githubActionsRepos.List()); diff != "" {
+		t.Errorf("Unexpected repos (-want +got):\n%s", diff)
+	}
+
+	base := newbaseProwJobTemplateData("knative/serving")
+	addWorkflowJob("knative/serving", "pull-knative-serving-build-tests", false, base, nil)
+	addWorkflowJob("knative/eventing", "pull-knative-eventing-build-tests", false, base, nil)
+	if len(workflowJobs) != 1 || workflowJobs[0].jobName != "pull-knative-serving-build-tests" {
+		t.Errorf("Expected only the job of the opted-in repo, got %v", workflowJobs)
+	}
+}
+
+func TestGenerateWorkflows(t *testing.T) {
+	SetupForTesting()
+	dir, err := ioutil.TempDir("", "workflows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	githubActionsRepos.Insert("knative/serving")
+	base := newbaseProwJobTemplateData("knative/serving")
+	base.Timeout = 50
+	base.AlwaysRun = true
+	base.Env = []string{envNameToKey("GO111MODULE"), envValueToValue("on")}
+	addWorkflowJob("knative/serving", "pull-knative-serving-unit-tests", false, base, []string{"./test/presubmit-tests.sh", "--unit-tests"})
+	generateWorkflows(dir)
+	setOutput("")
+	if logFatalCalls != 0 {
+		t.Fatalf("LogFatal was called")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "knative/serving/.github/workflows/pull-knative-serving-unit-tests.yaml"))
+	if err != nil {
+		t.Fatalf("Workflow was not generated: %v", err)
+	}
+	var workflow struct {
+		Name string                 `yaml:"name"`
+		On   map[string]interface{} `yaml:"on"`
+		Jobs map[string]struct {
+			TimeoutMinutes int               `yaml:"timeout-minutes"`
+			Env            map[string]string `yaml:"env"`
+			Steps          []struct {
+				Uses string `yaml:"uses"`
+				Run  string `yaml:"run"`
+			} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	if err := yaml.Unmarshal(content, &workflow); err != nil {
+		t.Fatalf("Cannot parse the workflow: %v\n%s", err, content)
+	}
+	if _, ok := workflow.On["pull_request"]; !ok {
+		t.Errorf("Expected a pull_request trigger, got %v", workflow.On)
+	}
+	job, ok := workflow.Jobs["pull-knative-serving-unit-tests"]
+	if !ok {
+		t.Fatalf("Expected the job in the workflow, got %v", workflow.Jobs)
+	}
+	if job.TimeoutMinutes != 50 || job.Env["GO111MODULE"] != "on" {
+		t.Errorf("Unexpected job settings %+v", job)
+	}
+	if len(job.Steps) != 2 || job.Steps[1].Run != "./test/presubmit-tests.sh --unit-tests\n" {
+		t.Errorf("Unexpected steps %+v", job.Steps)
+	}
+}
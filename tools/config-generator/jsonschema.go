@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// jsonschema.go generates the JSON Schema of the meta config from its typed
+// schema, so that editors can validate and autocomplete the meta config.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const (
+	// metaConfigSchemaFile is the published JSON Schema of the meta config,
+	// relative to the config-generator directory.
+	metaConfigSchemaFile = "schema/config_knative.schema.json"
+
+	jsonSchemaVersion = "http://json-schema.org/draft-07/schema#"
+)
+
+var singleStringType = reflect.TypeOf(singleString(""))
+
+// jsonSchema is the subset of JSON Schema used for describing the meta config.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	MinItems             int                    `json:"minItems,omitempty"`
+	MaxItems             int                    `json:"maxItems,omitempty"`
+}
+
+// schemaForType returns the JSON Schema of the given type.
+func schemaForType(t reflect.Type) *jsonSchema {
+	if t == singleStringType {
+		return &jsonSchema{OneOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonSchema{Type: "string"}, MinItems: 1, MaxItems: 1},
+		}}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		// Pointers are used for the fields that can be set to null.
+		if t.Elem().Kind() != reflect.Struct {
+			return &jsonSchema{OneOf: []*jsonSchema{schemaForType(t.Elem()), {Type: "null"}}}
+		}
+		return schemaForType(t.Elem())
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int:
+		return &jsonSchema{Type: "integer"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: schemaForType(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: schemaForType(t.Elem())}
+	case reflect.Struct:
+		s := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema), AdditionalProperties: false}
+		addStructProperties(s, t)
+		return s
+	}
+	logFatalf("Unsupported type %v in meta config schema", t)
+	return nil
+}
+
+// addStructProperties adds the yaml fields of the struct as properties of the schema.
+func addStructProperties(s *jsonSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		if tag == "" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if strings.Contains(tag, ",inline") {
+			addStructProperties(s, f.Type)
+			continue
+		}
+		p := schemaForType(f.Type)
+		p.Description = f.Tag.Get("doc")
+		s.Properties[name] = p
+	}
+}
+
+// metaConfigJSONSchema returns the JSON Schema of the meta config.
+func metaConfigJSONSchema() ([]byte, error) {
+	s := schemaForType(reflect.TypeOf(MetaConfig{}))
+	s.Schema = jsonSchemaVersion
+	s.Title = "config-generator meta config"
+	s.Description = "Meta config of Prow jobs and TestGrid, used by tools/config-generator"
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
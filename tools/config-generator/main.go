@@ -28,6 +28,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
@@ -111,6 +112,7 @@ type baseProwJobTemplateData struct {
 	Env                 []string
 	Volumes             []string
 	VolumeMounts        []string
+	SecretVolumes       []secretVolume
 	Resources           []string
 	ReporterConfig      []string
 	JobStatesToReport   []string
@@ -127,6 +129,9 @@ type baseProwJobTemplateData struct {
 	Cluster             string
 	NeedsMonitor        bool
 	Annotations         []string
+
+	// Provenance of the fields, only recorded when explaining a job.
+	Provenance *jobProvenance
 }
 
 // ####################################################################################################
@@ -237,7 +242,6 @@ func newbaseProwJobTemplateData(repo string) baseProwJobTemplateData {
 	data.RepoNameForJob = strings.ToLower(strings.Replace(repo, "/", "-", -1))
 
 	data.RepoBranch = "main" // Default to be main for other repos
-	data.GcsBucket = GCSBucket
 	data.RepoURI = "github.com/" + repo
 	data.CloneURI = fmt.Sprintf("\"https://%s.git\"", data.RepoURI)
 	data.GcsLogDir = fmt.Sprintf("gs://%s/%s", GCSBucket, LogsDir)
@@ -245,8 +249,6 @@ func newbaseProwJobTemplateData(repo string) baseProwJobTemplateData {
 	data.ReleaseGcs = strings.Replace(repo, data.OrgName+"/", "knative-releases/", 1)
 	data.AlwaysRun = true
 	data.Optional = false
-	data.Image = prowTestsDockerImage
-	data.ServiceAccount = testAccount
 	data.Command = ""
 	data.Args = make([]string, 0)
 	data.Volumes = make([]string, 0)
@@ -255,6 +257,14 @@ func newbaseProwJobTemplateData(repo string) baseProwJobTemplateData {
 	data.Labels = make([]string, 0)
 	data.Annotations = make([]string, 0)
 	data.Cluster = "cluster: \"build-knative\""
+	data.Provenance = newJobProvenance()
+	data.trace(defaultSource("newbaseProwJobTemplateData"))
+	data.GcsBucket = GCSBucket
+	data.traceFields(flagSource("gcs-bucket"), "GcsBucket")
+	data.Image = prowTestsDockerImage
+	data.traceFields(flagSource("image-docker", "prow-tests-docker"), "Image")
+	data.ServiceAccount = testAccount
+	data.traceFields(flagSource("test-account"), "ServiceAccount")
 	return data
 }
 
@@ -300,6 +310,12 @@ func addMonitoringPubsubLabelsToJob(data *baseProwJobTemplateData, runID string)
 	addLabelToJob(data, "prow.k8s.io/pubsub.runID", runID)
 }
 
+// secretVolume is a secret mounted in a job.
+type secretVolume struct {
+	Name      string
+	MountPath string
+}
+
 // addVolumeToJob adds the given mount path as volume for the job.
 func addVolumeToJob(data *baseProwJobTemplateData, mountPath, name string, isSecret bool, content []string) {
 	(*data).VolumeMounts = append((*data).VolumeMounts, []string{"- name: " + name, "  mountPath: " + mountPath}...)
@@ -310,6 +326,7 @@ func addVolumeToJob(data *baseProwJobTemplateData, mountPath, name string, isSec
 	if isSecret {
 		arr := []string{"  secret:", "    secretName: " + name}
 		s = append(s, arr...)
+		(*data).SecretVolumes = append((*data).SecretVolumes, secretVolume{Name: name, MountPath: mountPath})
 	}
 	for _, line := range content {
 		s = append(s, "  "+line)
@@ -384,6 +401,7 @@ func setReporterConfigReqForJob(res yaml.MapSlice, data *baseProwJobTemplateData
 // parseBasicJobConfigOverrides updates the given baseProwJobTemplateData with any base option present in the given config.
 func parseBasicJobConfigOverrides(data *baseProwJobTemplateData, config yaml.MapSlice) {
 	(*data).ExtraRefs = append((*data).ExtraRefs, "  base_ref: "+(*data).RepoBranch)
+	data.trace(helperSource("parseBasicJobConfigOverrides"))
 	for i, item := range config {
 		switch item.Key {
 		case "skip_branches":
@@ -423,6 +441,7 @@ func parseBasicJobConfigOverrides(data *baseProwJobTemplateData, config yaml.Map
 		default:
 			logFatalf("Unknown entry %q for job", item.Key)
 		}
+		data.trace(metaConfigSource(getString(item.Key)))
 		// Knock-out the item, signalling it was already parsed.
 		config[i] = yaml.MapItem{}
 	}
@@ -430,6 +449,17 @@ func parseBasicJobConfigOverrides(data *baseProwJobTemplateData, config yaml.Map
 	// Override any values if provided by command-line flags.
 	if timeoutOverride > 0 {
 		(*data).Timeout = timeoutOverride
+		data.trace(flagSource("timeout-override"))
+	}
+}
+
+// applyRepoSettings applies the settings of the repos section of the meta
+// config to the repositories with presubmit jobs.
+func applyRepoSettings() {
+	for i, repo := range repositories {
+		if settings, ok := metaConfig.Repos[repo.Name]; ok && settings.Performance {
+			repositories[i].EnablePerformanceTests = true
+		}
 	}
 }
 
@@ -476,9 +506,11 @@ func parseSection(config yaml.MapSlice, title string, generate sectionGenerator,
 		}
 		for _, repo := range getMapSlice(section.Value) {
 			repoName := getString(repo.Key)
-			for _, jobConfig := range getInterfaceArray(repo.Value) {
+			for i, jobConfig := range getInterfaceArray(repo.Value) {
+				currentJobEntry = jobPath(title, repoName, i)
 				generate(title, repoName, getMapSlice(jobConfig))
 			}
+			currentJobEntry = ""
 			if finalize != nil {
 				finalize(title, repoName, nil)
 			}
@@ -502,6 +534,9 @@ func gitHubRepo(data baseProwJobTemplateData) string {
 
 // executeTemplate outputs the given job template with the given data, respecting any filtering.
 func executeJobTemplate(name, templ, title, repoName, jobName string, groupByRepo bool, data interface{}) {
+	if explainJob != "" && jobName == explainJob {
+		explainRenderedJob(jobName, name, title, renderTemplate(name, templ, data), data)
+	}
 	if jobNameFilter != "" && jobNameFilter != jobName {
 		return
 	}
@@ -520,6 +555,13 @@ func executeJobTemplate(name, templ, title, repoName, jobName string, groupByRep
 
 // executeTemplate outputs the given template with the given data.
 func executeTemplate(name, templ string, data interface{}) {
+	for _, line := range strings.Split(renderTemplate(name, templ, data), "\n") {
+		output.outputConfig(line)
+	}
+}
+
+// renderTemplate returns the given template executed with the given data.
+func renderTemplate(name, templ string, data interface{}) string {
 	var res bytes.Buffer
 	funcMap := template.FuncMap{
 		"indent_section":       indentSection,
@@ -533,9 +575,7 @@ func executeTemplate(name, templ string, data interface{}) {
 	if err := t.Execute(&res, data); err != nil {
 		logFatalf("Error in template %s: %v", name, err)
 	}
-	for _, line := range strings.Split(res.String(), "\n") {
-		output.outputConfig(line)
-	}
+	return res.String()
 }
 
 // Multi-value flag parser.
@@ -704,6 +744,12 @@ func main() {
 	prowJobsConfigOutput := ""
 	testgridConfigOutput := ""
 	k8sTestgridConfigOutput := ""
+	diffAgainst := ""
+	diffCommentPR := ""
+	scheduleReport := ""
+	githubActionsOutput := ""
+	var lifecycleOpts releaseLifecycleOptions
+	var scheduleBudgets stringArrayFlag
 	var generateTestgridConfig = flag.Bool("generate-testgrid-config", true, "Whether to generate the testgrid config from the template file")
 	var generateK8sTestgridConfig = flag.Bool("generate-k8s-testgrid-config", true, "Whether to generate the k8s testgrid config from the template file")
 	var includeConfig = flag.Bool("include-config", true, "Whether to include general configuration (e.g., plank) in the generated config")
@@ -731,19 +777,86 @@ func main() {
 	flag.StringVar(&preCommand, "pre-command", "", "Executable for running instead of the real command of a job")
 	flag.BoolVar(&upgradeReleaseBranches, "upgrade-release-branches", false, "Update release branches jobs based on active branches")
 	flag.StringVar(&githubTokenPath, "github-token-path", "", "Token path for authenticating with github, used only when --upgrade-release-branches is on")
+	flag.IntVar(&lifecycleOpts.supportedReleases, "supported-releases", maxReleaseBranches, "Number of supported releases of each repo, used only by release-lifecycle")
+	flag.BoolVar(&lifecycleOpts.dryRun, "dry-run", false, "Print the plan of release-lifecycle instead of updating the configs and opening a PR")
+	flag.StringVar(&lifecycleOpts.pr.UserID, "git-userid", "", "GitHub ID of the fork to push the changes of release-lifecycle to, no PR is opened if not set")
+	flag.StringVar(&lifecycleOpts.authorName, "git-username", "", "The username to use on the git commit of release-lifecycle. Requires --git-email")
+	flag.StringVar(&lifecycleOpts.authorEmail, "git-email", "", "The email to use on the git commit of release-lifecycle. Requires --git-username")
+	flag.StringVar(&lifecycleOpts.pr.Org, "pr-org", "knative", "GitHub org of the repo the PR of release-lifecycle targets")
+	flag.StringVar(&lifecycleOpts.pr.Repo, "pr-repo", "test-infra", "GitHub repo the PR of release-lifecycle targets")
+	flag.StringVar(&lifecycleOpts.pr.Base, "pr-base", "main", "Base branch the PR of release-lifecycle targets")
+	flag.StringVar(&lifecycleOpts.pr.Head, "pr-head", "release-lifecycle", "Branch of the fork the changes of release-lifecycle are pushed to")
 	flag.Var(&extraEnvVars, "extra-env", "Extra environment variables (key=value) to add to a job")
+	flag.IntVar(&periodicScheduler.window, "schedule-window", defaultScheduleWindow, "Granularity (in minutes) of the cron schedules of periodic jobs, the concurrency budgets apply per window")
+	flag.StringVar(&periodicScheduler.timezone, "schedule-timezone", defaultScheduleTimezone, "Time zone of the preferred start times of daily and weekly periodic jobs")
+	flag.Var(&scheduleBudgets, "schedule-budget", "Maximum number (class=limit) of concurrently running periodic jobs of a resource class, overriding its default budget")
+	flag.StringVar(&scheduleReport, "schedule-report", "", "File to write the expected concurrency of periodic jobs to")
+	flag.StringVar(&githubActionsOutput, "github-actions-output", "", "Directory to write the GitHub Actions workflows of the opted-in repos to, as <org>/<repo>/.github/workflows/<job>.yaml; workflows are not generated if not set")
+	flag.StringVar(&explainJob, "explain", "", "Print the fields of the generated job with this name and where each of them comes from, instead of writing the configs")
+	flag.StringVar(&diffAgainst, "diff-against", "", "Directory of the checked-in Prow config (e.g. config/prod/prow); if set, print the semantic diff of the generated configs against it instead of writing them")
+	flag.StringVar(&diffCommentPR, "diff-comment-pr", "", "Pull request (org/repo#number) to post the semantic diff to, used only when --diff-against is set; authenticates with --github-token-path")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <config file>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s validate <config file>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s json-schema\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] release-lifecycle <config file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
-	if len(flag.Args()) != 1 {
+	switch flag.Arg(0) {
+	case "validate":
+		if len(flag.Args()) != 2 {
+			log.Fatal("Pass the config file to validate as parameter")
+		}
+		validateConfigFile(flag.Arg(1))
+		return
+	case "json-schema":
+		content, err := metaConfigJSONSchema()
+		if err != nil {
+			logFatalf("Failed generating JSON Schema: %v", err)
+		}
+		os.Stdout.Write(content)
+		return
+	}
+	args := flag.Args()
+	releaseLifecycle := flag.Arg(0) == "release-lifecycle"
+	if releaseLifecycle {
+		args = args[1:]
+	}
+	if len(args) != 1 {
 		log.Fatal("Pass the config file as parameter")
 	}
 
 	prowTestsDockerImage = path.Join(*dockerImagesBase, *prowTestsDockerImageName)
+	budgets, err := parseScheduleBudgets(scheduleBudgets)
+	if err != nil {
+		logFatalf("Invalid --schedule-budget: %v", err)
+	}
+	for class, limit := range budgets {
+		periodicScheduler.budgets[class] = limit
+	}
 
 	// We use MapSlice instead of maps to keep key order and create predictable output.
 	configYaml := yaml.MapSlice{}
 
 	// Read input config.
-	configFileName := flag.Arg(0)
+	configFileName := args[0]
+	var (
+		lifecycleGithub ghutil.GithubOperations
+		lifecyclePlan   releasePlan
+	)
+	if releaseLifecycle {
+		if lifecycleGithub, err = ghutil.NewGithubClient(githubTokenPath); err != nil {
+			logFatalf("Failed creating github client from %q: %v", githubTokenPath, err)
+		}
+		if lifecyclePlan, err = updateReleaseLifecycle(configFileName, lifecycleGithub, lifecycleOpts); err != nil {
+			logFatalf("Failed updating the jobs of supported releases: %v", err)
+		}
+		if lifecycleOpts.dryRun {
+			fmt.Print(lifecyclePlan.markdown())
+			return
+		}
+	}
 	if upgradeReleaseBranches {
 		gc, err := ghutil.NewGithubClient(githubTokenPath)
 		if err != nil {
@@ -758,10 +871,43 @@ func main() {
 	if err != nil {
 		logFatalf("Cannot read file %q: %v", configFileName, err)
 	}
+	if metaConfig, err = loadMetaConfig(configFileName, configFileContent); err != nil {
+		logFatalf("Invalid config:\n%v", err)
+	}
 	if err = yaml.Unmarshal(configFileContent, &configYaml); err != nil {
 		logFatalf("Cannot parse config %q: %v", configFileName, err)
 	}
 
+	if explainJob != "" {
+		metaConfigLines = newLineIndex(configFileContent)
+	}
+
+	var generated generatedConfigs
+	if diffAgainst != "" || explainJob != "" {
+		tempDir, err := ioutil.TempDir("", "config-generator")
+		if err != nil {
+			logFatalf("Cannot create temporary directory: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+		// logFatalf exits without running the deferred calls, so remove the
+		// temporary directory before exiting.
+		fatalf := logFatalf
+		logFatalf = func(format string, v ...interface{}) {
+			os.RemoveAll(tempDir)
+			fatalf(format, v...)
+		}
+		generated.prowJobs = filepath.Join(tempDir, "prow-jobs.yaml")
+		prowJobsConfigOutput = generated.prowJobs
+		if *generateTestgridConfig {
+			generated.testgrid = filepath.Join(tempDir, "testgrid.yaml")
+			testgridConfigOutput = generated.testgrid
+		}
+		if *generateK8sTestgridConfig {
+			generated.k8sTestgrid = filepath.Join(tempDir, "k8s-testgrid.yaml")
+			k8sTestgridConfigOutput = generated.k8sTestgrid
+		}
+	}
+
 	prowConfigData := getProwConfigData(configYaml)
 
 	// Generate Prow config.
@@ -769,7 +915,9 @@ func main() {
 	sectionMap = make(map[string]bool)
 	setOutput(prowJobsConfigOutput)
 	executeTemplate("general header", readTemplate(commonHeaderConfig), prowConfigData)
+	collectGithubActionsRepos(configYaml)
 	parseSection(configYaml, "presubmits", generatePresubmit, nil)
+	applyRepoSettings()
 	parseSection(configYaml, "periodics", generatePeriodic, generateGoCoveragePeriodic)
 	for _, repo := range repositories { // Keep order for predictable output.
 		if !repo.Processed && repo.EnableGoCoverage {
@@ -777,6 +925,7 @@ func main() {
 		}
 	}
 	generatePerfClusterUpdatePeriodicJobs()
+	flushPeriodicJobs(scheduleReport)
 
 	for _, repo := range repositories {
 		if repo.EnableGoCoverage {
@@ -849,6 +998,46 @@ func main() {
 		metaData.generateDashboardGroups()
 		metaData.generateNonAlignedDashboardGroups()
 	}
+
+	// Generate GitHub Actions workflows.
+	if githubActionsOutput != "" {
+		generateWorkflows(githubActionsOutput)
+	}
+
+	if diffAgainst != "" {
+		if err := reportConfigDiff(diffAgainst, generated, diffCommentPR); err != nil {
+			logFatalf("Cannot report the config diff: %v", err)
+		}
+	}
+
+	if explainJob != "" {
+		if err := writeExplanations(os.Stdout); err != nil {
+			logFatalf("Cannot explain job: %v", err)
+		}
+	}
+
+	if releaseLifecycle {
+		repo, err := openReleaseLifecycleRepo(githubTokenPath, lifecycleOpts)
+		if err != nil {
+			logFatalf("Failed opening the git repository: %v", err)
+		}
+		if err := submitReleaseLifecycle(lifecycleGithub, repo, lifecyclePlan, lifecycleOpts); err != nil {
+			logFatalf("Failed opening the PR for the supported releases: %v", err)
+		}
+	}
+}
+
+// validateConfigFile validates the given meta config file, and exits with the
+// errors found if it's invalid.
+func validateConfigFile(configFileName string) {
+	content, err := ioutil.ReadFile(configFileName)
+	if err != nil {
+		logFatalf("Cannot read file %q: %v", configFileName, err)
+	}
+	if _, err := loadMetaConfig(configFileName, content); err != nil {
+		logFatalf("Invalid config:\n%v", err)
+	}
+	log.Printf("Config %q is valid", configFileName)
 }
 
 // parseOrgAndRepoFromMapItem splits the "org/repo" string of a yaml.MapItem
@@ -74,8 +74,11 @@ func perfClusterPeriodicJob(jobNamePostFix, cronString, command string, args []s
 	data.PeriodicCommand = createCommand(data.Base)
 	data.Base.Annotations = []string{"  testgrid-create-test-group: \"false\""}
 	addMonitoringPubsubLabelsToJob(&data.Base, data.PeriodicJobName)
-	executeJobTemplate("performance tests periodic", readTemplate(periodicTestJob),
-		"periodics", repo.Name, data.PeriodicJobName, false, data)
+	data.Base.trace(helperSource("perfClusterPeriodicJob"))
+	data.Base.traceField("jobName", data.PeriodicJobName, helperSource("perfClusterPeriodicJob"))
+	data.Base.traceField("cron", data.CronString, helperSource("generatePerfClusterUpdatePeriodicJobs"))
+	executePeriodicJobTemplate("performance tests periodic", readTemplate(periodicTestJob),
+		"periodics", repo.Name, data, fixedCronRequest(data.PeriodicJobName, performanceResourceClass, data.CronString, data.Base.Timeout))
 }
 
 func perfClusterReconcilePostsubmitJob(jobNamePostFix, command string, args []string, repo repositoryData, sa string) {
@@ -85,6 +88,8 @@ func perfClusterReconcilePostsubmitJob(jobNamePostFix, command string, args []st
 	data.PostsubmitJobName = fmt.Sprintf("post-%s-%s", data.Base.RepoNameForJob, jobNamePostFix)
 	data.PostsubmitCommand = createCommand(data.Base)
 	addMonitoringPubsubLabelsToJob(&data.Base, data.PostsubmitJobName)
+	data.Base.trace(helperSource("perfClusterReconcilePostsubmitJob"))
+	data.Base.traceField("jobName", data.PostsubmitJobName, helperSource("perfClusterReconcilePostsubmitJob"))
 	executeJobTemplate("performance tests postsubmit", readTemplate(perfPostsubmitJob),
 		"postsubmits", repo.Name, data.PostsubmitJobName, true, data)
 }
@@ -98,5 +103,6 @@ func perfClusterBaseProwJob(command string, args []string, fullRepoName, sa stri
 	base.addEnvToJob("GITHUB_TOKEN", "/etc/performance-test/github-token")
 	base.addEnvToJob("SLACK_READ_TOKEN", "/etc/performance-test/slack-read-token")
 	base.addEnvToJob("SLACK_WRITE_TOKEN", "/etc/performance-test/slack-write-token")
+	base.trace(helperSource("perfClusterBaseProwJob"))
 	return base
 }
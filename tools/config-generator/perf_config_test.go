@@ -31,6 +31,7 @@ func TestGeneratePerfClusterUpdatePeriodicJobs(t *testing.T) {
 		},
 	}
 	generatePerfClusterUpdatePeriodicJobs()
+	flushPeriodicJobs("")
 	if logFatalCalls != 0 || len(GetOutput()) == 0 {
 		t.Errorf("Expected job to be written without errors")
 	}
@@ -43,6 +44,7 @@ func TestGeneratePerfClusterUpdatePeriodicJobs(t *testing.T) {
 		},
 	}
 	generatePerfClusterUpdatePeriodicJobs()
+	flushPeriodicJobs("")
 	if len(GetOutput()) != 0 {
 		t.Errorf("Expected nothing to be written")
 	}
@@ -59,7 +61,8 @@ func TestGeneratePerfClusterPostsubmitJob(t *testing.T) {
 func TestPerfClusterPeriodicJob(t *testing.T) {
 	SetupForTesting()
 	repoData := repositoryData{Name: "my-repo"}
-	perfClusterPeriodicJob("postfix", "cronString", "command", []string{"arg1", "arg2"}, repoData, "sa")
+	perfClusterPeriodicJob("postfix", "0 1 * * *", "command", []string{"arg1", "arg2"}, repoData, "sa")
+	flushPeriodicJobs("")
 
 	if logFatalCalls != 0 || len(GetOutput()) == 0 {
 		t.Errorf("Expected job to be written without errors")
@@ -22,8 +22,6 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
-	"hash/fnv"
-	"log"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -65,62 +63,6 @@ func (p periodicJobTemplateData) Clone() periodicJobTemplateData {
 	return r
 }
 
-func getUTCtime(i int) int {
-	r := i + 7
-	if r > 23 {
-		return r - 24
-	}
-	return r
-}
-
-func calculateMinuteOffset(str ...string) int {
-	h := fnv.New32a()
-	for _, s := range str {
-		h.Write([]byte(s))
-	}
-	return int(h.Sum32()) % 60
-}
-
-// Generate cron string based on job type, offset generated from jobname
-// instead of assign random value to ensure consistency among runs,
-// timeout is used for determining how many hours apart
-func generateCron(jobType, jobName, repoName string, timeout int) string {
-	minutesOffset := calculateMinuteOffset(jobType, jobName)
-	// Determines hourly job inteval based on timeout
-	hours := int((timeout+5)/60) + 1 // Allow at least 5 minutes between runs
-	hourCron := fmt.Sprintf("%d * * * *", minutesOffset)
-	if hours > 1 {
-		hourCron = fmt.Sprintf("%d */%d * * *", minutesOffset, hours)
-	}
-	daily := func(pacificHour int) string {
-		return fmt.Sprintf("%d %d * * *", minutesOffset, getUTCtime(pacificHour))
-	}
-	weekly := func(pacificHour, dayOfWeek int) string {
-		return fmt.Sprintf("%d %d * * %d", minutesOffset, getUTCtime(pacificHour), dayOfWeek)
-	}
-
-	var res string
-	switch jobType {
-	case "continuous", "custom-job", "auto-release": // As much as every hour
-		res = hourCron
-	case "branch-ci":
-		res = daily(1) // 1 AM
-	case "nightly":
-		res = daily(2) // 2 AM
-	case "dot-release":
-		if strings.HasSuffix(repoName, "-operator") {
-			// Every Tuesday noon
-			res = weekly(12, 2)
-		} else {
-			// Every Tuesday 2 AM
-			res = weekly(2, 2)
-		}
-	default:
-		log.Printf("job type not supported for cron generation '%s'", jobName)
-	}
-	return res
-}
-
 // generatePeriodic generates periodic job configs for the given repo and configuration.
 // Normally it generates one job per call
 // But if it is continuous or branch-ci job, it generates a second job for beta testing of new prow-tests images
@@ -131,8 +73,12 @@ func generatePeriodic(title string, repoName string, periodicConfig yaml.MapSlic
 	jobTemplate := readTemplate(periodicTestJob)
 	jobType := ""
 	isContinuousJob := false
+	resourceClass := ""
 	org := data.Base.OrgName
 	repo := data.Base.RepoName
+	// TestGrid tab of the job, for its annotations
+	var dashboardName, tabName string
+	var testgroupExtras map[string]string
 	// Parse the input yaml and set values data based on them
 	for i, item := range periodicConfig {
 		jobName := getString(item.Key)
@@ -198,6 +144,12 @@ func generatePeriodic(title string, repoName string, periodicConfig yaml.MapSlic
 			data.Base.Timeout = 120
 		case "cron":
 			data.CronString = getString(item.Value)
+			data.Base.traceField("cron", data.CronString, metaConfigSource("cron"))
+		case "resource-class":
+			resourceClass = getString(item.Value)
+			// The resource class is only used for scheduling, it doesn't change the TestGrid annotations.
+			periodicConfig[i] = yaml.MapItem{}
+			continue
 		case "release":
 			version := getString(item.Value)
 			jobNameSuffix = version + "-" + jobNameSuffix
@@ -208,29 +160,39 @@ func generatePeriodic(title string, repoName string, periodicConfig yaml.MapSlic
 		default:
 			continue
 		}
+		data.Base.trace(metaConfigSource(jobName))
 		// Knock-out the item, signalling it was already parsed.
 		periodicConfig[i] = yaml.MapItem{}
 
-		dashboardName := repo
-		tabName := data.Base.RepoName
+		dashboardName = repo
+		tabName = data.Base.RepoName
 		if jobNameSuffix != "" {
 			tabName += "-" + jobNameSuffix
 		}
-		testgroupExtras := getTestgroupExtras(org, jobName)
+		testgroupExtras = getTestgroupExtras(org, jobName)
 		data.Base.Annotations = generateProwJobAnnotations(dashboardName, tabName, testgroupExtras)
+		data.Base.trace(helperSource("generateProwJobAnnotations"))
 	}
 	parseBasicJobConfigOverrides(&data.Base, periodicConfig)
 	data.PeriodicJobName = fmt.Sprintf("ci-%s", data.Base.RepoNameForJob)
 	if jobNameSuffix != "" {
 		data.PeriodicJobName += "-" + jobNameSuffix
 	}
-	if data.CronString == "" {
-		data.CronString = generateCron(jobType, data.PeriodicJobName, data.Base.RepoName, data.Base.Timeout)
+	if override, ok := metaConfig.TestgridExtras[data.PeriodicJobName]; ok && testgroupExtras != nil {
+		override.apply(testgroupExtras)
+		data.Base.Annotations = generateProwJobAnnotations(dashboardName, tabName, testgroupExtras)
+		data.Base.trace(metaConfigSource("testgrid-extras"))
 	}
-	// Ensure required data exist.
-	if data.CronString == "" {
+	req, ok := newCronRequest(jobType, data.PeriodicJobName, data.Base.RepoName, data.Base.Timeout)
+	if data.CronString != "" {
+		req = fixedCronRequest(data.PeriodicJobName, req.class, data.CronString, data.Base.Timeout)
+	} else if !ok {
+		// Ensure required data exist.
 		logFatalf("Job %q is missing cron string", data.PeriodicJobName)
 	}
+	if resourceClass != "" {
+		req.class = resourceClass
+	}
 	if len(data.Base.Args) == 0 && data.Base.Command == "" {
 		logFatalf("Job %q is missing command", data.PeriodicJobName)
 	}
@@ -239,6 +201,7 @@ func generatePeriodic(title string, repoName string, periodicConfig yaml.MapSlic
 	}
 
 	// Generate config itself.
+	data.Base.traceField("jobName", data.PeriodicJobName, helperSource("generatePeriodic"))
 	data.PeriodicCommand = createCommand(data.Base)
 	if data.Base.ServiceAccount != "" {
 		data.Base.addEnvToJob("GOOGLE_APPLICATION_CREDENTIALS", data.Base.ServiceAccount)
@@ -249,12 +212,20 @@ func generatePeriodic(title string, repoName string, periodicConfig yaml.MapSlic
 		// The reason for having it is in https://github.com/knative/test-infra/issues/780.
 		data.Base.addEnvToJob("PULL_BASE_REF", data.Base.RepoBranch)
 	}
+	data.Base.trace(helperSource("generatePeriodic"))
 	addExtraEnvVarsToJob(extraEnvVars, &data.Base)
+	data.Base.trace(flagSource("extra-env"))
 	configureServiceAccountForJob(&data.Base)
+	data.Base.trace(helperSource("configureServiceAccountForJob"))
 	data.Base.DecorationConfig = []string{fmt.Sprintf("timeout: %dm", data.Base.Timeout)}
+	data.Base.trace(helperSource("generatePeriodic"))
 
-	// This is where the data actually gets written out
-	executeJobTemplate("periodic", jobTemplate, title, repoName, data.PeriodicJobName, false, data)
+	// This is where the data actually gets written out, once the cron schedule is assigned
+	executePeriodicJobTemplate("periodic", jobTemplate, title, repoName, data, req)
+	if jobType == "continuous" || jobType == "branch-ci" || jobType == "custom-job" {
+		// Release jobs are only run in Prow.
+		addWorkflowJob(repoName, data.PeriodicJobName, true, data.Base, data.PeriodicCommand)
+	}
 
 	// If job is a continuous run, add a duplicate for pre-release testing of new prow-tests image
 	// It will (mostly) run less often than source job
@@ -270,24 +241,21 @@ func generatePeriodic(title string, repoName string, periodicConfig yaml.MapSlic
 		tabAnnotation := betaData.Base.Annotations[1] + "-beta-prow-tests"
 		betaData.Base.Annotations[0] = dashboardAnnotation
 		betaData.Base.Annotations[1] = tabAnnotation
+		betaData.Base.trace(helperSource("generatePeriodic"))
+		betaData.Base.traceField("jobName", betaData.PeriodicJobName, helperSource("generatePeriodic"))
+		betaData.Base.traceField("cron", "", "cron scheduler, every 8 or 12 hours for beta jobs")
 
 		// Run 2 or 3 times a day because prow-tests beta testing has different desired interval than the underlying job
-		hours := []int{getUTCtime(1), getUTCtime(4)}
+		betaData.CronString = ""
+		betaReq := cronRequest{jobName: betaData.PeriodicJobName, class: req.class, duration: data.Base.Timeout, everyHours: 12, weekday: -1}
 		if jobType == "continuous" { // as opposed to branch-ci
 			// These jobs run 8-24 times per day, so it matters more if they break
 			// So test them slightly more often
-			hours = append(hours, getUTCtime(15))
-		}
-		var hoursStr []string
-		for _, h := range hours {
-			hoursStr = append(hoursStr, fmt.Sprint(h))
+			betaReq.everyHours = 8
 		}
-		betaData.CronString = fmt.Sprintf("%d %s * * *",
-			calculateMinuteOffset(jobType, betaData.PeriodicJobName),
-			strings.Join(hoursStr, ","))
 
 		// Write out our duplicate job
-		executeJobTemplate("periodic", jobTemplate, title, repoName, betaData.PeriodicJobName, false, betaData)
+		executePeriodicJobTemplate("periodic", jobTemplate, title, repoName, betaData, betaReq)
 
 		// Setup TestGrid here
 		// Each job becomes one of "test_groups"
@@ -330,15 +298,21 @@ func generateGoCoveragePeriodic(title string, repoName string, _ yaml.MapSlice)
 			fmt.Sprintf("--cov-threshold-percentage=%d", data.Base.GoCoverageThreshold)}
 		data.Base.ServiceAccount = ""
 		data.Base.ExtraRefs = append(data.Base.ExtraRefs, "  base_ref: "+data.Base.RepoBranch)
+		data.Base.trace(helperSource("generateGoCoveragePeriodic"))
+		data.Base.traceField("jobName", data.PeriodicJobName, helperSource("generateGoCoveragePeriodic"))
+		data.Base.traceField("cron", data.CronString, helperSource("generateGoCoveragePeriodic"))
 
 		addExtraEnvVarsToJob(extraEnvVars, &data.Base)
+		data.Base.trace(flagSource("extra-env"))
 		addMonitoringPubsubLabelsToJob(&data.Base, data.PeriodicJobName)
 		configureServiceAccountForJob(&data.Base)
 		dashboardName := data.Base.RepoName
 		tabName := data.Base.RepoName + "-" + jobNameSuffix
 		testgroupExtras := map[string]string{"short-text-metric": "coverage"}
 		data.Base.Annotations = generateProwJobAnnotations(dashboardName, tabName, testgroupExtras)
-		executeJobTemplate("periodic go coverage", readTemplate(periodicCustomJob), title, repoName, data.PeriodicJobName, false, data)
+		data.Base.trace(helperSource("generateGoCoveragePeriodic"))
+		executePeriodicJobTemplate("periodic go coverage", readTemplate(periodicCustomJob), title, repoName, data,
+			fixedCronRequest(data.PeriodicJobName, buildResourceClass, data.CronString, data.Base.Timeout))
 
 		betaData := data.Clone()
 
@@ -350,14 +324,17 @@ func generateGoCoveragePeriodic(title string, repoName string, _ yaml.MapSlice)
 		dashboardName = "prow-tests"
 		tabName += "-beta-prow-tests"
 		betaData.Base.Annotations = generateProwJobAnnotations(dashboardName, tabName, testgroupExtras)
+		betaData.Base.trace(helperSource("generateGoCoveragePeriodic"))
+		betaData.Base.traceField("jobName", betaData.PeriodicJobName, helperSource("generateGoCoveragePeriodic"))
+		betaData.Base.traceField("cron", "", "cron scheduler, daily for beta jobs")
 
 		// Run once a day because prow-tests beta testing has different desired interval than the underlying job
-		betaData.CronString = fmt.Sprintf("%d %s * * *",
-			calculateMinuteOffset("go-coverage", betaData.PeriodicJobName),
-			fmt.Sprint(getUTCtime(0)))
+		betaData.CronString = ""
+		betaReq := cronRequest{jobName: betaData.PeriodicJobName, class: buildResourceClass, duration: data.Base.Timeout,
+			localHour: 0, flexHours: 3, weekday: -1} // Midnight
 
 		// Write out our duplicate job
-		executeJobTemplate("periodic go coverage", readTemplate(periodicCustomJob), title, repoName, betaData.PeriodicJobName, false, betaData)
+		executePeriodicJobTemplate("periodic go coverage", readTemplate(periodicCustomJob), title, repoName, betaData, betaReq)
 
 		// Setup TestGrid here
 		// Each job becomes one of "test_groups"
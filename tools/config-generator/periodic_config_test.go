@@ -17,7 +17,6 @@ limitations under the License.
 package main
 
 import (
-	"fmt"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -38,86 +37,87 @@ func TestClone(t *testing.T) {
 	}
 }
 
-func TestGetUTCtime(t *testing.T) {
-	SetupForTesting()
-	for i := 0; i < 24; i++ {
-		utcTime := getUTCtime(i)
-		expected := (i + 7) % 24
-		if utcTime != expected {
-			t.Fatalf("Expected %d, got %d", expected, utcTime)
-		}
-	}
-}
-
-func TestCalculateMinuteOffset(t *testing.T) {
-	SetupForTesting()
-	out1 := calculateMinuteOffset("foo")
-	out2 := calculateMinuteOffset("foo")
-	if diff := cmp.Diff(out1, out2); diff != "" {
-		t.Fatalf("Same input should always yield same offset")
-	}
-}
-
-func TestGenerateCron(t *testing.T) {
+func TestNewCronRequest(t *testing.T) {
 	SetupForTesting()
 	jobName := "job-name"
 	tests := []struct {
 		jobType  string
 		repoName string
 		timeout  int
-		expected string
+		expected cronRequest
+		ok       bool
 	}{
 		{
-			jobType:  "not-supported",
-			expected: "",
+			jobType: "not-supported",
+			ok:      false,
 		},
 		{
 			jobType:  "continuous",
 			timeout:  54,
-			expected: fmt.Sprintf("%d * * * *", calculateMinuteOffset("continuous", jobName)),
+			expected: cronRequest{class: "e2e", duration: 54, everyHours: 1, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "continuous",
 			timeout:  55,
-			expected: fmt.Sprintf("%d */2 * * *", calculateMinuteOffset("continuous", jobName)),
+			expected: cronRequest{class: "e2e", duration: 55, everyHours: 2, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "continuous",
 			timeout:  60 + 55,
-			expected: fmt.Sprintf("%d */3 * * *", calculateMinuteOffset("continuous", jobName)),
+			expected: cronRequest{class: "e2e", duration: 115, everyHours: 3, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "custom-job",
 			timeout:  54,
-			expected: fmt.Sprintf("%d * * * *", calculateMinuteOffset("custom-job", jobName)),
+			expected: cronRequest{class: "e2e", duration: 54, everyHours: 1, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "auto-release",
 			timeout:  54,
-			expected: fmt.Sprintf("%d * * * *", calculateMinuteOffset("auto-release", jobName)),
+			expected: cronRequest{class: "release", duration: 54, everyHours: 1, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "branch-ci",
-			expected: fmt.Sprintf("%d 8 * * *", calculateMinuteOffset("branch-ci", jobName)),
+			timeout:  180,
+			expected: cronRequest{class: "e2e", duration: 180, localHour: 0, flexHours: 7, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "nightly",
-			expected: fmt.Sprintf("%d 9 * * *", calculateMinuteOffset("nightly", jobName)),
+			timeout:  180,
+			expected: cronRequest{class: "release", duration: 180, localHour: 0, flexHours: 6, weekday: -1},
+			ok:       true,
 		},
 		{
 			jobType:  "dot-release",
 			repoName: "foo",
-			expected: fmt.Sprintf("%d 9 * * 2", calculateMinuteOffset("dot-release", jobName)),
+			timeout:  180,
+			expected: cronRequest{class: "release", duration: 180, localHour: 0, flexHours: 7, weekday: 2},
+			ok:       true,
 		},
 		{
 			jobType:  "dot-release",
 			repoName: "foo-operator",
-			expected: fmt.Sprintf("%d 19 * * 2", calculateMinuteOffset("dot-release", jobName)),
+			timeout:  180,
+			expected: cronRequest{class: "release", duration: 180, localHour: 12, flexHours: 7, weekday: 2},
+			ok:       true,
 		},
 	}
 	for _, tc := range tests {
-		out := generateCron(tc.jobType, jobName, tc.repoName, tc.timeout)
-		if diff := cmp.Diff(out, tc.expected); diff != "" {
+		out, ok := newCronRequest(tc.jobType, jobName, tc.repoName, tc.timeout)
+		if ok != tc.ok {
+			t.Fatalf("For jobType %v: expected supported %v, got %v", tc.jobType, tc.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		tc.expected.jobName = jobName
+		if diff := cmp.Diff(out, tc.expected, cmp.AllowUnexported(cronRequest{})); diff != "" {
 			t.Fatalf("For jobType %v and timeout %d: (-got +want)\n%s", tc.jobType, tc.timeout, diff)
 		}
 	}
@@ -138,6 +138,7 @@ func TestGeneratePeriodic(t *testing.T) {
 	for _, item := range items {
 		periodicConfig = yaml.MapSlice{item}
 		generatePeriodic(title, repoName, periodicConfig)
+		flushPeriodicJobs("")
 		outputLen := len(GetOutput())
 		if outputLen == 0 {
 			t.Fatalf("Failure for key %d: No output", outputLen)
@@ -159,6 +160,7 @@ func TestGenerateGoCoveragePeriodic(t *testing.T) {
 		},
 	}
 	generateGoCoveragePeriodic("title", "repo-name", nil)
+	flushPeriodicJobs("")
 	if len(GetOutput()) == 0 {
 		t.Fatalf("No output")
 	}
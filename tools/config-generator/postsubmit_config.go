@@ -45,8 +45,12 @@ func generateGoCoveragePostsubmit(title, repoName string, _ yaml.MapSlice) {
 	data.Base = newbaseProwJobTemplateData(repoName)
 	data.Base.Branches = []string{data.Base.RepoBranch}
 	data.PostsubmitJobName = fmt.Sprintf("post-%s-go-coverage", data.Base.RepoNameForJob)
+	data.Base.trace(helperSource("generateGoCoveragePostsubmit"))
+	data.Base.traceField("jobName", data.PostsubmitJobName, helperSource("generateGoCoveragePostsubmit"))
 	addExtraEnvVarsToJob(extraEnvVars, &data.Base)
+	data.Base.trace(flagSource("extra-env"))
 	configureServiceAccountForJob(&data.Base)
+	data.Base.trace(helperSource("configureServiceAccountForJob"))
 	jobName := data.PostsubmitJobName
 	executeJobTemplate("postsubmit go coverage", readTemplate(goCoveragePostsubmitJob), title, repoName, jobName, true, data)
 	// Generate config for post-knative-serving-go-coverage-dev right after post-knative-serving-go-coverage,
@@ -54,6 +58,8 @@ func generateGoCoveragePostsubmit(title, repoName string, _ yaml.MapSlice) {
 	if data.PostsubmitJobName == "post-knative-serving-go-coverage" {
 		data.PostsubmitJobName += "-dev"
 		data.Base.Image = strings.ReplaceAll(data.Base.Image, ":stable", ":coverage-dev")
+		data.Base.trace(helperSource("generateGoCoveragePostsubmit"))
+		data.Base.traceField("jobName", data.PostsubmitJobName, helperSource("generateGoCoveragePostsubmit"))
 		executeJobTemplate("postsubmit go coverage", readTemplate(goCoveragePostsubmitJob), title, repoName, data.PostsubmitJobName, false, data)
 	}
 }
@@ -49,7 +49,9 @@ func generatePresubmit(title string, repoName string, presubmitConfig yaml.MapSl
 	var data presubmitJobTemplateData
 	data.Base = newbaseProwJobTemplateData(repoName)
 	data.Base.Command = presubmitScript
+	data.Base.traceFields(flagSource("presubmit-script"), "Command")
 	data.Base.GoCoverageThreshold = 50
+	data.Base.trace(defaultSource("generatePresubmit"))
 	jobTemplate := readTemplate(presubmitJob)
 	repoData := repositoryData{Name: repoName, EnableGoCoverage: false, GoCoverageThreshold: data.Base.GoCoverageThreshold}
 	generateJob := true
@@ -82,11 +84,15 @@ func generatePresubmit(title string, repoName string, presubmitConfig yaml.MapSl
 			repoData.GoCoverageThreshold = data.Base.GoCoverageThreshold
 		case "repo-settings":
 			generateJob = false
+		case "github-actions":
+			// Already handled by collectGithubActionsRepos.
 		case "run-if-changed":
 			data.RunIfChanged = "run_if_changed: \"" + getString(item.Value) + "\""
+			data.Base.traceField("runIfChanged", getString(item.Value), metaConfigSource("run-if-changed"))
 		default:
 			continue
 		}
+		data.Base.trace(metaConfigSource(getString(item.Key)))
 		// Knock-out the item, signalling it was already parsed.
 		presubmitConfig[i] = yaml.MapItem{}
 	}
@@ -98,19 +104,27 @@ func generatePresubmit(title string, repoName string, presubmitConfig yaml.MapSl
 	data.PresubmitCommand = createCommand(data.Base)
 	data.PresubmitPullJobName = "pull-" + data.PresubmitJobName
 	data.PresubmitPostJobName = "post-" + data.PresubmitJobName
+	data.Base.traceField("jobName", data.PresubmitPullJobName, helperSource("generatePresubmit"))
 	if data.Base.ServiceAccount != "" {
 		data.Base.addEnvToJob("GOOGLE_APPLICATION_CREDENTIALS", data.Base.ServiceAccount)
 		data.Base.addEnvToJob("E2E_CLUSTER_REGION", "us-central1")
+		data.Base.trace(helperSource("generatePresubmit"))
 	}
 	if data.Base.NeedsMonitor {
 		addMonitoringPubsubLabelsToJob(&data.Base, data.PresubmitPullJobName)
+		data.Base.trace(helperSource("addMonitoringPubsubLabelsToJob"))
 	}
 	addExtraEnvVarsToJob(extraEnvVars, &data.Base)
+	data.Base.trace(flagSource("extra-env"))
 	configureServiceAccountForJob(&data.Base)
+	data.Base.trace(helperSource("configureServiceAccountForJob"))
 	jobName := data.PresubmitPullJobName
 
 	// This is where the data actually gets written out
 	executeJobTemplate("presubmit", jobTemplate, title, repoName, jobName, true, data)
+	if !repoData.EnableGoCoverage {
+		addWorkflowJob(repoName, jobName, false, data.Base, data.PresubmitCommand)
+	}
 
 	// Generate config for pull-knative-serving-go-coverage-dev right after pull-knative-serving-go-coverage,
 	// this job is mainly for debugging purpose.
@@ -118,6 +132,8 @@ func generatePresubmit(title string, repoName string, presubmitConfig yaml.MapSl
 		data.PresubmitPullJobName += "-dev"
 		data.Base.AlwaysRun = false
 		data.Base.Image = strings.ReplaceAll(data.Base.Image, ":stable", ":coverage-dev")
+		data.Base.trace(helperSource("generatePresubmit"))
+		data.Base.traceField("jobName", data.PresubmitPullJobName, helperSource("generatePresubmit"))
 		template := strings.Replace(readTemplate(presubmitGoCoverageJob), "(all|", "(", 1)
 		executeJobTemplate("presubmit", template, title, repoName, data.PresubmitPullJobName, true, data)
 	}
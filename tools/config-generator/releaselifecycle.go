@@ -0,0 +1,360 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// releaselifecycle.go keeps the release branch jobs of the meta config in sync
+// with the supported releases of each repo, and opens a PR with the result.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/google/go-github/v32/github"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"knative.dev/test-infra/pkg/autopr"
+	"knative.dev/test-infra/pkg/ghutil"
+)
+
+// releaseLifecyclePRTitle is the title of the PR, also used for finding the PR
+// opened by a previous run.
+const releaseLifecyclePRTitle = "[Auto] Update jobs and dashboards of supported releases"
+
+// releaseJobTypes are the periodic job types that run against a release branch.
+var releaseJobTypes = []string{"branch-ci", "dot-release"}
+
+// releaseLifecycleOptions configures the release-lifecycle subcommand.
+type releaseLifecycleOptions struct {
+	supportedReleases int
+	dryRun            bool
+	// pr is where the changes are pushed to, and the PR proposing them.
+	pr autopr.Options
+	// authorName and authorEmail are the author of the commit.
+	authorName  string
+	authorEmail string
+}
+
+// releaseJobChange is a release branch job added to or retired from the meta config.
+type releaseJobChange struct {
+	Repo    string
+	JobType string
+	Release string
+}
+
+func (c releaseJobChange) String() string {
+	return fmt.Sprintf("%s %s %s", c.Repo, c.JobType, c.Release)
+}
+
+// releasePlan is the set of changes to bring the meta config in line with the
+// supported releases.
+type releasePlan struct {
+	// Supported releases of each repo, newest first.
+	Windows map[string][]string
+	Added   []releaseJobChange
+	Retired []releaseJobChange
+	// Release dashboards (e.g. knative-0.19) that appear or disappear in TestGrid.
+	AddedDashboards   []string
+	RemovedDashboards []string
+}
+
+// empty returns true if the meta config is already up to date.
+func (p releasePlan) empty() bool {
+	return len(p.Added) == 0 && len(p.Retired) == 0
+}
+
+// markdown formats the plan, for printing and as the PR body.
+func (p releasePlan) markdown() string {
+	var b strings.Builder
+	if p.empty() {
+		b.WriteString("The release branch jobs are up to date.\n")
+	}
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "#### %s\n\n", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "- `%s`\n", item)
+		}
+		b.WriteString("\n")
+	}
+	section("Jobs added", changeStrings(p.Added))
+	section("Jobs retired", changeStrings(p.Retired))
+	section("Dashboards added", p.AddedDashboards)
+	section("Dashboards removed", p.RemovedDashboards)
+
+	repos := make([]string, 0, len(p.Windows))
+	for repo := range p.Windows {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	var windows []string
+	for _, repo := range repos {
+		windows = append(windows, fmt.Sprintf("%s: %s", repo, strings.Join(p.Windows[repo], ", ")))
+	}
+	section("Supported releases", windows)
+	return b.String()
+}
+
+func changeStrings(changes []releaseJobChange) []string {
+	var res []string
+	for _, c := range changes {
+		res = append(res, c.String())
+	}
+	return res
+}
+
+// releaseVersions returns the [MAJOR].[MINOR] versions of the release branches, newest first.
+func releaseVersions(branches []*github.Branch) []string {
+	var versions []string
+	for _, branch := range branches {
+		if matches := releaseBranchRegex.FindStringSubmatch(branch.GetName()); len(matches) > 1 {
+			versions = append(versions, matches[1])
+		}
+	}
+	sortFunc(versions)
+	return versions
+}
+
+// supportedReleaseWindow returns the newest supportedReleases releases of the repo, newest first.
+func supportedReleaseWindow(gc ghutil.GithubOperations, repo string, supportedReleases int) ([]string, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("repo name %q should be in the form of [ORG]/[REPO]", repo)
+	}
+	branches, err := gc.ListBranches(parts[0], parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed listing branches for repo %q: %w", repo, err)
+	}
+	versions := releaseVersions(branches)
+	if len(versions) > supportedReleases {
+		versions = versions[:supportedReleases]
+	}
+	return versions, nil
+}
+
+// releaseJob returns the release branch job type and release of the job config,
+// or empty strings if it's not a release branch job.
+func releaseJob(jobConfig yaml.MapSlice) (jobType string, release string) {
+	for _, item := range jobConfig {
+		if k := getString(item.Key); strExists(releaseJobTypes, k) && getBool(item.Value) {
+			jobType = k
+		} else if k == "release" {
+			release = getString(item.Value)
+		}
+	}
+	if jobType == "" || release == "" {
+		return "", ""
+	}
+	return jobType, release
+}
+
+// withRelease returns a copy of the job config testing the given release.
+func withRelease(jobConfig yaml.MapSlice, release string) yaml.MapSlice {
+	res := make(yaml.MapSlice, len(jobConfig))
+	for i, item := range jobConfig {
+		if item.Key == "release" {
+			item.Value = release
+		}
+		res[i] = item
+	}
+	return res
+}
+
+// planRepoReleaseJobs updates the release branch jobs of a repo for the given
+// window of supported releases. For each release job type the repo already
+// has, the jobs of releases older than the window are retired, and jobs are
+// added for the releases newer than the newest job, copied from the newest job.
+// Releases of the window older than the newest job are not backfilled, they
+// were skipped on purpose.
+func planRepoReleaseJobs(repo string, jobs []interface{}, window []string, plan *releasePlan) []interface{} {
+	if len(window) == 0 {
+		return jobs
+	}
+	oldest := window[len(window)-1]
+	newest := make(map[string]int)
+	for i, job := range jobs {
+		jobType, release := releaseJob(getMapSlice(job))
+		if jobType == "" {
+			continue
+		}
+		if j, ok := newest[jobType]; !ok || versionComp(release, releaseOf(jobs[j])) > 0 {
+			newest[jobType] = i
+		}
+	}
+
+	var res []interface{}
+	for i, job := range jobs {
+		jobConfig := getMapSlice(job)
+		jobType, release := releaseJob(jobConfig)
+		if jobType != "" && versionComp(release, oldest) < 0 {
+			plan.Retired = append(plan.Retired, releaseJobChange{Repo: repo, JobType: jobType, Release: release})
+			continue
+		}
+		res = append(res, job)
+		if jobType == "" || newest[jobType] != i {
+			continue
+		}
+		// Add the new releases right after the newest job of the same type, oldest first.
+		for k := len(window) - 1; k >= 0; k-- {
+			if versionComp(window[k], release) > 0 {
+				res = append(res, withRelease(jobConfig, window[k]))
+				plan.Added = append(plan.Added, releaseJobChange{Repo: repo, JobType: jobType, Release: window[k]})
+			}
+		}
+	}
+	return res
+}
+
+func releaseOf(job interface{}) string {
+	_, release := releaseJob(getMapSlice(job))
+	return release
+}
+
+// releaseDashboards returns the TestGrid dashboards generated for the release
+// branch jobs of the periodics, which are named after the org and the release.
+func releaseDashboards(periodics yaml.MapSlice) sets.String {
+	dashboards := sets.NewString()
+	for _, repo := range periodics {
+		org := strings.Split(getString(repo.Key), "/")[0]
+		for _, job := range getInterfaceArray(repo.Value) {
+			if _, release := releaseJob(getMapSlice(job)); release != "" {
+				dashboards.Insert(org + "-" + release)
+			}
+		}
+	}
+	return dashboards
+}
+
+// planReleaseLifecycle computes the supported releases of each repo with
+// release branch jobs in the periodics of the meta config, and updates the jobs.
+func planReleaseLifecycle(config yaml.MapSlice, gc ghutil.GithubOperations, supportedReleases int) (releasePlan, error) {
+	plan := releasePlan{Windows: make(map[string][]string)}
+	for i, section := range config {
+		if section.Key != "periodics" {
+			continue
+		}
+		periodics := getMapSlice(section.Value)
+		before := releaseDashboards(periodics)
+		for j, repo := range periodics {
+			repoName := getString(repo.Key)
+			jobs := getInterfaceArray(repo.Value)
+			hasReleaseJobs := false
+			for _, job := range jobs {
+				if jobType, _ := releaseJob(getMapSlice(job)); jobType != "" {
+					hasReleaseJobs = true
+				}
+			}
+			if !hasReleaseJobs {
+				continue
+			}
+			window, err := supportedReleaseWindow(gc, repoName, supportedReleases)
+			if err != nil {
+				return plan, err
+			}
+			if len(window) == 0 {
+				log.Printf("No release branches found for repo %q, keeping its jobs", repoName)
+				continue
+			}
+			plan.Windows[repoName] = window
+			periodics[j].Value = planRepoReleaseJobs(repoName, jobs, window, &plan)
+		}
+		after := releaseDashboards(periodics)
+		plan.AddedDashboards = after.Difference(before).List()
+		plan.RemovedDashboards = before.Difference(after).List()
+		config[i].Value = periodics
+	}
+	return plan, nil
+}
+
+// updateReleaseLifecycle updates the release branch jobs of the meta config
+// file, and returns the plan. The file is left untouched in dry run mode.
+func updateReleaseLifecycle(configFileName string, gc ghutil.GithubOperations, opts releaseLifecycleOptions) (releasePlan, error) {
+	var plan releasePlan
+	info, err := os.Lstat(configFileName)
+	if err != nil {
+		return plan, fmt.Errorf("failed stats file %q: %w", configFileName, err)
+	}
+	content, err := ioutil.ReadFile(configFileName)
+	if err != nil {
+		return plan, fmt.Errorf("cannot read file %q: %w", configFileName, err)
+	}
+	config := yaml.MapSlice{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return plan, fmt.Errorf("cannot parse config %q: %w", configFileName, err)
+	}
+	if plan, err = planReleaseLifecycle(config, gc, opts.supportedReleases); err != nil {
+		return plan, err
+	}
+	if opts.dryRun || plan.empty() {
+		return plan, nil
+	}
+	updated, err := yaml.Marshal(&config)
+	if err != nil {
+		return plan, fmt.Errorf("failed marshal modified content: %w", err)
+	}
+	updated = append(headerComments(content), updated...)
+	return plan, ioutil.WriteFile(configFileName, updated, info.Mode())
+}
+
+// openReleaseLifecycleRepo opens the git repository of the current directory,
+// authenticated with the token of the fork owner for pushing the changes.
+func openReleaseLifecycleRepo(tokenPath string, opts releaseLifecycleOptions) (*autopr.GitRepo, error) {
+	token, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the github token %q: %w", tokenPath, err)
+	}
+	repo, err := autopr.OpenRepo(".")
+	if err != nil {
+		return nil, err
+	}
+	repo.AuthorName = strings.Trim(opts.authorName, "'")
+	repo.AuthorEmail = opts.authorEmail
+	repo.Auth = &githttp.BasicAuth{Username: opts.pr.UserID, Password: strings.TrimSpace(string(token))}
+	return repo, nil
+}
+
+// submitReleaseLifecycle commits the updated meta config and generated
+// configs in the git repo, pushes them to the fork of the user and opens or
+// updates the PR.
+func submitReleaseLifecycle(gc ghutil.GithubOperations, repo autopr.Repo, plan releasePlan, opts releaseLifecycleOptions) error {
+	if plan.empty() {
+		log.Print("Release branch jobs are up to date, skip PR")
+		return nil
+	}
+	if opts.pr.UserID == "" {
+		log.Print("No --git-userid given, skip PR")
+		return nil
+	}
+	a := &autopr.AutoPR{
+		Options: opts.pr,
+		Git:     repo,
+		GitHub:  gc,
+	}
+	a.Title = releaseLifecyclePRTitle
+	a.Description = "Jobs and dashboards updated for the supported releases of each repo.\n\n" + plan.markdown()
+	a.DryRun = opts.dryRun
+	// The meta config and the configs are already updated.
+	_, err := a.Run(nil)
+	return err
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
+
+	"knative.dev/test-infra/pkg/autopr"
+	"knative.dev/test-infra/pkg/autopr/fakeautopr"
+	"knative.dev/test-infra/pkg/ghutil/fakeghutil"
+)
+
+// fakeBranchesClient returns the branches of each repo, keyed by org/repo.
+type fakeBranchesClient struct {
+	*fakeghutil.FakeGithubClient
+}
+
+func (c fakeBranchesClient) ListBranches(org, repo string) ([]*github.Branch, error) {
+	return c.Branches[org+"/"+repo], nil
+}
+
+func newFakeBranchesClient(branches map[string][]string) fakeBranchesClient {
+	fgc := fakeghutil.NewFakeGithubClient()
+	fgc.Branches = make(map[string][]*github.Branch)
+	for repo, names := range branches {
+		for i := range names {
+			fgc.Branches[repo] = append(fgc.Branches[repo], &github.Branch{Name: &names[i]})
+		}
+	}
+	return fakeBranchesClient{fgc}
+}
+
+const releaseLifecycleConfig = `# header comment
+presubmits:
+  org1/repo1:
+  - build-tests: true
+periodics:
+  org1/repo1:
+  - continuous: true
+  - branch-ci: true
+    release: "0.18"
+  - branch-ci: true
+    release: "0.19"
+  - dot-release: true
+    release: "0.18"
+    timeout: 90
+  - dot-release: true
+    release: "0.19"
+    timeout: 90
+  - auto-release: true
+  org1/repo2:
+  - continuous: true
+  - branch-ci: true
+    release: "0.19"
+  org2/repo3:
+  - branch-ci: true
+    release: "0.1"
+`
+
+func TestUpdateReleaseLifecycle(t *testing.T) {
+	SetupForTesting()
+	gc := newFakeBranchesClient(map[string][]string{
+		"org1/repo1": {"main", "release-0.18", "release-0.19", "release-0.20", "release-0.21"},
+		"org1/repo2": {"main", "release-0.19", "release-0.20", "release-0.21"},
+		// No release branches, the jobs are kept.
+		"org2/repo3": {"main"},
+	})
+	f, err := ioutil.TempFile("", "release-lifecycle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := ioutil.WriteFile(f.Name(), []byte(releaseLifecycleConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run leaves the file untouched.
+	plan, err := updateReleaseLifecycle(f.Name(), gc, releaseLifecycleOptions{supportedReleases: 3, dryRun: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(f.Name()); string(content) != releaseLifecycleConfig {
+		t.Errorf("File was changed in dry run mode:\n%s", content)
+	}
+
+	want := releasePlan{
+		Windows: map[string][]string{
+			"org1/repo1": {"0.21", "0.20", "0.19"},
+			"org1/repo2": {"0.21", "0.20", "0.19"},
+		},
+		Added: []releaseJobChange{
+			{Repo: "org1/repo1", JobType: "branch-ci", Release: "0.20"},
+			{Repo: "org1/repo1", JobType: "branch-ci", Release: "0.21"},
+			{Repo: "org1/repo1", JobType: "dot-release", Release: "0.20"},
+			{Repo: "org1/repo1", JobType: "dot-release", Release: "0.21"},
+			{Repo: "org1/repo2", JobType: "branch-ci", Release: "0.20"},
+			{Repo: "org1/repo2", JobType: "branch-ci", Release: "0.21"},
+		},
+		Retired: []releaseJobChange{
+			{Repo: "org1/repo1", JobType: "branch-ci", Release: "0.18"},
+			{Repo: "org1/repo1", JobType: "dot-release", Release: "0.18"},
+		},
+		AddedDashboards:   []string{"org1-0.20", "org1-0.21"},
+		RemovedDashboards: []string{"org1-0.18"},
+	}
+	if diff := cmp.Diff(want, plan); diff != "" {
+		t.Errorf("Unexpected plan (-want +got):\n%s", diff)
+	}
+
+	if _, err := updateReleaseLifecycle(f.Name(), gc, releaseLifecycleOptions{supportedReleases: 3}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantConfig := `# header comment
+presubmits:
+  org1/repo1:
+  - build-tests: true
+periodics:
+  org1/repo1:
+  - continuous: true
+  - branch-ci: true
+    release: "0.19"
+  - branch-ci: true
+    release: "0.20"
+  - branch-ci: true
+    release: "0.21"
+  - dot-release: true
+    release: "0.19"
+    timeout: 90
+  - dot-release: true
+    release: "0.20"
+    timeout: 90
+  - dot-release: true
+    release: "0.21"
+    timeout: 90
+  - auto-release: true
+  org1/repo2:
+  - continuous: true
+  - branch-ci: true
+    release: "0.19"
+  - branch-ci: true
+    release: "0.20"
+  - branch-ci: true
+    release: "0.21"
+  org2/repo3:
+  - branch-ci: true
+    release: "0.1"
+`
+	if diff := cmp.Diff(wantConfig, string(content)); diff != "" {
+		t.Errorf("Unexpected config (-want +got):\n%s", diff)
+	}
+
+	// Running again is a no-op.
+	plan, err = updateReleaseLifecycle(f.Name(), gc, releaseLifecycleOptions{supportedReleases: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !plan.empty() {
+		t.Errorf("Expected an empty plan, got %+v", plan)
+	}
+}
+
+func TestReleasePlanMarkdown(t *testing.T) {
+	plan := releasePlan{
+		Windows: map[string][]string{"org1/repo1": {"0.21", "0.20"}},
+		Added:   []releaseJobChange{{Repo: "org1/repo1", JobType: "branch-ci", Release: "0.21"}},
+		Retired: []releaseJobChange{{Repo: "org1/repo1", JobType: "branch-ci", Release: "0.19"}},
+	}
+	md := plan.markdown()
+	for _, s := range []string{
+		"#### Jobs added\n\n- `org1/repo1 branch-ci 0.21`",
+		"#### Jobs retired\n\n- `org1/repo1 branch-ci 0.19`",
+		"#### Supported releases\n\n- `org1/repo1: 0.21, 0.20`",
+	} {
+		if !strings.Contains(md, s) {
+			t.Errorf("Expected %q in markdown:\n%s", s, md)
+		}
+	}
+	if strings.Contains(md, "Dashboards") {
+		t.Errorf("Unexpected empty section in markdown:\n%s", md)
+	}
+	if md := (releasePlan{}).markdown(); !strings.Contains(md, "up to date") {
+		t.Errorf("Unexpected markdown for empty plan:\n%s", md)
+	}
+}
+
+func TestSubmitReleaseLifecycle(t *testing.T) {
+	fgc := fakeghutil.NewFakeGithubClient()
+	fgc.PullRequests["test-infra"] = make(map[int]*github.PullRequest)
+	opts := releaseLifecycleOptions{pr: autopr.Options{Org: "knative", Repo: "test-infra", Head: "release-lifecycle", Base: "main", UserID: "bot"}}
+
+	// Nothing is committed for an empty plan.
+	repo := fakeautopr.NewFakeRepo("config/prod/prow/config_knative.yaml")
+	if err := submitReleaseLifecycle(fgc, repo, releasePlan{}, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(repo.Commits) != 0 {
+		t.Errorf("Expected no commit for an empty plan, got %v", repo.Commits)
+	}
+
+	plan := releasePlan{Added: []releaseJobChange{{Repo: "org1/repo1", JobType: "branch-ci", Release: "0.21"}}}
+	if err := submitReleaseLifecycle(fgc, repo, plan, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(repo.Pushes) != 1 || repo.Pushes[0].Branch != "release-lifecycle" || repo.Pushes[0].RemoteURL != "https://github.com/bot/test-infra.git" {
+		t.Errorf("Unexpected pushes %v", repo.Pushes)
+	}
+	if len(fgc.PullRequests["test-infra"]) != 1 {
+		t.Fatalf("Expected a new PR, got %d", len(fgc.PullRequests["test-infra"]))
+	}
+
+	repo.Change("config/prod/prow/config_knative.yaml")
+	plan.Added = append(plan.Added, releaseJobChange{Repo: "org1/repo2", JobType: "branch-ci", Release: "0.21"})
+	if err := submitReleaseLifecycle(fgc, repo, plan, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fgc.PullRequests["test-infra"]) != 1 {
+		t.Fatalf("Expected the PR to be updated, got %d PRs", len(fgc.PullRequests["test-infra"]))
+	}
+	for _, pr := range fgc.PullRequests["test-infra"] {
+		if pr.GetTitle() != releaseLifecyclePRTitle || !strings.Contains(pr.GetBody(), "org1/repo2 branch-ci 0.21") {
+			t.Errorf("PR was not updated: %q %q", pr.GetTitle(), pr.GetBody())
+		}
+	}
+}
@@ -0,0 +1,592 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// scheduler.go assigns the cron schedules of the generated periodic jobs.
+// Instead of deriving the schedule of each job on its own, all periodic jobs
+// are collected first, then each of them is given the cron slot derived from a
+// stable hash of its name, so that adding or removing a job doesn't move the
+// other jobs. A job only moves to another slot if its own slot would exceed the
+// budget of its resource class.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// Embed the time zone database, so the schedule doesn't depend on the host.
+	_ "time/tzdata"
+)
+
+const (
+	minutesPerWeek = 7 * 24 * 60
+
+	// Defaults of the scheduler, can be changed through command-line flags.
+	defaultScheduleWindow   = 5
+	defaultScheduleTimezone = "America/Los_Angeles"
+
+	// Resource classes of the generated periodic jobs.
+	e2eResourceClass         = "e2e"
+	releaseResourceClass     = "release"
+	buildResourceClass       = "build"
+	performanceResourceClass = "performance"
+)
+
+// defaultScheduleBudgets are the concurrency budgets of the resource classes,
+// per window, that --schedule-budget overrides. They are above the load of the
+// jobs that cannot move, i.e. the continuous jobs and those with a fixed cron
+// schedule, so that the daily and weekly jobs are staggered around them.
+var defaultScheduleBudgets = map[string]int{
+	e2eResourceClass:         75,
+	releaseResourceClass:     70,
+	buildResourceClass:       15,
+	performanceResourceClass: 5,
+}
+
+// cronRequest describes when and how long a periodic job runs, for assigning its cron schedule.
+type cronRequest struct {
+	jobName string
+	class   string
+	// duration is the expected run time in minutes, the job timeout is used as the upper bound.
+	duration int
+	// fixedCron is the cron schedule set explicitly; the job is not scheduled
+	// but still counts towards the load.
+	fixedCron string
+	// everyHours is the number of hours between runs, for jobs that run several times a day.
+	everyHours int
+	// localHour is the preferred start hour, in the scheduling time zone, of
+	// jobs that run once a day or once a week. The job starts at most flexHours later.
+	localHour int
+	flexHours int
+	// weekday is the day of the week of weekly jobs, -1 for daily jobs.
+	weekday int
+}
+
+// newCronRequest returns the cron request for the given type of periodic job,
+// false if the job type doesn't support generated cron schedules.
+func newCronRequest(jobType, jobName, repoName string, timeout int) (cronRequest, bool) {
+	req := cronRequest{jobName: jobName, class: e2eResourceClass, duration: timeout, weekday: -1}
+	switch jobType {
+	case "continuous", "custom-job", "auto-release": // As much as every hour
+		// Allow at least 5 minutes between runs
+		req.everyHours = int((timeout+5)/60) + 1
+		if jobType == "auto-release" {
+			req.class = releaseResourceClass
+		}
+	case "branch-ci":
+		req.localHour, req.flexHours = 0, 7 // Between midnight and 7 AM
+	case "nightly":
+		req.class = releaseResourceClass
+		req.localHour, req.flexHours = 0, 6 // Between midnight and 6 AM
+	case "dot-release":
+		req.class = releaseResourceClass
+		req.weekday = int(time.Tuesday)
+		req.localHour, req.flexHours = 0, 7 // Every Tuesday between midnight and 7 AM
+		if strings.HasSuffix(repoName, "-operator") {
+			req.localHour = 12 // Every Tuesday afternoon
+		}
+	default:
+		return req, false
+	}
+	return req, true
+}
+
+// fixedCronRequest returns the request of a job with an explicit cron schedule.
+func fixedCronRequest(jobName, class, cron string, timeout int) cronRequest {
+	return cronRequest{jobName: jobName, class: class, duration: timeout, fixedCron: cron, weekday: -1}
+}
+
+// pendingPeriodicJob is a periodic job whose output is deferred until the cron
+// schedules of all periodic jobs are assigned.
+type pendingPeriodicJob struct {
+	name     string
+	templ    string
+	title    string
+	repoName string
+	data     periodicJobTemplateData
+	req      cronRequest
+}
+
+// cronScheduler collects the periodic jobs and assigns their cron schedules.
+type cronScheduler struct {
+	// window is the granularity of the schedule in minutes; the concurrency is
+	// computed, and budgets are applied, per window.
+	window int
+	// timezone is the time zone of the preferred start hours of daily and weekly jobs.
+	timezone string
+	// budgets is the maximum number of concurrently running jobs per resource class.
+	budgets map[string]int
+	pending []pendingPeriodicJob
+	// crons are the assigned cron schedules, by job name.
+	crons map[string]string
+}
+
+func newCronScheduler() *cronScheduler {
+	budgets := make(map[string]int)
+	for class, limit := range defaultScheduleBudgets {
+		budgets[class] = limit
+	}
+	return &cronScheduler{
+		window:   defaultScheduleWindow,
+		timezone: defaultScheduleTimezone,
+		budgets:  budgets,
+		crons:    make(map[string]string),
+	}
+}
+
+// periodicScheduler is the scheduler of all the generated periodic jobs.
+var periodicScheduler = newCronScheduler()
+
+// parseScheduleBudgets parses the budgets given as class=limit.
+func parseScheduleBudgets(values []string) (map[string]int, error) {
+	budgets := make(map[string]int)
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid budget %q, expecting class=limit", v)
+		}
+		limit, err := strconv.Atoi(parts[1])
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit in budget %q, expecting a positive number", v)
+		}
+		budgets[parts[0]] = limit
+	}
+	return budgets, nil
+}
+
+// executePeriodicJobTemplate defers the output of the periodic job until its
+// cron schedule is assigned by flushPeriodicJobs.
+func executePeriodicJobTemplate(name, templ, title, repoName string, data periodicJobTemplateData, req cronRequest) {
+	periodicScheduler.pending = append(periodicScheduler.pending, pendingPeriodicJob{
+		name:     name,
+		templ:    templ,
+		title:    title,
+		repoName: repoName,
+		data:     data,
+		req:      req,
+	})
+}
+
+// flushPeriodicJobs assigns the cron schedules of the pending periodic jobs and
+// outputs them in the order they were generated. If reportFile is set, the
+// expected concurrency curve is written to it.
+func flushPeriodicJobs(reportFile string) {
+	s := periodicScheduler
+	reqs := make([]cronRequest, len(s.pending))
+	for i, job := range s.pending {
+		reqs[i] = job.req
+	}
+	crons, load, err := s.schedule(reqs)
+	if err != nil {
+		logFatalf("Failed scheduling periodic jobs: %v", err)
+		return
+	}
+	for i, job := range s.pending {
+		job.data.CronString = crons[i]
+		s.crons[job.data.PeriodicJobName] = crons[i]
+		executeJobTemplate(job.name, job.templ, job.title, job.repoName, job.data.PeriodicJobName, false, job.data)
+	}
+	s.pending = nil
+
+	report := load.report(s.budgets)
+	for _, class := range load.classes() {
+		peak := load.peak(class)
+		if budget := s.budgets[class]; budget > 0 && peak > budget {
+			log.Printf("Expected concurrency %d of %q periodic jobs exceeds the budget %d", peak, class, budget)
+		}
+	}
+	if reportFile != "" {
+		if err := ioutil.WriteFile(reportFile, []byte(report), 0644); err != nil {
+			logFatalf("Cannot write the schedule report %q: %v", reportFile, err)
+		}
+	}
+}
+
+// scheduleLoad is the number of jobs running in each window of the week, per resource class.
+type scheduleLoad struct {
+	window int
+	slots  map[string][]int
+}
+
+func (l *scheduleLoad) classLoad(class string) []int {
+	if _, ok := l.slots[class]; !ok {
+		l.slots[class] = make([]int, minutesPerWeek/l.window)
+	}
+	return l.slots[class]
+}
+
+// add adds a job starting at the given minutes of the week and running for duration minutes.
+func (l *scheduleLoad) add(class string, starts []int, duration int) {
+	load := l.classLoad(class)
+	for _, start := range starts {
+		for _, s := range l.occupiedSlots(start, duration) {
+			load[s]++
+		}
+	}
+}
+
+// occupiedSlots returns the windows a job starting at the given minute of the week runs in.
+func (l *scheduleLoad) occupiedSlots(start, duration int) []int {
+	n := (duration + l.window - 1) / l.window
+	if n < 1 {
+		n = 1
+	}
+	total := minutesPerWeek / l.window
+	res := make([]int, n)
+	for i := range res {
+		res[i] = (start/l.window + i) % total
+	}
+	return res
+}
+
+func (l *scheduleLoad) classes() []string {
+	var res []string
+	for class := range l.slots {
+		res = append(res, class)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (l *scheduleLoad) peak(class string) int {
+	peak := 0
+	for _, v := range l.slots[class] {
+		if v > peak {
+			peak = v
+		}
+	}
+	return peak
+}
+
+// report returns the expected concurrency curve per resource class, as the
+// maximum number of concurrently running jobs within each hour of the day.
+func (l *scheduleLoad) report(budgets map[string]int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Expected concurrency of periodic jobs, per %d minutes window, times in UTC\n", l.window)
+	slotsPerHour := 60 / l.window
+	for _, class := range l.classes() {
+		load := l.slots[class]
+		fmt.Fprintf(&sb, "\nResource class %q: peak %d", class, l.peak(class))
+		if budget := budgets[class]; budget > 0 {
+			fmt.Fprintf(&sb, ", budget %d", budget)
+		}
+		sb.WriteString("\nhour  max\n")
+		for hour := 0; hour < 24; hour++ {
+			max := 0
+			for day := 0; day < 7; day++ {
+				for i := 0; i < slotsPerHour; i++ {
+					if v := load[(day*24+hour)*slotsPerHour+i]; v > max {
+						max = v
+					}
+				}
+			}
+			fmt.Fprintf(&sb, "%02d    %3d %s\n", hour, max, strings.Repeat("#", max))
+		}
+	}
+	return sb.String()
+}
+
+// utcOffsets returns the UTC offsets in hours of the time zone in winter and in summer.
+func utcOffsets(timezone string) (int, int, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Use fixed dates so that the schedule doesn't depend on when it's generated.
+	_, winter := time.Date(2021, time.January, 1, 0, 0, 0, 0, loc).Zone()
+	_, summer := time.Date(2021, time.July, 1, 0, 0, 0, 0, loc).Zone()
+	return winter / 3600, summer / 3600, nil
+}
+
+// utcStartHours returns the UTC hours, relative to the start of the local day, that are within
+// [localHour, localHour+flexHours) in the time zone both in winter and in summer,
+// since cron schedules in UTC cannot follow daylight saving time.
+func utcStartHours(localHour, flexHours, winterOffset, summerOffset int) []int {
+	from, to := localHour-winterOffset, localHour-winterOffset+flexHours
+	if h := localHour - summerOffset; h > from {
+		from = h
+	}
+	if h := localHour - summerOffset + flexHours; h < to {
+		to = h
+	}
+	if from >= to {
+		// The window is shorter than the daylight saving time shift.
+		from, to = localHour-winterOffset, localHour-winterOffset+flexHours
+	}
+	var res []int
+	for h := from; h < to; h++ {
+		res = append(res, h)
+	}
+	return res
+}
+
+// splitUTCHour splits an hour relative to the start of the local day, which
+// is negative or beyond 23 if the UTC day is different, into the UTC hour of
+// the day and the shift of the day.
+func splitUTCHour(h int) (int, int) {
+	hour := (h%24 + 24) % 24
+	return hour, (h - hour) / 24
+}
+
+// minuteJitter spreads the jobs starting in the same window across its minutes,
+// derived from the job name to keep the schedule stable across runs.
+func minuteJitter(jobName string, window int) int {
+	h := fnv.New32a()
+	h.Write([]byte(jobName))
+	return int(h.Sum32() % uint32(window))
+}
+
+// preferredCandidate returns the index of the preferred candidate among n,
+// derived from the job name so that it doesn't depend on the other jobs.
+func preferredCandidate(jobName string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte("slot/" + jobName))
+	return int(h.Sum64() % uint64(n))
+}
+
+// candidateCrons returns the possible cron schedules of a request, in order of preference.
+func (s *cronScheduler) candidateCrons(req cronRequest, winterOffset, summerOffset int) []string {
+	jitter := minuteJitter(req.jobName, s.window)
+	var res []string
+	if req.everyHours > 0 {
+		for hour := 0; hour < req.everyHours && hour < 24; hour++ {
+			for minute := jitter; minute < 60; minute += s.window {
+				switch {
+				case req.everyHours == 1:
+					res = append(res, fmt.Sprintf("%d * * * *", minute))
+				case req.everyHours >= 24:
+					res = append(res, fmt.Sprintf("%d %d * * *", minute, hour))
+				case hour == 0:
+					res = append(res, fmt.Sprintf("%d */%d * * *", minute, req.everyHours))
+				default:
+					res = append(res, fmt.Sprintf("%d %d-23/%d * * *", minute, hour, req.everyHours))
+				}
+			}
+		}
+		return res
+	}
+	for _, h := range utcStartHours(req.localHour, req.flexHours, winterOffset, summerOffset) {
+		hour, dayShift := splitUTCHour(h)
+		for minute := jitter; minute < 60; minute += s.window {
+			if req.weekday < 0 {
+				res = append(res, fmt.Sprintf("%d %d * * *", minute, hour))
+			} else {
+				res = append(res, fmt.Sprintf("%d %d * * %d", minute, hour, ((req.weekday+dayShift)%7+7)%7))
+			}
+		}
+	}
+	return res
+}
+
+// candidateCost is the cost of placing a job, compared in order of the fields.
+type candidateCost struct {
+	// overBudget is the number of job windows exceeding the budget of the class.
+	overBudget int
+	// peak is the highest concurrency the job runs at.
+	peak int
+	// overlap is the total number of other jobs the job runs concurrently with.
+	overlap int
+}
+
+func (c candidateCost) less(o candidateCost) bool {
+	if c.overBudget != o.overBudget {
+		return c.overBudget < o.overBudget
+	}
+	if c.peak != o.peak {
+		return c.peak < o.peak
+	}
+	return c.overlap < o.overlap
+}
+
+func (l *scheduleLoad) cost(class string, starts []int, duration, budget int) candidateCost {
+	load := l.classLoad(class)
+	var c candidateCost
+	for _, start := range starts {
+		for _, s := range l.occupiedSlots(start, duration) {
+			v := load[s] + 1
+			if budget > 0 && v > budget {
+				c.overBudget++
+			}
+			if v > c.peak {
+				c.peak = v
+			}
+			c.overlap += load[s]
+		}
+	}
+	return c
+}
+
+// schedule returns the cron schedule of each request, and the resulting load.
+// Jobs with fixed schedules are accounted first, then the jobs taking the most
+// time per week are placed first, each in its preferred candidate slot. If that
+// slot exceeds the budget, the following candidates are tried in order, and if
+// none of them is within the budget, the cheapest one is used.
+func (s *cronScheduler) schedule(reqs []cronRequest) ([]string, *scheduleLoad, error) {
+	if s.window <= 0 || 60%s.window != 0 {
+		return nil, nil, fmt.Errorf("schedule window %d doesn't divide an hour", s.window)
+	}
+	winterOffset, summerOffset, err := utcOffsets(s.timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid time zone %q: %v", s.timezone, err)
+	}
+	load := &scheduleLoad{window: s.window, slots: make(map[string][]int)}
+	crons := make([]string, len(reqs))
+
+	type candidate struct {
+		cron   string
+		starts []int
+	}
+	type schedulable struct {
+		index      int
+		candidates []candidate
+		weight     int
+	}
+	var jobs []schedulable
+	for i, req := range reqs {
+		if req.fixedCron != "" {
+			starts, err := cronMinutesOfWeek(req.fixedCron)
+			if err != nil {
+				return nil, nil, fmt.Errorf("job %q: %v", req.jobName, err)
+			}
+			crons[i] = req.fixedCron
+			load.add(req.class, starts, req.duration)
+			continue
+		}
+		job := schedulable{index: i}
+		for _, cron := range s.candidateCrons(req, winterOffset, summerOffset) {
+			starts, err := cronMinutesOfWeek(cron)
+			if err != nil {
+				return nil, nil, fmt.Errorf("job %q: %v", req.jobName, err)
+			}
+			job.candidates = append(job.candidates, candidate{cron: cron, starts: starts})
+		}
+		if len(job.candidates) == 0 {
+			return nil, nil, fmt.Errorf("job %q has no possible cron schedule", req.jobName)
+		}
+		job.weight = len(job.candidates[0].starts) * len(load.occupiedSlots(0, req.duration))
+		jobs = append(jobs, job)
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].weight != jobs[j].weight {
+			return jobs[i].weight > jobs[j].weight
+		}
+		return reqs[jobs[i].index].jobName < reqs[jobs[j].index].jobName
+	})
+	for _, job := range jobs {
+		req := reqs[job.index]
+		n := len(job.candidates)
+		preferred := preferredCandidate(req.jobName, n)
+		best := -1
+		var bestCost candidateCost
+		for i := 0; i < n; i++ {
+			c := (preferred + i) % n
+			cost := load.cost(req.class, job.candidates[c].starts, req.duration, s.budgets[req.class])
+			if cost.overBudget == 0 {
+				best = c
+				break
+			}
+			if best < 0 || cost.less(bestCost) {
+				best, bestCost = c, cost
+			}
+		}
+		crons[job.index] = job.candidates[best].cron
+		load.add(req.class, job.candidates[best].starts, req.duration)
+	}
+	return crons, load, nil
+}
+
+// cronMinutesOfWeek returns the start times of a cron schedule in minutes since
+// Sunday 00:00. Only the minute, hour and day of week fields are considered,
+// the job is assumed to run on every day of the month.
+func cronMinutesOfWeek(cron string) ([]int, error) {
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron %q, expecting 5 fields", cron)
+	}
+	minutes, err := parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minute in cron %q: %v", cron, err)
+	}
+	hours, err := parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hour in cron %q: %v", cron, err)
+	}
+	days, err := parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return nil, fmt.Errorf("invalid day of week in cron %q: %v", cron, err)
+	}
+	seen := make(map[int]bool)
+	var res []int
+	for _, d := range days {
+		for _, h := range hours {
+			for _, m := range minutes {
+				// Both 0 and 7 are Sunday.
+				v := ((d%7)*24+h)*60 + m
+				if !seen[v] {
+					seen[v] = true
+					res = append(res, v)
+				}
+			}
+		}
+	}
+	sort.Ints(res)
+	return res, nil
+}
+
+// parseCronField parses a cron field made of comma separated values, ranges
+// and steps, e.g. "*/4", "3-23/4" or "1,2".
+func parseCronField(field string, min, max int) ([]int, error) {
+	var res []int
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], s
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			switch {
+			case len(bounds) == 2:
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			case step == 1:
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value %q out of range [%d, %d]", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
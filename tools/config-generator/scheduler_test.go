@@ -0,0 +1,268 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCronMinutesOfWeek(t *testing.T) {
+	tests := []struct {
+		cron     string
+		expected []int
+		err      bool
+	}{
+		{cron: "5 3 * * 2", expected: []int{(2*24+3)*60 + 5}},
+		{cron: "0 0 * * 7", expected: []int{0}},
+		{cron: "0 0,12 * * 0", expected: []int{0, 12 * 60}},
+		{cron: "30 */12 * * 1", expected: []int{24*60 + 30, 36*60 + 30}},
+		{cron: "0 3-23/8 * * 6", expected: []int{(6*24 + 3) * 60, (6*24 + 11) * 60, (6*24 + 19) * 60}},
+		{cron: "0 1", err: true},
+		{cron: "60 1 * * *", err: true},
+		{cron: "0 */0 * * *", err: true},
+		{cron: "0 5-3 * * *", err: true},
+		{cron: "x 1 * * *", err: true},
+	}
+	for _, tc := range tests {
+		out, err := cronMinutesOfWeek(tc.cron)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Expected error for %q", tc.cron)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tc.cron, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.expected, out); diff != "" {
+			t.Errorf("Unexpected minutes for %q (-want +got):\n%s", tc.cron, diff)
+		}
+	}
+
+	daily, err := cronMinutesOfWeek("0 1 * * *")
+	if err != nil || len(daily) != 7 {
+		t.Errorf("Expected 7 runs per week for a daily cron, got %v (%v)", daily, err)
+	}
+}
+
+func TestUTCStartHours(t *testing.T) {
+	winter, summer, err := utcOffsets("America/Los_Angeles")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if winter != -8 || summer != -7 {
+		t.Fatalf("Expected offsets -8 and -7, got %d and %d", winter, summer)
+	}
+	// 1 AM to 4 AM in both winter and summer.
+	if diff := cmp.Diff([]int{9, 10}, utcStartHours(1, 3, winter, summer)); diff != "" {
+		t.Errorf("Unexpected hours (-want +got):\n%s", diff)
+	}
+	// The window is shorter than the daylight saving time shift.
+	if diff := cmp.Diff([]int{9}, utcStartHours(1, 1, winter, summer)); diff != "" {
+		t.Errorf("Unexpected hours (-want +got):\n%s", diff)
+	}
+	// Time zones ahead of UTC start on the previous UTC day.
+	if diff := cmp.Diff([]int{-1, 0}, utcStartHours(0, 2, 1, 1)); diff != "" {
+		t.Errorf("Unexpected hours (-want +got):\n%s", diff)
+	}
+	hour, shift := splitUTCHour(-1)
+	if hour != 23 || shift != -1 {
+		t.Errorf("Expected hour 23 of previous day, got %d %d", hour, shift)
+	}
+	hour, shift = splitUTCHour(25)
+	if hour != 1 || shift != 1 {
+		t.Errorf("Expected hour 1 of next day, got %d %d", hour, shift)
+	}
+}
+
+func TestCandidateCrons(t *testing.T) {
+	s := newCronScheduler()
+	s.window = 30
+	jitter := minuteJitter("job", 30)
+
+	hourly := s.candidateCrons(cronRequest{jobName: "job", everyHours: 2, weekday: -1}, -8, -7)
+	expected := []string{
+		fmt.Sprintf("%d */2 * * *", jitter),
+		fmt.Sprintf("%d */2 * * *", jitter+30),
+		fmt.Sprintf("%d 1-23/2 * * *", jitter),
+		fmt.Sprintf("%d 1-23/2 * * *", jitter+30),
+	}
+	if diff := cmp.Diff(expected, hourly); diff != "" {
+		t.Errorf("Unexpected candidates (-want +got):\n%s", diff)
+	}
+
+	weekly := s.candidateCrons(cronRequest{jobName: "job", localHour: 23, flexHours: 3, weekday: 2}, -8, -7)
+	expected = []string{
+		fmt.Sprintf("%d 7 * * 3", jitter),
+		fmt.Sprintf("%d 7 * * 3", jitter+30),
+		fmt.Sprintf("%d 8 * * 3", jitter),
+		fmt.Sprintf("%d 8 * * 3", jitter+30),
+	}
+	if diff := cmp.Diff(expected, weekly); diff != "" {
+		t.Errorf("Unexpected candidates (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	s := newCronScheduler()
+	s.window = 30
+	s.budgets = map[string]int{"e2e": 2}
+	reqs := []cronRequest{
+		fixedCronRequest("fixed", "e2e", "0 * * * *", 30),
+		{jobName: "job-a", class: "e2e", duration: 30, everyHours: 1, weekday: -1},
+		{jobName: "job-b", class: "e2e", duration: 30, everyHours: 1, weekday: -1},
+		{jobName: "job-c", class: "e2e", duration: 30, everyHours: 1, weekday: -1},
+		{jobName: "job-d", class: "release", duration: 30, localHour: 2, flexHours: 3, weekday: -1},
+	}
+	crons, load, err := s.schedule(reqs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if crons[0] != "0 * * * *" {
+		t.Errorf("Expected the fixed cron to be kept, got %q", crons[0])
+	}
+	// 4 hourly jobs of 30 minutes are spread across the two windows of each hour.
+	if peak := load.peak("e2e"); peak != 2 {
+		t.Errorf("Expected peak concurrency 2 for e2e, got %d: %v", peak, crons)
+	}
+	if !strings.HasSuffix(crons[4], " 10 * * *") && !strings.HasSuffix(crons[4], " 11 * * *") {
+		t.Errorf("Expected job-d to start between 2 and 4 AM Pacific time, got %q", crons[4])
+	}
+
+	// The schedule is deterministic.
+	again, _, err := s.schedule(reqs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(crons, again); diff != "" {
+		t.Errorf("Schedule changed between runs (-first +second):\n%s", diff)
+	}
+
+	report := load.report(s.budgets)
+	for _, want := range []string{`Resource class "e2e": peak 2, budget 2`, `Resource class "release": peak 1`} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected %q in report:\n%s", want, report)
+		}
+	}
+
+	s.window = 7
+	if _, _, err := s.schedule(reqs); err == nil {
+		t.Errorf("Expected error for a window not dividing an hour")
+	}
+	s.window = 30
+	s.timezone = "Nowhere/Unknown"
+	if _, _, err := s.schedule(reqs); err == nil {
+		t.Errorf("Expected error for an unknown time zone")
+	}
+}
+
+func TestScheduleKeepsExistingJobs(t *testing.T) {
+	s := newCronScheduler()
+	var reqs []cronRequest
+	for i := 0; i < 20; i++ {
+		reqs = append(reqs,
+			cronRequest{jobName: fmt.Sprintf("continuous-%d", i), class: "e2e", duration: 120, everyHours: 3, weekday: -1},
+			cronRequest{jobName: fmt.Sprintf("nightly-%d", i), class: "release", duration: 60, localHour: 2, flexHours: 3, weekday: -1})
+	}
+	crons, _, err := s.schedule(reqs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	added := append([]cronRequest{
+		{jobName: "new-continuous", class: "e2e", duration: 120, everyHours: 3, weekday: -1},
+		{jobName: "new-nightly", class: "release", duration: 60, localHour: 2, flexHours: 3, weekday: -1},
+	}, reqs...)
+	again, _, err := s.schedule(added)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Adding jobs doesn't move the existing ones.
+	if diff := cmp.Diff(crons, again[2:]); diff != "" {
+		t.Errorf("Existing jobs moved (-before +after):\n%s", diff)
+	}
+}
+
+func TestScheduleOverBudget(t *testing.T) {
+	s := newCronScheduler()
+	s.window = 30
+	s.budgets = map[string]int{"e2e": 1}
+	reqs := []cronRequest{
+		{jobName: "job-a", class: "e2e", duration: 60, everyHours: 1, weekday: -1},
+		{jobName: "job-b", class: "e2e", duration: 60, everyHours: 1, weekday: -1},
+	}
+	_, load, err := s.schedule(reqs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Jobs are still scheduled if the budget cannot be met.
+	if peak := load.peak("e2e"); peak != 2 {
+		t.Errorf("Expected peak concurrency 2, got %d", peak)
+	}
+}
+
+func TestParseScheduleBudgets(t *testing.T) {
+	budgets, err := parseScheduleBudgets([]string{"e2e=40", "release=5"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(map[string]int{"e2e": 40, "release": 5}, budgets); diff != "" {
+		t.Errorf("Unexpected budgets (-want +got):\n%s", diff)
+	}
+	for _, v := range []string{"e2e", "=1", "e2e=0", "e2e=x"} {
+		if _, err := parseScheduleBudgets([]string{v}); err == nil {
+			t.Errorf("Expected error for %q", v)
+		}
+	}
+}
+
+func TestDefaultScheduleBudgets(t *testing.T) {
+	s := newCronScheduler()
+	if diff := cmp.Diff(defaultScheduleBudgets, s.budgets); diff != "" {
+		t.Errorf("Unexpected budgets (-want +got):\n%s", diff)
+	}
+	// Overriding a budget doesn't change the defaults.
+	s.budgets[e2eResourceClass] = 1
+	if defaultScheduleBudgets[e2eResourceClass] == 1 {
+		t.Error("Expected the default budgets to be copied")
+	}
+}
+
+func TestFlushPeriodicJobs(t *testing.T) {
+	SetupForTesting()
+	var data periodicJobTemplateData
+	data.PeriodicJobName = "ci-job"
+	executePeriodicJobTemplate("periodic", "cron: [[.CronString]]", "periodics", "org/repo", data,
+		cronRequest{jobName: data.PeriodicJobName, class: "e2e", duration: 60, everyHours: 2, weekday: -1})
+	if len(GetOutput()) != 0 {
+		t.Fatalf("Expected the output to be deferred, got %q", GetOutput())
+	}
+	flushPeriodicJobs("")
+	if logFatalCalls != 0 {
+		t.Fatalf("LogFatal was called")
+	}
+	if out := GetOutput(); !strings.HasPrefix(out, "periodics:\ncron: ") || !strings.Contains(out, "/2 * * *") {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if len(periodicScheduler.pending) != 0 {
+		t.Errorf("Expected no pending jobs after flushing")
+	}
+}
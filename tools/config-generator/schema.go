@@ -0,0 +1,435 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// schema.go defines the typed schema of the meta config file, which is used
+// for validating the meta config before generating anything from it.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var orgRepoRegex = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// metaConfig is the decoded meta config, for the sections that are only read
+// from the typed schema.
+var metaConfig = &MetaConfig{}
+
+// MetaConfig is the meta config file, e.g. config_knative.yaml.
+type MetaConfig struct {
+	Repos          map[string]RepoConfig           `yaml:"repos,omitempty" doc:"Repository wide settings, keyed by org/repo"`
+	Presubmits     map[string][]PresubmitJobConfig `yaml:"presubmits" doc:"Presubmit jobs, keyed by org/repo"`
+	Periodics      map[string][]PeriodicJobConfig  `yaml:"periodics" doc:"Periodic jobs, keyed by org/repo"`
+	CustomJobs     []CustomJobConfig               `yaml:"custom-jobs,omitempty" doc:"Jobs not generated from the meta config, e.g. in jobs/custom, to add to TestGrid"`
+	TestgridExtras map[string]TestgridExtras       `yaml:"testgrid-extras,omitempty" doc:"TestGrid settings overriding the generated ones, keyed by test group, i.e. job, name"`
+}
+
+// RepoConfig contains the settings of a repository, which has presubmit jobs.
+type RepoConfig struct {
+	Performance bool `yaml:"performance,omitempty" doc:"Whether to enable performance tests for the repo"`
+}
+
+// CustomJobConfig is a job whose Prow config is not generated, added to a
+// TestGrid dashboard, see addCustomJobsTestgrid.
+type CustomJobConfig struct {
+	Name           string `yaml:"name" doc:"Name of the Prow job"`
+	DashboardGroup string `yaml:"dashboard-group,omitempty" doc:"TestGrid dashboard group, \"maintenance\" by default"`
+	Dashboard      string `yaml:"dashboard,omitempty" doc:"TestGrid dashboard, \"utilities\" by default"`
+}
+
+// TestgridExtras are the TestGrid settings of a test group.
+type TestgridExtras struct {
+	AlertStaleResultsHours int    `yaml:"alert-stale-results-hours,omitempty" doc:"Alert if the job didn't run for this many hours"`
+	NumFailuresToAlert     int    `yaml:"num-failures-to-alert,omitempty" doc:"Alert after this many consecutive failures"`
+	AlertMailToAddresses   string `yaml:"alert-mail-to-addresses,omitempty" doc:"Email addresses the alerts are sent to, separated by commas"`
+	ShortTextMetric        string `yaml:"short-text-metric,omitempty" doc:"Metric shown in the cells, e.g. \"coverage\""`
+}
+
+// apply overrides the extras of the test group template with the settings.
+func (e TestgridExtras) apply(extras map[string]string) {
+	if e.AlertStaleResultsHours != 0 {
+		extras["alert_stale_results_hours"] = strconv.Itoa(e.AlertStaleResultsHours)
+	}
+	if e.NumFailuresToAlert != 0 {
+		extras["num_failures_to_alert"] = strconv.Itoa(e.NumFailuresToAlert)
+	}
+	if e.AlertMailToAddresses != "" {
+		extras["alert_options"] = fmt.Sprintf("\n    alert_mail_to_addresses: %q", e.AlertMailToAddresses)
+	}
+	if e.ShortTextMetric != "" {
+		extras["short_text_metric"] = e.ShortTextMetric
+	}
+}
+
+// JobConfig contains the options shared by all jobs, see parseBasicJobConfigOverrides.
+type JobConfig struct {
+	SkipBranches   []string                     `yaml:"skip_branches,omitempty" doc:"Branches the job doesn't run on"`
+	Branches       []string                     `yaml:"branches,omitempty" doc:"Branches the job runs on"`
+	Args           []string                     `yaml:"args,omitempty" doc:"Arguments of the command"`
+	Timeout        int                          `yaml:"timeout,omitempty" doc:"Timeout of the job in minutes"`
+	Command        singleString                 `yaml:"command,omitempty" doc:"Command to run, overriding the default script"`
+	NeedsMonitor   bool                         `yaml:"needs-monitor,omitempty" doc:"Whether to add the pubsub labels for test-infra monitoring"`
+	NeedsDind      bool                         `yaml:"needs-dind,omitempty" doc:"Whether to enable docker-in-docker"`
+	AlwaysRun      *bool                        `yaml:"always-run,omitempty" doc:"Whether to always run the job"`
+	Performance    bool                         `yaml:"performance,omitempty" doc:"Whether to enable performance tests for the repo"`
+	EnvVars        []string                     `yaml:"env-vars,omitempty" doc:"Extra environment variables, as key=value"`
+	Optional       bool                         `yaml:"optional,omitempty" doc:"Whether the job is optional for merging"`
+	Resources      map[string]map[string]string `yaml:"resources,omitempty" doc:"Resource requests and limits of the job container"`
+	ReporterConfig *ReporterConfig              `yaml:"reporter_config,omitempty" doc:"Reporter config of the job"`
+}
+
+// ReporterConfig is the reporter config of a job.
+type ReporterConfig struct {
+	Slack *SlackReporterConfig `yaml:"slack,omitempty" doc:"Slack reporter config"`
+}
+
+// SlackReporterConfig is the Slack reporter config of a job.
+type SlackReporterConfig struct {
+	Channel           string   `yaml:"channel" doc:"Slack channel to report to"`
+	JobStatesToReport []string `yaml:"job_states_to_report,omitempty" doc:"Job states to report"`
+	ReportTemplate    string   `yaml:"report_template,omitempty" doc:"Template of the report message"`
+}
+
+// PresubmitJobConfig is a presubmit job, see generatePresubmit.
+type PresubmitJobConfig struct {
+	JobConfig           `yaml:",inline"`
+	BuildTests          bool   `yaml:"build-tests,omitempty" doc:"Generate the build tests job"`
+	UnitTests           bool   `yaml:"unit-tests,omitempty" doc:"Generate the unit tests job"`
+	IntegrationTests    bool   `yaml:"integration-tests,omitempty" doc:"Generate the integration tests job"`
+	GoCoverage          bool   `yaml:"go-coverage,omitempty" doc:"Generate the go coverage job"`
+	GoCoverageThreshold int    `yaml:"go-coverage-threshold,omitempty" doc:"Go coverage threshold in percentage"`
+	CustomTest          string `yaml:"custom-test,omitempty" doc:"Name of the custom test job"`
+	RepoSettings        *bool  `yaml:"repo-settings,omitempty" doc:"Repository wide settings instead of a job"`
+	RunIfChanged        string `yaml:"run-if-changed,omitempty" doc:"Regex of changed files that trigger the job"`
+	GithubActions       bool   `yaml:"github-actions,omitempty" doc:"Also generate GitHub Actions workflows for the test jobs of the repository, only in repo-settings"`
+
+	// keys are the keys set in the meta config, in order.
+	keys []string
+}
+
+// PeriodicJobConfig is a periodic job, see generatePeriodic.
+type PeriodicJobConfig struct {
+	JobConfig     `yaml:",inline"`
+	Continuous    bool   `yaml:"continuous,omitempty" doc:"Generate the continuous job"`
+	Nightly       bool   `yaml:"nightly,omitempty" doc:"Generate the nightly release job"`
+	BranchCI      bool   `yaml:"branch-ci,omitempty" doc:"Generate the continuous job for a release branch"`
+	DotRelease    bool   `yaml:"dot-release,omitempty" doc:"Generate the dot release job"`
+	AutoRelease   bool   `yaml:"auto-release,omitempty" doc:"Generate the auto release job"`
+	CustomJob     string `yaml:"custom-job,omitempty" doc:"Name of the custom job"`
+	Cron          string `yaml:"cron,omitempty" doc:"Cron schedule, generated if not set"`
+	Release       string `yaml:"release,omitempty" doc:"Release version, e.g. \"0.19\", for jobs on a release branch"`
+	ResourceClass string `yaml:"resource-class,omitempty" doc:"Resource class, e.g. \"e2e\", whose concurrency budget applies when generating the cron schedule"`
+
+	// keys are the keys set in the meta config, in order.
+	keys []string
+}
+
+// singleString is a string that can also be written as a list of one string.
+type singleString string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (s *singleString) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		*s = singleString(str)
+		return nil
+	}
+	var arr []string
+	if err := unmarshal(&arr); err != nil || len(arr) != 1 {
+		return errors.New("expecting a string or a list of one string")
+	}
+	*s = singleString(arr[0])
+	return nil
+}
+
+// presubmitJobEntry and periodicJobEntry are used for decoding the fields
+// without recursing into UnmarshalYAML, their names show up in decoding errors.
+type (
+	presubmitJobEntry PresubmitJobConfig
+	periodicJobEntry  PeriodicJobConfig
+)
+
+// mapKeys returns the keys of the yaml map being unmarshalled, in order.
+func mapKeys(unmarshal func(interface{}) error) ([]string, error) {
+	var m yaml.MapSlice
+	if err := unmarshal(&m); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(m))
+	for _, item := range m {
+		keys = append(keys, fmt.Sprint(item.Key))
+	}
+	return keys, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *PresubmitJobConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal((*presubmitJobEntry)(p)); err != nil {
+		return err
+	}
+	keys, err := mapKeys(unmarshal)
+	p.keys = keys
+	return err
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *PeriodicJobConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal((*periodicJobEntry)(p)); err != nil {
+		return err
+	}
+	keys, err := mapKeys(unmarshal)
+	p.keys = keys
+	return err
+}
+
+var (
+	presubmitJobTypes = []string{"build-tests", "unit-tests", "integration-tests", "go-coverage", "custom-test", "repo-settings"}
+	periodicJobTypes  = []string{"continuous", "nightly", "branch-ci", "dot-release", "auto-release", "custom-job"}
+)
+
+// jobTypesOf returns the keys that are job types.
+func jobTypesOf(keys []string, jobTypes []string) []string {
+	var res []string
+	for _, k := range keys {
+		if strExists(jobTypes, k) {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// validationError is an error found in the meta config, at the given line.
+type validationError struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (e validationError) String() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// validationErrors is the list of errors found in the meta config file.
+type validationErrors struct {
+	File   string
+	Errors []validationError
+}
+
+func (e *validationErrors) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = e.File + ": " + err.String()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// lineIndex maps the job entries of the meta config to their line numbers.
+// yaml.v2 doesn't expose the line numbers of decoded values, so the lines are
+// found by scanning the file, which is formatted as a two level map of lists.
+type lineIndex map[string]int
+
+func jobPath(section, repo string, index int) string {
+	return fmt.Sprintf("%s[%q][%d]", section, repo, index)
+}
+
+// addKey records the line of the key of a job entry, e.g. `timeout: 90`.
+func (idx lineIndex) addKey(job, line string, lineNumber int) {
+	if i := strings.Index(line, ":"); i > 0 {
+		idx[job+"."+line[:i]] = lineNumber
+	}
+}
+
+func newLineIndex(content []byte) lineIndex {
+	idx := make(lineIndex)
+	section, repo, item := "", "", -1
+	for i, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0 && strings.HasSuffix(trimmed, ":"):
+			section, repo, item = strings.TrimSuffix(trimmed, ":"), "", -1
+		case indent == 2 && strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(trimmed, "-"):
+			repo, item = strings.Trim(strings.TrimSuffix(trimmed, ":"), `"'`), -1
+			idx[section+"/"+repo] = i + 1
+		case indent == 2 && strings.HasPrefix(trimmed, "-") && repo != "":
+			item++
+			idx[jobPath(section, repo, item)] = i + 1
+			idx.addKey(jobPath(section, repo, item), strings.TrimSpace(strings.TrimPrefix(trimmed, "-")), i+1)
+		case indent == 4 && item >= 0:
+			idx.addKey(jobPath(section, repo, item), trimmed, i+1)
+		}
+	}
+	return idx
+}
+
+// loadMetaConfig strictly decodes and validates the meta config.
+func loadMetaConfig(fileName string, content []byte) (*MetaConfig, error) {
+	var config MetaConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if errs := config.validate(newLineIndex(content)); len(errs) != 0 {
+		return nil, &validationErrors{File: fileName, Errors: errs}
+	}
+	return &config, nil
+}
+
+// validate returns the semantic errors in the meta config.
+func (c *MetaConfig) validate(lines lineIndex) []validationError {
+	var errs []validationError
+	addErr := func(p string, format string, args ...interface{}) {
+		errs = append(errs, validationError{Line: lines[p], Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(c.Presubmits) == 0 {
+		addErr("presubmits", "missing presubmits section")
+	}
+	if len(c.Periodics) == 0 {
+		addErr("periodics", "missing periodics section")
+	}
+
+	presubmitRepos := make([]string, 0, len(c.Presubmits))
+	for repo := range c.Presubmits {
+		presubmitRepos = append(presubmitRepos, repo)
+	}
+	sort.Strings(presubmitRepos)
+	for _, repo := range presubmitRepos {
+		if !orgRepoRegex.MatchString(repo) {
+			addErr("presubmits/"+repo, "repository %q is expected to be \"org/repo\"", repo)
+		}
+		for i, job := range c.Presubmits[repo] {
+			p := jobPath("presubmits", repo, i)
+			if types := jobTypesOf(job.keys, presubmitJobTypes); len(types) != 1 {
+				addErr(p, "expecting exactly one of %v, got %v", presubmitJobTypes, types)
+			}
+			if job.GithubActions && !strExists(job.keys, "repo-settings") {
+				addErr(p, "github-actions is a repository setting, expecting it in repo-settings")
+			}
+			if job.GoCoverageThreshold < 0 || job.GoCoverageThreshold > 100 {
+				addErr(p, "go-coverage-threshold %d is not within [0, 100]", job.GoCoverageThreshold)
+			}
+			for _, msg := range job.JobConfig.validate() {
+				addErr(p, msg)
+			}
+		}
+	}
+
+	periodicRepos := make([]string, 0, len(c.Periodics))
+	for repo := range c.Periodics {
+		periodicRepos = append(periodicRepos, repo)
+	}
+	sort.Strings(periodicRepos)
+	for _, repo := range periodicRepos {
+		if !orgRepoRegex.MatchString(repo) {
+			addErr("periodics/"+repo, "repository %q is expected to be \"org/repo\"", repo)
+		}
+		for i, job := range c.Periodics[repo] {
+			p := jobPath("periodics", repo, i)
+			if types := jobTypesOf(job.keys, periodicJobTypes); len(types) != 1 {
+				addErr(p, "expecting exactly one of %v, got %v", periodicJobTypes, types)
+			}
+			if job.BranchCI && job.Release == "" {
+				addErr(p, "branch-ci jobs must set release")
+			}
+			if job.Release != "" && !releaseVersionRegex.MatchString(job.Release) {
+				addErr(p, "release %q is expected to be \"MAJOR.MINOR\"", job.Release)
+			}
+			for _, msg := range job.JobConfig.validate() {
+				addErr(p, msg)
+			}
+		}
+	}
+
+	for _, repo := range sortedKeys(c.Repos) {
+		if !orgRepoRegex.MatchString(repo) {
+			addErr("repos/"+repo, "repository %q is expected to be \"org/repo\"", repo)
+		} else if _, ok := c.Presubmits[repo]; !ok {
+			addErr("repos/"+repo, "repository %q has no presubmits", repo)
+		}
+	}
+
+	customJobs := make(map[string]bool)
+	for i, job := range c.CustomJobs {
+		p := fmt.Sprintf("custom-jobs[%d]", i)
+		switch {
+		case job.Name == "":
+			addErr(p, "name must be set")
+		case customJobs[job.Name] || strExists(customJobnames, job.Name):
+			addErr(p, "custom job %q is duplicated", job.Name)
+		}
+		customJobs[job.Name] = true
+	}
+
+	for _, name := range sortedKeys(c.TestgridExtras) {
+		p := "testgrid-extras/" + name
+		extras := c.TestgridExtras[name]
+		if extras.AlertStaleResultsHours < 0 || extras.NumFailuresToAlert < 0 {
+			addErr(p, "alert-stale-results-hours and num-failures-to-alert must be positive")
+		}
+		for _, email := range strings.Split(extras.AlertMailToAddresses, ",") {
+			if extras.AlertMailToAddresses != "" && !strings.Contains(email, "@") {
+				addErr(p, "alert-mail-to-addresses %q is expected to be a list of emails", extras.AlertMailToAddresses)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// sortedKeys returns the keys of the map of the meta config section, sorted.
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var releaseVersionRegex = regexp.MustCompile(`^\d+\.\d+$`)
+
+// validate returns the error messages for the options shared by all jobs.
+func (j *JobConfig) validate() []string {
+	var msgs []string
+	if j.Timeout < 0 {
+		msgs = append(msgs, fmt.Sprintf("timeout %d must be positive", j.Timeout))
+	}
+	for _, env := range j.EnvVars {
+		if len(strings.SplitN(env, "=", 2)) != 2 {
+			msgs = append(msgs, fmt.Sprintf("environment variable %q is expected to be \"key=value\"", env))
+		}
+	}
+	if j.ReporterConfig != nil && j.ReporterConfig.Slack != nil && j.ReporterConfig.Slack.Channel == "" {
+		msgs = append(msgs, "reporter_config.slack.channel must be set")
+	}
+	return msgs
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadMetaConfig(t *testing.T) {
+	SetupForTesting()
+	tests := []struct {
+		name    string
+		in      string
+		wantErr []string
+	}{{
+		name: "valid",
+		in: `# comment
+presubmits:
+  org1/repo1:
+  - repo-settings: null
+    performance: true
+  - build-tests: true
+    command:
+    - ./test/presubmit-tests.sh
+    env-vars:
+    - FOO=bar
+  - custom-test: e2e
+    resources:
+      requests:
+        memory: 12Gi
+periodics:
+  org1/repo1:
+  - continuous: true
+    reporter_config:
+      slack:
+        channel: foo
+        job_states_to_report:
+        - failure
+  - branch-ci: true
+    release: "0.19"
+`,
+	}, {
+		name: "unknown key",
+		in: `presubmits:
+  org1/repo1:
+  - build-tests: true
+    timout: 50
+periodics:
+  org1/repo1:
+  - continuous: true
+`,
+		wantErr: []string{"line 4: field timout not found"},
+	}, {
+		name: "wrong type",
+		in: `presubmits:
+  org1/repo1:
+  - build-tests: true
+    command:
+    - foo
+    - bar
+periodics:
+  org1/repo1:
+  - continuous: true
+`,
+		wantErr: []string{"expecting a string or a list of one string"},
+	}, {
+		name: "semantic errors",
+		in: `presubmits:
+  org1/repo1:
+  - build-tests: true
+  - unit-tests: true
+    build-tests: true
+  - go-coverage: true
+    go-coverage-threshold: 120
+periodics:
+  repo1:
+  - continuous: true
+  org1/repo1:
+  - branch-ci: true
+    env-vars:
+    - FOO
+  - dot-release: true
+    release: v0.19
+`,
+		wantErr: []string{
+			`line 4: presubmits["org1/repo1"][1]: expecting exactly one of`,
+			`line 6: presubmits["org1/repo1"][2]: go-coverage-threshold 120 is not within [0, 100]`,
+			`line 12: periodics["org1/repo1"][0]: branch-ci jobs must set release`,
+			`line 12: periodics["org1/repo1"][0]: environment variable "FOO" is expected to be "key=value"`,
+			`line 15: periodics["org1/repo1"][1]: release "v0.19" is expected to be "MAJOR.MINOR"`,
+			`line 9: periodics/repo1: repository "repo1" is expected to be "org/repo"`,
+		},
+	}, {
+		name: "github-actions outside repo-settings",
+		in: `presubmits:
+  org1/repo1:
+  - repo-settings: null
+    github-actions: true
+  - build-tests: true
+    github-actions: true
+periodics:
+  org1/repo1:
+  - continuous: true
+`,
+		wantErr: []string{`line 5: presubmits["org1/repo1"][1]: github-actions is a repository setting, expecting it in repo-settings`},
+	}, {
+		name: "repos, custom jobs and testgrid extras",
+		in: `repos:
+  org1/repo1:
+    performance: true
+presubmits:
+  org1/repo1:
+  - build-tests: true
+periodics:
+  org1/repo1:
+  - continuous: true
+custom-jobs:
+- name: ci-org1-repo1-custom
+  dashboard: repo1
+testgrid-extras:
+  ci-org1-repo1-continuous:
+    alert-stale-results-hours: 48
+    alert-mail-to-addresses: "someone@example.com,other@example.com"
+`,
+	}, {
+		name: "invalid repos, custom jobs and testgrid extras",
+		in: `repos:
+  org1/repo2:
+    performance: true
+presubmits:
+  org1/repo1:
+  - build-tests: true
+periodics:
+  org1/repo1:
+  - continuous: true
+custom-jobs:
+- name: ci-knative-cleanup
+- dashboard: repo1
+testgrid-extras:
+  ci-org1-repo1-continuous:
+    num-failures-to-alert: -1
+    alert-mail-to-addresses: "someone"
+`,
+		wantErr: []string{
+			`line 2: repos/org1/repo2: repository "org1/repo2" has no presubmits`,
+			`custom-jobs[0]: custom job "ci-knative-cleanup" is duplicated`,
+			`custom-jobs[1]: name must be set`,
+			`line 14: testgrid-extras/ci-org1-repo1-continuous: alert-stale-results-hours and num-failures-to-alert must be positive`,
+			`line 14: testgrid-extras/ci-org1-repo1-continuous: alert-mail-to-addresses "someone" is expected to be a list of emails`,
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMetaConfig("config.yaml", []byte(tt.in))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("loadMetaConfig() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("loadMetaConfig() expected errors %v, got none", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("loadMetaConfig() error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProdMetaConfigIsValid(t *testing.T) {
+	SetupForTesting()
+	fileName := "../../config/prod/prow/config_knative.yaml"
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMetaConfig(fileName, content); err != nil {
+		t.Errorf("loadMetaConfig() failed: %v", err)
+	}
+}
+
+func TestPublishedJSONSchemaIsUpToDate(t *testing.T) {
+	SetupForTesting()
+	want, err := metaConfigJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(metaConfigSchemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("%s is out of date, regenerate it with `go run . json-schema > %s` (-want +got):\n%s",
+			metaConfigSchemaFile, metaConfigSchemaFile, diff)
+	}
+}
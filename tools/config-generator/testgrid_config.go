@@ -252,6 +252,14 @@ func executeTestGroupTemplate(testGroupName string, gcsLogDir string, extras map
 	data.Base.TestGroupName = testGroupName
 	data.GcsLogDir = gcsLogDir
 	data.Extras = extras
+	if override, ok := metaConfig.TestgridExtras[testGroupName]; ok {
+		// The extras can be shared with other test groups
+		data.Extras = make(map[string]string, len(extras))
+		for k, v := range extras {
+			data.Extras[k] = v
+		}
+		override.apply(data.Extras)
+	}
 	executeTemplate("test group", readTemplate(testGroupTemplate), data)
 }
 
@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -268,6 +269,24 @@ func TestExecuteTestGroupTemplate(t *testing.T) {
 	}
 }
 
+func TestExecuteTestGroupTemplateWithTestgridExtras(t *testing.T) {
+	SetupForTesting()
+	metaConfig.TestgridExtras = map[string]TestgridExtras{
+		"tg-name": {AlertStaleResultsHours: 48, AlertMailToAddresses: "someone@example.com"},
+	}
+	shared := map[string]string{"alert_stale_results_hours": "3"}
+	executeTestGroupTemplate("tg-name", "gcs-log-dir", shared)
+	out := GetOutput()
+	for _, want := range []string{"alert_stale_results_hours: 48", `alert_mail_to_addresses: "someone@example.com"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the test group, got:\n%s", want, out)
+		}
+	}
+	if shared["alert_stale_results_hours"] != "3" {
+		t.Errorf("The extras shared with other test groups were modified: %v", shared)
+	}
+}
+
 func TestGenerateDashboard(t *testing.T) {
 	SetupForTesting()
 	projName := "proj-name"
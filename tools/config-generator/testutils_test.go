@@ -15,6 +15,8 @@ package main
 
 import (
 	"bytes"
+
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 var outputBuffer bytes.Buffer
@@ -40,4 +42,12 @@ func SetupForTesting() {
 	logFatalf = logFatalfMock
 	logFatalCalls = 0
 	sectionMap = make(map[string]bool)
+	periodicScheduler = newCronScheduler()
+	githubActionsRepos = sets.NewString()
+	workflowJobs = nil
+	explainJob = ""
+	metaConfigLines = nil
+	currentJobEntry = ""
+	jobExplanations = nil
+	metaConfig = &MetaConfig{}
 }
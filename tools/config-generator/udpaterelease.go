@@ -60,9 +60,24 @@ func upgradeReleaseBranchesTemplate(configfileName string, gc ghutil.GithubOpera
 	if err != nil {
 		return fmt.Errorf("failed marshal modified content: %w", err)
 	}
+	updated = append(headerComments(content), updated...)
 	return ioutil.WriteFile(configfileName, updated, info.Mode())
 }
 
+// headerComments returns the comment lines at the top of the file, e.g. the
+// modeline pointing editors to the JSON Schema, which are lost when the yaml is
+// marshalled again.
+func headerComments(content []byte) []byte {
+	var header []byte
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		header = append(header, line...)
+	}
+	return header
+}
+
 func getReposMap(gc ghutil.GithubOperations, val interface{}) (interface{}, error) {
 	reposMap := getMapSlice(val)
 	for j, repo := range reposMap {
@@ -56,6 +56,21 @@ func TestUpgradeReleaseBranchesTemplate(t *testing.T) {
     release: "0.5"
   - branch-ci: true
     release: "0.6"
+`,
+			nil,
+		}, {
+			"Keep_header_comments",
+			true,
+			`# header comment
+periodics:
+  org1/repo1:
+  - branch-ci: true
+    release: "0.6"`,
+			`# header comment
+periodics:
+  org1/repo1:
+  - branch-ci: true
+    release: "0.6"
 `,
 			nil,
 		}, {
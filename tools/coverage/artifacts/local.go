@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"knative.dev/test-infra/tools/coverage/logUtil"
+	"knative.dev/test-infra/tools/coverage/modules"
 )
 
 type LocalArtifacts struct {
@@ -66,14 +67,18 @@ func (arts *LocalArtifacts) KeyProfileCreator() *os.File {
 	return keyProfileFile
 }
 
-// ProduceProfileFile produce coverage profile (&its stdout) by running go test on target package
-// for periodic job, produce junit xml for testgrid in addition
-func (arts *LocalArtifacts) ProduceProfileFile(covTargetsStr string) {
-	// creates artifacts directory
+// makeDirectory creates artifacts directory
+func (arts *LocalArtifacts) makeDirectory() {
 	log.Printf("mkdir -p %s\n", arts.directory)
 	cmd := exec.Command("mkdir", "-p", arts.directory)
 	log.Printf("artifacts dir=%s\n", arts.directory)
 	cmd.Run()
+}
+
+// ProduceProfileFile produce coverage profile (&its stdout) by running go test on target package
+// for periodic job, produce junit xml for testgrid in addition
+func (arts *LocalArtifacts) ProduceProfileFile(covTargetsStr string) {
+	arts.makeDirectory()
 
 	// convert targets from a single string to a lists of strings
 	var covTargets []string
@@ -84,3 +89,24 @@ func (arts *LocalArtifacts) ProduceProfileFile(covTargetsStr string) {
 
 	runProfiling(covTargets, arts)
 }
+
+// ProduceModulesProfileFile produces a single coverage profile (&its stdout) by
+// running go test on target packages in each of the given modules, and merging
+// the results
+func (arts *LocalArtifacts) ProduceModulesProfileFile(covTargetsStr string, mods []modules.Module) {
+	arts.makeDirectory()
+
+	covTargets := strings.Split(covTargetsStr, " ")
+	var runs []moduleRun
+	for _, m := range mods {
+		targets := modules.Targets(mods, m, covTargets)
+		if len(targets) == 0 {
+			log.Printf("No coverage target in module %s, skipping", m.Path)
+			continue
+		}
+		log.Printf("covTargets of module %s = %v\n", m.Path, targets)
+		runs = append(runs, moduleRun{dir: m.Dir, covTargets: targets})
+	}
+
+	runModulesProfiling(runs, arts)
+}
@@ -16,12 +16,17 @@ limitations under the License.
 package artifacts
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	covIo "knative.dev/test-infra/tools/coverage/io"
+	"knative.dev/test-infra/tools/coverage/logUtil"
 )
 
 type ProfileReader struct {
@@ -32,19 +37,68 @@ func NewProfileReader(reader io.ReadCloser) *ProfileReader {
 	return &ProfileReader{reader}
 }
 
+// moduleRun is a go test run for coverage in a module directory
+type moduleRun struct {
+	dir        string
+	covTargets []string
+}
+
 // runProfiling writes coverage profile (&its stdout) by running go test on
 // target package
 func runProfiling(covTargets []string, localArts *LocalArtifacts) {
 	log.Println("\nStarts calc.runProfiling(...)")
 
+	output := runGoTest("", covTargets, localArts.ProfilePath())
+
+	log.Printf("coverage profile created @ '%s'", localArts.ProfilePath())
+	covIo.CreateMarker(localArts.Directory(), CovProfileCompletionMarker)
+
+	writeStdout(output, localArts)
+	log.Printf("Ends calc.runProfiling(...)\n\n")
+}
+
+// runModulesProfiling writes a coverage profile (&its stdout) merged from the
+// profiles of running go test in each module
+func runModulesProfiling(runs []moduleRun, localArts *LocalArtifacts) {
+	log.Println("\nStarts calc.runModulesProfiling(...)")
+
+	var output []byte
+	var profiles []string
+	for i, run := range runs {
+		profilePath, err := filepath.Abs(fmt.Sprintf("%s.%d", localArts.ProfilePath(), i))
+		if err != nil {
+			logUtil.LogFatalf("Error getting absolute path of profile: %v", err)
+		}
+		output = append(output, runGoTest(run.dir, run.covTargets, profilePath)...)
+		profiles = append(profiles, profilePath)
+	}
+
+	if err := mergeProfiles(profiles, localArts.ProfilePath()); err != nil {
+		logUtil.LogFatalf("Error merging coverage profiles: %v", err)
+	}
+	for _, p := range profiles {
+		os.Remove(p)
+	}
+
+	log.Printf("coverage profile created @ '%s'", localArts.ProfilePath())
+	covIo.CreateMarker(localArts.Directory(), CovProfileCompletionMarker)
+
+	writeStdout(output, localArts)
+	log.Printf("Ends calc.runModulesProfiling(...)\n\n")
+}
+
+// runGoTest runs go test with coverage profiling in dir, and returns the
+// combined output
+func runGoTest(dir string, covTargets []string, profilePath string) []byte {
 	cmdArgs := []string{"test"}
 
 	cmdArgs = append(cmdArgs, covTargets...)
 	cmdArgs = append(cmdArgs, []string{"-covermode=count",
-		"-coverprofile", localArts.ProfilePath()}...)
+		"-coverprofile", profilePath}...)
 
-	log.Printf("go cmdArgs=%v\n", cmdArgs)
+	log.Printf("go cmdArgs=%v, dir=%q\n", cmdArgs, dir)
 	cmd := exec.Command("go", cmdArgs...)
+	cmd.Dir = dir
 
 	output, errCmdOutput := cmd.CombinedOutput()
 
@@ -52,10 +106,47 @@ func runProfiling(covTargets []string, localArts *LocalArtifacts) {
 		log.Printf("Error running 'go test -coverprofile ': error='%v'; combined output='%s'\n",
 			errCmdOutput, output)
 	}
+	return output
+}
+
+// mergeProfiles concatenates the given coverage profiles into dst, keeping
+// only the first mode line. Profiles that don't exist are skipped.
+func mergeProfiles(profiles []string, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
 
-	log.Printf("coverage profile created @ '%s'", localArts.ProfilePath())
-	covIo.CreateMarker(localArts.Directory(), CovProfileCompletionMarker)
+	modeWritten := false
+	for _, p := range profiles {
+		content, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			log.Printf("Coverage profile %s not found, skipping", p)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if line == "" {
+				continue
+			}
+			if strings.HasPrefix(line, "mode:") {
+				if modeWritten {
+					continue
+				}
+				modeWritten = true
+			}
+			if _, err := fmt.Fprintln(out, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
+func writeStdout(output []byte, localArts *LocalArtifacts) {
 	stdoutPath := localArts.CovStdoutPath()
 	stdoutFile, err := os.Create(stdoutPath)
 	if err == nil {
@@ -65,6 +156,4 @@ func runProfiling(covTargets []string, localArts *LocalArtifacts) {
 	}
 	defer stdoutFile.Close()
 	log.Printf("stdout of test coverage stored in %s\n", stdoutPath)
-	log.Printf("Ends calc.runProfiling(...)\n\n")
-	return
 }
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMergeProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge-profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	profiles := []string{path.Join(dir, "p0"), path.Join(dir, "p1"), path.Join(dir, "missing")}
+	contents := []string{
+		"mode: count\nknative.dev/repo/a.go:1.1,2.2 1 1\n",
+		"mode: count\nknative.dev/repo/nested/b.go:1.1,2.2 1 0\n",
+	}
+	for i, content := range contents {
+		if err := ioutil.WriteFile(profiles[i], []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := path.Join(dir, "merged")
+	if err := mergeProfiles(profiles, dst); err != nil {
+		t.Fatalf("mergeProfiles() failed: %v", err)
+	}
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "mode: count\nknative.dev/repo/a.go:1.1,2.2 1 1\nknative.dev/repo/nested/b.go:1.1,2.2 1 0\n"
+	if string(got) != want {
+		t.Errorf("merged profile = %q, want %q", got, want)
+	}
+}
@@ -142,6 +142,16 @@ func (c *Coverage) String() string {
 	return "ratio not exist"
 }
 
+// CoveredStmts returns the number of statements covered
+func (c *Coverage) CoveredStmts() int {
+	return c.nCoveredStmts
+}
+
+// AllStmts returns the number of all statements
+func (c *Coverage) AllStmts() int {
+	return c.nAllStmts
+}
+
 func (c *Coverage) LineCovLink() string {
 	return c.lineCovLink
 }
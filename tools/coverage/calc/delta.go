@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"knative.dev/test-infra/tools/coverage/githubUtil"
+	"knative.dev/test-infra/tools/coverage/modules"
 	"knative.dev/test-infra/tools/coverage/str"
 )
 
@@ -120,15 +121,14 @@ func NewGroupChanges(baseList *CoverageList, newList *CoverageList) *GroupChange
 }
 
 // processChangedFiles checks each entry in GroupChanges and see if it is
-// include in the github commit. If yes, then include that in the covbot report
+// include in the github commit. If yes, then include that in the covbot report.
+// If the repository has more than one module, the report is grouped by module.
 func (changes *GroupChanges) processChangedFiles(githubFilePaths map[string]bool) (string, bool, bool) {
 	log.Printf("\nFinding joining set of changed files from profile[count=%d] & github\n", len(changes.Changed))
 	rows := []string{
 		"The following is the coverage report on the affected files.",
 		fmt.Sprintf("Say `/test %s` to re-run this coverage report", os.Getenv("JOB_NAME")),
 		"",
-		"File | Old Coverage | New Coverage | Delta",
-		"---- |:------------:|:------------:|:-----:",
 	}
 
 	isEmpty, isCoverageLow := true, false
@@ -139,6 +139,11 @@ func (changes *GroupChanges) processChangedFiles(githubFilePaths map[string]bool
 		log.Printf("No github connection. Listing each file with a coverage change.")
 	}
 
+	mods := githubUtil.Modules()
+	groupByModule := len(mods) > 1
+	var moduleOrder []string
+	moduleRows := make(map[string][]string)
+
 	for i, inc := range changes.Changed {
 		pathFromProfile := githubUtil.FilePathProfileToGithub(inc.base.Name())
 
@@ -149,7 +154,16 @@ func (changes *GroupChanges) processChangedFiles(githubFilePaths map[string]bool
 		}
 		if noRepoConnection || githubFilePaths[pathFromProfile] {
 			fmt.Printf("\tYes!")
-			rows = append(rows, inc.githubBotRow(i, pathFromProfile))
+			moduleName := ""
+			if groupByModule {
+				if m, ok := modules.ForProfilePath(mods, inc.base.Name()); ok {
+					moduleName = m.Path
+				}
+			}
+			if _, ok := moduleRows[moduleName]; !ok {
+				moduleOrder = append(moduleOrder, moduleName)
+			}
+			moduleRows[moduleName] = append(moduleRows[moduleName], inc.githubBotRow(i, pathFromProfile))
 			isEmpty = false
 
 			if inc.new.IsCoverageLow(changes.NewGroup.covThresholdInt) {
@@ -162,6 +176,25 @@ func (changes *GroupChanges) processChangedFiles(githubFilePaths map[string]bool
 		fmt.Printf("\n")
 	}
 	fmt.Println("End of Finding joining set of changed files from profile & github")
+
+	if groupByModule {
+		sort.Strings(moduleOrder)
+	}
+	if len(moduleOrder) == 0 {
+		moduleOrder = []string{""}
+	}
+	for i, moduleName := range moduleOrder {
+		if i > 0 {
+			rows = append(rows, "")
+		}
+		if groupByModule {
+			rows = append(rows, fmt.Sprintf("Module `%s`:", moduleName), "")
+		}
+		rows = append(rows,
+			"File | Old Coverage | New Coverage | Delta",
+			"---- |:------------:|:------------:|:-----:")
+		rows = append(rows, moduleRows[moduleName]...)
+	}
 	rows = append(rows, "")
 
 	return strings.Join(rows, "\n"), isEmpty, isCoverageLow
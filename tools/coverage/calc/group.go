@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/test-infra/tools/coverage/modules"
 	"knative.dev/test-infra/tools/coverage/str"
 )
 
@@ -128,6 +129,23 @@ func (g *CoverageList) Subset(prefix string) *CoverageList {
 	return s
 }
 
+// SubsetOfModule returns the subset of files that belong to module m, which
+// excludes the files in modules nested under m
+func (g *CoverageList) SubsetOfModule(mods []modules.Module, m modules.Module) *CoverageList {
+	s := NewCoverageList(m.Path, g.concernedFiles, g.covThresholdInt)
+	for _, c := range g.group {
+		if owner, ok := modules.ForProfilePath(mods, c.Name()); ok && owner == m {
+			s.append(&c)
+		}
+	}
+	return s
+}
+
+// Size returns the number of file Coverage objects in the group
+func (g *CoverageList) Size() int {
+	return g.size()
+}
+
 // Map returns maps the file name to its coverage for faster retrieval
 // & membership check
 func (g *CoverageList) Map() map[string]Coverage {
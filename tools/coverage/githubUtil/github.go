@@ -24,14 +24,40 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+
+	"knative.dev/test-infra/tools/coverage/modules"
 )
 
+// repoModules are the go modules in the repository, used for mapping file paths
+// in coverage profile to github. If empty, file paths are mapped through GOPATH.
+var repoModules []modules.Module
+
+// SetModules sets the go modules used for mapping file paths in coverage profile
+func SetModules(mods []modules.Module) {
+	repoModules = mods
+}
+
+// Modules returns the go modules used for mapping file paths in coverage profile
+func Modules() []modules.Module {
+	return repoModules
+}
+
 // convert a file path from profile format to github format.
 // Equivalent to remove all path prefix up to and including the repo name
 // e.g.
 //   knative.dev/$REPO_NAME/pkg/... -> pkg/...
 //   github.com/$REPO_OWNER/$REPO_NAME/pkg/... -> pkg/...
+// If modules are set, the module path is replaced by the module directory instead
+// e.g.
+//   knative.dev/$REPO_NAME/$NESTED_MODULE/pkg/... -> $NESTED_MODULE_DIR/pkg/...
 func FilePathProfileToGithub(file string) string {
+	if len(repoModules) != 0 {
+		result, ok := modules.RepoPath(repoModules, file)
+		if !ok {
+			log.Fatalf("no module path is a prefix of filepath (%s)", file)
+		}
+		return result
+	}
 	repoPath, err := GetRepoPath()
 	if err != nil {
 		log.Fatalf("Cannot get relative repo path: %v", err)
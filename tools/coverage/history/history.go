@@ -0,0 +1,276 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package history keeps the time series of per-package coverage produced by
+// periodic and postsubmit runs, and computes coverage trends out of it
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"knative.dev/test-infra/tools/coverage/calc"
+)
+
+const (
+	gcsPrefix = "gs://"
+	// maxUpdateAttempts is the number of times an update of a history stored
+	// in GCS is tried when the history is concurrently updated
+	maxUpdateAttempts = 5
+)
+
+// errConflict is returned when writing a history which changed since it was read
+var errConflict = errors.New("the coverage history changed concurrently")
+
+// PackageCoverage is the coverage summary of a single package
+type PackageCoverage struct {
+	CoveredStmts int `json:"covered"`
+	AllStmts     int `json:"all"`
+}
+
+// Percentage returns the coverage of the package in percentage points
+func (pc PackageCoverage) Percentage() float64 {
+	if pc.AllStmts == 0 {
+		return 0
+	}
+	return float64(pc.CoveredStmts) * 100 / float64(pc.AllStmts)
+}
+
+// Record is the coverage snapshot of one build
+type Record struct {
+	Timestamp time.Time                  `json:"timestamp"`
+	Job       string                     `json:"job,omitempty"`
+	Build     int                        `json:"build,omitempty"`
+	Commit    string                     `json:"commit,omitempty"`
+	Packages  map[string]PackageCoverage `json:"packages"`
+}
+
+// History is the list of records, sorted by timestamp in ascending order
+type History struct {
+	Records []Record `json:"records"`
+}
+
+// Append adds a record to the history, keeping it sorted by timestamp
+func (h *History) Append(r Record) {
+	h.Records = append(h.Records, r)
+	sort.SliceStable(h.Records, func(i, j int) bool {
+		return h.Records[i].Timestamp.Before(h.Records[j].Timestamp)
+	})
+}
+
+// NewRecord summarizes the file level coverage in the given CoverageList per
+// package, i.e. per directory containing the files
+func NewRecord(g *calc.CoverageList, timestamp time.Time, job string, build int, commit string) Record {
+	packages := make(map[string]PackageCoverage)
+	for _, cov := range *g.Group() {
+		dir := path.Dir(cov.Name())
+		pc := packages[dir]
+		pc.CoveredStmts += cov.CoveredStmts()
+		pc.AllStmts += cov.AllStmts()
+		packages[dir] = pc
+	}
+	return Record{
+		Timestamp: timestamp,
+		Job:       job,
+		Build:     build,
+		Commit:    commit,
+		Packages:  packages,
+	}
+}
+
+// Store loads and saves the coverage history
+type Store interface {
+	// Load returns the stored history, or an empty history if nothing is stored yet
+	Load() (*History, error)
+	// Update loads the history, applies change to it and saves it back,
+	// without losing concurrent updates
+	Update(change func(h *History)) error
+}
+
+// AppendRecord appends the record to the history of the store
+func AppendRecord(s Store, r Record) error {
+	if err := s.Update(func(h *History) { h.Append(r) }); err != nil {
+		return fmt.Errorf("failed appending to coverage history: %w", err)
+	}
+	return nil
+}
+
+func decode(content []byte) (*History, error) {
+	h := &History{}
+	if len(content) == 0 {
+		return h, nil
+	}
+	if err := json.Unmarshal(content, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// FileStore stores the history as a JSON file on local disk
+type FileStore struct {
+	Path string
+}
+
+// Load reads the history from the JSON file
+func (s *FileStore) Load() (*History, error) {
+	content, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return &History{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(content)
+}
+
+// Update updates the history in the JSON file, which is only written by a
+// single run at a time
+func (s *FileStore) Update(change func(h *History)) error {
+	h, err := s.Load()
+	if err != nil {
+		return err
+	}
+	change(h)
+	content, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.Path, content, 0644)
+}
+
+// GcsStore stores the history as a JSON object in GCS. Concurrent runs update
+// it with optimistic concurrency: the object is only written if its generation
+// didn't change since it was read, otherwise the update is retried.
+type GcsStore struct {
+	Ctx    context.Context
+	Client *storage.Client
+	Bucket string
+	Object string
+}
+
+// Load reads the history from the GCS object
+func (s *GcsStore) Load() (*History, error) {
+	content, _, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return decode(content)
+}
+
+// Update updates the history in the GCS object
+func (s *GcsStore) Update(change func(h *History)) error {
+	return update(s, change)
+}
+
+// read returns the content of the GCS object and its generation, 0 if it
+// doesn't exist yet
+func (s *GcsStore) read() ([]byte, int64, error) {
+	r, err := s.Client.Bucket(s.Bucket).Object(s.Object).NewReader(s.Ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	return content, r.Attrs.Generation, err
+}
+
+// write writes the content to the GCS object if it's still at the generation,
+// and returns errConflict otherwise
+func (s *GcsStore) write(content []byte, generation int64) error {
+	conds := storage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		conds = storage.Conditions{DoesNotExist: true}
+	}
+	w := s.Client.Bucket(s.Bucket).Object(s.Object).If(conds).NewWriter(s.Ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return err
+	}
+	err := w.Close()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return errConflict
+	}
+	return err
+}
+
+// versionedObject is an object storing the history, written only if it didn't
+// change since it was read
+type versionedObject interface {
+	read() (content []byte, generation int64, err error)
+	write(content []byte, generation int64) error
+}
+
+// update applies change to the history of the object, and retries from
+// reading it when it was concurrently written
+func update(o versionedObject, change func(h *History)) error {
+	for attempt := 1; ; attempt++ {
+		content, generation, err := o.read()
+		if err != nil {
+			return err
+		}
+		h, err := decode(content)
+		if err != nil {
+			return err
+		}
+		change(h)
+		if content, err = json.MarshalIndent(h, "", "  "); err != nil {
+			return err
+		}
+		err = o.write(content, generation)
+		if err != errConflict || attempt == maxUpdateAttempts {
+			return err
+		}
+	}
+}
+
+// NewStore returns a GcsStore if location is a gs:// URL, otherwise a FileStore.
+// The GcsStore authenticates with the service account JSON key file if set,
+// with the application default credentials otherwise.
+func NewStore(ctx context.Context, location, serviceAccount string) (Store, error) {
+	if !strings.HasPrefix(location, gcsPrefix) {
+		return &FileStore{Path: location}, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(location, gcsPrefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid GCS location %q, expecting gs://<bucket>/<object>", location)
+	}
+	var opts []option.ClientOption
+	if serviceAccount != "" {
+		opts = append(opts, option.WithCredentialsFile(serviceAccount))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GcsStore{Ctx: ctx, Client: client, Bucket: parts[0], Object: parts[1]}, nil
+}
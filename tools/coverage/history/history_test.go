@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var day0 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func record(days, build int, pkgs map[string]PackageCoverage) Record {
+	return Record{
+		Timestamp: day0.Add(time.Duration(days) * 24 * time.Hour),
+		Build:     build,
+		Packages:  pkgs,
+	}
+}
+
+func TestFileStoreAppendRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &FileStore{Path: path.Join(dir, "history.json")}
+
+	h, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() on missing file should not fail: %v", err)
+	}
+	if len(h.Records) != 0 {
+		t.Fatalf("expected empty history, got %v", h.Records)
+	}
+
+	r2 := record(2, 2, map[string]PackageCoverage{"pkg/a": {CoveredStmts: 1, AllStmts: 2}})
+	r1 := record(1, 1, map[string]PackageCoverage{"pkg/a": {CoveredStmts: 2, AllStmts: 2}})
+	for _, r := range []Record{r2, r1} {
+		if err := AppendRecord(s, r); err != nil {
+			t.Fatalf("AppendRecord() failed: %v", err)
+		}
+	}
+
+	h, err = s.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	want := []Record{r1, r2}
+	if diff := cmp.Diff(want, h.Records); diff != "" {
+		t.Errorf("records unexpected (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	s, err := NewStore(context.Background(), "/tmp/history.json", "")
+	if err != nil {
+		t.Fatalf("NewStore() failed: %v", err)
+	}
+	if _, ok := s.(*FileStore); !ok {
+		t.Errorf("expected FileStore for local path, got %T", s)
+	}
+	for _, location := range []string{"gs://", "gs://bucket", "gs:///object"} {
+		if _, err := NewStore(context.Background(), location, ""); err == nil {
+			t.Errorf("expected error for location %q", location)
+		}
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	cases := []struct {
+		pc   PackageCoverage
+		want float64
+	}{
+		{PackageCoverage{CoveredStmts: 1, AllStmts: 4}, 25},
+		{PackageCoverage{CoveredStmts: 0, AllStmts: 0}, 0},
+	}
+	for _, c := range cases {
+		if got := c.pc.Percentage(); got != c.want {
+			t.Errorf("Percentage() of %v = %v, want %v", c.pc, got, c.want)
+		}
+	}
+}
+
+// fakeObject is a versionedObject which another run writes to between the
+// first reads and writes
+type fakeObject struct {
+	content    []byte
+	generation int64
+	// concurrent are the records appended by another run after each read
+	concurrent []Record
+	writes     int
+}
+
+func (o *fakeObject) read() ([]byte, int64, error) {
+	content, generation := o.content, o.generation
+	if len(o.concurrent) != 0 {
+		h, _ := decode(o.content)
+		h.Append(o.concurrent[0])
+		o.concurrent = o.concurrent[1:]
+		o.content, _ = json.Marshal(h)
+		o.generation++
+	}
+	return content, generation, nil
+}
+
+func (o *fakeObject) write(content []byte, generation int64) error {
+	o.writes++
+	if generation != o.generation {
+		return errConflict
+	}
+	o.content = content
+	o.generation++
+	return nil
+}
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	r1 := record(1, 1, map[string]PackageCoverage{"pkg/a": {CoveredStmts: 1, AllStmts: 2}})
+	r2 := record(2, 2, map[string]PackageCoverage{"pkg/a": {CoveredStmts: 2, AllStmts: 2}})
+	r3 := record(3, 3, map[string]PackageCoverage{"pkg/a": {CoveredStmts: 2, AllStmts: 4}})
+	o := &fakeObject{concurrent: []Record{r1, r3}}
+	if err := update(o, func(h *History) { h.Append(r2) }); err != nil {
+		t.Fatalf("update() failed: %v", err)
+	}
+	if o.writes != 3 {
+		t.Errorf("expected 3 writes, the first two conflicting, got %d", o.writes)
+	}
+	h, err := decode(o.content)
+	if err != nil {
+		t.Fatalf("decode() failed: %v", err)
+	}
+	if diff := cmp.Diff([]Record{r1, r2, r3}, h.Records); diff != "" {
+		t.Errorf("records unexpected (-want +got):\n%s", diff)
+	}
+
+	o = &fakeObject{concurrent: make([]Record, maxUpdateAttempts)}
+	if err := update(o, func(h *History) { h.Append(r2) }); err != errConflict {
+		t.Errorf("expected errConflict after %d attempts, got %v", maxUpdateAttempts, err)
+	}
+}
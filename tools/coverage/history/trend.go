@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change is the coverage change of a single package between two records
+type Change struct {
+	Package string
+	// Before is the coverage in percentage points in the baseline record,
+	// nil if the package didn't exist then
+	Before *float64
+	// After is the coverage in percentage points in the latest record,
+	// nil if the package doesn't exist anymore
+	After *float64
+}
+
+// Delta returns the change in percentage points, 0 if the package was added or removed
+func (c Change) Delta() float64 {
+	if c.Before == nil || c.After == nil {
+		return 0
+	}
+	return *c.After - *c.Before
+}
+
+// Trend is the per package coverage change between a baseline and the latest record
+type Trend struct {
+	Baseline Record
+	Latest   Record
+	Changes  []Change
+}
+
+// ComputeTrend compares the latest record with the most recent record that is at
+// least window older. If there is no such record, the oldest record is used as
+// the baseline. It returns nil if there are fewer than two records.
+func ComputeTrend(h *History, window time.Duration) *Trend {
+	if len(h.Records) < 2 {
+		return nil
+	}
+	latest := h.Records[len(h.Records)-1]
+	baseline := h.Records[0]
+	cutoff := latest.Timestamp.Add(-window)
+	for _, r := range h.Records[:len(h.Records)-1] {
+		if r.Timestamp.After(cutoff) {
+			break
+		}
+		baseline = r
+	}
+
+	pkgs := make(map[string]bool)
+	for pkg := range baseline.Packages {
+		pkgs[pkg] = true
+	}
+	for pkg := range latest.Packages {
+		pkgs[pkg] = true
+	}
+	t := &Trend{Baseline: baseline, Latest: latest}
+	for pkg := range pkgs {
+		c := Change{Package: pkg}
+		if pc, ok := baseline.Packages[pkg]; ok {
+			v := pc.Percentage()
+			c.Before = &v
+		}
+		if pc, ok := latest.Packages[pkg]; ok {
+			v := pc.Percentage()
+			c.After = &v
+		}
+		t.Changes = append(t.Changes, c)
+	}
+	sort.Slice(t.Changes, func(i, j int) bool {
+		return t.Changes[i].Package < t.Changes[j].Package
+	})
+	return t
+}
+
+// Regressions returns the changes where coverage dropped more than maxDrop points
+func (t *Trend) Regressions(maxDrop float64) []Change {
+	var res []Change
+	for _, c := range t.Changes {
+		if -c.Delta() > maxDrop {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func formatPercentage(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", *v)
+}
+
+// Report returns a human readable report of the changes
+func (t *Trend) Report() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Coverage changes from build %d (%s) to build %d (%s):\n",
+		t.Baseline.Build, t.Baseline.Timestamp.Format(time.RFC3339),
+		t.Latest.Build, t.Latest.Timestamp.Format(time.RFC3339))
+	for _, c := range t.Changes {
+		fmt.Fprintf(&sb, "%s\t%s -> %s\t(%+.1f)\n", c.Package,
+			formatPercentage(c.Before), formatPercentage(c.After), c.Delta())
+	}
+	return sb.String()
+}
+
+// RegressionMessage returns the message for notifying the given regressions
+func RegressionMessage(regressions []Change, maxDrop float64) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Coverage of %d package(s) dropped more than %.1f points:\n", len(regressions), maxDrop)
+	for _, c := range regressions {
+		fmt.Fprintf(&sb, "- `%s`: %s -> %s (%+.1f)\n", c.Package,
+			formatPercentage(c.Before), formatPercentage(c.After), c.Delta())
+	}
+	return sb.String()
+}
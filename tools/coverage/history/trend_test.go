@@ -0,0 +1,83 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const week = 7 * 24 * time.Hour
+
+func testHistory() *History {
+	h := &History{}
+	h.Append(record(0, 1, map[string]PackageCoverage{
+		"pkg/a": {CoveredStmts: 80, AllStmts: 100},
+		"pkg/b": {CoveredStmts: 50, AllStmts: 100},
+	}))
+	h.Append(record(3, 2, map[string]PackageCoverage{
+		"pkg/a": {CoveredStmts: 10, AllStmts: 100},
+		"pkg/b": {CoveredStmts: 10, AllStmts: 100},
+	}))
+	h.Append(record(9, 3, map[string]PackageCoverage{
+		"pkg/a": {CoveredStmts: 70, AllStmts: 100},
+		"pkg/b": {CoveredStmts: 60, AllStmts: 100},
+		"pkg/c": {CoveredStmts: 1, AllStmts: 2},
+	}))
+	return h
+}
+
+func TestComputeTrend(t *testing.T) {
+	tr := ComputeTrend(testHistory(), week)
+	if tr == nil {
+		t.Fatal("ComputeTrend() returned nil")
+	}
+	// Build 2 is within the window, so build 1 is the baseline
+	if tr.Baseline.Build != 1 || tr.Latest.Build != 3 {
+		t.Fatalf("expected builds 1 -> 3, got %d -> %d", tr.Baseline.Build, tr.Latest.Build)
+	}
+	deltas := map[string]float64{}
+	for _, c := range tr.Changes {
+		deltas[c.Package] = c.Delta()
+	}
+	want := map[string]float64{"pkg/a": -10, "pkg/b": 10, "pkg/c": 0}
+	for pkg, d := range want {
+		if got, ok := deltas[pkg]; !ok || got != d {
+			t.Errorf("delta of %s = %v, want %v", pkg, got, d)
+		}
+	}
+
+	if tr := ComputeTrend(&History{Records: testHistory().Records[:1]}, week); tr != nil {
+		t.Errorf("expected nil trend for a single record, got %v", tr)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	tr := ComputeTrend(testHistory(), week)
+	if got := tr.Regressions(10); len(got) != 0 {
+		t.Errorf("expected no regressions above 10 points, got %v", got)
+	}
+	got := tr.Regressions(5)
+	if len(got) != 1 || got[0].Package != "pkg/a" {
+		t.Fatalf("expected pkg/a to regress more than 5 points, got %v", got)
+	}
+	msg := RegressionMessage(got, 5)
+	if !strings.Contains(msg, "`pkg/a`: 80.0% -> 70.0% (-10.0)") {
+		t.Errorf("unexpected regression message: %s", msg)
+	}
+}
@@ -16,16 +16,22 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
+	"knative.dev/test-infra/pkg/slackutil"
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/gcs"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
 	"knative.dev/test-infra/tools/coverage/githubUtil/githubPr"
+	"knative.dev/test-infra/tools/coverage/history"
 	"knative.dev/test-infra/tools/coverage/logUtil"
+	"knative.dev/test-infra/tools/coverage/modules"
 	"knative.dev/test-infra/tools/coverage/testgrid"
 )
 
@@ -38,6 +44,9 @@ const (
 	defaultCovThreshold        = 50
 	defaultArtifactsDir        = "./artifacts/"
 	defaultCoverageProfileName = "coverage_profile.txt"
+	defaultTrendWindow         = 7 * 24 * time.Hour
+	defaultMaxCoverageDrop     = 5
+	defaultSlackUserName       = "Knative Coverage Robot"
 )
 
 func main() {
@@ -56,6 +65,16 @@ func main() {
 	githubTokenPath := flag.String("github-token", "", "path to token to access github repo")
 	covThreshold := flag.Int("cov-threshold-percentage", defaultCovThreshold, "token to access GitHub repo")
 	postingBotUserName := flag.String("posting-robot", "knative-metrics-robot", "github user name for coverage robot")
+	historyLocation := flag.String("history", "", "local path or gs://<bucket>/<object> of the coverage history JSON file, "+
+		"postsubmit and periodic runs append per-package coverage to it")
+	serviceAccount := flag.String("service-account", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "JSON key file for the GCS service account of the coverage history")
+	reportTrend := flag.Bool("report-trend", false, "report per-package coverage changes from the history instead of running coverage")
+	trendWindow := flag.Duration("trend-window", defaultTrendWindow, "time window for comparing coverage in the trend report")
+	maxCoverageDrop := flag.Float64("max-coverage-drop", defaultMaxCoverageDrop, "max coverage drop of a package in percentage points before it's a regression")
+	failOnRegression := flag.Bool("fail-on-regression", false, "fail the trend report if any package regressed")
+	slackChannel := flag.String("slack-channel", "", "slack channel to notify about coverage regressions")
+	slackTokenPath := flag.String("slack-token", "", "path to token for posting to slack")
+	slackUserName := flag.String("slack-user", defaultSlackUserName, "slack user name for posting coverage regressions")
 	flag.Parse()
 
 	log.Printf("container flag list: postsubmit-gcs-bucket=%s; postSubmitJobName=%s; "+
@@ -64,6 +83,12 @@ func main() {
 		*gcsBucketName, *postSubmitJobName, *artifactsDir, *coverageTargetDir, *coverageProfileName,
 		*githubTokenPath, *covThreshold, *postingBotUserName)
 
+	if *reportTrend {
+		runTrendReport(*historyLocation, *serviceAccount, *trendWindow, *maxCoverageDrop, *failOnRegression,
+			*slackUserName, *slackTokenPath, *slackChannel)
+		return
+	}
+
 	log.Println("Getting env values")
 	pr := os.Getenv("PULL_NUMBER")
 	pullSha := os.Getenv("PULL_PULL_SHA")
@@ -82,7 +107,18 @@ func main() {
 		defaultStdoutRedirect,
 	)
 
-	localArtifacts.ProduceProfileFile(*coverageTargetDir)
+	mods, err := modules.Discover(".")
+	if err != nil {
+		log.Fatalf("Failed discovering go modules: %v", err)
+	}
+	if len(mods) == 0 {
+		log.Println("No go module found, mapping coverage profile paths through GOPATH")
+		localArtifacts.ProduceProfileFile(*coverageTargetDir)
+	} else {
+		log.Printf("Found go modules: %v", mods)
+		githubUtil.SetModules(mods)
+		localArtifacts.ProduceModulesProfileFile(*coverageTargetDir, mods)
+	}
 
 	log.Printf("Running workflow: %s\n", jobType)
 	switch jobType {
@@ -120,8 +156,44 @@ func main() {
 		}
 	case "periodic":
 		log.Printf("job type is %v, producing testsuite xml...\n", jobType)
-		testgrid.ProfileToTestsuiteXML(localArtifacts, *covThreshold)
+		testgrid.ProfileToTestsuiteXML(localArtifacts, *covThreshold, mods)
+	}
+
+	if *historyLocation != "" && (jobType == "periodic" || jobType == "postsubmit") {
+		store, err := history.NewStore(context.Background(), *historyLocation, *serviceAccount)
+		if err != nil {
+			log.Fatalf("Failed creating coverage history store: %v", err)
+		}
+		build, _ := strconv.Atoi(os.Getenv("BUILD_NUMBER"))
+		if err := RecordHistory(store, localArtifacts, *covThreshold, jobName, build, baseSha); err != nil {
+			log.Fatalf("Failed recording coverage history: %v", err)
+		}
 	}
 
 	fmt.Println("end of code coverage main")
 }
+
+func runTrendReport(historyLocation, serviceAccount string, window time.Duration, maxDrop float64, failOnRegression bool,
+	slackUserName, slackTokenPath, slackChannel string) {
+	if historyLocation == "" {
+		log.Fatal("--history must be set for --report-trend")
+	}
+	store, err := history.NewStore(context.Background(), historyLocation, serviceAccount)
+	if err != nil {
+		log.Fatalf("Failed creating coverage history store: %v", err)
+	}
+	var slackClient slackutil.WriteOperations
+	if slackChannel != "" {
+		if slackClient, err = slackutil.NewWriteClient(slackUserName, slackTokenPath); err != nil {
+			log.Fatalf("Failed creating slack client: %v", err)
+		}
+	}
+	regressed, err := RunTrendReport(store, window, maxDrop, slackClient, slackChannel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if regressed && failOnRegression {
+		logUtil.LogFatalf("Coverage of some packages dropped more than %.1f points, "+
+			"fail trend report intentionally", maxDrop)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package modules discovers the go modules in a repository, and maps the file
+// paths in coverage profiles, which are prefixed with the module path, to file
+// paths in the repository
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Module is a go module in the repository
+type Module struct {
+	// Path is the module path declared in go.mod
+	Path string
+	// Dir is the directory of the module, relative to the repository root
+	Dir string
+}
+
+// skippedDir returns true for directories that are ignored by the go tool,
+// or that contain third party modules
+func skippedDir(name string) bool {
+	return name == "vendor" || name == "third_party" || name == "testdata" ||
+		strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// Discover finds all go modules under root, sorted by directory
+func Discover(root string) ([]Module, error) {
+	var mods []Module
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if p != root && skippedDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "go.mod" {
+			return nil
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		modPath := modfile.ModulePath(content)
+		if modPath == "" {
+			return fmt.Errorf("no module path declared in %q", p)
+		}
+		dir, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		mods = append(mods, Module{Path: modPath, Dir: filepath.ToSlash(dir)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(mods, func(i, j int) bool {
+		return mods[i].Dir < mods[j].Dir
+	})
+	return mods, nil
+}
+
+// ForProfilePath returns the module that a file path in coverage profile belongs
+// to, which is the module with the longest matching module path
+func ForProfilePath(mods []Module, file string) (Module, bool) {
+	var res Module
+	found := false
+	for _, m := range mods {
+		if strings.HasPrefix(file, m.Path+"/") && len(m.Path) > len(res.Path) {
+			res = m
+			found = true
+		}
+	}
+	return res, found
+}
+
+// RepoPath converts a file path in coverage profile to the path relative to the
+// repository root
+func RepoPath(mods []Module, file string) (string, bool) {
+	m, ok := ForProfilePath(mods, file)
+	if !ok {
+		return "", false
+	}
+	return path.Join(m.Dir, strings.TrimPrefix(file, m.Path+"/")), true
+}
+
+// isWithin returns true if dir is the same as, or a subdirectory of, parent.
+// Both are relative to the repository root.
+func isWithin(dir, parent string) bool {
+	dir, parent = path.Clean(dir), path.Clean(parent)
+	return parent == "." || dir == parent || strings.HasPrefix(dir, parent+"/")
+}
+
+// forDir returns the innermost module containing dir
+func forDir(mods []Module, dir string) (Module, bool) {
+	var res Module
+	found := false
+	for _, m := range mods {
+		if isWithin(dir, m.Dir) && (!found || len(path.Clean(m.Dir)) > len(path.Clean(res.Dir))) {
+			res = m
+			found = true
+		}
+	}
+	return res, found
+}
+
+// Targets returns the go test package patterns, relative to the directory of
+// module m, that cover the given coverage targets in m. mods are all the
+// modules in the repository, so that targets in nested modules are excluded.
+func Targets(mods []Module, m Module, covTargets []string) []string {
+	var res []string
+	for _, target := range covTargets {
+		if isWithin(m.Dir, target) {
+			return []string{"./..."}
+		}
+		if owner, ok := forDir(mods, target); ok && owner == m {
+			rel := path.Clean(target)
+			if m.Dir != "." {
+				rel = strings.TrimPrefix(rel, path.Clean(m.Dir)+"/")
+			}
+			res = append(res, "./"+path.Join(rel, "..."))
+		}
+	}
+	return res
+}
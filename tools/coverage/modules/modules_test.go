@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testModules = []Module{
+	{Path: "knative.dev/repo", Dir: "."},
+	{Path: "knative.dev/repo/nested", Dir: "nested"},
+	{Path: "knative.dev/tool", Dir: "tools/tool"},
+}
+
+func writeGoMod(t *testing.T, root, dir, modPath string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("module " + modPath + "\n\ngo 1.15\n")
+	if err := ioutil.WriteFile(filepath.Join(root, dir, "go.mod"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root, err := ioutil.TempDir("", "modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeGoMod(t, root, ".", "knative.dev/repo")
+	writeGoMod(t, root, "nested", "knative.dev/repo/nested")
+	writeGoMod(t, root, "tools/tool", "knative.dev/tool")
+	writeGoMod(t, root, "vendor/github.com/foo", "github.com/foo")
+	writeGoMod(t, root, "third_party/bar", "github.com/bar")
+	writeGoMod(t, root, "pkg/testdata/baz", "github.com/baz")
+
+	got, err := Discover(root)
+	if err != nil {
+		t.Fatalf("Discover() failed: %v", err)
+	}
+	if diff := cmp.Diff(testModules, got); diff != "" {
+		t.Errorf("Discover() unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestRepoPath(t *testing.T) {
+	cases := []struct {
+		file   string
+		want   string
+		wantOk bool
+	}{
+		{"knative.dev/repo/pkg/a.go", "pkg/a.go", true},
+		{"knative.dev/repo/nested/pkg/a.go", "nested/pkg/a.go", true},
+		{"knative.dev/repo/nestedfoo/a.go", "nestedfoo/a.go", true},
+		{"knative.dev/tool/cmd/main.go", "tools/tool/cmd/main.go", true},
+		{"github.com/other/a.go", "", false},
+	}
+	for _, c := range cases {
+		got, ok := RepoPath(testModules, c.file)
+		if got != c.want || ok != c.wantOk {
+			t.Errorf("RepoPath(%q) = %q, %v, want %q, %v", c.file, got, ok, c.want, c.wantOk)
+		}
+	}
+}
+
+func TestTargets(t *testing.T) {
+	cases := []struct {
+		name       string
+		covTargets []string
+		want       [][]string
+	}{{
+		name:       "repo root",
+		covTargets: []string{"."},
+		want:       [][]string{{"./..."}, {"./..."}, {"./..."}},
+	}, {
+		name:       "directories",
+		covTargets: []string{"pkg", "nested/pkg", "tools"},
+		want:       [][]string{{"./pkg/...", "./tools/..."}, {"./pkg/..."}, {"./..."}},
+	}, {
+		name:       "module directory",
+		covTargets: []string{"nested"},
+		want:       [][]string{nil, {"./..."}, nil},
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for i, m := range testModules {
+				got := Targets(testModules, m, c.covTargets)
+				if diff := cmp.Diff(c.want[i], got); diff != "" {
+					t.Errorf("Targets(%s) unexpected result (-want +got):\n%s", m.Path, diff)
+				}
+			}
+		})
+	}
+}
@@ -25,8 +25,11 @@ import (
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/calc"
 	"knative.dev/test-infra/tools/coverage/logUtil"
+	"knative.dev/test-infra/tools/coverage/modules"
 )
 
+const overallTestName = "OVERALL"
+
 // NewTestCase constructs the TestCase struct
 func NewTestCase(targetName, coverage string, failure bool) junit.TestCase {
 	tc := junit.TestCase{
@@ -49,12 +52,18 @@ func NewTestCase(targetName, coverage string, failure bool) junit.TestCase {
 // toTestsuite populates Testsuite struct with data from CoverageList and actual file
 // directories from OS
 func toTestsuite(g *calc.CoverageList, dirs []string) *junit.TestSuite {
+	return toNamedTestsuite("", overallTestName, g, dirs)
+}
+
+// toNamedTestsuite populates Testsuite struct with the given name, and the
+// overall coverage of the CoverageList reported as overallName
+func toNamedTestsuite(name, overallName string, g *calc.CoverageList, dirs []string) *junit.TestSuite {
 	g.Summarize()
 	covThresInt := g.CovThresInt()
-	ts := junit.TestSuite{}
+	ts := junit.TestSuite{Name: name}
 
 	// Add overall coverage
-	ts.AddTestCase(NewTestCase("OVERALL", g.PercentageForTestgrid(), g.IsCoverageLow(covThresInt)))
+	ts.AddTestCase(NewTestCase(overallName, g.PercentageForTestgrid(), g.IsCoverageLow(covThresInt)))
 
 	fmt.Println("")
 	log.Println("Constructing Testsuite Struct for Testgrid")
@@ -85,9 +94,29 @@ func toTestsuite(g *calc.CoverageList, dirs []string) *junit.TestSuite {
 	return &ts
 }
 
+// toModuleTestsuites populates one Testsuite for the overall coverage, and one
+// Testsuite per module, containing the coverage of the files and directories
+// in the module
+func toModuleTestsuites(g *calc.CoverageList, mods []modules.Module) []*junit.TestSuite {
+	g.Summarize()
+	overall := junit.TestSuite{}
+	overall.AddTestCase(NewTestCase(overallTestName, g.PercentageForTestgrid(), g.IsCoverageLow(g.CovThresInt())))
+	res := []*junit.TestSuite{&overall}
+	for _, m := range mods {
+		modCov := g.SubsetOfModule(mods, m)
+		if modCov.Size() == 0 {
+			log.Printf("Skipping module %s as it has no files with coverage data.\n", m.Path)
+			continue
+		}
+		res = append(res, toNamedTestsuite(m.Path, overallTestName+" "+m.Path, modCov, modCov.GetDirs()))
+	}
+	return res
+}
+
 // ProfileToTestsuiteXML uses coverage profile (and it's corresponding stdout) to produce junit xml
-// which serves as the input for test coverage testgrid
-func ProfileToTestsuiteXML(arts *artifacts.LocalArtifacts, covThres int) {
+// which serves as the input for test coverage testgrid. If there is more than one module,
+// the coverage is grouped into one testsuite per module.
+func ProfileToTestsuiteXML(arts *artifacts.LocalArtifacts, covThres int, mods []modules.Module) {
 	groupCov := calc.CovList(
 		artifacts.NewProfileReader(arts.ProfileReader()),
 		nil,
@@ -101,7 +130,13 @@ func ProfileToTestsuiteXML(arts *artifacts.LocalArtifacts, covThres int) {
 	defer f.Close()
 
 	suites := junit.TestSuites{}
-	suites.AddTestSuite(toTestsuite(groupCov, groupCov.GetDirs()))
+	if len(mods) > 1 {
+		for _, ts := range toModuleTestsuites(groupCov, mods) {
+			suites.AddTestSuite(ts)
+		}
+	} else {
+		suites.AddTestSuite(toTestsuite(groupCov, groupCov.GetDirs()))
+	}
 	output, err := suites.ToBytes("", "    ")
 	if err != nil {
 		logUtil.LogFatalf("error: %v\n", err)
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"knative.dev/test-infra/pkg/slackutil"
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/history"
+)
+
+// RecordHistory appends the per-package coverage of the local profile to the history store
+func RecordHistory(store history.Store, arts *artifacts.LocalArtifacts, covThreshold int,
+	job string, build int, commit string) error {
+	log.Println("starting RecordHistory(...)")
+	groupCov := calc.CovList(
+		artifacts.NewProfileReader(arts.ProfileReader()),
+		nil,
+		nil,
+		covThreshold,
+	)
+	record := history.NewRecord(groupCov, time.Now().UTC(), job, build, commit)
+	return history.AppendRecord(store, record)
+}
+
+// RunTrendReport prints the coverage changes within window, and notifies the
+// given Slack channel if any package dropped more than maxDrop points.
+// It returns true if there is any such regression.
+func RunTrendReport(store history.Store, window time.Duration, maxDrop float64,
+	slackClient slackutil.WriteOperations, slackChannel string) (bool, error) {
+	h, err := store.Load()
+	if err != nil {
+		return false, fmt.Errorf("failed loading coverage history: %w", err)
+	}
+	trend := history.ComputeTrend(h, window)
+	if trend == nil {
+		log.Printf("Not enough records (%d) in coverage history to compute a trend", len(h.Records))
+		return false, nil
+	}
+	fmt.Print(trend.Report())
+
+	regressions := trend.Regressions(maxDrop)
+	if len(regressions) == 0 {
+		return false, nil
+	}
+	msg := history.RegressionMessage(regressions, maxDrop)
+	log.Print(msg)
+	if slackClient != nil {
+		if err := slackClient.Post(msg, slackChannel); err != nil {
+			return true, fmt.Errorf("failed posting to Slack channel %q: %w", slackChannel, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/pkg/slackutil/fakeslackutil"
+	"knative.dev/test-infra/tools/coverage/history"
+)
+
+func TestRunTrendReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-trend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &history.FileStore{Path: path.Join(dir, "history.json")}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, covered := range []int{90, 50} {
+		r := history.Record{
+			Timestamp: start.Add(time.Duration(i) * 7 * 24 * time.Hour),
+			Build:     i,
+			Packages:  map[string]history.PackageCoverage{"pkg/a": {CoveredStmts: covered, AllStmts: 100}},
+		}
+		if err := history.AppendRecord(store, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	slack := fakeslackutil.NewFakeSlackClient()
+	regressed, err := RunTrendReport(store, 7*24*time.Hour, 5, slack, "coverage")
+	if err != nil {
+		t.Fatalf("RunTrendReport() failed: %v", err)
+	}
+	if !regressed {
+		t.Error("expected a regression to be reported")
+	}
+	if msgs := slack.History["coverage"]; len(msgs) != 1 {
+		t.Errorf("expected 1 message posted to Slack, got %d", len(msgs))
+	}
+
+	regressed, err = RunTrendReport(store, 7*24*time.Hour, 50, nil, "")
+	if err != nil || regressed {
+		t.Errorf("expected no regression above 50 points, got %v, %v", regressed, err)
+	}
+}
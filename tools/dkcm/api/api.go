@@ -0,0 +1,43 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package api defines the responses of the DKCM server, shared by the server
+// and its clients so that the clients don't link the server and its database
+// drivers.
+package api
+
+import "time"
+
+// ClusterInfo is the struct to return(end product) to Prow once the cluster is available
+type ClusterInfo struct {
+	ClusterName string `json:"clusterName"`
+	ProjectID   string `json:"projectID"`
+	Zone        string `json:"zone"`
+}
+
+// ServiceResponse is returned to Prow when getting the cluster of a request.
+type ServiceResponse struct {
+	IsReady     bool         `json:"isReady"`
+	Message     string       `json:"message"`
+	ClusterInfo *ClusterInfo `json:"clusterInfo"`
+	// LeaseExpiry is when the cluster is reclaimed unless the lease is renewed
+	LeaseExpiry *time.Time `json:"leaseExpiry,omitempty"`
+}
+
+// LeaseResponse is returned to Prow when renewing the lease of a cluster.
+type LeaseResponse struct {
+	LeaseExpiry time.Time `json:"leaseExpiry"`
+}
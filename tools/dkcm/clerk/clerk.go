@@ -21,35 +21,42 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/uuid"
 
 	"knative.dev/test-infra/pkg/mysql"
+	"knative.dev/test-infra/tools/dkcm/api"
+)
+
+const (
+	// columns of the tables, in the order they are scanned
+	clusterColumns    = "ID, ProjectID, Status, Zone, Nodes, NodeType"
+	requestColumns    = "ID, AccessToken, RequestTime, Zone, Nodes, NodeType, ProwJobID, ClusterID, LeaseExpiry"
+	leaseEventColumns = "ID, RequestID, ClusterID, ProjectID, ProwJobID, Event, EventTime, Message"
 )
 
 type Operations interface {
 	// check cluster available, if available, return cluster id and access token
-	CheckAvail(cp ClusterParams) (bool, int64)
+	CheckAvail(cp *ClusterParams) (bool, int64)
 	// check number of clusters of a status specific configurations
 	CheckNumStatus(cp *ClusterParams, status string) int64
 	// get with cluster id stored in the Cluster database
-	GetCluster(clusterID int64) (Response, error)
+	GetCluster(clusterID int64) (*api.ClusterInfo, error)
 	// delete a cluster entry
 	DeleteCluster(clusterID int64) error
 	// Insert a cluster entry
 	InsertCluster(c *Cluster) (int64, error)
 	// Update a cluster entry
 	UpdateCluster(clusterID int64, opts ...UpdateOption) error
+	// Change the status of a cluster entry only if it still has the given status
+	ClaimCluster(clusterID int64, from, to string) (bool, error)
 	// List clutsers (use for checking after downtime to see stale clusters)
 	ListClusters() ([]Cluster, error)
 	// get with accessToken stored in the Request database
-	GetRequest(accessToken string) (Request, error)
-	// Generate Unique access token for Prow
-	generateToken() string
-	// Insert a request entry
-	InsertRequest(r *Request) error
+	GetRequest(accessToken string) (*Request, error)
+	// Insert a request entry, and return the unique access token generated for Prow
+	InsertRequest(r *Request) (string, error)
 	// Update a request entry
 	UpdateRequest(requestID int64, opts ...UpdateOption) error
 	// List requests within a time interval (use for checking after downtime to see stale requests)
@@ -58,8 +65,16 @@ type Operations interface {
 	PriorityRanking(r *Request) int64
 	// Detect Timeout for requests and mark clusterID as -1 for timeout
 	ClearTimeOut(timeOut time.Duration) error
+	// List the requests assigned a cluster whose lease expired before now
+	ListExpiredLeases(now time.Time) ([]Request, error)
+	// Insert a lease event entry
+	InsertLeaseEvent(e *LeaseEvent) error
+	// List lease events within a time interval
+	ListLeaseEvents(window time.Duration) ([]LeaseEvent, error)
 }
 
+var _ Operations = (*DBClient)(nil)
+
 // compatible with both Row and Rows and unit test friendly
 type scannable interface {
 	Scan(dest ...interface{}) error
@@ -68,6 +83,7 @@ type scannable interface {
 // DBClient holds an active database connection. This implements all the fuunctions of Operations.
 type DBClient struct {
 	*sql.DB
+	dialect dialect
 }
 
 // consumer facing request display
@@ -76,19 +92,6 @@ func (r Request) String() string {
 		r.requestTime, r.Nodes, r.NodeType, r.ProwJobID, r.Zone)
 }
 
-// generate a list of strings that could be used in a query for ClusterParams
-func (cp *ClusterParams) generateParamsConditions(opts ...QueryClusterParamsOption) []string {
-	var fieldStatements []string
-	for _, opt := range opts {
-		fieldStatements = append(fieldStatements, opt(cp))
-	}
-	return fieldStatements
-}
-
-func generateAND(fieldStatements []string) string {
-	return strings.Join(fieldStatements, " AND ")
-}
-
 // Populate fields of Cluster
 func populateCluster(sc scannable) (*Cluster, error) {
 	c := &Cluster{ClusterParams: &ClusterParams{}}
@@ -99,23 +102,39 @@ func populateCluster(sc scannable) (*Cluster, error) {
 // Populate fields of Request
 func populateRequest(sc scannable) (*Request, error) {
 	r := &Request{ClusterParams: &ClusterParams{}}
-	err := sc.Scan(&r.ID, &r.accessToken, &r.requestTime, &r.Zone, &r.Nodes, &r.NodeType, &r.ProwJobID, &r.ClusterID)
+	var leaseExpiry sql.NullTime
+	err := sc.Scan(&r.ID, &r.accessToken, &r.requestTime, &r.Zone, &r.Nodes, &r.NodeType, &r.ProwJobID, &r.ClusterID, &leaseExpiry)
+	r.LeaseExpiry = leaseExpiry.Time
 	return r, err
 }
 
-// NewDB returns the DB object with an active database connection
+// NewDB returns the DB object with an active connection to the MySQL database,
+// after applying the pending migrations
 func NewDB(c *mysql.DBConfig) (*DBClient, error) {
 	db, err := c.Connect()
-	return &DBClient{db}, err
+	if err != nil {
+		return nil, err
+	}
+	return newDBClient(db, mysqlDialect)
+}
+
+// newDBClient returns the DB object for the connection, after applying the pending migrations
+func newDBClient(db *sql.DB, d dialect) (*DBClient, error) {
+	client := &DBClient{DB: db, dialect: d}
+	if err := client.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return client, nil
 }
 
 // check available clusters in Cluster db and return zone and projectID that would be used by ProwJob
 func (db *DBClient) CheckAvail(cp *ClusterParams) (bool, int64) {
 	// getting query string ready
-	conditions := generateAND(cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType()))
-	queryString := fmt.Sprintf("SELECT * FROM Clusters WHERE Status = 'Ready' AND %s", conditions)
+	conditions, args := cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())
+	queryString := fmt.Sprintf("SELECT %s FROM Clusters WHERE Status = 'Ready' AND %s", clusterColumns, generateAND(conditions))
 	// check whether available cluster exists
-	row := db.QueryRow(queryString)
+	row := db.QueryRow(queryString, args...)
 	c, err := populateCluster(row)
 	// no available cluster is found
 	if err != nil {
@@ -128,10 +147,9 @@ func (db *DBClient) CheckAvail(cp *ClusterParams) (bool, int64) {
 func (db *DBClient) CheckNumStatus(cp *ClusterParams, status string) int64 {
 	var count int64
 	// getting query string ready
-	conditions := generateAND(cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType()))
-	queryString := fmt.Sprintf("SELECT COUNT(*) FROM Clusters WHERE Status = '%s' AND %s", status, conditions)
-	log.Printf("query string is: %s", queryString)
-	err := db.QueryRow(queryString).Scan(&count)
+	conditions, args := cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())
+	queryString := fmt.Sprintf("SELECT COUNT(*) FROM Clusters WHERE Status = ? AND %s", generateAND(conditions))
+	err := db.QueryRow(queryString, append([]interface{}{status}, args...)...).Scan(&count)
 	if err != nil {
 		log.Printf("error is: %v", err)
 		return 0
@@ -141,7 +159,6 @@ func (db *DBClient) CheckNumStatus(cp *ClusterParams, status string) int64 {
 
 // insert a cluster entry into db
 func (db *DBClient) InsertCluster(c *Cluster) (int64, error) {
-	// query only rows that haven't been assigned a cluster and of the same config
 	stmt, err := db.Prepare(`INSERT INTO Clusters(Nodes, NodeType, Zone, ProjectID)
 							VALUES (?,?,?,?)`)
 	if err != nil {
@@ -155,30 +172,40 @@ func (db *DBClient) InsertCluster(c *Cluster) (int64, error) {
 	return res.LastInsertId()
 }
 
-func updateQueryString(dbName string, id int64, opts ...UpdateOption) string {
-	var fieldStatements []string
-	for _, opt := range opts {
-		fieldStatements = append(fieldStatements, opt())
-	}
-	return fmt.Sprintf("UPDATE %s SET %s WHERE ID = %d", dbName, strings.Join(fieldStatements, ","), id)
-}
-
 // UpdateCluster updates a cluster entry on different fields.
 func (db *DBClient) UpdateCluster(clusterID int64, opts ...UpdateOption) error {
-	queryString := updateQueryString(ClusterDB, clusterID, opts...)
-	_, err := db.Exec(queryString)
+	queryString, args, err := updateQueryString(ClusterDB, clusterID, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(queryString, args...)
 	return err
 }
 
+// ClaimCluster changes the status of the cluster from one status to another in
+// a single statement, and tells whether it did. It returns false if the
+// cluster doesn't have the from status anymore, e.g. because another caller
+// claimed it first.
+func (db *DBClient) ClaimCluster(clusterID int64, from, to string) (bool, error) {
+	res, err := db.Exec("UPDATE Clusters SET Status = ? WHERE ID = ? AND Status = ?", to, clusterID, from)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("fail to get rows affected: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
 // ListClusters lists all the clusters.
 func (db *DBClient) ListClusters() ([]Cluster, error) {
 	var result []Cluster
-	rows, err := db.Query(`
-	SELECT ID, Nodes, NodeType, ProjectID, Status, Zone
-	FROM Clusters`)
+	rows, err := db.Query("SELECT " + clusterColumns + " FROM Clusters")
 	if err != nil {
 		return result, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		c, err := populateCluster(rows)
 		if err != nil {
@@ -186,8 +213,7 @@ func (db *DBClient) ListClusters() ([]Cluster, error) {
 		}
 		result = append(result, *c)
 	}
-	return result, nil
-
+	return result, rows.Err()
 }
 
 // DeleteCluster deletes a row from Cluster db
@@ -212,16 +238,16 @@ func (db *DBClient) generateToken() string {
 }
 
 // assumption: get cluster only when it is ready, the clusterid that shows up on request db
-func (db *DBClient) GetCluster(clusterID int64) (*Response, error) {
+func (db *DBClient) GetCluster(clusterID int64) (*api.ClusterInfo, error) {
 	// check in with token
-	queryString := "SELECT * FROM Clusters WHERE ID = ?"
+	queryString := "SELECT " + clusterColumns + " FROM Clusters WHERE ID = ?"
 	row := db.QueryRow(queryString, clusterID)
 	c, err := populateCluster(row)
 	if err != nil {
-		return &Response{}, err
+		return &api.ClusterInfo{}, err
 	}
 	clusterName := nameCluster(clusterID)
-	cc := &Response{ClusterName: clusterName, ProjectID: c.ProjectID, Zone: c.Zone}
+	cc := &api.ClusterInfo{ClusterName: clusterName, ProjectID: c.ProjectID, Zone: c.Zone}
 	return cc, err
 }
 
@@ -253,21 +279,24 @@ func (db *DBClient) InsertRequest(r *Request) (string, error) {
 	}
 	defer stmt.Close()
 	accessToken := db.generateToken()
-	_, err = stmt.Exec(accessToken, r.requestTime, r.ProwJobID, r.Nodes, r.NodeType, r.Zone)
+	_, err = stmt.Exec(accessToken, r.requestTime.UTC(), r.ProwJobID, r.Nodes, r.NodeType, r.Zone)
 	return accessToken, err
 }
 
 // Update a request entry
 func (db *DBClient) UpdateRequest(requestID int64, opts ...UpdateOption) error {
-	queryString := updateQueryString(RequestDB, requestID, opts...)
-	_, err := db.Exec(queryString)
+	queryString, args, err := updateQueryString(RequestDB, requestID, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(queryString, args...)
 	return err
 }
 
 // Get a request row in Request db by accessToken
 func (db *DBClient) GetRequest(accessToken string) (*Request, error) {
 	// check in with token
-	queryString := "SELECT * FROM Requests WHERE AccessToken = ?"
+	queryString := "SELECT " + requestColumns + " FROM Requests WHERE AccessToken = ?"
 	row := db.QueryRow(queryString, accessToken)
 	r, err := populateRequest(row)
 	if err != nil {
@@ -279,14 +308,12 @@ func (db *DBClient) GetRequest(accessToken string) (*Request, error) {
 // List requests within a time interval (use for checking after downtime to see stale requests)
 func (db *DBClient) ListRequests(window time.Duration) ([]Request, error) {
 	var result []Request
-	startTime := time.Now().Add(-1 * window)
-	rows, err := db.Query(`
-	SELECT *
-	FROM Requests
-	WHERE RequestTime > ?`, startTime)
+	startTime := time.Now().Add(-1 * window).UTC()
+	rows, err := db.Query("SELECT "+requestColumns+" FROM Requests WHERE RequestTime > ?", startTime)
 	if err != nil {
 		return result, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		r, err := populateRequest(rows)
 		if err != nil {
@@ -294,18 +321,17 @@ func (db *DBClient) ListRequests(window time.Duration) ([]Request, error) {
 		}
 		result = append(result, *r)
 	}
-	return result, nil
+	return result, rows.Err()
 }
 
 // rank request priority so that available clusters are always assigned to the requests that come first
 func (db *DBClient) PriorityRanking(r *Request) int64 {
 	var rank int64
 	// getting query string ready
-	conditions := generateAND(r.ClusterParams.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType()))
+	conditions, args := r.ClusterParams.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())
 	// query only rows that haven't been assigned a cluster and of the same config
-	queryString := fmt.Sprintf("Select Rnk From (SELECT ID, RANK() OVER (ORDER BY RequestTime) Rnk FROM Requests WHERE ClusterID = 0 AND %s) Ranking WHERE Ranking.ID = %d", conditions, r.ID)
-	log.Printf("query string is: %s", queryString)
-	err := db.QueryRow(queryString).Scan(&rank)
+	queryString := fmt.Sprintf("SELECT Rnk FROM (SELECT ID, RANK() OVER (ORDER BY RequestTime) Rnk FROM Requests WHERE ClusterID = 0 AND %s) Ranking WHERE Ranking.ID = ?", generateAND(conditions))
+	err := db.QueryRow(queryString, append(args, r.ID)...).Scan(&rank)
 	if err != nil {
 		log.Printf("Got an error in the query: %v", err)
 		return math.MaxInt64
@@ -315,8 +341,26 @@ func (db *DBClient) PriorityRanking(r *Request) int64 {
 
 // disable request due to timeout
 func (db *DBClient) ClearTimeOut(timeOut time.Duration) error {
-	startTime := time.Now().Add(-1 * timeOut)
+	startTime := time.Now().Add(-1 * timeOut).UTC()
 	queryString := "UPDATE Requests SET ClusterID = -1 WHERE RequestTime <= ? AND ClusterID = 0"
 	_, err := db.Exec(queryString, startTime)
 	return err
 }
+
+// List the requests assigned a cluster whose lease expired before now
+func (db *DBClient) ListExpiredLeases(now time.Time) ([]Request, error) {
+	var result []Request
+	rows, err := db.Query("SELECT "+requestColumns+" FROM Requests WHERE ClusterID > 0 AND LeaseExpiry <= ?", now.UTC())
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := populateRequest(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, *r)
+	}
+	return result, rows.Err()
+}
@@ -91,19 +91,25 @@ func TestPopulateCluster(t *testing.T) {
 
 func TestGenerateAnd(t *testing.T) {
 	cases := []struct {
-		cp              *ClusterParams
-		wantResult      string
-		fieldStatements []string
+		cp         *ClusterParams
+		opts       []QueryClusterParamsOption
+		wantResult string
+		wantArgs   []interface{}
 	}{
-		{fakeClusterParams, "Zone = 'us-central1' AND Nodes = 4 AND NodeType = 'e2-standard-4'", fakeClusterParams.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())},
-		{fakeClusterParams2, "Zone = '' AND Nodes = 4 AND NodeType = 'e2-standard-4'", fakeClusterParams2.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())},
-		{fakeClusterParams3, "Nodes = 4", fakeClusterParams3.generateParamsConditions(QueryNodes())},
+		{fakeClusterParams, []QueryClusterParamsOption{QueryZone(), QueryNodes(), QueryNodeType()},
+			"Zone = ? AND Nodes = ? AND NodeType = ?", []interface{}{"us-central1", int64(4), "e2-standard-4"}},
+		{fakeClusterParams2, []QueryClusterParamsOption{QueryZone(), QueryNodes(), QueryNodeType()},
+			"Zone = ? AND Nodes = ? AND NodeType = ?", []interface{}{"", int64(4), "e2-standard-4"}},
+		{fakeClusterParams3, []QueryClusterParamsOption{QueryNodes()}, "Nodes = ?", []interface{}{int64(4)}},
 	}
 	for _, test := range cases {
-		conditions := generateAND(test.fieldStatements)
-		if conditions != test.wantResult {
+		fieldStatements, args := test.cp.generateParamsConditions(test.opts...)
+		if conditions := generateAND(fieldStatements); conditions != test.wantResult {
 			t.Errorf("GenerateAND = %q, want: %q", conditions, test.wantResult)
 		}
+		if diff := cmp.Diff(test.wantArgs, args); diff != "" {
+			t.Errorf("Query args got(+) is different from wanted(-)\n%v", diff)
+		}
 	}
 }
 
@@ -112,16 +118,33 @@ func TestUpdateQueryString(t *testing.T) {
 		dbName     string
 		id         int64
 		wantResult string
+		wantArgs   []interface{}
+		wantErr    bool
 		update     []UpdateOption
 	}{
-		{"Clusters", 1, "UPDATE Clusters SET Zone = 'us-central1' WHERE ID = 1", []UpdateOption{UpdateStringField("Zone", "us-central1")}},
-		{"Clusters", 1, "UPDATE Clusters SET Zone = 'us-central1',Nodes = 6 WHERE ID = 1", []UpdateOption{UpdateStringField("Zone", "us-central1"), UpdateNumField("Nodes", 6)}},
+		{"Clusters", 1, "UPDATE Clusters SET Zone = ? WHERE ID = ?", []interface{}{"us-central1", int64(1)}, false,
+			[]UpdateOption{UpdateStringField("Zone", "us-central1")}},
+		{"Clusters", 1, "UPDATE Clusters SET Zone = ?,Nodes = ? WHERE ID = ?", []interface{}{"us-central1", int64(6), int64(1)}, false,
+			[]UpdateOption{UpdateStringField("Zone", "us-central1"), UpdateNumField("Nodes", 6)}},
+		// values are never put into the statement
+		{"Requests", 2, "UPDATE Requests SET ProwJobID = ? WHERE ID = ?", []interface{}{"x'; DROP TABLE Requests; --", int64(2)}, false,
+			[]UpdateOption{UpdateStringField("ProwJobID", "x'; DROP TABLE Requests; --")}},
+		{"Requests", 1, "", nil, true, []UpdateOption{UpdateStringField("AccessToken", "a")}},
+		{"Clusters", 1, "", nil, true, []UpdateOption{UpdateStringField("Zone = 'a', Status", "b")}},
+		{"Unknown", 1, "", nil, true, []UpdateOption{UpdateStringField("Zone", "a")}},
+		{"Clusters", 1, "", nil, true, nil},
 	}
 	for _, test := range cases {
-		generatedString := updateQueryString(test.dbName, test.id, test.update...)
+		generatedString, args, err := updateQueryString(test.dbName, test.id, test.update...)
+		if (err != nil) != test.wantErr {
+			t.Errorf("UpdateQueryString err = %v, want error: %v", err, test.wantErr)
+		}
 		if generatedString != test.wantResult {
 			t.Errorf("UpdateQueryString = %q, want: %q", generatedString,
 				test.wantResult)
 		}
+		if diff := cmp.Diff(test.wantArgs, args); diff != "" {
+			t.Errorf("Update args got(+) is different from wanted(-)\n%v", diff)
+		}
 	}
 }
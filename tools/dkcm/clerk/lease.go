@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"fmt"
+	"time"
+)
+
+// LeaseEvent stores a row in the "LeaseEvents" table, recording a change of
+// the lease of a cluster assigned to a request.
+type LeaseEvent struct {
+	ID        int64     `json:"id"`
+	RequestID int64     `json:"requestID"`
+	ClusterID int64     `json:"clusterID"`
+	ProjectID string    `json:"projectID"`
+	ProwJobID string    `json:"prowJobID"`
+	Event     string    `json:"event"`
+	EventTime time.Time `json:"eventTime"`
+	Message   string    `json:"message"`
+}
+
+// consumer facing lease event display
+func (e LeaseEvent) String() string {
+	return fmt.Sprintf("Lease Event: (Event: %s, EventTime: %v, ProwJobID: %s, ClusterID: %d, ProjectID: %s, Message: %s)",
+		e.Event, e.EventTime, e.ProwJobID, e.ClusterID, e.ProjectID, e.Message)
+}
+
+// Populate fields of LeaseEvent
+func populateLeaseEvent(sc scannable) (*LeaseEvent, error) {
+	e := &LeaseEvent{}
+	err := sc.Scan(&e.ID, &e.RequestID, &e.ClusterID, &e.ProjectID, &e.ProwJobID, &e.Event, &e.EventTime, &e.Message)
+	return e, err
+}
+
+// insert a lease event entry
+func (db *DBClient) InsertLeaseEvent(e *LeaseEvent) error {
+	stmt, err := db.Prepare(`INSERT INTO LeaseEvents(RequestID, ClusterID, ProjectID, ProwJobID, Event, EventTime, Message)
+							VALUES (?,?,?,?,?,?,?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(e.RequestID, e.ClusterID, e.ProjectID, e.ProwJobID, e.Event, e.EventTime.UTC(), e.Message)
+	return err
+}
+
+// List lease events within a time interval, oldest first
+func (db *DBClient) ListLeaseEvents(window time.Duration) ([]LeaseEvent, error) {
+	var result []LeaseEvent
+	startTime := time.Now().Add(-1 * window).UTC()
+	rows, err := db.Query("SELECT "+leaseEventColumns+" FROM LeaseEvents WHERE EventTime > ? ORDER BY EventTime, ID", startTime)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := populateLeaseEvent(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, *e)
+	}
+	return result, rows.Err()
+}
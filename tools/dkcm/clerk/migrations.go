@@ -0,0 +1,184 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"knative.dev/test-infra/pkg/mysql"
+)
+
+// dialect is the flavor of SQL spoken by the database.
+type dialect string
+
+const (
+	mysqlDialect  dialect = "mysql"
+	sqliteDialect dialect = "sqlite3"
+)
+
+// idColumn returns the definition of an auto incremented primary key column.
+func (d dialect) idColumn() string {
+	if d == sqliteDialect {
+		return "ID INTEGER PRIMARY KEY AUTOINCREMENT"
+	}
+	return "ID int NOT NULL AUTO_INCREMENT PRIMARY KEY"
+}
+
+// column is a column added to an existing table.
+type column struct {
+	table, name, definition string
+}
+
+// migration is a versioned change of the database schema.
+type migration struct {
+	version     int
+	description string
+	// columns are added before running the statements, unless they exist.
+	columns    []column
+	statements func(d dialect) []string
+}
+
+// migrations are applied in order, each of them exactly once. Never change a
+// migration that was released, add a new one instead.
+//
+// MySQL commits each DDL statement implicitly, so a migration failing halfway
+// is partially applied. Migrations must be idempotent so that they can be
+// retried: create tables with IF NOT EXISTS and add columns with columns.
+var migrations = []migration{{
+	version:     1,
+	description: "create the Clusters and Requests tables",
+	statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS Clusters (
+  ` + d.idColumn() + `,
+  ProjectID varchar(1023) NOT NULL,
+  Status varchar(1023) DEFAULT 'WIP',
+  Zone varchar(1023) NOT NULL,
+  Nodes int NOT NULL,
+  NodeType varchar(1023) NOT NULL
+)`,
+			`CREATE TABLE IF NOT EXISTS Requests (
+  ` + d.idColumn() + `,
+  AccessToken varchar(1023) NOT NULL,
+  RequestTime timestamp,
+  Zone varchar(1023) NOT NULL,
+  Nodes int NOT NULL,
+  NodeType varchar(1023) NOT NULL,
+  ProwJobID varchar(1023) NOT NULL,
+  ClusterID int DEFAULT 0
+)`,
+		}
+	},
+}, {
+	version:     2,
+	description: "add lease expiries to Requests and create the LeaseEvents table",
+	columns:     []column{{"Requests", "LeaseExpiry", "timestamp NULL"}},
+	statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE IF NOT EXISTS LeaseEvents (
+  ` + d.idColumn() + `,
+  RequestID int NOT NULL,
+  ClusterID int NOT NULL,
+  ProjectID varchar(1023) NOT NULL,
+  ProwJobID varchar(1023) NOT NULL,
+  Event varchar(1023) NOT NULL,
+  EventTime timestamp NULL,
+  Message varchar(1023) NOT NULL
+)`,
+		}
+	},
+}}
+
+// Migrate applies the migrations that haven't been applied to the database
+// yet, and records their versions in the SchemaMigrations table.
+func (db *DBClient) Migrate() error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS SchemaMigrations (
+  Version int NOT NULL PRIMARY KEY,
+  Description varchar(1023) NOT NULL
+)`); err != nil {
+		return fmt.Errorf("failed creating the SchemaMigrations table: %w", err)
+	}
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		log.Printf("Applying migration %d: %s", m.version, m.description)
+		if err := db.applyMigration(m); err != nil {
+			return fmt.Errorf("failed applying migration %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the database.
+func (db *DBClient) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(Version) FROM SchemaMigrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed getting the schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration runs the statements of the migration and records its version
+// in one transaction. It's only atomic with SQLite, as MySQL commits the DDL
+// statements of the migration one by one.
+func (db *DBClient) applyMigration(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, c := range m.columns {
+		exists, err := db.columnExists(tx, c)
+		if err != nil {
+			return mysql.RollbackTx(tx, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.name, c.definition)); err != nil {
+			return mysql.RollbackTx(tx, err)
+		}
+	}
+	for _, stmt := range m.statements(db.dialect) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return mysql.RollbackTx(tx, err)
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO SchemaMigrations(Version, Description) VALUES (?,?)", m.version, m.description); err != nil {
+		return mysql.RollbackTx(tx, err)
+	}
+	return tx.Commit()
+}
+
+// columnExists tells whether the column was already added to its table.
+func (db *DBClient) columnExists(tx *sql.Tx, c column) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?"
+	if db.dialect == sqliteDialect {
+		query = "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?"
+	}
+	var count int
+	if err := tx.QueryRow(query, c.table, c.name).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed checking column %s.%s: %w", c.table, c.name, err)
+	}
+	return count != 0, nil
+}
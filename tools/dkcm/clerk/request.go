@@ -27,6 +27,14 @@ type Request struct {
 	requestTime time.Time
 	ProwJobID   string
 	ClusterID   int64
+	// LeaseExpiry is when the cluster assigned to the request is reclaimed,
+	// unless the lease is renewed. It's zero if no cluster is assigned.
+	LeaseExpiry time.Time
+}
+
+// RequestTime returns when the request was made.
+func (r Request) RequestTime() time.Time {
+	return r.requestTime
 }
 
 // Function option that modify a field of Request
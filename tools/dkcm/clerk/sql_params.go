@@ -18,45 +18,100 @@ package clerk
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
-// Function option that returns a part of query for elements in ClusterParams
-type QueryClusterParamsOption func(*ClusterParams) string
+// QueryClusterParamsOption returns a condition on a field of ClusterParams,
+// as a column name and the value it must be equal to.
+type QueryClusterParamsOption func(*ClusterParams) (string, interface{})
 
-// Function option that returns as a key value pair for database update
-type UpdateOption func() string
+// UpdateOption returns a field to update, as a column name and its new value.
+type UpdateOption func() (string, interface{})
+
+// updatableColumns are the columns of each table that can be updated.
+var updatableColumns = map[string]map[string]bool{
+	ClusterDB: {"ProjectID": true, "Status": true, "Zone": true, "Nodes": true, "NodeType": true},
+	RequestDB: {"Zone": true, "Nodes": true, "NodeType": true, "ProwJobID": true, "ClusterID": true, "LeaseExpiry": true},
+}
 
-// update query for changing string attributes
 func UpdateStringField(key string, value string) UpdateOption {
-	return func() string {
-		return fmt.Sprintf("%s = '%s'", key, value)
+	return func() (string, interface{}) {
+		return key, value
 	}
 }
 
-// update query for changing numeric attributes
 func UpdateNumField(key string, value int64) UpdateOption {
-	return func() string {
-		return fmt.Sprintf("%s = %d", key, value)
+	return func() (string, interface{}) {
+		return key, value
+	}
+}
+
+// UpdateTimeField sets a time column, to NULL if the time is zero.
+func UpdateTimeField(key string, value time.Time) UpdateOption {
+	return func() (string, interface{}) {
+		if value.IsZero() {
+			return key, nil
+		}
+		return key, value.UTC()
 	}
 }
 
-// return a query string of zone
 func QueryZone() QueryClusterParamsOption {
-	return func(cp *ClusterParams) string {
-		return fmt.Sprintf("Zone = '%s'", cp.Zone)
+	return func(cp *ClusterParams) (string, interface{}) {
+		return "Zone", cp.Zone
 	}
 }
 
-// return a query string of number of nodes
 func QueryNodes() QueryClusterParamsOption {
-	return func(cp *ClusterParams) string {
-		return fmt.Sprintf("Nodes = %d", cp.Nodes)
+	return func(cp *ClusterParams) (string, interface{}) {
+		return "Nodes", cp.Nodes
 	}
 }
 
-// return a query string of node type
 func QueryNodeType() QueryClusterParamsOption {
-	return func(cp *ClusterParams) string {
-		return fmt.Sprintf("NodeType = '%s'", cp.NodeType)
+	return func(cp *ClusterParams) (string, interface{}) {
+		return "NodeType", cp.NodeType
+	}
+}
+
+// generateParamsConditions returns the conditions that could be used in a query for ClusterParams,
+// with placeholders for their values, and the values to bind to the placeholders.
+func (cp *ClusterParams) generateParamsConditions(opts ...QueryClusterParamsOption) ([]string, []interface{}) {
+	var fieldStatements []string
+	var args []interface{}
+	for _, opt := range opts {
+		column, value := opt(cp)
+		fieldStatements = append(fieldStatements, column+" = ?")
+		args = append(args, value)
+	}
+	return fieldStatements, args
+}
+
+func generateAND(fieldStatements []string) string {
+	return strings.Join(fieldStatements, " AND ")
+}
+
+// updateQueryString returns the statement updating the row with the given id in the table,
+// with placeholders for the new values, and the values to bind to the placeholders.
+func updateQueryString(dbName string, id int64, opts ...UpdateOption) (string, []interface{}, error) {
+	columns, ok := updatableColumns[dbName]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown table %q", dbName)
+	}
+	if len(opts) == 0 {
+		return "", nil, fmt.Errorf("nothing to update in table %q", dbName)
+	}
+	var fieldStatements []string
+	var args []interface{}
+	for _, opt := range opts {
+		column, value := opt()
+		if !columns[column] {
+			return "", nil, fmt.Errorf("column %q of table %q cannot be updated", column, dbName)
+		}
+		fieldStatements = append(fieldStatements, column+" = ?")
+		args = append(args, value)
 	}
+	args = append(args, id)
+	return fmt.Sprintf("UPDATE %s SET %s WHERE ID = ?", dbName, strings.Join(fieldStatements, ",")), args, nil
 }
@@ -0,0 +1,49 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"database/sql"
+	"fmt"
+
+	// register the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewSQLiteDB returns the DB object backed by the SQLite database file at path,
+// creating it if it doesn't exist, after applying the pending migrations.
+func NewSQLiteDB(path string) (*DBClient, error) {
+	// wait for the lock instead of failing when the file is used by another process
+	return openSQLite(fmt.Sprintf("file:%s?_busy_timeout=5000", path))
+}
+
+// NewMemoryDB returns the DB object backed by an in-memory SQLite database, which
+// is gone once the DB object is closed. It's meant for running DKCM locally and in tests.
+func NewMemoryDB() (*DBClient, error) {
+	return openSQLite(":memory:")
+}
+
+func openSQLite(dataSourceName string) (*DBClient, error) {
+	db, err := sql.Open(string(sqliteDialect), dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the SQLite database: %w", err)
+	}
+	// SQLite doesn't support concurrent writes, and each connection to
+	// ":memory:" has its own database, so share a single connection.
+	db.SetMaxOpenConns(1)
+	return newDBClient(db, sqliteDialect)
+}
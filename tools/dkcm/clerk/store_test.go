@@ -0,0 +1,327 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"knative.dev/test-infra/tools/dkcm/api"
+)
+
+func newTestDB(t *testing.T) *DBClient {
+	db, err := NewMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed creating the in-memory database: '%v'", err)
+	}
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("Failed getting the schema version: '%v'", err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("Schema version = %d, want %d", version, want)
+	}
+	// Applying migrations again is a no-op.
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed migrating again: '%v'", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM SchemaMigrations").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrations) {
+		t.Errorf("Got %d migrations recorded, want %d", count, len(migrations))
+	}
+}
+
+func TestMigratePartiallyApplied(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	// Simulate migration 2 failing after adding its column, which MySQL
+	// commits right away.
+	if _, err := db.Exec("DROP TABLE LeaseEvents"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DELETE FROM SchemaMigrations WHERE Version = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed retrying the partially applied migration: '%v'", err)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM LeaseEvents"); err != nil {
+		t.Errorf("LeaseEvents table is missing after migrating: '%v'", err)
+	}
+	if version, err := db.SchemaVersion(); err != nil || version != 2 {
+		t.Errorf("Schema version = %d, %v, want 2", version, err)
+	}
+}
+
+func TestClusters(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+	other := NewClusterParams(AddZone("us-west1"), AddNodes(4), AddNodeType("e2-standard-4"))
+
+	if avail, _ := db.CheckAvail(cp); avail {
+		t.Error("Expected no available cluster in an empty database")
+	}
+	var ids []int64
+	for _, c := range []*Cluster{
+		{ClusterParams: cp, ProjectID: "p1"},
+		{ClusterParams: cp, ProjectID: "p2"},
+		{ClusterParams: other, ProjectID: "p3"},
+	} {
+		id, err := db.InsertCluster(c)
+		if err != nil {
+			t.Fatalf("Failed inserting cluster %v: '%v'", c, err)
+		}
+		ids = append(ids, id)
+	}
+	if n := db.CheckNumStatus(cp, "WIP"); n != 2 {
+		t.Errorf("Got %d WIP clusters, want 2", n)
+	}
+
+	if err := db.UpdateCluster(ids[1], UpdateStringField("Status", "Ready")); err != nil {
+		t.Fatalf("Failed updating cluster: '%v'", err)
+	}
+	avail, id := db.CheckAvail(cp)
+	if !avail || id != ids[1] {
+		t.Errorf("CheckAvail = %v, %d, want true, %d", avail, id, ids[1])
+	}
+	if n := db.CheckNumStatus(cp, "Ready"); n != 1 {
+		t.Errorf("Got %d ready clusters, want 1", n)
+	}
+	if claimed, err := db.ClaimCluster(ids[0], "Ready", "In Use"); claimed || err != nil {
+		t.Errorf("ClaimCluster of a WIP cluster = %v, %v, want false, nil", claimed, err)
+	}
+	if claimed, err := db.ClaimCluster(ids[1], "Ready", "In Use"); !claimed || err != nil {
+		t.Errorf("ClaimCluster = %v, %v, want true, nil", claimed, err)
+	}
+	if claimed, err := db.ClaimCluster(ids[1], "Ready", "Deleting"); claimed || err != nil {
+		t.Errorf("ClaimCluster of a claimed cluster = %v, %v, want false, nil", claimed, err)
+	}
+	if err := db.UpdateCluster(ids[1], UpdateStringField("Status", "Ready")); err != nil {
+		t.Fatalf("Failed updating cluster: '%v'", err)
+	}
+	if err := db.UpdateCluster(ids[1], UpdateStringField("Status = 'Ready', ProjectID", "p")); err == nil {
+		t.Error("Expected an error updating an unknown column")
+	}
+
+	resp, err := db.GetCluster(ids[1])
+	if err != nil {
+		t.Fatalf("Failed getting cluster: '%v'", err)
+	}
+	if diff := cmp.Diff(&api.ClusterInfo{ClusterName: nameCluster(ids[1]), ProjectID: "p2", Zone: "us-central1"}, resp); diff != "" {
+		t.Errorf("GetCluster got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	if err := db.DeleteCluster(ids[0]); err != nil {
+		t.Fatalf("Failed deleting cluster: '%v'", err)
+	}
+	if err := db.DeleteCluster(ids[0]); err == nil {
+		t.Error("Expected an error deleting a deleted cluster")
+	}
+	clusters, err := db.ListClusters()
+	if err != nil {
+		t.Fatalf("Failed listing clusters: '%v'", err)
+	}
+	var projects []string
+	for _, c := range clusters {
+		projects = append(projects, c.ProjectID)
+	}
+	if diff := cmp.Diff([]string{"p2", "p3"}, projects); diff != "" {
+		t.Errorf("ListClusters got(+) is different from wanted(-)\n%v", diff)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+	now := time.Now()
+
+	var requests []*Request
+	for i, prowJobID := range []string{"old", "first", "second"} {
+		r := NewRequest(AddProwJobID(prowJobID), AddRequestTime(now.Add(time.Duration(i-2)*time.Minute)))
+		if prowJobID == "old" {
+			r.requestTime = now.Add(-2 * time.Hour)
+		}
+		r.ClusterParams = cp
+		token, err := db.InsertRequest(r)
+		if err != nil {
+			t.Fatalf("Failed inserting request: '%v'", err)
+		}
+		if r, err = db.GetRequest(token); err != nil {
+			t.Fatalf("Failed getting request: '%v'", err)
+		}
+		requests = append(requests, r)
+	}
+	if _, err := db.GetRequest("' OR '1'='1"); err == nil {
+		t.Error("Expected an error getting a request with a wrong token")
+	}
+
+	// Requests are ranked by their request time.
+	for i, r := range requests {
+		if rank := db.PriorityRanking(r); rank != int64(i+1) {
+			t.Errorf("Request %q is ranked %d, want %d", r.ProwJobID, rank, i+1)
+		}
+	}
+
+	recent, err := db.ListRequests(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed listing requests: '%v'", err)
+	}
+	if len(recent) != 2 {
+		t.Errorf("Got %d requests in the last hour, want 2", len(recent))
+	}
+
+	// Only the old request times out.
+	if err := db.ClearTimeOut(time.Hour); err != nil {
+		t.Fatalf("Failed clearing timed out requests: '%v'", err)
+	}
+	if err := db.UpdateRequest(requests[1].ID, UpdateNumField("ClusterID", 7)); err != nil {
+		t.Fatalf("Failed updating request: '%v'", err)
+	}
+	var clusterIDs []int64
+	for _, r := range requests {
+		got, err := db.GetRequest(r.accessToken)
+		if err != nil {
+			t.Fatalf("Failed getting request: '%v'", err)
+		}
+		clusterIDs = append(clusterIDs, got.ClusterID)
+	}
+	if diff := cmp.Diff([]int64{-1, 7, 0}, clusterIDs); diff != "" {
+		t.Errorf("Request cluster IDs got(+) is different from wanted(-)\n%v", diff)
+	}
+	// The second request is now first in line.
+	if rank := db.PriorityRanking(requests[2]); rank != 1 {
+		t.Errorf("Request %q is ranked %d, want 1", requests[2].ProwJobID, rank)
+	}
+}
+
+func TestSQLiteDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dkcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dkcm.db")
+
+	db, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("Failed creating the SQLite database: '%v'", err)
+	}
+	id, err := db.InsertCluster(&Cluster{ClusterParams: &ClusterParams{Zone: "us-central1", Nodes: 4, NodeType: "e2-standard-4"}, ProjectID: "p"})
+	if err != nil {
+		t.Fatalf("Failed inserting cluster: '%v'", err)
+	}
+	db.Close()
+
+	// The data is kept in the file.
+	db, err = NewSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("Failed opening the SQLite database again: '%v'", err)
+	}
+	defer db.Close()
+	if _, err := db.GetCluster(id); err != nil {
+		t.Errorf("Failed getting cluster after reopening the database: '%v'", err)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+	now := time.Now()
+
+	var requests []*Request
+	for i, prowJobID := range []string{"expired", "valid", "pending"} {
+		r := NewRequest(AddProwJobID(prowJobID), AddRequestTime(now))
+		r.ClusterParams = cp
+		token, err := db.InsertRequest(r)
+		if err != nil {
+			t.Fatalf("Failed inserting request: '%v'", err)
+		}
+		if r, err = db.GetRequest(token); err != nil {
+			t.Fatalf("Failed getting request: '%v'", err)
+		}
+		if !r.LeaseExpiry.IsZero() {
+			t.Errorf("Request %q has lease expiry %v before being assigned a cluster", prowJobID, r.LeaseExpiry)
+		}
+		if prowJobID != "pending" {
+			if err := db.UpdateRequest(r.ID, UpdateNumField("ClusterID", int64(i+1)),
+				UpdateTimeField("LeaseExpiry", now.Add(time.Duration(2*i-1)*time.Minute))); err != nil {
+				t.Fatalf("Failed updating request: '%v'", err)
+			}
+		}
+		requests = append(requests, r)
+	}
+
+	got, err := db.GetRequest(requests[1].accessToken)
+	if err != nil {
+		t.Fatalf("Failed getting request: '%v'", err)
+	}
+	if want := now.Add(time.Minute); !got.LeaseExpiry.Equal(want) {
+		t.Errorf("Lease expiry = %v, want %v", got.LeaseExpiry, want)
+	}
+	expired, err := db.ListExpiredLeases(now)
+	if err != nil {
+		t.Fatalf("Failed listing expired leases: '%v'", err)
+	}
+	if len(expired) != 1 || expired[0].ProwJobID != "expired" {
+		t.Errorf("Got expired leases %v, want the one of request %q", expired, "expired")
+	}
+	// Clearing the lease expiry stops the lease from expiring.
+	if err := db.UpdateRequest(requests[0].ID, UpdateTimeField("LeaseExpiry", time.Time{})); err != nil {
+		t.Fatalf("Failed clearing the lease expiry: '%v'", err)
+	}
+	if expired, err = db.ListExpiredLeases(now.Add(time.Hour)); err != nil || len(expired) != 1 || expired[0].ProwJobID != "valid" {
+		t.Errorf("Got expired leases %v, %v, want the one of request %q", expired, err, "valid")
+	}
+
+	for i, event := range []string{"Acquired", "Expired"} {
+		e := &LeaseEvent{RequestID: requests[0].ID, ClusterID: 1, ProjectID: "p1", ProwJobID: "expired", Event: event, EventTime: now.Add(time.Duration(i) * time.Second)}
+		if err := db.InsertLeaseEvent(e); err != nil {
+			t.Fatalf("Failed inserting lease event: '%v'", err)
+		}
+	}
+	if err := db.InsertLeaseEvent(&LeaseEvent{Event: "Old", EventTime: now.Add(-2 * time.Hour)}); err != nil {
+		t.Fatalf("Failed inserting lease event: '%v'", err)
+	}
+	events, err := db.ListLeaseEvents(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed listing lease events: '%v'", err)
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	if diff := cmp.Diff([]string{"Acquired", "Expired"}, names); diff != "" {
+		t.Errorf("ListLeaseEvents got(+) is different from wanted(-)\n%v", diff)
+	}
+}
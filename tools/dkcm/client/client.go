@@ -0,0 +1,283 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client requests clusters from DKCM for Prow jobs, waits for them to
+// be ready and releases them after use.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/pkg/gke"
+	"knative.dev/test-infra/pkg/metautil"
+	"knative.dev/test-infra/tools/dkcm/api"
+)
+
+const (
+	// DefaultPollInterval is the initial interval between two checks of the cluster.
+	DefaultPollInterval = 5 * time.Second
+	// DefaultMaxPollInterval caps the interval between two checks of the cluster,
+	// which doubles after each check.
+	DefaultMaxPollInterval = time.Minute
+	// DefaultRenewInterval is how often the lease of a cluster is renewed, well
+	// below the lease duration of the server so that a failed renewal can be retried.
+	DefaultRenewInterval = 10 * time.Minute
+
+	// Keys to be written into metadata.json
+	providerKey    = "E2E:Provider"
+	e2eRegionKey   = "E2E:Region"
+	e2eZoneKey     = "E2E:Zone"
+	clusterNameKey = "E2E:Machine"
+	projectKey     = "E2E:Project"
+)
+
+// Params are the parameters of the requested cluster, the server defaults the
+// ones not set.
+type Params struct {
+	Zone     string
+	Nodes    int64
+	NodeType string
+}
+
+// Client talks to the DKCM server at Host.
+type Client struct {
+	// Host is the base URL of the server, e.g. http://dkcm
+	Host       string
+	HTTPClient *http.Client
+	// PollInterval is the initial interval between two checks of the cluster
+	PollInterval time.Duration
+	// MaxPollInterval caps the interval between two checks of the cluster
+	MaxPollInterval time.Duration
+}
+
+// NewClient returns a client of the DKCM server at host, adding the http://
+// scheme if it's missing.
+func NewClient(host string) *Client {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return &Client{
+		Host:            strings.TrimSuffix(host, "/"),
+		HTTPClient:      &http.Client{Timeout: time.Minute},
+		PollInterval:    DefaultPollInterval,
+		MaxPollInterval: DefaultMaxPollInterval,
+	}
+}
+
+// do sends the request and returns the body of the response, or an error if
+// the server didn't respond with 200.
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading the response of %s: %w", req.URL.Path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// RequestCluster requests a cluster for the Prow job, and returns the token to
+// get and release it.
+func (c *Client) RequestCluster(prowJobID string, p Params) (string, error) {
+	form := url.Values{"prowjobid": {prowJobID}}
+	if p.Zone != "" {
+		form.Set("zone", p.Zone)
+	}
+	if p.Nodes > 0 {
+		form.Set("nodes", strconv.FormatInt(p.Nodes, 10))
+	}
+	if p.NodeType != "" {
+		form.Set("nodeType", p.NodeType)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Host+"/request-cluster", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed requesting a cluster: %w", err)
+	}
+	return string(body), nil
+}
+
+// GetCluster checks once whether the cluster of the token is ready.
+func (c *Client) GetCluster(token string) (*api.ServiceResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Host+"/get-cluster?token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting the cluster: %w", err)
+	}
+	sr := &api.ServiceResponse{}
+	if err := json.Unmarshal(body, sr); err != nil {
+		return nil, fmt.Errorf("failed parsing the cluster response %q: %w", body, err)
+	}
+	return sr, nil
+}
+
+// WaitForCluster polls the server until the cluster of the token is ready, or
+// the context is done. The interval between two checks doubles from
+// PollInterval up to MaxPollInterval.
+func (c *Client) WaitForCluster(ctx context.Context, token string) (*api.ClusterInfo, error) {
+	interval := c.PollInterval
+	for {
+		sr, err := c.GetCluster(token)
+		if err != nil {
+			return nil, err
+		}
+		if sr.IsReady {
+			if sr.ClusterInfo == nil {
+				return nil, fmt.Errorf("the cluster is ready but its info is missing: %q", sr.Message)
+			}
+			return sr.ClusterInfo, nil
+		}
+		log.Printf("%s Checking again in %v", sr.Message, interval)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for the cluster: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > c.MaxPollInterval {
+			interval = c.MaxPollInterval
+		}
+	}
+}
+
+// Acquire waits for the cluster of the token, writes its info into
+// metadata.json in metaDataDir, see WriteMetaData, and its credentials to the
+// kubeconfig file, see GetCredentials.
+func (c *Client) Acquire(ctx context.Context, token, metaDataDir, kubeconfig string) (*api.ClusterInfo, error) {
+	info, err := c.WaitForCluster(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteMetaData(info, metaDataDir); err != nil {
+		return info, err
+	}
+	return info, GetCredentials(info, kubeconfig)
+}
+
+// ReleaseCluster releases the cluster of the token, once the Prow job is done with it.
+func (c *Client) ReleaseCluster(token string) error {
+	req, err := http.NewRequest(http.MethodGet, c.Host+"/clean-cluster?token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := c.do(req); err != nil {
+		return fmt.Errorf("failed releasing the cluster: %w", err)
+	}
+	return nil
+}
+
+// RenewLease renews the lease of the cluster of the token, and returns when
+// it expires. The server reclaims the cluster once its lease expired.
+func (c *Client) RenewLease(token string) (time.Time, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Host+"/renew-lease?token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	body, err := c.do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed renewing the lease: %w", err)
+	}
+	lr := &api.LeaseResponse{}
+	if err := json.Unmarshal(body, lr); err != nil {
+		return time.Time{}, fmt.Errorf("failed parsing the lease response %q: %w", body, err)
+	}
+	return lr.LeaseExpiry, nil
+}
+
+// KeepLease renews the lease of the cluster of the token at every interval,
+// until the context is done. Failed renewals are retried at the next interval.
+func (c *Client) KeepLease(ctx context.Context, token string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expiry, err := c.RenewLease(token); err != nil {
+				log.Print(err)
+			} else {
+				log.Printf("Renewed the lease of the cluster until %v", expiry)
+			}
+		}
+	}
+}
+
+// GetCredentials writes the credentials of the cluster to the kubeconfig file,
+// or to the default kubeconfig of kubectl if it's empty.
+func GetCredentials(info *api.ClusterInfo, kubeconfig string) error {
+	var opts []cmd.Option
+	if kubeconfig != "" {
+		opts = append(opts, cmd.WithEnvs(append(os.Environ(), "KUBECONFIG="+kubeconfig)))
+	}
+	locationFlag := "--region"
+	if _, zone := gke.RegionZoneFromLoc(info.Zone); zone != "" {
+		locationFlag = "--zone"
+	}
+	clusterAuthCmd := fmt.Sprintf(
+		"gcloud container clusters get-credentials %s %s %s --project %s",
+		info.ClusterName, locationFlag, info.Zone, info.ProjectID)
+	if out, err := cmd.RunCommand(clusterAuthCmd, opts...); err != nil {
+		return fmt.Errorf("failed getting the credentials of the cluster: %q, %w", out, err)
+	}
+	return nil
+}
+
+// WriteMetaData writes the cluster info into metadata.json in dir, or in the
+// artifacts directory of the Prow job if dir is empty.
+func WriteMetaData(info *api.ClusterInfo, dir string) error {
+	c, err := metautil.NewClient(dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("Writing metadata to: %q", c.Path)
+	region, zone := gke.RegionZoneFromLoc(info.Zone)
+	for key, val := range map[string]string{
+		providerKey:    "dkcm",
+		e2eRegionKey:   region,
+		e2eZoneKey:     zone,
+		clusterNameKey: info.ClusterName,
+		projectKey:     info.ProjectID,
+	} {
+		if err := c.Set(key, val); err != nil {
+			return fmt.Errorf("failed saving metadata %q:%q: %w", key, val, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	boskoscommon "sigs.k8s.io/boskos/common"
+
+	boskosFake "knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos/fake"
+	"knative.dev/test-infra/pkg/clustermanager/kubetest2"
+	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/pkg/metautil"
+	"knative.dev/test-infra/tools/dkcm/api"
+	"knative.dev/test-infra/tools/dkcm/clerk"
+	"knative.dev/test-infra/tools/dkcm/mainservice"
+	"knative.dev/test-infra/tools/dkcm/pool"
+)
+
+// newTestClient returns a client of a server running the real handlers, on an
+// in-memory database, which fails creating clusters with kubetest2Err.
+func newTestClient(t *testing.T, kubetest2Err error) (*Client, *boskosFake.FakeBoskosClient) {
+	db, err := clerk.NewMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed creating the in-memory database: '%v'", err)
+	}
+	boskosOps := &boskosFake.FakeBoskosClient{}
+	for _, name := range []string{"p1", "p2", "p3"} {
+		boskosOps.NewGKEProject(name)
+	}
+	policy := pool.DefaultPolicy()
+	policy.Budget = 3
+	s := mainservice.NewServer(db, boskosOps, "sa.json", policy)
+	s.RunKubetest2 = func(*kubetest2.Options, *kubetest2.GKEClusterConfig) error {
+		return kubetest2Err
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		db.Close()
+	})
+	c := NewClient(ts.URL)
+	c.PollInterval = 10 * time.Millisecond
+	c.MaxPollInterval = 50 * time.Millisecond
+	return c, boskosOps
+}
+
+func mockRunCommand(cmdLines *[]string) func() {
+	oldFunc := cmd.RunCommand
+	cmd.RunCommand = func(cmdLine string, options ...cmd.Option) (string, error) {
+		*cmdLines = append(*cmdLines, cmdLine)
+		return "", nil
+	}
+	return func() {
+		cmd.RunCommand = oldFunc
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	for host, want := range map[string]string{
+		"dkcm":                 "http://dkcm",
+		"http://dkcm:8080/":    "http://dkcm:8080",
+		"https://dkcm.example": "https://dkcm.example",
+	} {
+		if got := NewClient(host).Host; got != want {
+			t.Errorf("NewClient(%q).Host = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestAcquireAndRelease(t *testing.T) {
+	c, boskosOps := newTestClient(t, nil)
+	var cmdLines []string
+	defer mockRunCommand(&cmdLines)()
+
+	token, err := c.RequestCluster("job", Params{Zone: "us-east1-b", Nodes: 2})
+	if err != nil {
+		t.Fatalf("Failed requesting a cluster: '%v'", err)
+	}
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info, err := c.Acquire(ctx, token, dir, "/tmp/kubeconfig")
+	if err != nil {
+		t.Fatalf("Failed acquiring the cluster: '%v'", err)
+	}
+	if info.Zone != "us-east1-b" || info.ClusterName != mainservice.DefaultClusterName || info.ProjectID == "" {
+		t.Errorf("Unexpected cluster info: %+v", info)
+	}
+
+	// The cluster info is written into metadata.json.
+	meta, err := metautil.NewClient(dir)
+	if err != nil {
+		t.Fatalf("Failed creating the metadata client: '%v'", err)
+	}
+	for key, want := range map[string]string{
+		providerKey:    "dkcm",
+		e2eRegionKey:   "us-east1",
+		e2eZoneKey:     "b",
+		clusterNameKey: mainservice.DefaultClusterName,
+		projectKey:     info.ProjectID,
+	} {
+		if got, err := meta.Get(key); err != nil || got != want {
+			t.Errorf("Metadata %q = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	// The credentials of the zonal cluster are fetched.
+	wantCmdLines := []string{"gcloud container clusters get-credentials e2e-cls --zone us-east1-b --project " + info.ProjectID}
+	if diff := cmp.Diff(wantCmdLines, cmdLines); diff != "" {
+		t.Errorf("got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	// The lease of the cluster can be renewed until it's released.
+	expiry, err := c.RenewLease(token)
+	if err != nil || !expiry.After(time.Now()) {
+		t.Errorf("RenewLease = %v, %v, want an expiry in the future", expiry, err)
+	}
+
+	// Releasing the cluster frees its project, and can't be done twice.
+	if err := c.ReleaseCluster(token); err != nil {
+		t.Fatalf("Failed releasing the cluster: '%v'", err)
+	}
+	for _, res := range boskosOps.GetResources() {
+		if res.Name == info.ProjectID && res.State != boskoscommon.Free {
+			t.Errorf("Project %q of the released cluster is %s, want free", res.Name, res.State)
+		}
+	}
+	if err := c.ReleaseCluster(token); err == nil {
+		t.Error("Expected an error releasing the cluster twice")
+	}
+	if _, err := c.RenewLease(token); err == nil {
+		t.Error("Expected an error renewing the lease of a released cluster")
+	}
+}
+
+func TestWaitForClusterTimeout(t *testing.T) {
+	c, _ := newTestClient(t, errors.New("stockout"))
+	token, err := c.RequestCluster("job", Params{})
+	if err != nil {
+		t.Fatalf("Failed requesting a cluster: '%v'", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if info, err := c.WaitForCluster(ctx, token); err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForCluster = %+v, %v, want a deadline exceeded error", info, err)
+	}
+}
+
+func TestWrongToken(t *testing.T) {
+	c, _ := newTestClient(t, nil)
+	if _, err := c.GetCluster("' OR '1'='1"); err == nil {
+		t.Error("Expected an error getting a cluster with a wrong token")
+	}
+	if err := c.ReleaseCluster("' OR '1'='1"); err == nil {
+		t.Error("Expected an error releasing a cluster with a wrong token")
+	}
+}
+
+func TestGetCredentials(t *testing.T) {
+	var cmdLines []string
+	defer mockRunCommand(&cmdLines)()
+	if err := GetCredentials(&api.ClusterInfo{ClusterName: "e2e-cls", ProjectID: "p1", Zone: "us-west1"}, ""); err != nil {
+		t.Fatalf("Unexpected error: '%v'", err)
+	}
+	want := []string{"gcloud container clusters get-credentials e2e-cls --region us-west1 --project p1"}
+	if diff := cmp.Diff(want, cmdLines); diff != "" {
+		t.Errorf("got(+) is different from wanted(-)\n%v", diff)
+	}
+}
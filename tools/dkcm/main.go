@@ -6,10 +6,14 @@ import (
 	"os"
 
 	"knative.dev/test-infra/pkg/mysql"
+	"knative.dev/test-infra/tools/dkcm/clerk"
 	"knative.dev/test-infra/tools/dkcm/mainservice"
+	"knative.dev/test-infra/tools/dkcm/pool"
 )
 
 func main() {
+	dbType := flag.String("database", "mysql", "The dkcm database type, one of mysql, sqlite or memory")
+	dbFile := flag.String("database-file", "dkcm.db", "The SQLite database file, used if the database type is sqlite")
 	dbName := flag.String("database-name", "dkcm", "The dkcm database name")
 	dbPort := flag.String("database-port", "3306", "The dkcm database port")
 
@@ -21,14 +25,33 @@ func main() {
 
 	gcpServiceAccount := flag.String("gcp-service-account", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "JSON key file for GCP service account")
 
+	policy := pool.DefaultPolicy()
+	policy.AddFlags(flag.CommandLine)
+
 	flag.Parse()
 
-	dbConfig, err := mysql.ConfigureDB(*dbUserSF, *dbPassSF, *dbHost, *dbPort, *dbName)
+	var db *clerk.DBClient
+	var err error
+	switch *dbType {
+	case "mysql":
+		dbConfig, err := mysql.ConfigureDB(*dbUserSF, *dbPassSF, *dbHost, *dbPort, *dbName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db, err = clerk.NewDB(dbConfig)
+	case "sqlite":
+		db, err = clerk.NewSQLiteDB(*dbFile)
+	case "memory":
+		db, err = clerk.NewMemoryDB()
+	default:
+		log.Fatalf("Unknown database type %q, must be one of mysql, sqlite or memory", *dbType)
+	}
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Failed to create Clerk client: %v", err)
 	}
+	defer db.Close()
 
-	if err := mainservice.Start(dbConfig, *boskosClientHost, *gcpServiceAccount); err != nil {
+	if err := mainservice.Start(db, *boskosClientHost, *gcpServiceAccount, policy); err != nil {
 		log.Fatalf("Failed to start main service: %v", err)
 	}
 }
@@ -16,27 +16,46 @@ limitations under the License.
 
 package mainservice
 
+import "time"
+
 const (
 	// default parameters for mainservice server
-	DefaultClusterName   = "e2e-cls"
-	DefaultNetworkName   = "e2e-network"
-	DefaultZone          = "us-west1"
-	DefaultNodeType      = "e2-standard-4"
-	DefaultOverProvision = 5
-	DefaultNodesCount    = 4
-	DefaultTimeOut       = 60
-	DefaultPort          = "8080"
-
-	// four statuses of cluster
-	Ready = "Ready"
-	WIP   = "WIP"
-	InUse = "In Use"
-	Fail  = "Failed"
+	DefaultClusterName = "e2e-cls"
+	DefaultNetworkName = "e2e-network"
+	DefaultZone        = "us-west1"
+	DefaultNodeType    = "e2-standard-4"
+	DefaultNodesCount  = 4
+	DefaultTimeOut     = 60
+	DefaultPort        = "8080"
+
+	// five statuses of cluster
+	Ready    = "Ready"
+	WIP      = "WIP"
+	InUse    = "In Use"
+	Fail     = "Failed"
+	Deleting = "Deleting"
 
 	// Field for use when query Cluster db
-	Status    = "Status"
-	ClusterID = "ClusterID"
+	Status      = "Status"
+	ClusterID   = "ClusterID"
+	LeaseExpiry = "LeaseExpiry"
+
+	// ClusterID of the requests whose cluster was reclaimed after their lease expired
+	ReapedClusterID = -2
+
+	// lease events
+	LeaseAcquired = "Acquired"
+	LeaseReleased = "Released"
+	LeaseDeleted  = "ExpiredDeleted"
 
 	// time interval to examine timeout requests
 	CheckInterval = 2
+
+	// time interval to resize the pools
+	PoolSizingInterval = time.Minute
+
+	// how long a cluster is leased to a request, unless the lease is renewed
+	DefaultLeaseDuration = time.Hour
+	// time interval to reclaim the clusters whose lease expired
+	ReapInterval = time.Minute
 )
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mainservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"knative.dev/test-infra/tools/dkcm/api"
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+// LeaseReport lists the lease events of a window, and how many clusters each
+// Prow job leaked, i.e. never released before their lease expired.
+type LeaseReport struct {
+	Events []clerk.LeaseEvent `json:"events"`
+	Leaks  map[string]int     `json:"leaks"`
+}
+
+// recordLeaseEvent records the lease event with best effort.
+func (s *Server) recordLeaseEvent(r *clerk.Request, clusterID int64, projectID, event, message string) {
+	e := &clerk.LeaseEvent{
+		RequestID: r.ID,
+		ClusterID: clusterID,
+		ProjectID: projectID,
+		ProwJobID: r.ProwJobID,
+		Event:     event,
+		EventTime: time.Now(),
+		Message:   message,
+	}
+	log.Print(e)
+	if err := s.db.InsertLeaseEvent(e); err != nil {
+		log.Printf("Failed to insert a new LeaseEvent entry: %v", err)
+	}
+}
+
+// handle renewing the lease of the cluster assigned to a request
+func (s *Server) handleRenewLease(w http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("token")
+	r, err := s.db.GetRequest(token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("there is an error getting the request with the token: %v, please try again", err), http.StatusForbidden)
+		return
+	}
+	if r.ClusterID <= 0 || r.LeaseExpiry.IsZero() {
+		http.Error(w, "there is no cluster leased to the request, it was never assigned, released or its lease expired", http.StatusForbidden)
+		return
+	}
+	expiry := time.Now().Add(s.LeaseDuration)
+	if err := s.db.UpdateRequest(r.ID, clerk.UpdateTimeField(LeaseExpiry, expiry)); err != nil {
+		http.Error(w, fmt.Sprintf("there is an error renewing the lease: %v, please try again", err), http.StatusInternalServerError)
+		return
+	}
+	responseJson, err := json.Marshal(&api.LeaseResponse{LeaseExpiry: expiry})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("there is an error getting parsing response: %v, please try again", err), http.StatusInternalServerError)
+		return
+	}
+	w.Write(responseJson)
+}
+
+// runReaper reclaims the clusters whose lease expired at every interval.
+func (s *Server) runReaper(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.reapExpiredLeases()
+	}
+}
+
+// reapExpiredLeases reclaims the clusters of the requests whose lease expired.
+// They are deleted and their projects released, like released clusters, as the
+// Prow job may have left anything in them. The pools are refilled with new
+// clusters when they're sized again.
+func (s *Server) reapExpiredLeases() {
+	requests, err := s.db.ListExpiredLeases(time.Now())
+	if err != nil {
+		log.Printf("Failed listing the expired leases: %v", err)
+		return
+	}
+	for i := range requests {
+		r := &requests[i]
+		c, err := s.db.GetCluster(r.ClusterID)
+		if err != nil {
+			log.Printf("Failed getting the cluster of the expired lease of %v: %v", r, err)
+			continue
+		}
+		// detach the cluster from the request first, so that the Prow job
+		// can't use or release it anymore
+		if err := s.db.UpdateRequest(r.ID, clerk.UpdateNumField(ClusterID, ReapedClusterID), clerk.UpdateTimeField(LeaseExpiry, time.Time{})); err != nil {
+			log.Printf("Failed to update the Request entry of the expired lease: %v", err)
+			continue
+		}
+		s.recordLeaseEvent(r, r.ClusterID, c.ProjectID, LeaseDeleted, "the lease expired")
+		if err := s.db.DeleteCluster(r.ClusterID); err != nil {
+			log.Printf("Failed to delete the Cluster entry of the expired lease: %v", err)
+			continue
+		}
+		if err := s.boskosOps.ReleaseGKEProject(c.ProjectID); err != nil {
+			log.Printf("Failed to release Boskos Project: %v", err)
+		}
+	}
+}
+
+// handle listing the lease events of a window, e.g. ?window=24h, and the
+// clusters leaked by each Prow job
+func (s *Server) handleLeaseReport(w http.ResponseWriter, req *http.Request) {
+	window := 24 * time.Hour
+	if fromQuery := req.URL.Query().Get("window"); fromQuery != "" {
+		var err error
+		if window, err = time.ParseDuration(fromQuery); err != nil || window <= 0 {
+			http.Error(w, fmt.Sprintf("invalid window %q, it must be a positive duration", fromQuery), http.StatusBadRequest)
+			return
+		}
+	}
+	events, err := s.db.ListLeaseEvents(window)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("there is an error listing the lease events: %v, please try again", err), http.StatusInternalServerError)
+		return
+	}
+	report := LeaseReport{Events: events, Leaks: make(map[string]int)}
+	for _, e := range events {
+		if e.Event == LeaseDeleted {
+			report.Leaks[e.ProwJobID]++
+		}
+	}
+	responseJson, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("there is an error getting parsing response: %v, please try again", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJson)
+}
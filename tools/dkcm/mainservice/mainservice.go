@@ -28,41 +28,71 @@ import (
 
 	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
 	"knative.dev/test-infra/pkg/clustermanager/kubetest2"
-	"knative.dev/test-infra/pkg/mysql"
+	"knative.dev/test-infra/tools/dkcm/api"
 	"knative.dev/test-infra/tools/dkcm/clerk"
+	"knative.dev/test-infra/tools/dkcm/pool"
 )
 
 var (
-	// channel serves as a lock for go routine
-	chanLock             = make(chan struct{})
-	boskosClient         *boskos.Client
-	dbClient             *clerk.DBClient
-	serviceAccount       string
 	DefaultClusterParams = clerk.ClusterParams{Zone: DefaultZone, Nodes: DefaultNodesCount, NodeType: DefaultNodeType}
 )
 
-// Response to Prow
-type ServiceResponse struct {
-	IsReady     bool            `json:"isReady"`
-	Message     string          `json:"message"`
-	ClusterInfo *clerk.Response `json:"clusterInfo"`
+// Server handles the cluster requests from Prow jobs.
+type Server struct {
+	db             clerk.Operations
+	boskosOps      boskos.Operation
+	serviceAccount string
+	policy         pool.Policy
+	// RunKubetest2 creates the GKE cluster, it's kubetest2.Run unless overridden, e.g. in tests
+	RunKubetest2 func(*kubetest2.Options, *kubetest2.GKEClusterConfig) error
+	// LeaseDuration is how long a cluster is leased to a request, unless the lease is renewed
+	LeaseDuration time.Duration
+	// channel serves as a lock for go routine
+	chanLock chan struct{}
+	// poolStatus is the last sizing of the pools
+	poolStatus      PoolStatus
+	poolStatusMutex sync.Mutex
 }
 
-func Start(dbConfig *mysql.DBConfig, boskosClientHost, gcpServiceAccount string) error {
-	var err error
-	boskosClient, err = boskos.NewClient(boskosClientHost, "", "")
-	if err != nil {
-		return fmt.Errorf("failed to create Boskos client: %w", err)
+// NewServer returns a Server storing clusters and requests in db, and
+// creating clusters in the projects acquired through boskosOps, in pools sized
+// by the policy.
+func NewServer(db clerk.Operations, boskosOps boskos.Operation, gcpServiceAccount string, policy pool.Policy) *Server {
+	return &Server{
+		db:             db,
+		boskosOps:      boskosOps,
+		serviceAccount: gcpServiceAccount,
+		policy:         policy,
+		RunKubetest2:   kubetest2.Run,
+		LeaseDuration:  DefaultLeaseDuration,
+		chanLock:       make(chan struct{}, 1),
+	}
+}
+
+// Handler returns the handler serving all the endpoints of the server.
+func (s *Server) Handler() http.Handler {
+	server := http.NewServeMux()
+	server.HandleFunc("/request-cluster", s.handleNewClusterRequest)
+	server.HandleFunc("/get-cluster", s.handleGetCluster)
+	server.HandleFunc("/clean-cluster", s.handleCleanCluster)
+	server.HandleFunc("/renew-lease", s.handleRenewLease)
+	server.HandleFunc("/admin/pool", s.handlePoolStatus)
+	server.HandleFunc("/admin/request-log", s.handleRequestLog)
+	server.HandleFunc("/admin/leases", s.handleLeaseReport)
+	return server
+}
+
+func Start(db clerk.Operations, boskosClientHost, gcpServiceAccount string, policy pool.Policy) error {
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid pool policy: %w", err)
 	}
-	dbClient, err = clerk.NewDB(dbConfig)
+	boskosClient, err := boskos.NewClient(boskosClientHost, "", "")
 	if err != nil {
-		return fmt.Errorf("failed to create Clerk client: %w", err)
+		return fmt.Errorf("failed to create Boskos client: %w", err)
 	}
-	serviceAccount = gcpServiceAccount
-	server := http.NewServeMux()
-	server.HandleFunc("/request-cluster", handleNewClusterRequest)
-	server.HandleFunc("/get-cluster", handleGetCluster)
-	server.HandleFunc("/clean-cluster", handleCleanCluster)
+	s := NewServer(db, boskosClient, gcpServiceAccount, policy)
+	go s.runPoolSizer(PoolSizingInterval)
+	go s.runReaper(ReapInterval)
 	// use PORT environment variable, or default to 8080
 	port := DefaultPort
 	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
@@ -70,70 +100,58 @@ func Start(dbConfig *mysql.DBConfig, boskosClientHost, gcpServiceAccount string)
 	}
 	// start the web server on port and accept requests
 	log.Printf("Server listening on port %q", port)
-	return http.ListenAndServe(fmt.Sprintf(":%v", port), server)
+	return http.ListenAndServe(fmt.Sprintf(":%v", port), s.Handler())
 }
 
 // handle cleaning cluster request after usage
-func handleCleanCluster(w http.ResponseWriter, req *http.Request) {
+func (s *Server) handleCleanCluster(w http.ResponseWriter, req *http.Request) {
 	// add project name
 	token := req.URL.Query().Get("token")
-	r, err := dbClient.GetRequest(token)
+	r, err := s.db.GetRequest(token)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("there is an error getting the request with the token: %v, please try again", err), http.StatusForbidden)
 		return
 	}
-	c, err := dbClient.GetCluster(r.ClusterID)
+	c, err := s.db.GetCluster(r.ClusterID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("there is an error getting the cluster with the token: %v, please try again", err), http.StatusForbidden)
 		return
 	}
-	err = dbClient.DeleteCluster(r.ClusterID)
+	err = s.db.DeleteCluster(r.ClusterID)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("there is an error deleting the cluster with the token: %v, please try again", err), http.StatusForbidden)
 		return
 	}
-	err = boskosClient.ReleaseGKEProject(c.ProjectID)
+	if err := s.db.UpdateRequest(r.ID, clerk.UpdateTimeField(LeaseExpiry, time.Time{})); err != nil {
+		log.Printf("Failed to clear the lease expiry of the Request entry: %v", err)
+	}
+	s.recordLeaseEvent(r, r.ClusterID, c.ProjectID, LeaseReleased, "")
+	err = s.boskosOps.ReleaseGKEProject(c.ProjectID)
 	if err != nil {
 		http.Error(w, "there is an error releasing Boskos's project. Please try again.", http.StatusInternalServerError)
 		return
 	}
 }
 
-// check the pool capacity and create clusters if necessary
-func checkPoolCap(cp *clerk.ClusterParams) {
-	chanLock <- struct{}{}
-	numAvail := dbClient.CheckNumStatus(cp, Ready)
-	numWIP := dbClient.CheckNumStatus(cp, WIP)
-	diff := DefaultOverProvision - numAvail - numWIP
-	var wg sync.WaitGroup
-	// create cluster if not meeting overprovisioning criteria
-	for i := int64(0); i < diff; i++ {
-		wg.Add(1)
-		log.Printf("Creating a new cluster: %v", cp)
-		go CreateCluster(cp, &wg)
-	}
-	wg.Wait()
-	<-chanLock
-}
-
 // assign clusters if available upon request
-func CreateCluster(cp *clerk.ClusterParams, wg *sync.WaitGroup) {
-	project, err := boskosClient.AcquireGKEProject(boskos.GKEProjectResource)
+func (s *Server) CreateCluster(cp *clerk.ClusterParams, wg *sync.WaitGroup) {
+	project, err := s.boskosOps.AcquireGKEProject(boskos.GKEProjectResource)
 	if err != nil {
+		wg.Done()
 		log.Printf("Failed to acquire a project from boskos: %v", err)
 		return
 	}
 	projectName := project.Name
 	c := clerk.NewCluster(clerk.AddProjectID(projectName))
 	c.ClusterParams = cp
-	clusterID, err := dbClient.InsertCluster(c)
+	clusterID, err := s.db.InsertCluster(c)
 	wg.Done()
 	if err != nil {
 		log.Printf("Failed to insert a new Cluster entry: %v", err)
 		return
 	}
-	if err := kubetest2.Run(&kubetest2.Options{}, &kubetest2.GKEClusterConfig{
-		GCPServiceAccount: serviceAccount,
+	if err := s.RunKubetest2(&kubetest2.Options{}, &kubetest2.GKEClusterConfig{
+		GCPServiceAccount: s.serviceAccount,
 		GCPProjectID:      projectName,
 		Name:              DefaultClusterName,
 		Region:            cp.Zone,
@@ -145,46 +163,60 @@ func CreateCluster(cp *clerk.ClusterParams, wg *sync.WaitGroup) {
 		Version:           "latest",
 		Scopes:            "cloud-platform",
 	}); err != nil {
-		if err := dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(Status, Fail)); err != nil {
+		if err := s.db.UpdateCluster(clusterID, clerk.UpdateStringField(Status, Fail)); err != nil {
 			log.Printf("Failed to insert delete new Cluster entry: %v", err)
 			return
 		}
-		err = boskosClient.ReleaseGKEProject(c.ProjectID)
+		err = s.boskosOps.ReleaseGKEProject(c.ProjectID)
 		if err != nil {
 			log.Printf("Failed to release Boskos Project: %v", err)
 			return
 		}
 		return
 	}
-	if err := dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(Status, Ready)); err != nil {
+	if err := s.db.UpdateCluster(clusterID, clerk.UpdateStringField(Status, Ready)); err != nil {
 		log.Printf("Failed to insert a new Cluster entry: %v", err)
 		return
 	}
 }
 
 // assign clusters if available upon request
-func AssignCluster(token string, w http.ResponseWriter) {
-	r, err := dbClient.GetRequest(token)
+func (s *Server) AssignCluster(token string, w http.ResponseWriter) {
+	r, err := s.db.GetRequest(token)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("there is an error getting the request with the token: %v, please try again", err), http.StatusForbidden)
 		return
 	}
 	// check if the Prow job has enough priority to get an existing cluster
-	ranking := dbClient.PriorityRanking(r)
-	numAvail := dbClient.CheckNumStatus(r.ClusterParams, Ready)
-	available, clusterID := dbClient.CheckAvail(r.ClusterParams)
-	var serviceResponse *ServiceResponse
+	ranking := s.db.PriorityRanking(r)
+	numAvail := s.db.CheckNumStatus(r.ClusterParams, Ready)
+	available, clusterID := s.db.CheckAvail(r.ClusterParams)
+	if available && ranking <= numAvail {
+		// claim the cluster, so that it's not deleted when shrinking the pools
+		// or assigned to another request in the meantime
+		claimed, err := s.db.ClaimCluster(clusterID, Ready, InUse)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("there is an error claiming available clusters: %v, please try again", err), http.StatusInternalServerError)
+			return
+		}
+		available = claimed
+	}
+	var serviceResponse *api.ServiceResponse
 	if available && ranking <= numAvail {
-		response, err := dbClient.GetCluster(clusterID)
+		response, err := s.db.GetCluster(clusterID)
 		if err != nil {
+			s.db.ClaimCluster(clusterID, InUse, Ready)
 			http.Error(w, fmt.Sprintf("there is an error getting available clusters: %v, please try again", err), http.StatusInternalServerError)
 			return
 		}
-		dbClient.UpdateRequest(r.ID, clerk.UpdateNumField(ClusterID, clusterID))
-		dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(Status, InUse))
-		serviceResponse = &ServiceResponse{IsReady: true, Message: "Your cluster is ready!", ClusterInfo: response}
+		// every cluster is created with the same name, in its own project
+		response.ClusterName = DefaultClusterName
+		leaseExpiry := time.Now().Add(s.LeaseDuration)
+		s.db.UpdateRequest(r.ID, clerk.UpdateNumField(ClusterID, clusterID), clerk.UpdateTimeField(LeaseExpiry, leaseExpiry))
+		s.recordLeaseEvent(r, clusterID, response.ProjectID, LeaseAcquired, fmt.Sprintf("leased until %v", leaseExpiry))
+		serviceResponse = &api.ServiceResponse{IsReady: true, Message: "Your cluster is ready!", ClusterInfo: response, LeaseExpiry: &leaseExpiry}
 	} else {
-		serviceResponse = &ServiceResponse{IsReady: false, Message: "Your cluster isn't ready yet! Please check back later."}
+		serviceResponse = &api.ServiceResponse{IsReady: false, Message: "Your cluster isn't ready yet! Please check back later."}
 	}
 	responseJson, err := json.Marshal(serviceResponse)
 	if err != nil {
@@ -195,7 +227,7 @@ func AssignCluster(token string, w http.ResponseWriter) {
 }
 
 // handle new cluster request
-func handleNewClusterRequest(w http.ResponseWriter, req *http.Request) {
+func (s *Server) handleNewClusterRequest(w http.ResponseWriter, req *http.Request) {
 	prowJobID := req.PostFormValue("prowjobid")
 	nodesCount, err := strconv.Atoi(req.PostFormValue("nodes"))
 	if err != nil || nodesCount <= 0 {
@@ -212,17 +244,17 @@ func handleNewClusterRequest(w http.ResponseWriter, req *http.Request) {
 	cp := clerk.NewClusterParams(clerk.AddZone(zone), clerk.AddNodes(int64(nodesCount)), clerk.AddNodeType(nodesType))
 	r := clerk.NewRequest(clerk.AddProwJobID(prowJobID), clerk.AddRequestTime(time.Now()))
 	r.ClusterParams = cp
-	accessToken, err := dbClient.InsertRequest(r)
+	accessToken, err := s.db.InsertRequest(r)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("there is an error creating new request: %v. Please try again.", err), http.StatusInternalServerError)
 		return
 	}
-	go checkPoolCap(cp)
+	go s.resizePools()
 	w.Write([]byte(accessToken))
 }
 
 // handle get cluster request
-func handleGetCluster(w http.ResponseWriter, req *http.Request) {
+func (s *Server) handleGetCluster(w http.ResponseWriter, req *http.Request) {
 	token := req.URL.Query().Get("token")
-	AssignCluster(token, w)
+	s.AssignCluster(token, w)
 }
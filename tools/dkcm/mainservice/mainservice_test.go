@@ -0,0 +1,439 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mainservice
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	boskoscommon "sigs.k8s.io/boskos/common"
+
+	boskosFake "knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos/fake"
+	"knative.dev/test-infra/pkg/clustermanager/kubetest2"
+	"knative.dev/test-infra/tools/dkcm/api"
+	"knative.dev/test-infra/tools/dkcm/clerk"
+	"knative.dev/test-infra/tools/dkcm/pool"
+)
+
+// testShape is the shape of the clusters requested in the tests, its pool
+// keeps minPoolSize clusters.
+var testShape = pool.Shape{Zone: "us-east1", Nodes: 2, NodeType: DefaultNodeType}
+
+const minPoolSize = 3
+
+func testPolicy() pool.Policy {
+	p := pool.DefaultPolicy()
+	p.Bounds = map[pool.Shape]pool.Bounds{testShape: {Min: minPoolSize, Max: 5}}
+	p.Budget = 6
+	return p
+}
+
+// fakeKubetest2 records the clusters created.
+type fakeKubetest2 struct {
+	err      error
+	clusters []kubetest2.GKEClusterConfig
+	mutex    sync.Mutex
+}
+
+func (f *fakeKubetest2) run(opts *kubetest2.Options, cc *kubetest2.GKEClusterConfig) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.clusters = append(f.clusters, *cc)
+	return f.err
+}
+
+func (f *fakeKubetest2) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.clusters)
+}
+
+// newTestServer returns a server whose cluster creations fail with
+// kubetest2Err, after applying the configure funcs to it.
+func newTestServer(t *testing.T, kubetest2Err error, configure ...func(*Server)) (*httptest.Server, *boskosFake.FakeBoskosClient, *fakeKubetest2) {
+	db, err := clerk.NewMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed creating the in-memory database: '%v'", err)
+	}
+	boskosOps := &boskosFake.FakeBoskosClient{}
+	for _, name := range []string{"p1", "p2", "p3", "p4", "p5", "p6"} {
+		boskosOps.NewGKEProject(name)
+	}
+	f := &fakeKubetest2{err: kubetest2Err}
+	s := NewServer(db, boskosOps, "sa.json", testPolicy())
+	s.RunKubetest2 = f.run
+	for _, c := range configure {
+		c(s)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		db.Close()
+	})
+	return ts, boskosOps, f
+}
+
+func requestCluster(t *testing.T, ts *httptest.Server, form url.Values) string {
+	resp, err := http.PostForm(ts.URL+"/request-cluster", form)
+	if err != nil {
+		t.Fatalf("Failed requesting a cluster: '%v'", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Requesting a cluster returned %d: %s", resp.StatusCode, body)
+	}
+	return string(body)
+}
+
+func getCluster(t *testing.T, ts *httptest.Server, token string) (int, *api.ServiceResponse) {
+	resp, err := http.Get(ts.URL + "/get-cluster?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed getting the cluster: '%v'", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	sr := &api.ServiceResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(sr); err != nil {
+		t.Fatalf("Failed decoding the response: '%v'", err)
+	}
+	return resp.StatusCode, sr
+}
+
+// waitForCluster polls the server until the cluster is ready, like Prow jobs do.
+func waitForCluster(t *testing.T, ts *httptest.Server, token string) *api.ServiceResponse {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if code, sr := getCluster(t, ts, token); code == http.StatusOK && sr.IsReady {
+			return sr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the cluster")
+	return nil
+}
+
+func TestClusterLifecycle(t *testing.T) {
+	ts, boskosOps, f := newTestServer(t, nil)
+
+	token := requestCluster(t, ts, url.Values{"prowjobid": {"job"}, "nodes": {"2"}, "zone": {"us-east1"}})
+	sr := waitForCluster(t, ts, token)
+	if sr.ClusterInfo == nil || sr.ClusterInfo.Zone != "us-east1" || sr.ClusterInfo.ProjectID == "" {
+		t.Errorf("Unexpected cluster info: %+v", sr.ClusterInfo)
+	}
+	// The pool is sized to its minimum, which is more than the clusters
+	// forecasted for a single request.
+	deadline := time.Now().Add(10 * time.Second)
+	for f.count() < minPoolSize && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := f.count(); n != minPoolSize {
+		t.Errorf("Created %d clusters, want %d", n, minPoolSize)
+	}
+	for _, cc := range f.clusters {
+		if cc.Region != "us-east1" || cc.MinNodes != 2 || cc.MaxNodes != 2 || cc.Machine != DefaultNodeType || cc.GCPServiceAccount != "sa.json" {
+			t.Errorf("Unexpected cluster config: %+v", cc)
+		}
+	}
+
+	// Cleaning the cluster releases its project.
+	resp, err := http.Get(ts.URL + "/clean-cluster?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed cleaning the cluster: '%v'", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Cleaning the cluster returned %d", resp.StatusCode)
+	}
+	for _, res := range boskosOps.GetResources() {
+		if res.Name == sr.ClusterInfo.ProjectID && res.State != boskoscommon.Free {
+			t.Errorf("Project %q of the cleaned cluster is %s, want free", res.Name, res.State)
+		}
+	}
+	// The cluster cannot be cleaned twice.
+	resp, err = http.Get(ts.URL + "/clean-cluster?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed cleaning the cluster again: '%v'", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Cleaning the cluster again returned %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestGetClusterWithWrongToken(t *testing.T) {
+	ts, _, _ := newTestServer(t, nil)
+	if code, _ := getCluster(t, ts, "' OR '1'='1"); code != http.StatusForbidden {
+		t.Errorf("Getting a cluster with a wrong token returned %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestClusterCreationFailure(t *testing.T) {
+	ts, boskosOps, f := newTestServer(t, errors.New("stockout"))
+
+	token := requestCluster(t, ts, url.Values{"prowjobid": {"job"}, "nodes": {"2"}, "zone": {"us-east1"}})
+	deadline := time.Now().Add(10 * time.Second)
+	for f.count() < minPoolSize && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The projects of the failed clusters are released.
+	deadline = time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		busy := 0
+		for _, res := range boskosOps.GetResources() {
+			if res.State == boskoscommon.Busy {
+				busy++
+			}
+		}
+		if busy == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, res := range boskosOps.GetResources() {
+		if res.State == boskoscommon.Busy {
+			t.Errorf("Project %q of a failed cluster is still busy", res.Name)
+		}
+	}
+	code, sr := getCluster(t, ts, token)
+	if code != http.StatusOK || sr.IsReady {
+		t.Errorf("Get cluster = %d, %+v, want a cluster not ready", code, sr)
+	}
+}
+
+func TestPoolAdminEndpoints(t *testing.T) {
+	ts, _, _ := newTestServer(t, nil)
+	requestCluster(t, ts, url.Values{"prowjobid": {"job"}, "nodes": {"2"}, "zone": {"us-east1"}})
+
+	// The sizing of the pools is visible once done.
+	var status PoolStatus
+	deadline := time.Now().Add(10 * time.Second)
+	for len(status.Decisions) == 0 && time.Now().Before(deadline) {
+		resp, err := http.Get(ts.URL + "/admin/pool")
+		if err != nil {
+			t.Fatalf("Failed getting the pool status: '%v'", err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Failed decoding the pool status: '%v'", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(status.Decisions) != 1 {
+		t.Fatalf("Got decisions %+v, want one for %v", status.Decisions, testShape)
+	}
+	if d := status.Decisions[0]; d.Shape != testShape || d.Pending != 1 || d.Desired != minPoolSize || d.Delta != minPoolSize || d.Reason != "min bound" {
+		t.Errorf("Unexpected decision: %+v", d)
+	}
+
+	// The requests can be exported to replay them in a simulation.
+	resp, err := http.Get(ts.URL + "/admin/request-log?window=1h")
+	if err != nil {
+		t.Fatalf("Failed getting the request log: '%v'", err)
+	}
+	requests, err := pool.ReadRequestLog(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed reading the request log: '%v'", err)
+	}
+	if len(requests) != 1 || requests[0].Shape != testShape {
+		t.Errorf("Got requests %+v, want one of shape %v", requests, testShape)
+	}
+	resp, err = http.Get(ts.URL + "/admin/request-log?window=yesterday")
+	if err != nil {
+		t.Fatalf("Failed getting the request log: '%v'", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Getting the request log with an invalid window returned %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestDeleteReadyClustersSkipsClaimedClusters(t *testing.T) {
+	db, err := clerk.NewMemoryDB()
+	if err != nil {
+		t.Fatalf("Failed creating the in-memory database: '%v'", err)
+	}
+	defer db.Close()
+	boskosOps := &boskosFake.FakeBoskosClient{}
+	s := NewServer(db, boskosOps, "sa.json", testPolicy())
+	for _, project := range []string{"p1", "p2"} {
+		boskosOps.NewGKEProject(project)
+		if _, err := boskosOps.AcquireGKEProject(boskosOps.GetResources()[0].Type); err != nil {
+			t.Fatalf("Failed acquiring project: '%v'", err)
+		}
+		c := clerk.NewCluster(clerk.AddProjectID(project))
+		c.ClusterParams = testShape.ClusterParams()
+		id, err := db.InsertCluster(c)
+		if err != nil {
+			t.Fatalf("Failed inserting cluster: '%v'", err)
+		}
+		if err := db.UpdateCluster(id, clerk.UpdateStringField(Status, Ready)); err != nil {
+			t.Fatalf("Failed updating cluster: '%v'", err)
+		}
+	}
+	clusters, err := db.ListClusters()
+	if err != nil {
+		t.Fatalf("Failed listing clusters: '%v'", err)
+	}
+	// The first cluster is assigned to a request after the pools were sized.
+	if claimed, err := db.ClaimCluster(clusters[0].ID, Ready, InUse); !claimed || err != nil {
+		t.Fatalf("ClaimCluster = %v, %v, want true, nil", claimed, err)
+	}
+
+	s.deleteReadyClusters(clusters, testShape, 2)
+	clusters, err = db.ListClusters()
+	if err != nil {
+		t.Fatalf("Failed listing clusters: '%v'", err)
+	}
+	if len(clusters) != 1 || clusters[0].ProjectID != "p1" || clusters[0].Status != InUse {
+		t.Errorf("Got clusters %v after deleting ready clusters, want only the one in use", clusters)
+	}
+	for _, r := range boskosOps.GetResources() {
+		if want := map[string]string{"p1": boskoscommon.Busy, "p2": boskoscommon.Free}[r.Name]; r.State != want {
+			t.Errorf("Project %q is %q, want %q", r.Name, r.State, want)
+		}
+	}
+}
+
+func renewLease(t *testing.T, ts *httptest.Server, token string) (int, *api.LeaseResponse) {
+	resp, err := http.Get(ts.URL + "/renew-lease?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed renewing the lease: '%v'", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	lr := &api.LeaseResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(lr); err != nil {
+		t.Fatalf("Failed decoding the response: '%v'", err)
+	}
+	return resp.StatusCode, lr
+}
+
+func getLeaseReport(t *testing.T, ts *httptest.Server) *LeaseReport {
+	resp, err := http.Get(ts.URL + "/admin/leases?window=1h")
+	if err != nil {
+		t.Fatalf("Failed getting the lease report: '%v'", err)
+	}
+	defer resp.Body.Close()
+	report := &LeaseReport{}
+	if err := json.NewDecoder(resp.Body).Decode(report); err != nil {
+		t.Fatalf("Failed decoding the lease report: '%v'", err)
+	}
+	return report
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	ts, _, _ := newTestServer(t, nil)
+	token := requestCluster(t, ts, url.Values{"prowjobid": {"job"}, "nodes": {"2"}, "zone": {"us-east1"}})
+	if code, _ := renewLease(t, ts, token); code != http.StatusForbidden {
+		t.Errorf("Renewing the lease before getting the cluster returned %d, want %d", code, http.StatusForbidden)
+	}
+	sr := waitForCluster(t, ts, token)
+	if sr.LeaseExpiry == nil || !sr.LeaseExpiry.After(time.Now().Add(DefaultLeaseDuration-time.Minute)) {
+		t.Errorf("Got lease expiry %v, want about %v from now", sr.LeaseExpiry, DefaultLeaseDuration)
+	}
+	code, lr := renewLease(t, ts, token)
+	if code != http.StatusOK || lr.LeaseExpiry.Before(*sr.LeaseExpiry) {
+		t.Errorf("Renewing the lease returned %d, %+v, want an expiry after %v", code, lr, sr.LeaseExpiry)
+	}
+
+	resp, err := http.Get(ts.URL + "/clean-cluster?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed cleaning the cluster: '%v'", err)
+	}
+	resp.Body.Close()
+	// The lease ends with the release of the cluster.
+	if code, _ := renewLease(t, ts, token); code != http.StatusForbidden {
+		t.Errorf("Renewing the lease after releasing the cluster returned %d, want %d", code, http.StatusForbidden)
+	}
+	var events []string
+	for _, e := range getLeaseReport(t, ts).Events {
+		events = append(events, e.Event)
+	}
+	if diff := cmp.Diff([]string{LeaseAcquired, LeaseReleased}, events); diff != "" {
+		t.Errorf("Lease events got(+) is different from wanted(-)\n%v", diff)
+	}
+}
+
+func TestReapExpiredLeases(t *testing.T) {
+	var s *Server
+	ts, boskosOps, f := newTestServer(t, nil, func(server *Server) {
+		s = server
+		s.LeaseDuration = time.Millisecond
+	})
+	token := requestCluster(t, ts, url.Values{"prowjobid": {"leaky-job"}, "nodes": {"2"}, "zone": {"us-east1"}})
+	sr := waitForCluster(t, ts, token)
+	time.Sleep(10 * time.Millisecond)
+	s.reapExpiredLeases()
+
+	// The Prow job can neither renew nor release the reclaimed cluster.
+	if code, _ := renewLease(t, ts, token); code != http.StatusForbidden {
+		t.Errorf("Renewing an expired lease returned %d, want %d", code, http.StatusForbidden)
+	}
+	resp, err := http.Get(ts.URL + "/clean-cluster?token=" + url.QueryEscape(token))
+	if err != nil {
+		t.Fatalf("Failed cleaning the cluster: '%v'", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Cleaning a reclaimed cluster returned %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	// The reclaimed cluster is never handed out again, its project is
+	// released to be cleaned up, and the pool is refilled with a new cluster.
+	for _, res := range boskosOps.GetResources() {
+		if res.Name == sr.ClusterInfo.ProjectID && res.State != boskoscommon.Free {
+			t.Errorf("Project of the reclaimed cluster is %q, want %q", res.State, boskoscommon.Free)
+		}
+	}
+	clusters, err := s.db.ListClusters()
+	if err != nil {
+		t.Fatalf("Failed listing clusters: '%v'", err)
+	}
+	for _, c := range clusters {
+		if c.ProjectID == sr.ClusterInfo.ProjectID {
+			t.Errorf("The reclaimed cluster is still in the pool: %v", c)
+		}
+	}
+	created := f.count()
+	s.resizePools()
+	if f.count() != created+1 {
+		t.Errorf("Created %d clusters after reclaiming one, want 1", f.count()-created)
+	}
+
+	report := getLeaseReport(t, ts)
+	if n := len(report.Events); n != 2 || report.Events[1].Event != LeaseDeleted {
+		t.Errorf("Got lease events %+v, want the last one to be %s", report.Events, LeaseDeleted)
+	}
+	if diff := cmp.Diff(map[string]int{"leaky-job": 1}, report.Leaks); diff != "" {
+		t.Errorf("Leaks got(+) is different from wanted(-)\n%v", diff)
+	}
+}
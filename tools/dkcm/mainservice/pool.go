@@ -0,0 +1,177 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mainservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"knative.dev/test-infra/tools/dkcm/clerk"
+	"knative.dev/test-infra/tools/dkcm/pool"
+)
+
+// PoolStatus is the last sizing of the pools, served by the admin endpoint.
+type PoolStatus struct {
+	Policy    pool.Policy     `json:"policy"`
+	Time      time.Time       `json:"time"`
+	Decisions []pool.Decision `json:"decisions"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// runPoolSizer resizes the pools at every interval, so that they also shrink
+// when no request comes in.
+func (s *Server) runPoolSizer(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.resizePools()
+	}
+}
+
+// resizePools sizes the pools from the request history and the clusters, then
+// creates or deletes clusters accordingly.
+func (s *Server) resizePools() {
+	s.chanLock <- struct{}{}
+	defer func() { <-s.chanLock }()
+	status := PoolStatus{Policy: s.policy, Time: time.Now()}
+	defer s.setPoolStatus(&status)
+
+	requests, err := s.db.ListRequests(s.policy.HistoryWindow)
+	if err != nil {
+		status.Error = fmt.Sprintf("failed listing the requests: %v", err)
+		log.Printf("Failed sizing the pools: %s", status.Error)
+		return
+	}
+	clusters, err := s.db.ListClusters()
+	if err != nil {
+		status.Error = fmt.Sprintf("failed listing the clusters: %v", err)
+		log.Printf("Failed sizing the pools: %s", status.Error)
+		return
+	}
+	poolRequests := make([]pool.Request, 0, len(requests))
+	for _, r := range requests {
+		// requests not assigned a cluster yet have ClusterID 0, timed out
+		// ones have -1
+		poolRequests = append(poolRequests, pool.Request{Shape: pool.ShapeOf(r.ClusterParams), Time: r.RequestTime(), Pending: r.ClusterID == 0})
+	}
+	poolClusters := make([]pool.Cluster, 0, len(clusters))
+	for _, c := range clusters {
+		var state pool.ClusterState
+		switch c.Status {
+		case WIP:
+			state = pool.ClusterCreating
+		case Ready:
+			state = pool.ClusterReady
+		case InUse:
+			state = pool.ClusterInUse
+		default:
+			// the projects of failed and deleted clusters are released
+			continue
+		}
+		poolClusters = append(poolClusters, pool.Cluster{Shape: pool.ShapeOf(c.ClusterParams), State: state})
+	}
+	status.Decisions = pool.Size(s.policy, status.Time, poolRequests, poolClusters)
+
+	var wg sync.WaitGroup
+	for _, d := range status.Decisions {
+		for i := int64(0); i < d.Delta; i++ {
+			wg.Add(1)
+			log.Printf("Creating a new cluster: %v (%s)", d.Shape, d.Reason)
+			go s.CreateCluster(d.Shape.ClusterParams(), &wg)
+		}
+		if d.Delta < 0 {
+			s.deleteReadyClusters(clusters, d.Shape, -d.Delta)
+		}
+	}
+	wg.Wait()
+}
+
+// deleteReadyClusters deletes n ready clusters of the shape and releases their
+// projects. The clusters are listed before sizing the pools, so each of them
+// is claimed first, skipping the ones assigned to a request in the meantime.
+func (s *Server) deleteReadyClusters(clusters []clerk.Cluster, shape pool.Shape, n int64) {
+	for _, c := range clusters {
+		if n == 0 {
+			return
+		}
+		if c.Status != Ready || pool.ShapeOf(c.ClusterParams) != shape {
+			continue
+		}
+		if claimed, err := s.db.ClaimCluster(c.ID, Ready, Deleting); err != nil {
+			log.Printf("Failed to claim the cluster for deletion: %v", err)
+			continue
+		} else if !claimed {
+			log.Printf("Cluster %d isn't ready anymore, skip deleting it", c.ID)
+			continue
+		}
+		log.Printf("Deleting a ready cluster: %v", c)
+		if err := s.db.DeleteCluster(c.ID); err != nil {
+			log.Printf("Failed to delete the Cluster entry: %v", err)
+			continue
+		}
+		if err := s.boskosOps.ReleaseGKEProject(c.ProjectID); err != nil {
+			log.Printf("Failed to release Boskos Project: %v", err)
+		}
+		n--
+	}
+}
+
+func (s *Server) setPoolStatus(status *PoolStatus) {
+	s.poolStatusMutex.Lock()
+	defer s.poolStatusMutex.Unlock()
+	s.poolStatus = *status
+}
+
+// handle getting the last sizing of the pools
+func (s *Server) handlePoolStatus(w http.ResponseWriter, req *http.Request) {
+	s.poolStatusMutex.Lock()
+	status := s.poolStatus
+	s.poolStatusMutex.Unlock()
+	responseJson, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("there is an error getting parsing response: %v, please try again", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJson)
+}
+
+// handle exporting the requests of a window, e.g. ?window=168h, as a log to
+// replay with the pool simulation
+func (s *Server) handleRequestLog(w http.ResponseWriter, req *http.Request) {
+	window := s.policy.HistoryWindow
+	if fromQuery := req.URL.Query().Get("window"); fromQuery != "" {
+		var err error
+		if window, err = time.ParseDuration(fromQuery); err != nil || window <= 0 {
+			http.Error(w, fmt.Sprintf("invalid window %q, it must be a positive duration", fromQuery), http.StatusBadRequest)
+			return
+		}
+	}
+	requests, err := s.db.ListRequests(window)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("there is an error listing the requests: %v, please try again", err), http.StatusInternalServerError)
+		return
+	}
+	requestLog := make([]pool.Request, 0, len(requests))
+	for _, r := range requests {
+		requestLog = append(requestLog, pool.Request{Shape: pool.ShapeOf(r.ClusterParams), Time: r.RequestTime()})
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	pool.WriteRequestLog(w, requestLog)
+}
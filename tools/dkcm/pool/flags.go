@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// boundsFlag sets the bounds of a shape each time it's passed.
+type boundsFlag map[Shape]Bounds
+
+func (f *boundsFlag) String() string {
+	var specs []string
+	for s, b := range *f {
+		specs = append(specs, fmt.Sprintf("%s=%d:%d", s, b.Min, b.Max))
+	}
+	sort.Strings(specs)
+	return strings.Join(specs, ", ")
+}
+
+func (f *boundsFlag) Set(val string) error {
+	s, b, err := ParseShapeBounds(val)
+	if err != nil {
+		return err
+	}
+	if *f == nil {
+		*f = make(map[Shape]Bounds)
+	}
+	(*f)[s] = b
+	return nil
+}
+
+// defaultBoundsFlag sets the default bounds from min:max.
+type defaultBoundsFlag Bounds
+
+func (f *defaultBoundsFlag) String() string {
+	return fmt.Sprintf("%d:%d", f.Min, f.Max)
+}
+
+func (f *defaultBoundsFlag) Set(val string) error {
+	b, err := parseBounds(val)
+	if err != nil {
+		return err
+	}
+	*f = defaultBoundsFlag(b)
+	return nil
+}
+
+// AddFlags adds the flags configuring the policy to fs, with the current
+// values of the policy as defaults.
+func (p *Policy) AddFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&p.RecentWindow, "pool-recent-window", p.RecentWindow, "The requests in this window give the current request rate")
+	fs.DurationVar(&p.HistoryWindow, "pool-history-window", p.HistoryWindow, "The requests in this window give the request rate by hour of the day")
+	fs.Float64Var(&p.RecentWeight, "pool-recent-weight", p.RecentWeight, "The weight of the current request rate in the forecast, between 0 and 1")
+	fs.DurationVar(&p.LeadTime, "pool-lead-time", p.LeadTime, "How long it takes to get a new cluster ready, pools are sized to serve the requests forecasted in this period")
+	fs.Var((*defaultBoundsFlag)(&p.DefaultBounds), "pool-default-bounds", "The bounds (min:max) of the pools of shapes without bounds set by --pool-bounds")
+	fs.Var((*boundsFlag)(&p.Bounds), "pool-bounds", "The bounds of the pool of a shape (zone/nodes/nodeType=min:max), can be passed multiple times")
+	fs.Int64Var(&p.Budget, "pool-budget", p.Budget, "The maximum number of clusters of all shapes, including the ones in use")
+}
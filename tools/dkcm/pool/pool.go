@@ -0,0 +1,348 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pool sizes the pools of warm clusters kept by DKCM, from the demand
+// forecasted for each cluster shape.
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+// Shape is the configuration shared by the clusters of a pool.
+type Shape struct {
+	Zone     string `json:"zone"`
+	Nodes    int64  `json:"nodes"`
+	NodeType string `json:"nodeType"`
+}
+
+// ShapeOf returns the shape of clusters with the given params.
+func ShapeOf(cp *clerk.ClusterParams) Shape {
+	return Shape{Zone: cp.Zone, Nodes: cp.Nodes, NodeType: cp.NodeType}
+}
+
+// ClusterParams returns the params for creating clusters of the shape.
+func (s Shape) ClusterParams() *clerk.ClusterParams {
+	return clerk.NewClusterParams(clerk.AddZone(s.Zone), clerk.AddNodes(s.Nodes), clerk.AddNodeType(s.NodeType))
+}
+
+func (s Shape) String() string {
+	return fmt.Sprintf("%s/%d/%s", s.Zone, s.Nodes, s.NodeType)
+}
+
+// ParseShapeBounds parses the bounds of a shape from zone/nodes/nodeType=min:max,
+// e.g. us-west1/4/e2-standard-4=1:5
+func ParseShapeBounds(spec string) (Shape, Bounds, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return Shape{}, Bounds{}, fmt.Errorf("%q must be zone/nodes/nodeType=min:max", spec)
+	}
+	fields := strings.Split(parts[0], "/")
+	if len(fields) != 3 || fields[0] == "" || fields[2] == "" {
+		return Shape{}, Bounds{}, fmt.Errorf("shape %q must be zone/nodes/nodeType", parts[0])
+	}
+	nodes, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || nodes <= 0 {
+		return Shape{}, Bounds{}, fmt.Errorf("nodes of shape %q must be a positive number", parts[0])
+	}
+	b, err := parseBounds(parts[1])
+	if err != nil {
+		return Shape{}, Bounds{}, err
+	}
+	return Shape{Zone: fields[0], Nodes: nodes, NodeType: fields[2]}, b, nil
+}
+
+// parseBounds parses bounds from min:max.
+func parseBounds(spec string) (Bounds, error) {
+	minMax := strings.Split(spec, ":")
+	if len(minMax) != 2 {
+		return Bounds{}, fmt.Errorf("bounds %q must be min:max", spec)
+	}
+	var b Bounds
+	var err error
+	if b.Min, err = strconv.ParseInt(minMax[0], 10, 64); err != nil {
+		return Bounds{}, fmt.Errorf("invalid min of bounds %q: %w", spec, err)
+	}
+	if b.Max, err = strconv.ParseInt(minMax[1], 10, 64); err != nil {
+		return Bounds{}, fmt.Errorf("invalid max of bounds %q: %w", spec, err)
+	}
+	return b, b.validate()
+}
+
+// Bounds are the minimum and maximum numbers of warm clusters in a pool.
+type Bounds struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+func (b Bounds) validate() error {
+	if b.Min < 0 || b.Max < b.Min {
+		return fmt.Errorf("bounds %d:%d must satisfy 0 <= min <= max", b.Min, b.Max)
+	}
+	return nil
+}
+
+// Policy configures how the pools are sized.
+type Policy struct {
+	// RecentWindow: the requests in this window give the current request rate
+	RecentWindow time.Duration `json:"recentWindow"`
+	// HistoryWindow: the requests in this window give the request rate by hour of the day
+	HistoryWindow time.Duration `json:"historyWindow"`
+	// RecentWeight: the weight of the current request rate in the forecast, between 0 and 1,
+	// the request rate by hour of the day has the rest
+	RecentWeight float64 `json:"recentWeight"`
+	// LeadTime: how long it takes to get a new cluster ready, pools are sized to serve the
+	// requests forecasted in this period
+	LeadTime time.Duration `json:"leadTime"`
+	// DefaultBounds: the bounds of the pools of shapes not in Bounds
+	DefaultBounds Bounds `json:"defaultBounds"`
+	// Bounds: the bounds of the pools by shape
+	Bounds map[Shape]Bounds `json:"-"`
+	// Budget: the maximum number of clusters of all shapes, including the ones in use,
+	// usually the number of Boskos projects DKCM can use
+	Budget int64 `json:"budget"`
+}
+
+// DefaultPolicy returns the policy used if not configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		RecentWindow:  time.Hour,
+		HistoryWindow: 7 * 24 * time.Hour,
+		RecentWeight:  0.5,
+		LeadTime:      15 * time.Minute,
+		DefaultBounds: Bounds{Min: 0, Max: 5},
+		Budget:        20,
+	}
+}
+
+// Validate checks the policy.
+func (p Policy) Validate() error {
+	if p.RecentWindow <= 0 || p.HistoryWindow <= 0 || p.LeadTime <= 0 {
+		return errors.New("windows and lead time must be positive")
+	}
+	if p.RecentWeight < 0 || p.RecentWeight > 1 {
+		return fmt.Errorf("recent weight %v must be between 0 and 1", p.RecentWeight)
+	}
+	if p.Budget < 0 {
+		return fmt.Errorf("budget %d must not be negative", p.Budget)
+	}
+	if err := p.DefaultBounds.validate(); err != nil {
+		return fmt.Errorf("invalid default bounds: %w", err)
+	}
+	for s, b := range p.Bounds {
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("invalid bounds of shape %s: %w", s, err)
+		}
+	}
+	return nil
+}
+
+func (p Policy) bounds(s Shape) Bounds {
+	if b, ok := p.Bounds[s]; ok {
+		return b
+	}
+	return p.DefaultBounds
+}
+
+// Request is a cluster request, as recorded by the clerk.
+type Request struct {
+	Shape Shape
+	Time  time.Time
+	// Pending is true if the request is still waiting for a cluster
+	Pending bool
+}
+
+// ClusterState is the state of a cluster with regard to the pool.
+type ClusterState int
+
+const (
+	// ClusterCreating is a cluster being created for the pool.
+	ClusterCreating ClusterState = iota
+	// ClusterReady is a warm cluster waiting for a request.
+	ClusterReady
+	// ClusterInUse is a cluster assigned to a request, it still counts in the budget.
+	ClusterInUse
+)
+
+// Cluster is a cluster holding a Boskos project.
+type Cluster struct {
+	Shape Shape
+	State ClusterState
+}
+
+// Decision is how the pool of a shape is sized, and why.
+type Decision struct {
+	Shape Shape `json:"shape"`
+	// RecentRate: requests per hour in the recent window
+	RecentRate float64 `json:"recentRate"`
+	// TimeOfDayRate: requests per hour in the hour following the current time of day,
+	// on the previous days of the history window
+	TimeOfDayRate float64 `json:"timeOfDayRate"`
+	// Forecast: requests expected in the lead time
+	Forecast float64 `json:"forecast"`
+	// Pending: requests waiting for a cluster
+	Pending int64 `json:"pending"`
+
+	Creating int64 `json:"creating"`
+	Ready    int64 `json:"ready"`
+	InUse    int64 `json:"inUse"`
+
+	// Desired: the number of warm clusters, ready or being created, wanted in the pool
+	Desired int64 `json:"desired"`
+	// Delta: the number of clusters to create if positive, or to delete if negative
+	Delta  int64  `json:"delta"`
+	Reason string `json:"reason"`
+}
+
+// Size returns the decisions sizing the pool of each shape at the given time,
+// from the requests in the history window and the clusters holding projects.
+// Decisions are sorted by shape.
+func Size(p Policy, now time.Time, requests []Request, clusters []Cluster) []Decision {
+	byShape := make(map[Shape]*Decision)
+	decision := func(s Shape) *Decision {
+		if d, ok := byShape[s]; ok {
+			return d
+		}
+		d := &Decision{Shape: s}
+		byShape[s] = d
+		return d
+	}
+	for s := range p.Bounds {
+		decision(s)
+	}
+
+	days := math.Max(1, math.Floor(p.HistoryWindow.Hours()/24))
+	recentCounts := make(map[Shape]int)
+	timeOfDayCounts := make(map[Shape]int)
+	for _, r := range requests {
+		if r.Time.After(now) || !r.Time.After(now.Add(-p.HistoryWindow)) {
+			continue
+		}
+		d := decision(r.Shape)
+		if r.Pending {
+			d.Pending++
+		}
+		if r.Time.After(now.Add(-p.RecentWindow)) {
+			recentCounts[r.Shape]++
+		}
+		// requests made in the hour following the current time of day, on
+		// the previous days, tell what's coming next
+		if age := now.Sub(r.Time); age > 23*time.Hour && age%(24*time.Hour) >= 23*time.Hour {
+			timeOfDayCounts[r.Shape]++
+		}
+	}
+	var inUse int64
+	for _, c := range clusters {
+		d := decision(c.Shape)
+		switch c.State {
+		case ClusterCreating:
+			d.Creating++
+		case ClusterReady:
+			d.Ready++
+		case ClusterInUse:
+			d.InUse++
+			inUse++
+		}
+	}
+
+	decisions := make([]*Decision, 0, len(byShape))
+	wanted := make(map[Shape]int64, len(byShape))
+	for s, d := range byShape {
+		d.RecentRate = float64(recentCounts[s]) / p.RecentWindow.Hours()
+		d.TimeOfDayRate = float64(timeOfDayCounts[s]) / days
+		d.Forecast = (p.RecentWeight*d.RecentRate + (1-p.RecentWeight)*d.TimeOfDayRate) * p.LeadTime.Hours()
+		// pending requests take the next clusters ready, the pool needs more
+		// for the requests forecasted
+		want := d.Pending + int64(math.Ceil(d.Forecast))
+		d.Reason = "forecast"
+		if d.Pending > 0 {
+			d.Reason = "pending requests and forecast"
+		}
+		b := p.bounds(s)
+		if want < b.Min {
+			want = b.Min
+			d.Reason = "min bound"
+		}
+		if want > b.Max {
+			want = b.Max
+			d.Reason = "max bound"
+		}
+		wanted[s] = want
+		decisions = append(decisions, d)
+	}
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].Shape.String() < decisions[j].Shape.String()
+	})
+
+	allocate(p, decisions, wanted, p.Budget-inUse)
+
+	result := make([]Decision, len(decisions))
+	for i, d := range decisions {
+		d.Delta = d.Desired - d.Ready - d.Creating
+		// only ready clusters can be deleted, the ones being created are
+		// left as they are
+		if d.Delta < -d.Ready {
+			d.Delta = -d.Ready
+		}
+		result[i] = *d
+	}
+	return result
+}
+
+// allocate sets the desired sizes of the pools within the budget left by the
+// clusters in use. The minimums are allocated first, then one cluster at a
+// time to the pool missing the most clusters, so that the budget is shared
+// according to the demand.
+func allocate(p Policy, decisions []*Decision, wanted map[Shape]int64, budget int64) {
+	for _, phase := range []func(d *Decision) int64{
+		func(d *Decision) int64 { return minInt64(p.bounds(d.Shape).Min, wanted[d.Shape]) },
+		func(d *Decision) int64 { return wanted[d.Shape] },
+	} {
+		for budget > 0 {
+			var next *Decision
+			for _, d := range decisions {
+				if missing := phase(d) - d.Desired; missing > 0 && (next == nil || missing > phase(next)-next.Desired) {
+					next = d
+				}
+			}
+			if next == nil {
+				break
+			}
+			next.Desired++
+			budget--
+		}
+	}
+	for _, d := range decisions {
+		if d.Desired < wanted[d.Shape] {
+			d.Reason = "budget"
+		}
+	}
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var (
+	small = Shape{Zone: "us-west1", Nodes: 2, NodeType: "e2-standard-4"}
+	large = Shape{Zone: "us-west1", Nodes: 6, NodeType: "e2-standard-8"}
+)
+
+func testPolicy() Policy {
+	p := DefaultPolicy()
+	p.DefaultBounds = Bounds{Min: 0, Max: 10}
+	p.Budget = 100
+	return p
+}
+
+// requestsAt returns n requests of the shape spread evenly in the hour before t.
+func requestsAt(s Shape, t time.Time, n int) []Request {
+	var requests []Request
+	for i := 0; i < n; i++ {
+		requests = append(requests, Request{Shape: s, Time: t.Add(-time.Duration(i+1) * time.Hour / time.Duration(n+1))})
+	}
+	return requests
+}
+
+func clustersOf(s Shape, state ClusterState, n int) []Cluster {
+	var clusters []Cluster
+	for i := 0; i < n; i++ {
+		clusters = append(clusters, Cluster{Shape: s, State: state})
+	}
+	return clusters
+}
+
+func concat(lists ...[]Request) []Request {
+	var all []Request
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+func TestSize(t *testing.T) {
+	now := time.Date(2020, 8, 10, 10, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-23 * time.Hour)
+	pending := func(rs []Request) []Request {
+		for i := range rs {
+			rs[i].Pending = true
+		}
+		return rs
+	}
+	withBounds := func(b map[Shape]Bounds, budget int64) Policy {
+		p := testPolicy()
+		p.Bounds = b
+		p.Budget = budget
+		return p
+	}
+
+	datas := []struct {
+		name     string
+		policy   Policy
+		requests []Request
+		clusters []Cluster
+		want     []Decision
+	}{{
+		name:   "no demand",
+		policy: testPolicy(),
+		want:   []Decision{},
+	}, {
+		name:   "min bound without demand",
+		policy: withBounds(map[Shape]Bounds{small: {Min: 2, Max: 4}}, 100),
+		want:   []Decision{{Shape: small, Desired: 2, Delta: 2, Reason: "min bound"}},
+	}, {
+		// 16 requests in the last hour, and 8 in the following hour yesterday:
+		// (0.5*16 + 0.5*8/7) * 0.25 = 2.14 requests expected in the lead time
+		name:     "recent rate and time of day",
+		policy:   testPolicy(),
+		requests: concat(requestsAt(small, now, 16), requestsAt(small, yesterday, 8)),
+		clusters: clustersOf(small, ClusterReady, 1),
+		want: []Decision{{
+			Shape: small, RecentRate: 16, TimeOfDayRate: 8.0 / 7, Forecast: (8 + 4.0/7) / 4,
+			Ready: 1, Desired: 3, Delta: 2, Reason: "forecast",
+		}},
+	}, {
+		name:     "pending requests",
+		policy:   testPolicy(),
+		requests: pending(requestsAt(small, now, 4)),
+		clusters: clustersOf(small, ClusterCreating, 1),
+		want: []Decision{{
+			Shape: small, RecentRate: 4, Forecast: 0.5,
+			Pending: 4, Creating: 1, Desired: 5, Delta: 4, Reason: "pending requests and forecast",
+		}},
+	}, {
+		name:     "max bound",
+		policy:   withBounds(map[Shape]Bounds{small: {Min: 0, Max: 2}}, 100),
+		requests: pending(requestsAt(small, now, 4)),
+		want: []Decision{{
+			Shape: small, RecentRate: 4, Forecast: 0.5,
+			Pending: 4, Desired: 2, Delta: 2, Reason: "max bound",
+		}},
+	}, {
+		name:     "scale down ready clusters only",
+		policy:   testPolicy(),
+		clusters: append(clustersOf(small, ClusterReady, 2), clustersOf(small, ClusterCreating, 3)...),
+		want:     []Decision{{Shape: small, Ready: 2, Creating: 3, Delta: -2, Reason: "forecast"}},
+	}, {
+		name:     "requests out of the history window are ignored",
+		policy:   testPolicy(),
+		requests: requestsAt(small, now.Add(-8*24*time.Hour), 10),
+		want:     []Decision{},
+	}, {
+		// the budget of 6 less the 2 clusters in use leaves 4 clusters:
+		// 1 for the min of the large pool, then 3 for the small pool
+		// that misses the most clusters
+		name:   "budget shared by demand",
+		policy: withBounds(map[Shape]Bounds{large: {Min: 1, Max: 10}}, 6),
+		requests: concat(
+			pending(requestsAt(small, now, 5)),
+			pending(requestsAt(large, now, 2))),
+		clusters: clustersOf(large, ClusterInUse, 2),
+		want: []Decision{{
+			Shape: small, RecentRate: 5, Forecast: 0.625,
+			Pending: 5, Desired: 3, Delta: 3, Reason: "budget",
+		}, {
+			Shape: large, RecentRate: 2, Forecast: 0.25,
+			Pending: 2, InUse: 2, Desired: 1, Delta: 1, Reason: "budget",
+		}},
+	}, {
+		name:     "budget exhausted by clusters in use",
+		policy:   withBounds(nil, 2),
+		requests: pending(requestsAt(small, now, 1)),
+		clusters: append(clustersOf(small, ClusterInUse, 2), clustersOf(small, ClusterReady, 1)...),
+		want: []Decision{{
+			Shape: small, RecentRate: 1, Forecast: 0.125,
+			Pending: 1, Ready: 1, InUse: 2, Delta: -1, Reason: "budget",
+		}},
+	}}
+
+	for _, data := range datas {
+		t.Run(data.name, func(t *testing.T) {
+			got := Size(data.policy, now, data.requests, data.clusters)
+			if diff := cmp.Diff(data.want, got, cmpopts.EquateApprox(0, 1e-9), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("got(+) is different from wanted(-)\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestParseShapeBounds(t *testing.T) {
+	datas := []struct {
+		spec      string
+		wantShape Shape
+		wantBound Bounds
+		wantErr   bool
+	}{
+		{"us-west1/2/e2-standard-4=1:3", small, Bounds{Min: 1, Max: 3}, false},
+		{"us-west1/2/e2-standard-4=0:0", small, Bounds{}, false},
+		{"us-west1/2/e2-standard-4", Shape{}, Bounds{}, true},
+		{"us-west1/e2-standard-4=1:3", Shape{}, Bounds{}, true},
+		{"us-west1/zero/e2-standard-4=1:3", Shape{}, Bounds{}, true},
+		{"us-west1/2/e2-standard-4=3", Shape{}, Bounds{}, true},
+		{"us-west1/2/e2-standard-4=3:1", small, Bounds{Min: 3, Max: 1}, true},
+		{"us-west1/2/e2-standard-4=-1:1", small, Bounds{Min: -1, Max: 1}, true},
+	}
+	for _, data := range datas {
+		shape, bounds, err := ParseShapeBounds(data.spec)
+		if (err != nil) != data.wantErr {
+			t.Errorf("ParseShapeBounds(%q) got error '%v', want error: %v", data.spec, err, data.wantErr)
+		}
+		if err == nil && (shape != data.wantShape || bounds != data.wantBound) {
+			t.Errorf("ParseShapeBounds(%q) = %v, %v, want %v, %v", data.spec, shape, bounds, data.wantShape, data.wantBound)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	datas := []struct {
+		name    string
+		modify  func(*Policy)
+		wantErr bool
+	}{
+		{"default", func(*Policy) {}, false},
+		{"zero lead time", func(p *Policy) { p.LeadTime = 0 }, true},
+		{"recent weight above 1", func(p *Policy) { p.RecentWeight = 1.5 }, true},
+		{"negative budget", func(p *Policy) { p.Budget = -1 }, true},
+		{"invalid default bounds", func(p *Policy) { p.DefaultBounds = Bounds{Min: 2, Max: 1} }, true},
+		{"invalid shape bounds", func(p *Policy) { p.Bounds = map[Shape]Bounds{small: {Min: 2, Max: 1}} }, true},
+	}
+	for _, data := range datas {
+		p := DefaultPolicy()
+		data.modify(&p)
+		if err := p.Validate(); (err != nil) != data.wantErr {
+			t.Errorf("%s: got error '%v', want error: %v", data.name, err, data.wantErr)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Simulation replays a request log against a sizing policy.
+type Simulation struct {
+	Policy Policy
+	// Step: how often the pools are resized and the requests served
+	Step time.Duration
+	// CreationTime: how long it takes to create a cluster
+	CreationTime time.Duration
+	// HoldTime: how long a request holds its cluster before releasing it
+	HoldTime time.Duration
+	// Timeout: how long to keep running after the last request before giving up
+	// on the requests never served, e.g. because of a zero budget
+	Timeout time.Duration
+}
+
+// SimulationResult summarizes how the requests of a simulation were served.
+type SimulationResult struct {
+	Requests int `json:"requests"`
+	Served   int `json:"served"`
+
+	MeanWait time.Duration `json:"meanWait"`
+	P95Wait  time.Duration `json:"p95Wait"`
+	MaxWait  time.Duration `json:"maxWait"`
+
+	ClustersCreated int `json:"clustersCreated"`
+	ClustersDeleted int `json:"clustersDeleted"`
+	// IdleClusterHours: the hours clusters spent ready without being used
+	IdleClusterHours float64 `json:"idleClusterHours"`
+	// PeakClusters: the maximum number of clusters holding projects at the same time
+	PeakClusters int `json:"peakClusters"`
+}
+
+func (r SimulationResult) String() string {
+	return fmt.Sprintf("served %d/%d requests, wait mean %v p95 %v max %v, "+
+		"created %d clusters, deleted %d, idle for %.1f cluster hours, peak %d clusters",
+		r.Served, r.Requests, r.MeanWait, r.P95Wait, r.MaxWait,
+		r.ClustersCreated, r.ClustersDeleted, r.IdleClusterHours, r.PeakClusters)
+}
+
+type simCluster struct {
+	shape Shape
+	state ClusterState
+	// until is when a cluster being created gets ready, or a cluster in use is released
+	until time.Time
+}
+
+// Run replays the requests of the log, which doesn't need to be sorted.
+func (s Simulation) Run(log []Request) SimulationResult {
+	requests := make([]Request, len(log))
+	copy(requests, log)
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].Time.Before(requests[j].Time) })
+
+	result := SimulationResult{Requests: len(requests)}
+	if len(requests) == 0 {
+		return result
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 24 * time.Hour
+	}
+	end := requests[len(requests)-1].Time.Add(timeout)
+
+	var (
+		clusters []*simCluster
+		history  []Request
+		// pending holds the indexes in history of the requests waiting for a cluster
+		pending []int
+		waits   []time.Duration
+		next    int
+	)
+	for now := requests[0].Time; len(waits) < len(requests) && !now.After(end); now = now.Add(s.Step) {
+		// release the clusters no longer used and get the created ones ready
+		kept := clusters[:0]
+		for _, c := range clusters {
+			if !c.until.After(now) {
+				if c.state == ClusterInUse {
+					continue
+				}
+				if c.state == ClusterCreating {
+					c.state = ClusterReady
+				}
+			}
+			kept = append(kept, c)
+		}
+		clusters = kept
+
+		for ; next < len(requests) && !requests[next].Time.After(now); next++ {
+			r := requests[next]
+			r.Pending = true
+			history = append(history, r)
+			pending = append(pending, len(history)-1)
+		}
+
+		// serve the pending requests in order with the ready clusters
+		waiting := pending[:0]
+		for _, i := range pending {
+			c := findCluster(clusters, history[i].Shape, ClusterReady)
+			if c == nil {
+				waiting = append(waiting, i)
+				continue
+			}
+			c.state, c.until = ClusterInUse, now.Add(s.HoldTime)
+			history[i].Pending = false
+			waits = append(waits, now.Sub(history[i].Time))
+		}
+		pending = waiting
+
+		snapshot := make([]Cluster, len(clusters))
+		for i, c := range clusters {
+			snapshot[i] = Cluster{Shape: c.shape, State: c.state}
+		}
+		for _, d := range Size(s.Policy, now, history, snapshot) {
+			for i := int64(0); i < d.Delta; i++ {
+				clusters = append(clusters, &simCluster{shape: d.Shape, state: ClusterCreating, until: now.Add(s.CreationTime)})
+				result.ClustersCreated++
+			}
+			for i := d.Delta; i < 0; i++ {
+				c := findCluster(clusters, d.Shape, ClusterReady)
+				clusters = removeCluster(clusters, c)
+				result.ClustersDeleted++
+			}
+		}
+
+		ready := 0
+		for _, c := range clusters {
+			if c.state == ClusterReady {
+				ready++
+			}
+		}
+		result.IdleClusterHours += float64(ready) * s.Step.Hours()
+		if len(clusters) > result.PeakClusters {
+			result.PeakClusters = len(clusters)
+		}
+		history = trimHistory(history, &pending, now.Add(-s.Policy.HistoryWindow))
+	}
+
+	result.Served = len(waits)
+	if len(waits) > 0 {
+		sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+		var total time.Duration
+		for _, w := range waits {
+			total += w
+		}
+		result.MeanWait = total / time.Duration(len(waits))
+		result.P95Wait = waits[int(math.Ceil(0.95*float64(len(waits))))-1]
+		result.MaxWait = waits[len(waits)-1]
+	}
+	return result
+}
+
+func findCluster(clusters []*simCluster, shape Shape, state ClusterState) *simCluster {
+	for _, c := range clusters {
+		if c.shape == shape && c.state == state {
+			return c
+		}
+	}
+	return nil
+}
+
+func removeCluster(clusters []*simCluster, c *simCluster) []*simCluster {
+	for i := range clusters {
+		if clusters[i] == c {
+			return append(clusters[:i], clusters[i+1:]...)
+		}
+	}
+	return clusters
+}
+
+// trimHistory drops the requests older than since that are not pending, and
+// updates the indexes of the pending requests accordingly.
+func trimHistory(history []Request, pending *[]int, since time.Time) []Request {
+	drop := 0
+	for drop < len(history) && !history[drop].Time.After(since) && !history[drop].Pending {
+		drop++
+	}
+	if drop == 0 {
+		return history
+	}
+	for i := range *pending {
+		(*pending)[i] -= drop
+	}
+	return history[drop:]
+}
+
+// ReadRequestLog reads a request log in CSV, with one request per line as
+// time,zone,nodes,nodeType where the time is in RFC 3339, e.g.
+//
+//	2020-08-01T10:04:05Z,us-west1,4,e2-standard-4
+//
+// Empty lines and lines starting with # are ignored.
+func ReadRequestLog(r io.Reader) ([]Request, error) {
+	var requests []Request
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: %q must be time,zone,nodes,nodeType", line, text)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time: %w", line, err)
+		}
+		nodes, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || nodes <= 0 {
+			return nil, fmt.Errorf("line %d: nodes %q must be a positive number", line, fields[2])
+		}
+		requests = append(requests, Request{Shape: Shape{Zone: fields[1], Nodes: nodes, NodeType: fields[3]}, Time: t})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading the request log: %w", err)
+	}
+	return requests, nil
+}
+
+// WriteRequestLog writes the requests in the format read by ReadRequestLog.
+func WriteRequestLog(w io.Writer, requests []Request) error {
+	for _, r := range requests {
+		if _, err := fmt.Fprintf(w, "%s,%s,%d,%s\n",
+			r.Time.UTC().Format(time.RFC3339), r.Shape.Zone, r.Shape.Nodes, r.Shape.NodeType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadRequestLog(t *testing.T) {
+	log := `# time,zone,nodes,nodeType
+2020-08-01T10:04:05Z,us-west1,2,e2-standard-4
+
+2020-08-01T11:00:00+02:00, us-west1, 6, e2-standard-8
+`
+	got, err := ReadRequestLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("Unexpected error reading the log: '%v'", err)
+	}
+	want := []Request{
+		{Shape: small, Time: time.Date(2020, 8, 1, 10, 4, 5, 0, time.UTC)},
+		{Shape: large, Time: time.Date(2020, 8, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	// a log written can be read back
+	var buf bytes.Buffer
+	if err := WriteRequestLog(&buf, got); err != nil {
+		t.Fatalf("Unexpected error writing the log: '%v'", err)
+	}
+	again, err := ReadRequestLog(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error reading the written log: '%v'", err)
+	}
+	if diff := cmp.Diff(want, again, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	for _, invalid := range []string{
+		"2020-08-01T10:04:05Z,us-west1,2",
+		"yesterday,us-west1,2,e2-standard-4",
+		"2020-08-01T10:04:05Z,us-west1,0,e2-standard-4",
+	} {
+		if _, err := ReadRequestLog(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error reading %q", invalid)
+		}
+	}
+}
+
+func TestSimulation(t *testing.T) {
+	start := time.Date(2020, 8, 10, 9, 0, 0, 0, time.UTC)
+	// a request every 5 minutes for 3 hours
+	var log []Request
+	for i := 0; i < 36; i++ {
+		log = append(log, Request{Shape: small, Time: start.Add(time.Duration(i) * 5 * time.Minute)})
+	}
+	sim := Simulation{
+		Step:         time.Minute,
+		CreationTime: 10 * time.Minute,
+		HoldTime:     20 * time.Minute,
+	}
+
+	// without warm clusters, every request waits for its cluster to be created
+	sim.Policy = testPolicy()
+	sim.Policy.RecentWeight = 0
+	cold := sim.Run(log)
+	if cold.Served != len(log) || cold.MeanWait < sim.CreationTime {
+		t.Errorf("Without forecast got %v, want all requests served after at least %v", cold, sim.CreationTime)
+	}
+
+	// forecasting from the recent rate keeps clusters warm, requests wait less,
+	// at the cost of clusters waiting for requests
+	sim.Policy = testPolicy()
+	sim.Policy.LeadTime = 30 * time.Minute
+	warm := sim.Run(log)
+	if warm.Served != len(log) || warm.MeanWait >= cold.MeanWait || warm.P95Wait >= cold.P95Wait {
+		t.Errorf("With forecast got %v, want all requests served faster than %v", warm, cold)
+	}
+	if warm.IdleClusterHours <= cold.IdleClusterHours {
+		t.Errorf("With forecast got %v idle cluster hours, want more than %v", warm.IdleClusterHours, cold.IdleClusterHours)
+	}
+
+	// the budget caps the clusters, some requests are never served
+	sim.Policy.Budget = 0
+	sim.Timeout = time.Hour
+	none := sim.Run(log)
+	if none.Served != 0 || none.PeakClusters != 0 || none.Requests != len(log) {
+		t.Errorf("With no budget got %v, want no request served", none)
+	}
+	sim.Policy.Budget = 2
+	capped := sim.Run(log)
+	if capped.PeakClusters > 2 {
+		t.Errorf("With a budget of 2 got %d clusters at peak", capped.PeakClusters)
+	}
+}
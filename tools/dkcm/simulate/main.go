@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// simulate replays a request log, e.g. exported from the /admin/request-log
+// endpoint of DKCM, against a pool sizing policy.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"knative.dev/test-infra/tools/dkcm/pool"
+)
+
+func main() {
+	logFile := flag.String("request-log", "", "The request log to replay, in CSV with one time,zone,nodes,nodeType per line")
+	step := flag.Duration("step", time.Minute, "How often the pools are resized and the requests served")
+	creationTime := flag.Duration("creation-time", 10*time.Minute, "How long it takes to create a cluster")
+	holdTime := flag.Duration("hold-time", 30*time.Minute, "How long a request holds its cluster before releasing it")
+	timeout := flag.Duration("timeout", 24*time.Hour, "How long to keep running after the last request before giving up on the requests not served")
+	jsonOutput := flag.Bool("json", false, "Print the result in JSON")
+
+	policy := pool.DefaultPolicy()
+	policy.AddFlags(flag.CommandLine)
+
+	flag.Parse()
+
+	if *logFile == "" {
+		log.Fatal("--request-log must be set")
+	}
+	if *step <= 0 {
+		log.Fatal("--step must be positive")
+	}
+	if err := policy.Validate(); err != nil {
+		log.Fatalf("Invalid pool policy: %v", err)
+	}
+	f, err := os.Open(*logFile)
+	if err != nil {
+		log.Fatalf("Failed opening the request log: %v", err)
+	}
+	requests, err := pool.ReadRequestLog(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed reading the request log %q: %v", *logFile, err)
+	}
+
+	result := pool.Simulation{
+		Policy:       policy,
+		Step:         *step,
+		CreationTime: *creationTime,
+		HoldTime:     *holdTime,
+		Timeout:      *timeout,
+	}.Run(requests)
+	if *jsonOutput {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed encoding the result: %v", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Println(result)
+}
@@ -45,6 +45,9 @@ type JobConfig struct {
 	Type          string         `yaml:"type"`
 	IssueRepo     string         `yaml:"issueRepo,omitempty"`
 	SlackChannels []SlackChannel `yaml:"slackChannels,omitempty"`
+	// Annotations are the Prow job annotations of the job, to link it to its
+	// Testgrid tab if it's not in the Testgrid config
+	Annotations map[string]string `yaml:"annotations,omitempty"`
 }
 
 // SlackChannel contains Slack channels info
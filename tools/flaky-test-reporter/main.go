@@ -47,6 +47,7 @@ func main() {
 	slackAccount := flag.String("slack-account", "", "slack secret file for authenticating with Slack")
 	buildsCountOverride := flag.Int("build-count", 10, "count of builds to scan")
 	skipReport := flag.Bool("skip-report", false, "skip Github and Slack report")
+	testgridConfig := flag.String("testgrid-config", "", "Testgrid config file for linking the jobs to their Testgrid tabs, default to the one of the repo")
 	dryrun := flag.Bool("dry-run", false, "dry run switch")
 	flag.Parse()
 
@@ -100,7 +101,7 @@ func main() {
 		log.Printf("--skip-report provided, skipping Github and Slack report")
 	} else {
 		flakyIssues, ghErr = githubOperations(*githubAccount, repoDataAll, *dryrun)
-		slackErr = slackOperations(*slackAccount, repoDataAll, flakyIssues, *dryrun)
+		slackErr = slackOperations(*slackAccount, *testgridConfig, repoDataAll, flakyIssues, *dryrun)
 	}
 
 	if jobErr != nil {
@@ -132,7 +133,7 @@ func isWeekend(t time.Time) bool {
 	return weekDay == time.Saturday || weekDay == time.Sunday
 }
 
-func slackOperations(slackToken string, repoData []RepoData, flakyIssues map[string][]flakyIssue, dryrun bool) error {
+func slackOperations(slackToken, testgridConfig string, repoData []RepoData, flakyIssues map[string][]flakyIssue, dryrun bool) error {
 	if isWeekend(time.Now()) {
 		log.Print("Skip Slack notification on weekend")
 		return nil
@@ -148,5 +149,11 @@ func slackOperations(slackToken string, repoData []RepoData, flakyIssues map[str
 		return err
 	}
 
-	return sendSlackNotifications(repoData, client, flakyIssues, dryrun)
+	tgConfig, err := loadTestgridConfig(testgridConfig)
+	if err != nil {
+		// don't fail as the Testgrid links are optional
+		log.Printf("WARNING: Testgrid links won't be sent: %v", err)
+	}
+
+	return sendSlackNotifications(repoData, client, tgConfig, flakyIssues, dryrun)
 }
@@ -27,6 +27,7 @@ import (
 	"knative.dev/test-infra/pkg/helpers"
 	"knative.dev/test-infra/pkg/slackutil"
 	"knative.dev/test-infra/pkg/testgrid"
+	"knative.dev/test-infra/tools/flaky-test-reporter/config"
 )
 
 const (
@@ -35,8 +36,29 @@ const (
 	testgridFilter = "exclude-non-failed-tests=20"
 )
 
-// createSlackMessageForRepo creates slack message layout from RepoData
-func createSlackMessageForRepo(rd RepoData, flakyIssuesMap map[string][]flakyIssue) string {
+// loadTestgridConfig loads and validates the Testgrid config file, the one of
+// the repo if not set
+func loadTestgridConfig(path string) (*testgrid.Config, error) {
+	var tgConfig *testgrid.Config
+	var err error
+	if path == "" {
+		tgConfig, err = testgrid.NewConfig()
+	} else {
+		tgConfig, err = testgrid.NewConfigFromFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed loading Testgrid config: %v", err)
+	}
+	if err := tgConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Testgrid config: %v", err)
+	}
+	return tgConfig, nil
+}
+
+// createSlackMessageForRepo creates slack message layout from RepoData, with
+// a link to the Testgrid tab of the job if it's in the Testgrid config or in its
+// Prow job annotations
+func createSlackMessageForRepo(rd RepoData, tgConfig *testgrid.Config, flakyIssuesMap map[string][]flakyIssue) string {
 	flakyTests := getFlakyTests(rd)
 	message := fmt.Sprintf("As of %s, there are %d flaky tests in '%s' from repo '%s'",
 		time.Unix(*rd.LastBuildStartTime, 0).String(), len(flakyTests), rd.Config.Name, rd.Config.Repo)
@@ -64,15 +86,33 @@ func createSlackMessageForRepo(rd RepoData, flakyIssuesMap map[string][]flakyIss
 		}
 	}
 
-	if testgridTabURL, err := testgrid.GetTestgridTabURL(rd.Config.Name, []string{testgridFilter}); err != nil {
+	if testgridTabURL, err := getTestgridTabURL(rd.Config, tgConfig); err != nil {
 		log.Println(err) // don't fail as this could be optional
-	} else {
+	} else if testgridTabURL != "" {
 		message += fmt.Sprintf("\nSee Testgrid for up-to-date flaky tests information: %s", testgridTabURL)
 	}
 	return message
 }
 
-func sendSlackNotifications(repoDataAll []RepoData, c slackutil.WriteOperations, flakyIssues map[string][]flakyIssue, dryrun bool) error {
+// getTestgridTabURL gets the Testgrid URL for the tab of the job from the
+// Testgrid config, or from its Prow job annotations if it's not in the config.
+// It returns an empty URL if the job has neither.
+func getTestgridTabURL(jc config.JobConfig, tgConfig *testgrid.Config) (string, error) {
+	filters := []string{testgridFilter}
+	var err error
+	if tgConfig != nil {
+		var url string
+		if url, err = tgConfig.GetTabURL(jc.Name, filters); err == nil {
+			return url, nil
+		}
+	}
+	if len(jc.Annotations) == 0 {
+		return "", err
+	}
+	return testgrid.GetTabURLFromAnnotations(jc.Name, jc.Annotations, filters)
+}
+
+func sendSlackNotifications(repoDataAll []RepoData, c slackutil.WriteOperations, tgConfig *testgrid.Config, flakyIssues map[string][]flakyIssue, dryrun bool) error {
 	var allErrs []error
 	for _, rd := range repoDataAll {
 		channels := rd.Config.SlackChannels
@@ -87,7 +127,7 @@ func sendSlackNotifications(repoDataAll []RepoData, c slackutil.WriteOperations,
 			channel := channels[i]
 			go func() {
 				defer wg.Done()
-				message := createSlackMessageForRepo(rd, flakyIssues)
+				message := createSlackMessageForRepo(rd, tgConfig, flakyIssues)
 				if err := helpers.Run(
 					fmt.Sprintf("post Slack message for job '%s' from repo '%s' in channel '%s'", rd.Config.Name, rd.Config.Repo, channel.Name),
 					func() error {
@@ -0,0 +1,114 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/pkg/testgrid"
+	"knative.dev/test-infra/tools/flaky-test-reporter/config"
+)
+
+func TestCreateSlackMessageForRepoTestgridLink(t *testing.T) {
+	tgConfig := &testgrid.Config{
+		TestGroups: []testgrid.TestGroup{{Name: "ci-knative-serving-continuous", GCSPrefix: "logs/ci-knative-serving-continuous"}},
+		Dashboards: []testgrid.Dashboard{{
+			Name: "serving",
+			Tabs: []testgrid.Tab{{Name: "continuous", TestGroupName: "ci-knative-serving-continuous"}},
+		}},
+	}
+	startTime := int64(0)
+	link := "\nSee Testgrid for up-to-date flaky tests information: "
+	annotations := map[string]string{
+		testgrid.DashboardsAnnotation: "knative-serving, knative",
+		testgrid.TabNameAnnotation:    "nope",
+	}
+	tests := []struct {
+		name        string
+		job         string
+		annotations map[string]string
+		tgConfig    *testgrid.Config
+		want        string
+	}{{
+		name:     "job in the config",
+		job:      "ci-knative-serving-continuous",
+		tgConfig: tgConfig,
+		want:     link + "https://testgrid.knative.dev/serving#continuous&exclude-non-failed-tests=20",
+	}, {
+		name:     "job not in the config",
+		job:      "ci-knative-serving-nope",
+		tgConfig: tgConfig,
+	}, {
+		name: "no config",
+		job:  "ci-knative-serving-continuous",
+	}, {
+		name:        "job in the config and annotated",
+		job:         "ci-knative-serving-continuous",
+		annotations: annotations,
+		tgConfig:    tgConfig,
+		want:        link + "https://testgrid.knative.dev/serving#continuous&exclude-non-failed-tests=20",
+	}, {
+		name:        "job not in the config but annotated",
+		job:         "ci-knative-serving-nope",
+		annotations: annotations,
+		tgConfig:    tgConfig,
+		want:        link + "https://testgrid.k8s.io/knative-serving#nope&exclude-non-failed-tests=20",
+	}, {
+		name:        "no config but annotated",
+		job:         "ci-knative-serving-nope",
+		annotations: annotations,
+		want:        link + "https://testgrid.k8s.io/knative-serving#nope&exclude-non-failed-tests=20",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rd := RepoData{
+				Config:             config.JobConfig{Name: test.job, Repo: "serving", IssueRepo: "serving", Annotations: test.annotations},
+				LastBuildStartTime: &startTime,
+			}
+			message := createSlackMessageForRepo(rd, test.tgConfig, nil)
+			got := ""
+			if i := strings.Index(message, link); i >= 0 {
+				got = message[i:]
+			}
+			if got != test.want {
+				t.Errorf("Testgrid link of the message want: '%s', got: '%s'", test.want, got)
+			}
+		})
+	}
+}
+
+func TestGetTestgridTabURLForAllJobs(t *testing.T) {
+	tgConfig, err := loadTestgridConfig("")
+	if err != nil {
+		t.Fatalf("Failed loading the Testgrid config of the repo: '%v'", err)
+	}
+	if len(config.JobConfigs) == 0 {
+		t.Fatal("No job in the flaky test reporter config")
+	}
+	for _, jc := range config.JobConfigs {
+		t.Run(jc.Name, func(t *testing.T) {
+			url, err := getTestgridTabURL(jc, tgConfig)
+			if err != nil {
+				t.Fatalf("Failed getting the Testgrid tab URL: '%v'", err)
+			}
+			if url == "" {
+				t.Error("No Testgrid tab URL, the job is neither in the Testgrid config nor annotated")
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// files.go classifies the changed files as docs or generated files.
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// docsExtensions are the extensions of the docs files
+	docsExtensions = map[string]bool{".md": true, ".markdown": true, ".rst": true, ".adoc": true, ".txt": true}
+	// docsFiles are files without code nor tests
+	docsFiles = map[string]bool{"OWNERS": true, "OWNERS_ALIASES": true, "AUTHORS": true, "LICENSE": true, "CONTRIBUTORS": true}
+	// docsDir is the directory all files of are docs, wherever it is
+	docsDir = "docs"
+
+	// defaultGeneratedPatterns are the generated files in Knative repos, in
+	// the format of .gitattributes, which can override them
+	defaultGeneratedPatterns = []string{"/vendor/**", "/third_party/**", "zz_generated*.go", "*.pb.go"}
+
+	// generatedHeaderRegexp is the header of the generated Go files, see
+	// https://golang.org/s/generatedcode
+	generatedHeaderRegexp = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+)
+
+// isDocsFile returns whether the file is a docs file.
+func isDocsFile(file string) bool {
+	if docsExtensions[strings.ToLower(path.Ext(file))] || docsFiles[path.Base(file)] {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if dir == docsDir {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedRule marks the files matching the pattern as generated or not.
+type generatedRule struct {
+	pattern   string
+	generated bool
+}
+
+// generatedRules returns the rules for generated files, the default ones then
+// the linguist-generated attributes of the .gitattributes of the repo root.
+// As in .gitattributes, the last matching rule wins.
+func generatedRules(repoRoot string) ([]generatedRule, error) {
+	var rules []generatedRule
+	for _, p := range defaultGeneratedPatterns {
+		rules = append(rules, generatedRule{pattern: p, generated: true})
+	}
+	f, err := os.Open(filepath.Join(repoRoot, ".gitattributes"))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "linguist-generated", "linguist-generated=true":
+				rules = append(rules, generatedRule{pattern: fields[0], generated: true})
+			case "-linguist-generated", "linguist-generated=false":
+				rules = append(rules, generatedRule{pattern: fields[0], generated: false})
+			}
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// isGeneratedFile returns whether the file is generated, by the rules or the
+// header of the Go file if it still exists.
+func isGeneratedFile(repoRoot, file string, rules []generatedRule) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if matchAttributesPattern(rules[i].pattern, file) {
+			return rules[i].generated
+		}
+	}
+	if path.Ext(file) != ".go" {
+		return false
+	}
+	return hasGeneratedHeader(filepath.Join(repoRoot, filepath.FromSlash(file)))
+}
+
+// hasGeneratedHeader returns whether the Go file has the generated code
+// header before the package clause.
+func hasGeneratedHeader(file string) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if generatedHeaderRegexp.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+	return false
+}
+
+// matchAttributesPattern returns whether the file matches the pattern, as
+// matched in .gitattributes: patterns without slash match the file name in any
+// directory, the others match the path from the repo root, with ** matching any
+// number of directories.
+func matchAttributesPattern(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	if len(pattern) == 0 {
+		return len(file) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(file); i++ {
+			if matchSegments(pattern[1:], file[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(file) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], file[0])
+	return ok && matchSegments(pattern[1:], file[1:])
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"testing"
+)
+
+const testRepoRoot = "testdata/repo"
+
+func TestIsDocsFile(t *testing.T) {
+	for file, want := range map[string]bool{
+		"README.md":                true,
+		"pkg/a/CHANGELOG.markdown": true,
+		"docs/images/arch.png":     true,
+		"site/docs/serving/x.yaml": true,
+		"OWNERS":                   true,
+		"pkg/a/OWNERS":             true,
+		"pkg/a/a.go":               false,
+		"docsite/config.yaml":      false,
+		"hack/update-codegen.sh":   false,
+	} {
+		if got := isDocsFile(file); got != want {
+			t.Errorf("isDocsFile(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestIsGeneratedFile(t *testing.T) {
+	rules, err := generatedRules(testRepoRoot)
+	if err != nil {
+		t.Fatalf("Failed reading the rules: '%v'", err)
+	}
+	for file, want := range map[string]bool{
+		"vendor/github.com/x/y/y.go":        true,
+		"third_party/istio/istio.yaml":      false, // overridden by .gitattributes
+		"pkg/apis/zz_generated.deepcopy.go": true,
+		"pkg/apis/v1/x.pb.go":               true,
+		"pkg/client/clientset/x.go":         true,
+		"docs/api.md":                       true,
+		"docs/index.md":                     false,
+		"pkg/gen/zz_deepcopy.go":            true, // by its header
+		"pkg/gen/gen.go":                    false,
+		"pkg/gen/deleted.go":                false,
+		"hack/update-codegen.sh":            false,
+	} {
+		if got := isGeneratedFile(testRepoRoot, file, rules); got != want {
+			t.Errorf("isGeneratedFile(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestMatchAttributesPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"*.pb.go", "a/b/c.pb.go", true},
+		{"*.pb.go", "c.pb.go", true},
+		{"/vendor/**", "vendor/a/b.go", true},
+		{"/vendor/**", "pkg/vendor/a/b.go", false},
+		{"pkg/**/zz_generated.*.go", "pkg/apis/v1/zz_generated.deepcopy.go", true},
+		{"pkg/**/zz_generated.*.go", "pkg/zz_generated.deepcopy.go", true},
+		{"pkg/**/zz_generated.*.go", "cmd/zz_generated.deepcopy.go", false},
+		{"/docs/api.md", "docs/api.md", true},
+		{"/docs/api.md", "docs/api.md.orig", false},
+	}
+	for _, test := range tests {
+		if got := matchAttributesPattern(test.pattern, test.file); got != test.want {
+			t.Errorf("matchAttributesPattern(%q, %q) = %v, want %v", test.pattern, test.file, got, test.want)
+		}
+	}
+}
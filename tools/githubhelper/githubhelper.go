@@ -19,37 +19,136 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/google/go-github/v32/github"
 	"knative.dev/test-infra/pkg/ghutil"
 )
 
-var (
-	// Info about the current PR
-	repoOwner  = os.Getenv("REPO_OWNER")
-	repoName   = os.Getenv("REPO_NAME")
-	pullNumber = atoi(os.Getenv("PULL_NUMBER"), "pull number")
+// Output formats
+const (
+	textOutput = "text"
+	jsonOutput = "json"
+)
 
+var (
 	// Shared useful variables
 	verbose = false
-	client  *ghutil.GithubClient
 )
 
+// query is a question about the current PR, asked with a flag.
+type query struct {
+	// flag enables the query
+	flag  string
+	usage string
+	// key is the key of the answer in the json output
+	key string
+	run func(p *pullRequest) (interface{}, error)
+}
+
+// queries are the queries supported, their answers are printed in this order.
+var queries = []query{{
+	flag:  "list-changed-files",
+	usage: "List the files changed by the current pull request",
+	key:   "changedFiles",
+	run:   func(p *pullRequest) (interface{}, error) { return p.changedFiles() },
+}, {
+	flag:  "list-labels",
+	usage: "List the labels of the current pull request",
+	key:   "labels",
+	run:   func(p *pullRequest) (interface{}, error) { return p.labels() },
+}, {
+	flag:  "docs-only",
+	usage: "Print whether the current pull request only changes docs",
+	key:   "docsOnly",
+	run: func(p *pullRequest) (interface{}, error) {
+		files, err := p.changedFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !isDocsFile(f) {
+				return false, nil
+			}
+		}
+		return true, nil
+	},
+}, {
+	flag:  "generated-only",
+	usage: "Print whether the current pull request only changes generated files, as marked in .gitattributes or by their header",
+	key:   "generatedOnly",
+	run: func(p *pullRequest) (interface{}, error) {
+		files, err := p.changedFiles()
+		if err != nil {
+			return nil, err
+		}
+		rules, err := generatedRules(p.repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !isGeneratedFile(p.repoRoot, f, rules) {
+				return false, nil
+			}
+		}
+		return true, nil
+	},
+}, {
+	flag:  "list-changed-modules",
+	usage: "List the Go modules changed by the current pull request",
+	key:   "changedModules",
+	run: func(p *pullRequest) (interface{}, error) {
+		files, err := p.changedFiles()
+		if err != nil {
+			return nil, err
+		}
+		return changedModules(p.repoRoot, files)
+	},
+}, {
+	flag:  "list-changed-packages",
+	usage: "List the import paths of the Go packages affected by the current pull request, to run only their tests",
+	key:   "changedPackages",
+	run: func(p *pullRequest) (interface{}, error) {
+		files, err := p.changedFiles()
+		if err != nil {
+			return nil, err
+		}
+		return changedPackages(p.repoRoot, files)
+	},
+}, {
+	flag:  "list-commit-trailers",
+	usage: "List the trailers of the commits of the current pull request, e.g. 'Skip-E2E: true'",
+	key:   "commitTrailers",
+	run:   func(p *pullRequest) (interface{}, error) { return p.commitTrailers() },
+}, {
+	flag:  "author-association",
+	usage: "Print the association of the author of the current pull request with the repository, e.g. MEMBER or FIRST_TIME_CONTRIBUTOR",
+	key:   "authorAssociation",
+	run:   func(p *pullRequest) (interface{}, error) { return p.authorAssociation() },
+}, {
+	flag:  "is-fork",
+	usage: "Print whether the current pull request is from a fork",
+	key:   "isFork",
+	run:   func(p *pullRequest) (interface{}, error) { return p.isFork() },
+}}
+
 // authenticate creates client with given token if it's provided and exists,
 // otherwise it falls back to use an anonymous client
-func authenticate(githubTokenPath *string) {
-	var err error
-	client, err = ghutil.NewGithubClient(*githubTokenPath)
+func authenticate(githubTokenPath *string) ghutil.GithubOperations {
+	client, err := ghutil.NewGithubClient(*githubTokenPath)
 	if err != nil {
 		infof("Error creating client with token %q: %v", *githubTokenPath, err)
 		infof("Proceeding with unauthenticated client")
-		client = &ghutil.GithubClient{Client: github.NewClient(nil)}
+		return &ghutil.GithubClient{Client: github.NewClient(nil)}
 	}
+	return client
 }
 
 // atoi is a convenience function to convert a string to integer, failing in case of error.
@@ -68,29 +167,92 @@ func infof(template string, args ...interface{}) {
 	}
 }
 
-// listChangedFiles simply lists the files changed by the current PR.
-func listChangedFiles() {
-	infof("Listing changed files for PR %d in repository %s/%s", pullNumber, repoOwner, repoName)
-	files, err := client.ListFiles(repoOwner, repoName, pullNumber)
-	if err != nil {
-		log.Fatalf("Error listing files: %v", err)
+// runQueries runs the enabled queries, and returns their answers by json key.
+func runQueries(p *pullRequest, enabled map[string]bool) (map[string]interface{}, error) {
+	answers := make(map[string]interface{})
+	for _, q := range queries {
+		if !enabled[q.flag] {
+			continue
+		}
+		answer, err := q.run(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed querying --%s: %w", q.flag, err)
+		}
+		answers[q.key] = answer
 	}
+	return answers, nil
+}
 
-	for _, file := range files {
-		fmt.Println(*file.Filename)
+// formatText formats the answers in the order of the queries, a line per
+// item of lists, and a "Key: value" line per trailer.
+func formatText(answers map[string]interface{}) string {
+	var lines []string
+	for _, q := range queries {
+		switch answer := answers[q.key].(type) {
+		case nil:
+		case []string:
+			lines = append(lines, answer...)
+		case map[string][]string:
+			keys := make([]string, 0, len(answer))
+			for k := range answer {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				for _, v := range answer[k] {
+					lines = append(lines, fmt.Sprintf("%s: %s", k, v))
+				}
+			}
+		default:
+			lines = append(lines, fmt.Sprint(answer))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
 	}
+	return strings.Join(lines, "\n") + "\n"
 }
 
 func main() {
 	githubTokenPath := flag.String("github-token", os.Getenv("GITHUB_BOT_TOKEN"), "Github token file path for authenticating with Github")
-	listChangedFilesFlag := flag.Bool("list-changed-files", false, "List the files changed by the current pull request")
+	repoRoot := flag.String("repo-root", ".", "Root directory of the local checkout of the pull request, for the queries on the content of the changed files")
+	output := flag.String("output", textOutput, "Output format, text or json. The text output prints a line per item of lists, "+
+		"the json output an object with a key per query")
+	enabled := make(map[string]bool)
+	for _, q := range queries {
+		flag.Bool(q.flag, false, q.usage)
+	}
 	verboseFlag := flag.Bool("verbose", false, "Whether to dump extra info on output or not; intended for debugging")
 	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		if v, ok := f.Value.(flag.Getter); ok && v.Get() == true {
+			enabled[f.Name] = true
+		}
+	})
+	if *output != textOutput && *output != jsonOutput {
+		log.Fatalf("Unexpected output format %q, it must be %q or %q", *output, textOutput, jsonOutput)
+	}
 
 	verbose = *verboseFlag
-	authenticate(githubTokenPath)
+	p := &pullRequest{
+		client:   authenticate(githubTokenPath),
+		owner:    os.Getenv("REPO_OWNER"),
+		repo:     os.Getenv("REPO_NAME"),
+		number:   atoi(os.Getenv("PULL_NUMBER"), "pull number"),
+		repoRoot: *repoRoot,
+	}
 
-	if *listChangedFilesFlag {
-		listChangedFiles()
+	answers, err := runQueries(p, enabled)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == jsonOutput {
+		out, err := json.MarshalIndent(answers, "", "  ")
+		if err != nil {
+			log.Fatalf("Error formatting the output: %v", err)
+		}
+		fmt.Println(string(out))
+		return
 	}
+	fmt.Print(formatText(answers))
 }
@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
+
+	"knative.dev/test-infra/pkg/ghutil/fakeghutil"
+)
+
+func newFakePullRequest(t *testing.T, fork bool) *pullRequest {
+	fgc := fakeghutil.NewFakeGithubClient()
+	pr, err := fgc.CreatePullRequest("knative", "repo", "someone:fix", "main", "Fix the thing", "")
+	if err != nil {
+		t.Fatalf("Failed creating the PR: '%v'", err)
+	}
+	headRepo := "knative/repo"
+	if fork {
+		headRepo = "someone/repo"
+	}
+	pr.Head.Repo = &github.Repository{FullName: github.String(headRepo)}
+	pr.Base.Repo = &github.Repository{FullName: github.String("knative/repo")}
+	pr.AuthorAssociation = github.String("FIRST_TIME_CONTRIBUTOR")
+	pr.Labels = []*github.Label{{Name: github.String("size/S")}, {Name: github.String("area/API")}}
+
+	fgc.PRCommits[pr.GetNumber()] = []*github.RepositoryCommit{{
+		SHA:    github.String("sha1"),
+		Commit: &github.Commit{Message: github.String("Fix the thing\n\nSkip-E2E: true")},
+	}, {
+		SHA:    github.String("sha2"),
+		Commit: &github.Commit{Message: github.String("Update the docs\n\nSigned-off-by: Someone\nSkip-E2E: still")},
+	}}
+	fgc.CommitFiles["sha1"] = []*github.CommitFile{{Filename: github.String("pkg/a/a.go")}}
+	fgc.CommitFiles["sha2"] = []*github.CommitFile{{Filename: github.String("docs/index.md")}, {Filename: github.String("pkg/a/a.go")}}
+	return &pullRequest{client: fgc, owner: "knative", repo: "repo", number: pr.GetNumber(), repoRoot: testRepoRoot}
+}
+
+func TestRunQueries(t *testing.T) {
+	all := make(map[string]bool)
+	for _, q := range queries {
+		all[q.flag] = true
+	}
+	answers, err := runQueries(newFakePullRequest(t, true), all)
+	if err != nil {
+		t.Fatalf("Failed running the queries: '%v'", err)
+	}
+	want := map[string]interface{}{
+		"changedFiles":      []string{"docs/index.md", "pkg/a/a.go"},
+		"labels":            []string{"area/API", "size/S"},
+		"docsOnly":          false,
+		"generatedOnly":     false,
+		"changedModules":    []string{"example.com/repo"},
+		"changedPackages":   []string{"example.com/repo/pkg/a", "example.com/repo/pkg/c", "example.com/repo/pkg/d"},
+		"commitTrailers":    map[string][]string{"Skip-E2E": {"true", "still"}, "Signed-off-by": {"Someone"}},
+		"authorAssociation": "FIRST_TIME_CONTRIBUTOR",
+		"isFork":            true,
+	}
+	if diff := cmp.Diff(want, answers); diff != "" {
+		t.Errorf("answers got(+) is different from wanted(-)\n%v", diff)
+	}
+
+	out, err := json.Marshal(answers)
+	if err != nil {
+		t.Fatalf("Failed formatting the answers: '%v'", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("Failed decoding the json output: '%v'", err)
+	}
+	if decoded["isFork"] != true || decoded["authorAssociation"] != "FIRST_TIME_CONTRIBUTOR" {
+		t.Errorf("unexpected json output: %s", out)
+	}
+}
+
+func TestFormatText(t *testing.T) {
+	answers, err := runQueries(newFakePullRequest(t, false), map[string]bool{
+		"is-fork":              true,
+		"list-commit-trailers": true,
+		"list-changed-files":   true,
+	})
+	if err != nil {
+		t.Fatalf("Failed running the queries: '%v'", err)
+	}
+	want := "docs/index.md\npkg/a/a.go\n" +
+		"Signed-off-by: Someone\nSkip-E2E: true\nSkip-E2E: still\n" +
+		"false\n"
+	if diff := cmp.Diff(want, formatText(answers)); diff != "" {
+		t.Errorf("text output got(+) is different from wanted(-)\n%v", diff)
+	}
+	if got := formatText(map[string]interface{}{"changedFiles": []string{}}); got != "" {
+		t.Errorf("expected no output for no changed files, got %q", got)
+	}
+}
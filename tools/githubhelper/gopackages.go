@@ -0,0 +1,247 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// gopackages.go maps the changed files to the Go modules and packages they
+// belong to, in the local checkout of the PR.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	"knative.dev/test-infra/pkg/cmd"
+)
+
+// module is a Go module of the repo.
+type module struct {
+	// dir is the directory of the module, relative to the repo root
+	dir  string
+	path string
+}
+
+// importPath returns the import path of the package in the directory,
+// relative to the repo root.
+func (m *module) importPath(dir string) string {
+	if dir == m.dir {
+		return m.path
+	}
+	return m.path + "/" + strings.TrimPrefix(dir, m.dir+"/")
+}
+
+// moduleFinder finds the module of the directories of the repo, the innermost
+// one for nested modules.
+type moduleFinder struct {
+	repoRoot string
+	cache    map[string]*module
+}
+
+func newModuleFinder(repoRoot string) *moduleFinder {
+	return &moduleFinder{repoRoot: repoRoot, cache: make(map[string]*module)}
+}
+
+// find returns the module of the directory relative to the repo root, nil if
+// it's not in a module.
+func (f *moduleFinder) find(dir string) (*module, error) {
+	if m, ok := f.cache[dir]; ok {
+		return m, nil
+	}
+	var m *module
+	goMod := filepath.Join(f.repoRoot, filepath.FromSlash(dir), "go.mod")
+	content, err := ioutil.ReadFile(goMod)
+	switch {
+	case err == nil:
+		modulePath := modfile.ModulePath(content)
+		if modulePath == "" {
+			return nil, fmt.Errorf("no module path in %q", goMod)
+		}
+		m = &module{dir: dir, path: modulePath}
+	case !os.IsNotExist(err):
+		return nil, err
+	case dir != ".":
+		if m, err = f.find(path.Dir(dir)); err != nil {
+			return nil, err
+		}
+	}
+	f.cache[dir] = m
+	return m, nil
+}
+
+// changedModules returns the paths of the modules of the changed files,
+// sorted.
+func changedModules(repoRoot string, files []string) ([]string, error) {
+	finder := newModuleFinder(repoRoot)
+	set := make(map[string]bool)
+	for _, file := range files {
+		m, err := finder.find(path.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			set[m.path] = true
+		}
+	}
+	return sortedKeys(set), nil
+}
+
+// changedPackages returns the import paths of the Go packages affected by the
+// changed files, sorted, to run only their tests. They are the packages of the
+// changed Go files and testdata directories, the deleted and vendored ones
+// excluded, the packages of their module that depend on them, including through
+// their tests, and all the packages of a module, as <module>/..., if its go.mod
+// or go.sum changed.
+func changedPackages(repoRoot string, files []string) ([]string, error) {
+	finder := newModuleFinder(repoRoot)
+	wholeModules := make(map[string]bool)
+	packages := make(map[string]*module)
+	for _, file := range files {
+		dir := path.Dir(file)
+		name := path.Base(file)
+		if isVendored(file) {
+			continue
+		}
+		if name == "go.mod" || name == "go.sum" {
+			m, err := finder.find(dir)
+			if err != nil {
+				return nil, err
+			}
+			if m != nil && m.dir == dir {
+				wholeModules[m.path] = true
+			}
+			continue
+		}
+		if i := testdataIndex(dir); i >= 0 {
+			// path.Clean returns "." for the repo root
+			dir = path.Clean(strings.Join(strings.Split(dir, "/")[:i], "/"))
+		} else if path.Ext(name) != ".go" {
+			continue
+		}
+		if !hasGoFiles(filepath.Join(repoRoot, filepath.FromSlash(dir))) {
+			continue
+		}
+		m, err := finder.find(dir)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			packages[m.importPath(dir)] = m
+		}
+	}
+
+	set := make(map[string]bool)
+	for m := range wholeModules {
+		set[m+"/..."] = true
+	}
+	changed := make(map[*module]map[string]bool)
+	for p, m := range packages {
+		if wholeModules[m.path] {
+			continue
+		}
+		if changed[m] == nil {
+			changed[m] = make(map[string]bool)
+		}
+		changed[m][p] = true
+		set[p] = true
+	}
+	for m, pkgs := range changed {
+		dependents, err := dependentPackages(repoRoot, m, pkgs)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range dependents {
+			set[p] = true
+		}
+	}
+	return sortedKeys(set), nil
+}
+
+// dependentPackages returns the import paths of the packages of the module
+// that depend, directly or not, on one of the packages, or whose tests do.
+func dependentPackages(repoRoot string, m *module, pkgs map[string]bool) ([]string, error) {
+	dir := filepath.Join(repoRoot, filepath.FromSlash(m.dir))
+	out, err := cmd.RunCommand(`go list -e -test -f '{{.ImportPath}}:{{join .Deps ","}}' ./...`, cmd.WithDir(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed listing the dependencies of the packages of module %q: %w", m.path, err)
+	}
+	var dependents []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		// Skip the packages recompiled for the tests, as "<package> [<package>.test]",
+		// the test binaries, as "<package>.test", cover them.
+		if len(parts) != 2 || strings.Contains(parts[0], " ") {
+			continue
+		}
+		for _, dep := range strings.Split(parts[1], ",") {
+			dep = strings.SplitN(dep, " ", 2)[0]
+			if pkgs[dep] {
+				dependents = append(dependents, strings.TrimSuffix(parts[0], ".test"))
+				break
+			}
+		}
+	}
+	return dependents, nil
+}
+
+// isVendored returns whether the file is in a vendor directory.
+func isVendored(file string) bool {
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if dir == "vendor" {
+			return true
+		}
+	}
+	return false
+}
+
+// testdataIndex returns the index of the testdata directory in the path of
+// the directory, -1 if it isn't in one.
+func testdataIndex(dir string) int {
+	for i, d := range strings.Split(dir, "/") {
+		if d == "testdata" {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasGoFiles returns whether the directory has Go files, i.e. is a package.
+func hasGoFiles(dir string) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		if !f.IsDir() && path.Ext(f.Name()) == ".go" {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
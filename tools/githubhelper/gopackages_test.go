@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChangedModules(t *testing.T) {
+	got, err := changedModules(testRepoRoot, []string{
+		"README.md",
+		"pkg/a/a.go",
+		"nested/b/b.go",
+		"nested/go.sum",
+	})
+	if err != nil {
+		t.Fatalf("Failed getting the changed modules: '%v'", err)
+	}
+	want := []string{"example.com/repo", "example.com/repo/nested"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("modules got(+) is different from wanted(-)\n%v", diff)
+	}
+}
+
+func TestChangedPackages(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{{
+		name:  "no Go files",
+		files: []string{"README.md", "docs/index.md", "pkg/a/OWNERS"},
+		want:  []string{},
+	}, {
+		name: "Go files and testdata",
+		files: []string{
+			"pkg/a/a.go",
+			"pkg/a/testdata/input.json",
+			"cmd/tool/main.go",
+			"nested/b/b.go",
+		},
+		want: []string{
+			"example.com/repo/cmd/tool",
+			"example.com/repo/nested/b",
+			"example.com/repo/pkg/a",
+			"example.com/repo/pkg/c",
+			"example.com/repo/pkg/d",
+		},
+	}, {
+		name:  "package imported by the tests of another package",
+		files: []string{"pkg/c/c.go"},
+		want:  []string{"example.com/repo/pkg/c", "example.com/repo/pkg/d"},
+	}, {
+		name:  "deleted and vendored packages",
+		files: []string{"pkg/deleted/deleted.go", "vendor/github.com/x/y/y.go", "pkg/gen/gen.go"},
+		want:  []string{"example.com/repo/pkg/gen"},
+	}, {
+		name:  "go.mod of a nested module",
+		files: []string{"nested/go.mod", "nested/b/b.go", "pkg/a/a.go"},
+		want:  []string{"example.com/repo/nested/...", "example.com/repo/pkg/a", "example.com/repo/pkg/c", "example.com/repo/pkg/d"},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := changedPackages(testRepoRoot, test.files)
+			if err != nil {
+				t.Fatalf("Failed getting the changed packages: '%v'", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("packages got(+) is different from wanted(-)\n%v", diff)
+			}
+		})
+	}
+}
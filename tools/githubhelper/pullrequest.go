@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// pullrequest.go fetches the info of the current PR, once whatever the
+// number of queries needing it.
+
+package main
+
+import (
+	"sort"
+
+	"github.com/google/go-github/v32/github"
+	"knative.dev/test-infra/pkg/ghutil"
+)
+
+// pullRequest is the PR queried, its info is fetched from GitHub on demand.
+type pullRequest struct {
+	client ghutil.GithubOperations
+	owner  string
+	repo   string
+	number int
+	// repoRoot is the local checkout of the PR, for the queries on the
+	// content of the changed files
+	repoRoot string
+
+	pr      *github.PullRequest
+	files   []string
+	commits []*github.RepositoryCommit
+}
+
+// get returns the PR.
+func (p *pullRequest) get() (*github.PullRequest, error) {
+	if p.pr == nil {
+		infof("Getting PR %d in repository %s/%s", p.number, p.owner, p.repo)
+		pr, err := p.client.GetPullRequest(p.owner, p.repo, p.number)
+		if err != nil {
+			return nil, err
+		}
+		p.pr = pr
+	}
+	return p.pr, nil
+}
+
+// changedFiles returns the files changed by the PR, sorted.
+func (p *pullRequest) changedFiles() ([]string, error) {
+	if p.files == nil {
+		infof("Listing changed files for PR %d in repository %s/%s", p.number, p.owner, p.repo)
+		files, err := p.client.ListFiles(p.owner, p.repo, p.number)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		p.files = []string{}
+		for _, f := range files {
+			if name := f.GetFilename(); !seen[name] {
+				seen[name] = true
+				p.files = append(p.files, name)
+			}
+		}
+		sort.Strings(p.files)
+	}
+	return p.files, nil
+}
+
+// listCommits returns the commits of the PR.
+func (p *pullRequest) listCommits() ([]*github.RepositoryCommit, error) {
+	if p.commits == nil {
+		infof("Listing commits for PR %d in repository %s/%s", p.number, p.owner, p.repo)
+		commits, err := p.client.ListCommits(p.owner, p.repo, p.number)
+		if err != nil {
+			return nil, err
+		}
+		p.commits = commits
+	}
+	return p.commits, nil
+}
+
+// labels returns the names of the labels of the PR, sorted.
+func (p *pullRequest) labels() ([]string, error) {
+	pr, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for _, l := range pr.Labels {
+		labels = append(labels, l.GetName())
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// authorAssociation returns the association of the author of the PR with the
+// repository, e.g. MEMBER or FIRST_TIME_CONTRIBUTOR.
+func (p *pullRequest) authorAssociation() (string, error) {
+	pr, err := p.get()
+	if err != nil {
+		return "", err
+	}
+	return pr.GetAuthorAssociation(), nil
+}
+
+// isFork returns whether the head branch of the PR is in another repository
+// than the base branch.
+func (p *pullRequest) isFork() (bool, error) {
+	pr, err := p.get()
+	if err != nil {
+		return false, err
+	}
+	return pr.GetHead().GetRepo().GetFullName() != pr.GetBase().GetRepo().GetFullName(), nil
+}
+
+// commitTrailers returns the values of the trailers of all the commits of the
+// PR, by trailer key.
+func (p *pullRequest) commitTrailers() (map[string][]string, error) {
+	commits, err := p.listCommits()
+	if err != nil {
+		return nil, err
+	}
+	trailers := make(map[string][]string)
+	for _, c := range commits {
+		for _, t := range parseTrailers(c.GetCommit().GetMessage()) {
+			trailers[t.key] = append(trailers[t.key], t.value)
+		}
+	}
+	return trailers, nil
+}
@@ -0,0 +1,5 @@
+// Copyright
+
+// Code generated by hand. Edit freely.
+
+package gen
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// trailers.go parses the trailers of commit messages, e.g. "Skip-E2E: true".
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var trailerRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s*(.*)$`)
+
+type trailer struct {
+	key   string
+	value string
+}
+
+// parseTrailers returns the trailers of the commit message, which are the
+// "Key: value" lines of its last paragraph, as git interpret-trailers reads
+// them. Lines starting with whitespace continue the value of the previous
+// trailer. The subject is never a trailer.
+func parseTrailers(message string) []trailer {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+	var trailers []trailer
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(trailers) != 0 {
+			trailers[len(trailers)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+		m := trailerRegexp.FindStringSubmatch(line)
+		if m == nil {
+			// not a trailer block
+			return nil
+		}
+		trailers = append(trailers, trailer{key: m[1], value: strings.TrimSpace(m[2])})
+	}
+	return trailers
+}
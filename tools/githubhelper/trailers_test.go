@@ -0,0 +1,58 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []trailer
+	}{{
+		name:    "subject only",
+		message: "Skip-E2E: true",
+	}, {
+		name:    "no trailers",
+		message: "Fix the thing\n\nIt was broken: now it's not.\nReally.",
+	}, {
+		name:    "trailers",
+		message: "Fix the thing\n\nIt was broken.\n\nSkip-E2E: true\nSigned-off-by: Someone <someone@knative.dev>\n",
+		want: []trailer{
+			{key: "Skip-E2E", value: "true"},
+			{key: "Signed-off-by", value: "Someone <someone@knative.dev>"},
+		},
+	}, {
+		name:    "continuation and CRLF",
+		message: "Fix the thing\r\n\r\nCo-authored-by: Someone\r\n  <someone@knative.dev>\r\nSkip-E2E:true",
+		want: []trailer{
+			{key: "Co-authored-by", value: "Someone <someone@knative.dev>"},
+			{key: "Skip-E2E", value: "true"},
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseTrailers(test.message)
+			if diff := cmp.Diff(test.want, got, cmp.AllowUnexported(trailer{})); diff != "" {
+				t.Errorf("trailers got(+) is different from wanted(-)\n%v", diff)
+			}
+		})
+	}
+}
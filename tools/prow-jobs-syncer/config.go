@@ -16,10 +16,6 @@ limitations under the License.
 
 package main
 
-import (
-	"knative.dev/test-infra/pkg/ghutil"
-)
-
 const (
 	org  = "knative"
 	repo = "test-infra"
@@ -39,9 +35,7 @@ const (
 	configGenPath = "tools/config-generator"
 
 	oncallAddress = "https://storage.googleapis.com/knative-infra-oncall/oncall.json"
-)
 
-// GHClientWrapper handles methods for github issues
-type GHClientWrapper struct {
-	ghutil.GithubOperations
-}
+	// PRTitle is the title of the PR, and matches the existing one to update
+	PRTitle = "[Auto] Update prow jobs for release branches"
+)
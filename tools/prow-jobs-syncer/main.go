@@ -22,15 +22,17 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"strings"
 
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"knative.dev/test-infra/pkg/autopr"
 	"knative.dev/test-infra/pkg/cmd"
 	"knative.dev/test-infra/pkg/ghutil"
-
-	"knative.dev/test-infra/pkg/git"
 )
 
 func main() {
@@ -38,6 +40,8 @@ func main() {
 	gitUserID := flag.String("git-userid", "", "The github ID of user for hosting fork, i.e. Github ID of bot")
 	gitUserName := flag.String("git-username", "", "The username to use on the git commit. Requires --git-email")
 	gitEmail := flag.String("git-email", "", "The email to use on the git commit. Requires --git-username")
+	gitSignKey := flag.String("git-sign-key", "", "The armored private key file to sign the git commit with, optional")
+	gitSignPassphrase := flag.String("git-sign-passphrase-file", "", "The file of the passphrase of --git-sign-key, if it's encrypted")
 	dryrun := flag.Bool("dry-run", false, "dry run switch")
 	flag.Parse()
 
@@ -60,26 +64,56 @@ func main() {
 
 	configgenFullPath := path.Join(gopath, repoPath, configGenPath)
 
-	log.Print(cmd.RunCommand(fmt.Sprintf("go run %s %s",
-		configgenFullPath, strings.Join(configgenArgs, " "))))
-
 	gc, err := ghutil.NewGithubClient(*githubAccount)
 	if err != nil {
 		log.Fatalf("cannot authenticate to github: %v", err)
 	}
+	token, err := ioutil.ReadFile(*githubAccount)
+	if err != nil {
+		log.Fatalf("cannot read the github token: %v", err)
+	}
 
-	targetGI := git.Info{
-		Org:      org,
-		Repo:     repo,
-		Head:     PRHead,
-		Base:     PRBase,
-		UserID:   *gitUserID,
-		UserName: *gitUserName,
-		Email:    *gitEmail,
+	gitRepo, err := autopr.OpenRepo(path.Join(gopath, repoPath))
+	if err != nil {
+		log.Fatalf("cannot open the git repository: %v", err)
+	}
+	gitRepo.AuthorName = strings.Trim(*gitUserName, "'")
+	gitRepo.AuthorEmail = *gitEmail
+	gitRepo.Auth = &githttp.BasicAuth{Username: *gitUserID, Password: strings.TrimSpace(string(token))}
+	if *gitSignKey != "" {
+		var passphrase []byte
+		if *gitSignPassphrase != "" {
+			if passphrase, err = ioutil.ReadFile(*gitSignPassphrase); err != nil {
+				log.Fatalf("cannot read the passphrase of the sign key: %v", err)
+			}
+		}
+		if gitRepo.SignKey, err = autopr.ReadSignKey(*gitSignKey, strings.TrimSpace(string(passphrase))); err != nil {
+			log.Fatalf("cannot read the sign key: %v", err)
+		}
 	}
 
-	gcw := &GHClientWrapper{gc}
-	if err = createOrUpdatePR(gcw, targetGI, *dryrun); err != nil {
+	description, assignees := generatePRDescription()
+	a := &autopr.AutoPR{
+		Options: autopr.Options{
+			Org:         org,
+			Repo:        repo,
+			Head:        PRHead,
+			Base:        PRBase,
+			UserID:      *gitUserID,
+			Title:       PRTitle,
+			Description: description,
+			Assignees:   assignees,
+			DryRun:      *dryrun,
+		},
+		Git:    gitRepo,
+		GitHub: gc,
+	}
+	if _, err = a.Run(func() error {
+		out, err := cmd.RunCommand(fmt.Sprintf("go run %s %s",
+			configgenFullPath, strings.Join(configgenArgs, " ")))
+		log.Print(out)
+		return err
+	}); err != nil {
 		log.Fatalf("failed creating pullrequest: '%v'", err)
 	}
 }
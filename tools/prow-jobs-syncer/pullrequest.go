@@ -14,37 +14,28 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// pullrequest.go creates git commits and Pull Requests
+// pullrequest.go generates the description and assignees of the Pull Requests
 
 package main
 
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"strings"
-
-	"github.com/google/go-github/v32/github"
-	"knative.dev/test-infra/pkg/ghutil"
-	"knative.dev/test-infra/pkg/helpers"
-
-	"knative.dev/test-infra/pkg/git"
 )
 
-func generatePRBody() string {
-	body := "PR created for syncing release branches changes\n"
+// generatePRDescription returns the description of the PR, and the oncaller
+// to assign it to if any.
+func generatePRDescription() (string, []string) {
+	description := "PR created for syncing release branches changes\n"
 	oncaller, err := getOncaller()
-	assignment := "Nobody is currently oncall."
-	if err == nil {
-		if oncaller != "" {
-			assignment = fmt.Sprintf("/assign @%s\n/cc @%s\n", oncaller, oncaller)
-		}
-	} else {
-		assignment = fmt.Sprintf("An error occurred while finding an assignee: `%v`.", err)
+	if err != nil {
+		return description + fmt.Sprintf("An error occurred while finding an assignee: `%v`.", err), nil
 	}
-
-	return body + assignment
+	if oncaller == "" {
+		return description + "Nobody is currently oncall.", nil
+	}
+	return description + fmt.Sprintf("/cc @%s", oncaller), []string{oncaller}
 }
 
 func getOncaller() (string, error) {
@@ -66,60 +57,3 @@ func getOncaller() (string, error) {
 	}
 	return oncall.Oncall.ToolsInfra, nil
 }
-
-// Get existing open PR not merged yet
-func getExistingPR(gcw *GHClientWrapper, gi git.Info, matchTitle string) (*github.PullRequest, error) {
-	var res *github.PullRequest
-	PRs, err := gcw.ListPullRequests(gi.Org, gi.Repo, gi.GetHeadRef(), gi.Base)
-	if err == nil {
-		for _, PR := range PRs {
-			if string(ghutil.PullRequestOpenState) == *PR.State && strings.Contains(*PR.Title, matchTitle) {
-				res = PR
-				break
-			}
-		}
-	}
-	return res, err
-}
-
-func createOrUpdatePR(gcw *GHClientWrapper, gi git.Info, dryrun bool) error {
-	const matchTitle = "[Auto] Update prow jobs for release branches"
-	commitMsg := matchTitle
-	title := commitMsg
-	body := generatePRBody()
-	hasUpdates, err := git.MakeCommit(gi, commitMsg, dryrun)
-	if err != nil {
-		return fmt.Errorf("failed git commit: %w", err)
-	}
-	if !hasUpdates {
-		log.Print("There is nothing committed, skip PR")
-		return nil
-	}
-	existPR, err := getExistingPR(gcw, gi, matchTitle)
-	if err != nil {
-		return fmt.Errorf("failed querying existing pullrequests: %w", err)
-	}
-	if existPR != nil {
-		log.Printf("Found open PR %d", *existPR.Number)
-		return helpers.Run(
-			fmt.Sprintf("Updating PR %d, title: %q, body: %q", *existPR.Number, title, body),
-			func() error {
-				if _, err := gcw.EditPullRequest(gi.Org, gi.Repo, *existPR.Number, title, body); err != nil {
-					return fmt.Errorf("failed updating pullrequest: %w", err)
-				}
-				return nil
-			},
-			dryrun,
-		)
-	}
-	return helpers.Run(
-		fmt.Sprintf("Creating PR, title: %q, body: %q", title, body),
-		func() error {
-			if _, err := gcw.CreatePullRequest(gi.Org, gi.Repo, gi.GetHeadRef(), gi.Base, title, body); err != nil {
-				return fmt.Errorf("failed creating pullrequest: %w", err)
-			}
-			return nil
-		},
-		dryrun,
-	)
-}